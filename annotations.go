@@ -0,0 +1,36 @@
+package mcpserver
+
+import (
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Tool annotations tell clients how a tool behaves so that they can decide which calls need
+// the user's confirmation. mcp-go presents a tool without annotations as destructive and
+// reaching outside the server, so every tool is registered with one of these options. None
+// of them reaches outside the server; tools that do add mcp.WithOpenWorldHintAnnotation.
+
+// readOnlyTool annotates a tool that only reads and changes nothing
+func readOnlyTool() mcp.ToolOption {
+	return annotateTool(true, false, true)
+}
+
+// writeTool annotates a tool that adds or updates data without removing any; an idempotent
+// tool called again with the same arguments has no further effect
+func writeTool(idempotent bool) mcp.ToolOption {
+	return annotateTool(false, false, idempotent)
+}
+
+// destructiveTool annotates a tool that deletes, merges away or replaces data
+func destructiveTool(idempotent bool) mcp.ToolOption {
+	return annotateTool(false, true, idempotent)
+}
+
+// annotateTool sets the behavioural hints of a tool, keeping its title
+func annotateTool(readOnly, destructive, idempotent bool) mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		t.Annotations.ReadOnlyHint = mcp.ToBoolPtr(readOnly)
+		t.Annotations.DestructiveHint = mcp.ToBoolPtr(destructive)
+		t.Annotations.IdempotentHint = mcp.ToBoolPtr(idempotent)
+		t.Annotations.OpenWorldHint = mcp.ToBoolPtr(false)
+	}
+}
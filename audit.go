@@ -0,0 +1,337 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"gorm.io/gorm"
+)
+
+// Entities recorded in the audit log
+const (
+	auditProduct        = "product"
+	auditCategory       = "category"
+	auditProductImage   = "product_image"
+	auditProductTags    = "product_tags"
+	auditPromotion      = "promotion"
+	auditProductVariant = "product_variant"
+	auditOrder          = "order"
+	auditCustomer       = "customer"
+	auditSupplier       = "supplier"
+	auditPrompt         = "prompt"
+)
+
+// auditEntities lists the entities that can be filtered on, in the order they are documented
+var auditEntities = []string{auditProduct, auditCategory, auditProductImage, auditProductTags, auditPromotion, auditProductVariant, auditOrder, auditCustomer, auditSupplier, auditPrompt}
+
+// Actions recorded in the audit log
+const (
+	auditCreate     = "create"
+	auditUpdate     = "update"
+	auditDelete     = "delete"
+	auditHardDelete = "hard_delete"
+	auditRestore    = "restore"
+)
+
+// auditActions lists the actions that can be filtered on, in the order they are documented
+var auditActions = []string{auditCreate, auditUpdate, auditDelete, auditHardDelete, auditRestore}
+
+// maxAuditLogLimit caps the number of entries a single audit log read may return
+const maxAuditLogLimit = 1000
+
+// auditDocument is a JSON document stored as text; it is rendered as JSON rather than as a string
+type auditDocument string
+
+// MarshalJSON renders the document as is
+func (d auditDocument) MarshalJSON() ([]byte, error) {
+	if d == "" {
+		return []byte("null"), nil
+	}
+	return []byte(d), nil
+}
+
+// AuditEntry records a change of an entity: who made it, when, through which tool, and the
+// entity before and after the change
+type AuditEntry struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	At        time.Time     `gorm:"index" json:"at"`
+	SessionID string        `gorm:"index" json:"session_id,omitempty"`
+	Tool      string        `json:"tool,omitempty"`
+	Entity    string        `gorm:"index:idx_audit_entries_entity" json:"entity"`
+	EntityID  uint          `gorm:"index:idx_audit_entries_entity" json:"entity_id"`
+	Action    string        `json:"action"`
+	Before    auditDocument `json:"before,omitempty"`
+	After     auditDocument `json:"after,omitempty"`
+}
+
+// auditToolKey is the context key of the name of the tool being called
+type auditToolKey struct{}
+
+// auditRedactorKey is the context key of the redactor scrubbing audited documents
+type auditRedactorKey struct{}
+
+// auditMiddleware tags the context of tool calls with the tool name recorded in the audit log
+// and the redactor scrubbing the documents recorded
+func (app *App) auditMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx = context.WithValue(ctx, auditRedactorKey{}, app.redactor)
+		return next(context.WithValue(ctx, auditToolKey{}, request.Params.Name), request)
+	}
+}
+
+// recordAudit appends an audit entry for a change of an entity made in tx, attributed to the
+// session and tool of its context. before is nil for creations and after for deletions.
+// Sensitive fields of the documents are scrubbed by the redactor of the context.
+// Entries are written in the transaction of the change, so they are rolled back with it.
+func recordAudit(tx *gorm.DB, entity string, id uint, action string, before, after any) error {
+	ctx := tx.Statement.Context
+	entry := AuditEntry{
+		At:        time.Now().UTC(),
+		SessionID: sessionID(ctx),
+		Entity:    entity,
+		EntityID:  id,
+		Action:    action,
+	}
+	entry.Tool, _ = ctx.Value(auditToolKey{}).(string)
+
+	for _, doc := range []struct {
+		value any
+		dst   *auditDocument
+	}{{before, &entry.Before}, {after, &entry.After}} {
+		if doc.value == nil {
+			continue
+		}
+		data, err := json.Marshal(doc.value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audited %s: %w", entity, err)
+		}
+		if redactor, ok := ctx.Value(auditRedactorKey{}).(*Redactor); ok {
+			if data, err = redactor.redactJSON(data); err != nil {
+				return fmt.Errorf("failed to redact audited %s: %w", entity, err)
+			}
+		}
+		*doc.dst = auditDocument(data)
+	}
+
+	if err := tx.Session(&gorm.Session{NewDB: true}).Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	if entity == auditProduct || entity == auditProductTags {
+		noteProductChange(ctx, id)
+	}
+	return nil
+}
+
+// BeforeUpdate keeps the product as stored, for AfterUpdate to record it in the audit log
+func (p *Product) BeforeUpdate(tx *gorm.DB) error {
+	var stored []Product
+	if err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Limit(1).Find(&stored, p.ID).Error; err != nil {
+		return fmt.Errorf("failed to retrieve product for the audit log: %w", err)
+	}
+	p.stored = nil
+	if len(stored) > 0 {
+		p.stored = &stored[0]
+	}
+	return nil
+}
+
+// AfterCreate records the creation of a product in the audit log
+func (p *Product) AfterCreate(tx *gorm.DB) error {
+	return recordAudit(tx, auditProduct, p.ID, auditCreate, nil, p)
+}
+
+// AfterUpdate records the update of a product in the audit log; clearing the deletion time
+// of a soft-deleted product is recorded as a restore. Deletions are recorded explicitly.
+func (p *Product) AfterUpdate(tx *gorm.DB) error {
+	before := p.stored
+	p.stored = nil
+	action := auditUpdate
+	if before != nil && before.DeletedAt.Valid && !p.DeletedAt.Valid {
+		action = auditRestore
+	}
+	return recordAudit(tx, auditProduct, p.ID, action, before, p)
+}
+
+// recordProductDeletions records the deletion of products in the audit log
+func recordProductDeletions(tx *gorm.DB, hard bool, products ...Product) error {
+	action := auditDelete
+	if hard {
+		action = auditHardDelete
+	}
+	for i := range products {
+		if err := recordAudit(tx, auditProduct, products[i].ID, action, &products[i], nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuditQuery narrows and pages a read of the audit log; zero fields do not filter
+type AuditQuery struct {
+	Entity    string
+	EntityID  uint
+	Action    string
+	Tool      string
+	SessionID string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+	Offset    int
+}
+
+// filter applies the conditions of the query to db
+func (q AuditQuery) filter(db *gorm.DB) *gorm.DB {
+	for column, value := range map[string]string{"entity": q.Entity, "action": q.Action, "tool": q.Tool, "session_id": q.SessionID} {
+		if value != "" {
+			db = db.Where(column+" = ?", value)
+		}
+	}
+	if q.EntityID != 0 {
+		db = db.Where("entity_id = ?", q.EntityID)
+	}
+	if !q.Since.IsZero() {
+		db = db.Where("at >= ?", q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		db = db.Where("at <= ?", q.Until.UTC())
+	}
+	return db
+}
+
+// AuditLog returns a page of the audit entries matching q, most recent first, and the number
+// of matching entries
+func (dbs *DBService) AuditLog(ctx context.Context, q AuditQuery) ([]AuditEntry, int, error) {
+	var entries []AuditEntry
+	var total int64
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		db := q.filter(dbs.conn(ctx).Model(&AuditEntry{}))
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return err
+		}
+		db = db.Order("id DESC").Offset(q.Offset)
+		if q.Limit > 0 {
+			db = db.Limit(q.Limit)
+		}
+		return db.Find(&entries).Error
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve audit log: %w", err)
+	}
+	return entries, int(total), nil
+}
+
+// parseAuditQuery parses and validates the filter and paging parameters of an audit log read
+func parseAuditQuery(values url.Values) (AuditQuery, error) {
+	var q AuditQuery
+	var fields []FieldError
+
+	for name := range values {
+		switch name {
+		case "entity", "entity_id", "action", "tool", "session_id", "since", "until", "limit", "cursor", outputFormatArg, tenantArg:
+		default:
+			fields = append(fields, FieldError{Field: name, Message: "unknown parameter"})
+		}
+	}
+
+	q.Entity = values.Get("entity")
+	if q.Entity != "" && !slices.Contains(auditEntities, q.Entity) {
+		fields = append(fields, FieldError{Field: "entity", Message: "must be product, category, product_image, product_tags, promotion, product_variant, order, customer, supplier or prompt"})
+	}
+	if id := values.Get("entity_id"); id != "" {
+		n, err := strconv.ParseUint(id, 10, 0)
+		if err != nil || n == 0 {
+			fields = append(fields, FieldError{Field: "entity_id", Message: "must be a positive integer"})
+		}
+		q.EntityID = uint(n)
+	}
+	q.Action = values.Get("action")
+	if q.Action != "" && !slices.Contains(auditActions, q.Action) {
+		fields = append(fields, FieldError{Field: "action", Message: "must be create, update, delete, hard_delete or restore"})
+	}
+	q.Tool = values.Get("tool")
+	q.SessionID = values.Get("session_id")
+
+	since, until, err := parseTimeRange(values.Get("since"), values.Get("until"))
+	if err != nil {
+		fields = append(fields, err.(*ValidationError).Fields...)
+	}
+	q.Since, q.Until = since, until
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxAuditLogLimit {
+			fields = append(fields, FieldError{Field: "limit", Message: fmt.Sprintf("must be an integer between 1 and %d", maxAuditLogLimit)})
+		}
+		q.Limit = n
+	}
+	if cursor := values.Get("cursor"); cursor != "" {
+		offset, err := decodeCursor("cursor", cursor)
+		if err != nil {
+			fields = append(fields, FieldError{Field: "cursor", Message: "is not a cursor returned by this server"})
+		}
+		q.Offset = offset
+	}
+
+	if len(fields) > 0 {
+		return AuditQuery{}, &ValidationError{Fields: fields}
+	}
+	return q, nil
+}
+
+// redactAuditEntry scrubs the sensitive fields of the documents of entry
+func (app *App) redactAuditEntry(entry *AuditEntry) error {
+	for _, doc := range []*auditDocument{&entry.Before, &entry.After} {
+		if *doc == "" {
+			continue
+		}
+		data, err := app.redactor.redactJSON([]byte(*doc))
+		if err != nil {
+			return fmt.Errorf("failed to redact audit entry %d: %w", entry.ID, err)
+		}
+		*doc = auditDocument(data)
+	}
+	return nil
+}
+
+// auditLogHandler handles the audit log resource and template requests
+func (app *App) auditLogHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri, err := url.Parse(request.Params.URI)
+	if err != nil {
+		return nil, resourceError(invalidField("uri", err.Error()))
+	}
+	q, err := parseAuditQuery(uri.Query())
+	if err != nil {
+		return nil, resourceError(err)
+	}
+
+	limits := app.config.Results
+	requested := q.Limit
+	q.Limit = limits.fetchLimit(requested)
+	entries, total, err := app.dbService.AuditLog(ctx, q)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+	// Entries recorded outside tool calls, or before fields were made sensitive, are
+	// scrubbed as they are read
+	for i := range entries {
+		if err := app.redactAuditEntry(&entries[i]); err != nil {
+			return nil, resourceError(err)
+		}
+	}
+
+	data, n, truncation, err := limitResult(limits, entries, q.Offset, total, requested, true, renderJSON(func(page []AuditEntry) any { return page }))
+	if err != nil {
+		return nil, resourceError(err)
+	}
+	if err := app.quotas.AddRows(ctx, n); err != nil {
+		return nil, resourceError(err)
+	}
+	return truncatedResourceContents(request.Params.URI, string(data), truncation)
+}
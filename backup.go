@@ -0,0 +1,287 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// backupFilePrefix and backupFileExt name the files written by Backup
+const (
+	backupFilePrefix = "backup-"
+	backupFileExt    = ".db"
+)
+
+// BackupInfo describes a backup file on disk
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"size_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Backup writes a consistent online copy of the database into dir and returns its description
+func (dbs *DBService) Backup(ctx context.Context, dir string) (*BackupInfo, error) {
+	db := dbs.primary(ctx)
+	if db.Dialector.Name() != "sqlite" {
+		return nil, fmt.Errorf("backups are %w for %s", ErrUnsupported, db.Dialector.Name())
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	name := backupFilePrefix + time.Now().UTC().Format("20060102T150405.000Z") + backupFileExt
+	path := filepath.Join(dir, name)
+	if err := db.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return nil, fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup: %w", err)
+	}
+	return &BackupInfo{Name: name, Path: path, SizeBytes: info.Size(), CreatedAt: info.ModTime().UTC()}, nil
+}
+
+// listBackups returns the backups in dir, newest first
+func listBackups(dir string) ([]BackupInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []BackupInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupFilePrefix) || !strings.HasSuffix(name, backupFileExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupInfo{
+			Name:      name,
+			Path:      filepath.Join(dir, name),
+			SizeBytes: info.Size(),
+			CreatedAt: info.ModTime().UTC(),
+		})
+	}
+
+	// Names embed a sortable UTC timestamp
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+// pruneBackups deletes all but the newest keep backups in dir and returns the removed names
+func pruneBackups(dir string, keep int) ([]string, error) {
+	backups, err := listBackups(dir)
+	if err != nil || keep <= 0 || len(backups) <= keep {
+		return nil, err
+	}
+
+	var removed []string
+	for _, backup := range backups[keep:] {
+		if err := os.Remove(backup.Path); err != nil {
+			return removed, fmt.Errorf("failed to remove old backup %s: %w", backup.Name, err)
+		}
+		removed = append(removed, backup.Name)
+	}
+	return removed, nil
+}
+
+// BackupStatus reports the state of the backup scheduler
+type BackupStatus struct {
+	Enabled    bool        `json:"enabled"`
+	Interval   string      `json:"interval,omitempty"`
+	Retention  int         `json:"retention"`
+	LastRun    *time.Time  `json:"last_run,omitempty"`
+	LastError  string      `json:"last_error,omitempty"`
+	NextRun    *time.Time  `json:"next_run,omitempty"`
+	LastBackup *BackupInfo `json:"last_backup,omitempty"`
+}
+
+// BackupScheduler periodically backs up the database and applies retention
+type BackupScheduler struct {
+	app      *App
+	interval time.Duration
+	keep     int
+	dir      string
+
+	mu     sync.Mutex
+	status BackupStatus
+}
+
+// NewBackupScheduler creates a scheduler using the backup settings of the app configuration
+func NewBackupScheduler(app *App) *BackupScheduler {
+	cfg := app.config
+	return &BackupScheduler{
+		app:      app,
+		interval: cfg.BackupInterval,
+		keep:     cfg.BackupRetention,
+		dir:      cfg.BackupDir,
+		status: BackupStatus{
+			Enabled:   cfg.BackupInterval > 0,
+			Retention: cfg.BackupRetention,
+		},
+	}
+}
+
+// Start runs the scheduler until ctx is cancelled; it is a no-op when no interval is configured
+func (bs *BackupScheduler) Start(ctx context.Context) {
+	if bs.interval <= 0 {
+		return
+	}
+	bs.mu.Lock()
+	bs.status.Interval = bs.interval.String()
+	bs.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(bs.interval)
+		defer ticker.Stop()
+
+		bs.setNextRun(time.Now().Add(bs.interval))
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				bs.run(ctx)
+				bs.setNextRun(time.Now().Add(bs.interval))
+			}
+		}
+	}()
+	slog.Info("Scheduled database backups", "interval", bs.interval, "retention", bs.keep, "dir", bs.dir)
+}
+
+// run performs a single scheduled backup followed by retention
+func (bs *BackupScheduler) run(ctx context.Context) {
+	bs.app.inflight.begin()
+	defer bs.app.inflight.end()
+
+	now := time.Now().UTC()
+	info, err := bs.app.dbService.Backup(ctx, bs.dir)
+	if err == nil {
+		var removed []string
+		removed, err = pruneBackups(bs.dir, bs.keep)
+		if len(removed) > 0 {
+			slog.Info("Removed old backups", "files", removed)
+		}
+	}
+
+	bs.mu.Lock()
+	bs.status.LastRun = &now
+	bs.status.LastError = ""
+	if info != nil {
+		bs.status.LastBackup = info
+	}
+	if err != nil {
+		bs.status.LastError = err.Error()
+	}
+	bs.mu.Unlock()
+
+	if err != nil {
+		slog.Error("Scheduled backup failed", "error", err)
+		bs.app.broadcastLog(mcp.LoggingLevelError, "backup", map[string]any{
+			"message": "scheduled backup failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+	slog.Info("Scheduled backup completed", "file", info.Path, "size", info.SizeBytes)
+}
+
+// setNextRun records when the next backup is due
+func (bs *BackupScheduler) setNextRun(t time.Time) {
+	t = t.UTC()
+	bs.mu.Lock()
+	bs.status.NextRun = &t
+	bs.mu.Unlock()
+}
+
+// Status returns a snapshot of the scheduler state
+func (bs *BackupScheduler) Status() BackupStatus {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.status
+}
+
+// listBackupsHandler handles the backups resource request
+func (app *App) listBackupsHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	backups, err := listBackups(app.config.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	if backups == nil {
+		backups = []BackupInfo{}
+	}
+
+	jsonData, err := json.MarshalIndent(map[string]any{
+		"schedule": app.backups.Status(),
+		"backups":  backups,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal backups to JSON: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      "backups://list",
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
+
+// maxInlineBackupBytes bounds the size of backups returned inline by backup_database
+const maxInlineBackupBytes = 16 << 20
+
+// backupDatabaseHandler handles the backup_database tool request. The backup is written
+// to the backup directory and, if requested and small enough, returned as a blob.
+func (app *App) backupDatabaseHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	info, err := app.dbService.Backup(ctx, app.config.BackupDir)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	slog.Info("Database backed up on request", "path", info.Path, "size_bytes", info.SizeBytes)
+
+	jsonData, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal backup info to JSON: %w", err)
+	}
+	result := mcp.NewToolResultText(string(jsonData))
+	if !request.GetBool("include_blob", false) {
+		return result, nil
+	}
+
+	if info.SizeBytes > maxInlineBackupBytes {
+		result.Content = append(result.Content, mcp.NewTextContent(fmt.Sprintf(
+			"The backup is %d bytes, more than the %d bytes returned inline; copy it from %s instead.",
+			info.SizeBytes, maxInlineBackupBytes, info.Path)))
+		return result, nil
+	}
+	data, err := os.ReadFile(info.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	result.Content = append(result.Content, mcp.NewEmbeddedResource(mcp.BlobResourceContents{
+		URI:      "backups://" + info.Name,
+		MIMEType: "application/vnd.sqlite3",
+		Blob:     base64.StdEncoding.EncodeToString(data),
+	}))
+	return result, nil
+}
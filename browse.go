@@ -0,0 +1,222 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm/clause"
+)
+
+// tableRowsURIPrefix is the resource template serving the rows of a browsable table
+const tableRowsURIPrefix = "db://tables/"
+
+// Page sizes of table row reads
+const (
+	defaultTableRowsLimit = 100
+	maxTableRowsLimit     = 1000
+)
+
+// TableInfo describes a browsable table
+type TableInfo struct {
+	Name          string   `json:"name"`
+	Columns       []string `json:"columns"`
+	PrimaryKey    []string `json:"primary_key"`
+	MaskedColumns []string `json:"masked_columns,omitempty"`
+	RowsURI       string   `json:"rows_uri"`
+}
+
+// TableRowsPage is one page of rows read from a browsable table
+type TableRowsPage struct {
+	Table      string           `json:"table"`
+	Offset     int              `json:"offset"`
+	Limit      int              `json:"limit"`
+	Rows       []map[string]any `json:"rows"`
+	NextOffset *int             `json:"next_offset,omitempty"`
+	NextURI    string           `json:"next_uri,omitempty"`
+}
+
+// TableColumns returns the columns and primary key columns of a table
+func (dbs *DBService) TableColumns(ctx context.Context, table string) ([]string, []string, error) {
+	migrator := dbs.conn(ctx).Migrator()
+	if !migrator.HasTable(table) {
+		return nil, nil, fmt.Errorf("table %q %w", table, ErrNotFound)
+	}
+	columnTypes, err := migrator.ColumnTypes(table)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read columns of table %q: %w", table, err)
+	}
+
+	var columns, primaryKey []string
+	for _, ct := range columnTypes {
+		columns = append(columns, ct.Name())
+		if pk, ok := ct.PrimaryKey(); ok && pk {
+			primaryKey = append(primaryKey, ct.Name())
+		}
+	}
+	return columns, primaryKey, nil
+}
+
+// TableRows reads up to limit rows of a table starting at offset, ordered by
+// orderBy so that pages are stable. The table name must come from the allowlist.
+func (dbs *DBService) TableRows(ctx context.Context, table string, orderBy []string, offset, limit int) ([]map[string]any, error) {
+	db := dbs.conn(ctx).Table(table)
+	for _, column := range orderBy {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: column}})
+	}
+
+	var rows []map[string]any
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		rows = []map[string]any{}
+		return db.WithContext(ctx).Offset(offset).Limit(limit).Find(&rows).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table %q: %w", table, err)
+	}
+	return rows, nil
+}
+
+// browsableTable checks that a table is on the allowlist
+func (app *App) browsableTable(table string) error {
+	if !slices.Contains(app.config.BrowsableTables, table) {
+		return invalidField("table", fmt.Sprintf("table %q is not browsable (expected one of %s)", table, strings.Join(app.config.BrowsableTables, ", ")))
+	}
+	return nil
+}
+
+// parseTableRowsURI extracts the table, offset and limit of a table rows URI
+func parseTableRowsURI(raw string) (string, int, int, error) {
+	uri, err := url.Parse(raw)
+	if err != nil {
+		return "", 0, 0, invalidField("uri", err.Error())
+	}
+
+	path := strings.TrimPrefix(uri.Host+uri.Path, "tables/")
+	table, ok := strings.CutSuffix(path, "/rows")
+	if !ok || table == "" || strings.Contains(table, "/") {
+		return "", 0, 0, invalidField("uri", "expected db://tables/{table}/rows")
+	}
+
+	var fields []FieldError
+	values := uri.Query()
+	for name := range values {
+		if name != "offset" && name != "limit" && name != outputFormatArg && name != tenantArg {
+			fields = append(fields, FieldError{Field: name, Message: "unknown parameter"})
+		}
+	}
+
+	offset := 0
+	if v := values.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			fields = append(fields, FieldError{Field: "offset", Message: "must be a non-negative integer"})
+		}
+	}
+	limit := defaultTableRowsLimit
+	if v := values.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxTableRowsLimit {
+			fields = append(fields, FieldError{Field: "limit", Message: fmt.Sprintf("must be an integer between 1 and %d", maxTableRowsLimit)})
+		}
+	}
+
+	if len(fields) > 0 {
+		return "", 0, 0, &ValidationError{Fields: fields}
+	}
+	return table, offset, limit, nil
+}
+
+// tablesHandler handles the browsable tables resource request
+func (app *App) tablesHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	tables := make([]TableInfo, 0, len(app.config.BrowsableTables))
+	for _, table := range app.config.BrowsableTables {
+		columns, primaryKey, err := app.dbService.TableColumns(ctx, table)
+		if err != nil {
+			return nil, resourceError(err)
+		}
+		var masked []string
+		for _, column := range columns {
+			if app.redactor.isSensitive(column) {
+				masked = append(masked, column)
+			}
+		}
+		tables = append(tables, TableInfo{
+			Name:          table,
+			Columns:       columns,
+			PrimaryKey:    primaryKey,
+			MaskedColumns: masked,
+			RowsURI:       tableRowsURIPrefix + table + "/rows",
+		})
+	}
+
+	jsonData, err := json.MarshalIndent(tables, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tables to JSON: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
+
+// tableRowsHandler handles the table rows resource template; sensitive columns
+// are masked with the same rules as logged tool arguments
+func (app *App) tableRowsHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	table, offset, limit, err := parseTableRowsURI(request.Params.URI)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+	if err := app.browsableTable(table); err != nil {
+		return nil, resourceError(err)
+	}
+
+	columns, primaryKey, err := app.dbService.TableColumns(ctx, table)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+	orderBy := primaryKey
+	if len(orderBy) == 0 {
+		orderBy = columns
+	}
+
+	// Read one extra row to find out whether another page follows
+	rows, err := app.dbService.TableRows(ctx, table, orderBy, offset, limit+1)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+	page := TableRowsPage{Table: table, Offset: offset, Limit: limit}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		next := offset + limit
+		page.NextOffset = &next
+		page.NextURI = fmt.Sprintf("%s%s/rows?offset=%d&limit=%d", tableRowsURIPrefix, table, next, limit)
+	}
+	if err := app.quotas.AddRows(ctx, len(rows)); err != nil {
+		return nil, resourceError(err)
+	}
+
+	page.Rows = make([]map[string]any, len(rows))
+	for i, row := range rows {
+		page.Rows[i] = app.redactor.Redact(row).(map[string]any)
+	}
+
+	jsonData, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal table rows to JSON: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
@@ -0,0 +1,135 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// BulkDeleteResult reports the products matched or deleted by delete_products_where
+type BulkDeleteResult struct {
+	Matched           int        `json:"matched"`
+	IDs               []uint     `json:"ids"`
+	Confirmed         bool       `json:"confirmed"`
+	ConfirmationToken string     `json:"confirmation_token,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	Message           string     `json:"message"`
+}
+
+// matchingProductIDs returns the ids of the products matching filter
+func matchingProductIDs(db *gorm.DB, filter *ProductFilter) ([]uint, error) {
+	var ids []uint
+	if err := filter.Apply(db.Model(&Product{})).Order("id").Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to match products: %w", err)
+	}
+	return ids, nil
+}
+
+// PreviewDeleteProducts returns the ids of the products a bulk delete with filter would remove
+func (dbs *DBService) PreviewDeleteProducts(ctx context.Context, filter *ProductFilter) ([]uint, error) {
+	var ids []uint
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		var err error
+		ids, err = matchingProductIDs(dbs.primary(ctx), filter)
+		return err
+	})
+	return ids, err
+}
+
+// DeleteProductsWhere soft-deletes the products matching filter in a single transaction.
+// expected holds the ids reported by the preview; if the filter now matches other products
+// nothing is deleted.
+func (dbs *DBService) DeleteProductsWhere(ctx context.Context, filter *ProductFilter, expected []uint) ([]uint, error) {
+	var ids []uint
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			ids, err = matchingProductIDs(tx, filter)
+			if err != nil {
+				return err
+			}
+			if !slices.Equal(ids, expected) {
+				return fmt.Errorf("%w: the products matching the filter changed since the preview (%d now, %d previewed); preview again", ErrConflict, len(ids), len(expected))
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+			var products []Product
+			if err := tx.Find(&products, ids).Error; err != nil {
+				return fmt.Errorf("failed to retrieve products: %w", err)
+			}
+			if err := tx.Where("id IN ?", ids).Delete(&Product{}).Error; err != nil {
+				return fmt.Errorf("failed to delete products: %w", err)
+			}
+			if err := recordProductDeletions(tx, false, products...); err != nil {
+				return err
+			}
+			return recordProductVersions(tx, true, time.Now(), products...)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// deleteProductsWhereHandler handles the delete_products_where tool request. Without a
+// confirmation token it only previews the matching products and issues a one-time token;
+// deleting requires a second call with the same filter and that token.
+func (app *App) deleteProductsWhereHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	raw, ok := args["filter"].(map[string]any)
+	if !ok {
+		return toolErrorResult(invalidField("filter", "must be an object"))
+	}
+	filter, err := parseProductFilter("filter", raw)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	var result BulkDeleteResult
+	if token := request.GetString(confirmationTokenArg, ""); token == "" {
+		ids, err := app.dbService.PreviewDeleteProducts(ctx, filter)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		token, expiresAt, err := app.confirmations.issue(ctx, request.Params.Name, args, ids)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		result = BulkDeleteResult{
+			Matched:           len(ids),
+			IDs:               ids,
+			ConfirmationToken: token,
+			ExpiresAt:         &expiresAt,
+			Message:           "Preview only; call again with the same filter and this confirmation_token to delete these products",
+		}
+	} else {
+		pending, err := app.confirmations.redeem(ctx, request.Params.Name, args, token)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		ids, err := app.dbService.DeleteProductsWhere(ctx, filter, pending.Expected)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		result = BulkDeleteResult{
+			Matched:   len(ids),
+			IDs:       ids,
+			Confirmed: true,
+			Message:   fmt.Sprintf("Deleted %d products", len(ids)),
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bulk delete result to JSON: %w", err)
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,112 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// methodNotificationCancelled is the notification clients send to cancel a request, which
+// the MCP library does not handle itself
+const methodNotificationCancelled = "notifications/cancelled"
+
+// callIDField is the metadata field carrying the JSON-RPC id of a tool call from the
+// BeforeCallTool hook to the middleware
+const callIDField = "mcpserver/callId"
+
+// callIDKey is the context key of the JSON-RPC id of a tool call
+type callIDKey struct{}
+
+// callID returns the JSON-RPC id of the tool call of ctx, or an empty string outside calls
+func callID(ctx context.Context) string {
+	id, _ := ctx.Value(callIDKey{}).(string)
+	return id
+}
+
+// callKey identifies a running tool call
+type callKey struct {
+	session string
+	id      string
+}
+
+// CancellableCalls lets clients cancel their running tool calls with notifications/cancelled.
+// The library does not pass request ids to tool handlers, so the BeforeCallTool hook stores
+// the id in the metadata of the request, where the middleware takes it from to register the
+// call. Cancelling a call cancels its context: the database statement in progress fails, its
+// transaction is rolled back and long loops stop at their next check.
+type CancellableCalls struct {
+	mu    sync.Mutex
+	calls map[callKey]context.CancelFunc
+}
+
+// NewCancellableCalls creates an empty set of cancellable calls
+func NewCancellableCalls() *CancellableCalls {
+	return &CancellableCalls{calls: make(map[callKey]context.CancelFunc)}
+}
+
+// beforeCallTool records the id of a tool call in its metadata; it is registered as a
+// BeforeCallTool hook
+func (cc *CancellableCalls) beforeCallTool(ctx context.Context, id any, request *mcp.CallToolRequest) {
+	if id == nil {
+		return
+	}
+	if request.Params.Meta == nil {
+		request.Params.Meta = &mcp.Meta{}
+	}
+	if request.Params.Meta.AdditionalFields == nil {
+		request.Params.Meta.AdditionalFields = make(map[string]any)
+	}
+	request.Params.Meta.AdditionalFields[callIDField] = mcp.NewRequestId(id).String()
+}
+
+// middleware runs each tool call with a context that its cancellation notification cancels
+// and that holds its id
+func (cc *CancellableCalls) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var id string
+		if meta := request.Params.Meta; meta != nil {
+			id, _ = meta.AdditionalFields[callIDField].(string)
+			delete(meta.AdditionalFields, callIDField)
+		}
+		if id == "" {
+			return next(ctx, request)
+		}
+
+		key := callKey{session: sessionID(ctx), id: id}
+		ctx, cancel := context.WithCancel(context.WithValue(ctx, callIDKey{}, id))
+		cc.mu.Lock()
+		cc.calls[key] = cancel
+		cc.mu.Unlock()
+		defer func() {
+			cc.mu.Lock()
+			delete(cc.calls, key)
+			cc.mu.Unlock()
+			cancel()
+		}()
+		return next(ctx, request)
+	}
+}
+
+// onCancelled cancels the tool call named by a notifications/cancelled notification of the
+// same session. Notifications for calls that already finished are ignored.
+func (cc *CancellableCalls) onCancelled(ctx context.Context, notification mcp.JSONRPCNotification) {
+	requestID, ok := notification.Params.AdditionalFields["requestId"]
+	if !ok {
+		return
+	}
+	key := callKey{session: sessionID(ctx), id: mcp.NewRequestId(requestID).String()}
+	cc.mu.Lock()
+	cancel, ok := cc.calls[key]
+	cc.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	reason, _ := notification.Params.AdditionalFields["reason"].(string)
+	slog.Info("Tool call cancelled by the client", "session", key.session, "request_id", fmt.Sprint(requestID), "reason", reason)
+	cancel()
+}
@@ -0,0 +1,196 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// CatalogIssue is an anomaly found by the catalog validation
+type CatalogIssue struct {
+	Check        string `json:"check"`
+	Severity     string `json:"severity"`
+	ProductIDs   []uint `json:"product_ids"`
+	Message      string `json:"message"`
+	SuggestedFix string `json:"suggested_fix"`
+}
+
+// CatalogReport is the result of a catalog validation run
+type CatalogReport struct {
+	CheckedProducts int            `json:"checked_products"`
+	Checks          []string       `json:"checks"`
+	OK              bool           `json:"ok"`
+	Issues          []CatalogIssue `json:"issues"`
+}
+
+// catalogChecks lists the checks run by ValidateCatalog
+var catalogChecks = []string{"duplicate_codes", "negative_prices", "blank_codes", "orphaned_categories", "zero_stock"}
+
+// catalogRow is a product found by a catalog check
+type catalogRow struct {
+	ID       uint
+	Code     string
+	Price    float64
+	Category string
+}
+
+// ValidateCatalog checks the products for data-quality anomalies, each check being a query
+// returning only the products it flags
+func (dbs *DBService) ValidateCatalog(ctx context.Context) (*CatalogReport, error) {
+	var report *CatalogReport
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		report = &CatalogReport{Checks: catalogChecks, Issues: []CatalogIssue{}}
+		db := dbs.conn(ctx)
+		products := func() *gorm.DB { return db.Model(&Product{}) }
+
+		var count int64
+		if err := products().Count(&count).Error; err != nil {
+			return err
+		}
+		report.CheckedProducts = int(count)
+
+		var blank []uint
+		if err := products().Where("code IS NULL OR TRIM(code) = ''").Order("id").Pluck("id", &blank).Error; err != nil {
+			return err
+		}
+		for _, id := range blank {
+			report.Issues = append(report.Issues, CatalogIssue{
+				Check:        "blank_codes",
+				Severity:     "error",
+				ProductIDs:   []uint{id},
+				Message:      fmt.Sprintf("product %d has no code", id),
+				SuggestedFix: fmt.Sprintf("Assign a code with update_product {\"id\": %d, \"code\": \"...\"}", id),
+			})
+		}
+
+		var negative []catalogRow
+		if err := products().Select("id, code, price").Where("price < 0").Order("id").Scan(&negative).Error; err != nil {
+			return err
+		}
+		for _, p := range negative {
+			report.Issues = append(report.Issues, CatalogIssue{
+				Check:        "negative_prices",
+				Severity:     "error",
+				ProductIDs:   []uint{p.ID},
+				Message:      fmt.Sprintf("product %d (%s) has negative price %.2f", p.ID, p.Code, p.Price),
+				SuggestedFix: fmt.Sprintf("Correct the price with update_product {\"id\": %d, \"price\": ...}", p.ID),
+			})
+		}
+
+		var duplicated []string
+		err := products().Select("TRIM(code)").Where("TRIM(code) <> ''").
+			Group("TRIM(code)").Having("COUNT(*) > 1").Order("TRIM(code)").Pluck("TRIM(code)", &duplicated).Error
+		if err != nil {
+			return err
+		}
+		if len(duplicated) > 0 {
+			var rows []catalogRow
+			err := products().Select("id, TRIM(code) AS code").Where("TRIM(code) IN ?", duplicated).Order("id").Scan(&rows).Error
+			if err != nil {
+				return err
+			}
+			byCode := make(map[string][]uint)
+			for _, row := range rows {
+				byCode[row.Code] = append(byCode[row.Code], row.ID)
+			}
+			for _, code := range duplicated {
+				ids := byCode[code]
+				report.Issues = append(report.Issues, CatalogIssue{
+					Check:        "duplicate_codes",
+					Severity:     "warning",
+					ProductIDs:   ids,
+					Message:      fmt.Sprintf("code %q is used by %d products", code, len(ids)),
+					SuggestedFix: "Keep one product per code; rename the others with update_product or remove them with delete_products_where",
+				})
+			}
+		}
+
+		var orphaned []catalogRow
+		err = products().Select("id, category").
+			Where("category IS NOT NULL AND category NOT IN (?)", db.Model(&Category{}).Select("name")).
+			Order("category, id").Scan(&orphaned).Error
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(orphaned); {
+			category := orphaned[i].Category
+			var ids []uint
+			for ; i < len(orphaned) && orphaned[i].Category == category; i++ {
+				ids = append(ids, orphaned[i].ID)
+			}
+			report.Issues = append(report.Issues, CatalogIssue{
+				Check:        "orphaned_categories",
+				Severity:     "error",
+				ProductIDs:   ids,
+				Message:      fmt.Sprintf("%d products are in category %q, which does not exist", len(ids), category),
+				SuggestedFix: fmt.Sprintf("Create the category with create_category {\"name\": %q} or move the products with update_product", category),
+			})
+		}
+
+		// Products with variants are stocked by variant
+		var outOfStock []uint
+		err = products().Where("stock <= 0 AND id NOT IN (?)", db.Model(&ProductVariant{}).Where("stock > 0").Select("product_id")).
+			Order("id").Pluck("id", &outOfStock).Error
+		if err != nil {
+			return err
+		}
+		if len(outOfStock) > 0 {
+			report.Issues = append(report.Issues, CatalogIssue{
+				Check:        "zero_stock",
+				Severity:     "warning",
+				ProductIDs:   outOfStock,
+				Message:      fmt.Sprintf("%d products are out of stock", len(outOfStock)),
+				SuggestedFix: "Restock them with adjust_stock {\"id\": ..., \"delta\": ...}, or remove discontinued products with delete_product",
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate catalog: %w", err)
+	}
+
+	report.OK = len(report.Issues) == 0
+	return report, nil
+}
+
+// catalogReportJSON runs the catalog validation and renders the report
+func (app *App) catalogReportJSON(ctx context.Context) (string, error) {
+	report, err := app.dbService.ValidateCatalog(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal catalog report to JSON: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// validateCatalogHandler handles the validate_catalog tool request
+func (app *App) validateCatalogHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	text, err := app.catalogReportJSON(ctx)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	return mcp.NewToolResultText(text), nil
+}
+
+// catalogValidationHandler handles the catalog validation resource request
+func (app *App) catalogValidationHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	text, err := app.catalogReportJSON(ctx)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      "catalog://validation",
+			MIMEType: "application/json",
+			Text:     text,
+		},
+	}, nil
+}
@@ -0,0 +1,143 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// ErrCategoryNotFound is returned when a category lookup matches no row
+var ErrCategoryNotFound = fmt.Errorf("category %w", ErrNotFound)
+
+// Category groups products. Products reference their category by name, which is unique.
+type Category struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex"`
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// CategorySummary is a category with the number of products in it
+type CategorySummary struct {
+	Category
+	Products int64
+}
+
+// categoryRef returns the category reference of a product for name; an empty name leaves
+// the product uncategorized
+func categoryRef(name string) *string {
+	if name == "" {
+		return nil
+	}
+	return &name
+}
+
+// categoryValue returns the category of p, or nil if it is uncategorized
+func (p *Product) categoryValue() any {
+	if p.Category == nil {
+		return nil
+	}
+	return *p.Category
+}
+
+// checkCategory checks that the category of a product exists. The foreign key enforces this
+// too, except on SQLite, which cannot add one to an existing table.
+func (p *Product) checkCategory(tx *gorm.DB) error {
+	if p.Category == nil {
+		return nil
+	}
+	var count int64
+	err := tx.Model(&Category{}).Where("name = ?", *p.Category).Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to look up category: %w", err)
+	}
+	if count == 0 {
+		return invalidField("category", fmt.Sprintf("unknown category %q; create it with create_category first", *p.Category))
+	}
+	return nil
+}
+
+// ListCategories returns every category with the number of products in it, ordered by name
+func (dbs *DBService) ListCategories(ctx context.Context) ([]CategorySummary, error) {
+	var categories []CategorySummary
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.conn(ctx).Model(&Category{}).
+			Select("categories.*, COUNT(products.id) AS products").
+			Joins("LEFT JOIN products ON products.category = categories.name AND products.deleted_at IS NULL").
+			Group("categories.id").
+			Order("categories.name").
+			Scan(&categories).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve categories: %w", err)
+	}
+	return categories, nil
+}
+
+// CreateCategory inserts a new category; names must be unique
+func (dbs *DBService) CreateCategory(ctx context.Context, category *Category) error {
+	if strings.TrimSpace(category.Name) == "" {
+		return invalidField("name", "must not be empty")
+	}
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&Category{}).Where("name = ?", category.Name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: category %q already exists", ErrConflict, category.Name)
+			}
+			if err := tx.Create(category).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, auditCategory, category.ID, auditCreate, nil, category)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+	return nil
+}
+
+// listCategoriesHandler handles the list_categories tool request
+func (app *App) listCategoriesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	categories, err := app.dbService.ListCategories(ctx)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(categories, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal categories to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// createCategoryHandler handles the create_category tool request
+func (app *App) createCategoryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := request.RequireString("name")
+	if err != nil {
+		return argumentError("name", err), nil
+	}
+
+	category := &Category{
+		Name:        name,
+		Description: request.GetString("description", ""),
+	}
+	if err := app.dbService.CreateCategory(ctx, category); err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(category, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal category to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
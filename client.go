@@ -0,0 +1,200 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// clientUsage describes the client subcommand
+const clientUsage = `Usage: mcpserver client [flags] <action>
+
+Connects to an MCP server and runs one action:
+  list                   list tools, resources and resource templates
+  call <tool> [json]     call a tool with a JSON object of arguments
+  read <uri>             read a resource
+
+By default a new instance of this executable is started over stdio; use
+-command to start another server or -url to connect to a running HTTP server.
+
+Flags:
+`
+
+// RunClient implements the client subcommand
+func RunClient(args []string) error {
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	url := fs.String("url", "", "Streamable HTTP endpoint of a running server")
+	command := fs.String("command", "", "Server command line to start over stdio (defaults to this executable)")
+	timeout := fs.Duration("timeout", 30*time.Second, "Timeout for the whole client run")
+	verbose := fs.Bool("v", false, "Show the stderr output of a stdio server")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), clientUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing action")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	c, err := connectClient(ctx, *url, *command, *verbose)
+	if err != nil {
+		return err
+	}
+	if *url != "" {
+		// A stdio client is not closed explicitly: closing it races the transport's
+		// reader, which then prints a spurious error to stdout. The server exits on
+		// its own once our end of its stdin is closed at process exit.
+		defer c.Close()
+	}
+
+	action, rest := fs.Arg(0), fs.Args()[1:]
+	switch action {
+	case "list":
+		return clientList(ctx, c)
+	case "call":
+		if len(rest) < 1 || len(rest) > 2 {
+			return fmt.Errorf("usage: call <tool> [json-arguments]")
+		}
+		arguments := "{}"
+		if len(rest) == 2 {
+			arguments = rest[1]
+		}
+		return clientCall(ctx, c, rest[0], arguments)
+	case "read":
+		if len(rest) != 1 {
+			return fmt.Errorf("usage: read <uri>")
+		}
+		return clientRead(ctx, c, rest[0])
+	default:
+		return fmt.Errorf("unknown action %q (expected list, call or read)", action)
+	}
+}
+
+// connectClient connects and initializes a client over HTTP if url is set, otherwise over stdio
+func connectClient(ctx context.Context, url, command string, verbose bool) (*client.Client, error) {
+	var c *client.Client
+	var err error
+	if url != "" {
+		c, err = client.NewStreamableHttpClient(url)
+		if err == nil {
+			err = c.Start(ctx)
+		}
+	} else {
+		var argv []string
+		if argv = strings.Fields(command); len(argv) == 0 {
+			executable, exeErr := os.Executable()
+			if exeErr != nil {
+				return nil, fmt.Errorf("failed to locate server executable: %w", exeErr)
+			}
+			argv = []string{executable}
+		}
+		c, err = client.NewStdioMCPClient(argv[0], nil, argv[1:]...)
+		if err == nil {
+			// Drain the server's stderr so that its logging never blocks it
+			if stderr, ok := client.GetStderr(c); ok {
+				out := io.Discard
+				if verbose {
+					out = os.Stderr
+				}
+				go io.Copy(out, stderr)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "mcpserver-client", Version: "1.0.0"}
+	if _, err := c.Initialize(ctx, initRequest); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to initialize server: %w", err)
+	}
+	return c, nil
+}
+
+// clientList prints the tools, resources and resource templates of the server
+func clientList(ctx context.Context, c *client.Client) error {
+	tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to list tools: %w", err)
+	}
+	fmt.Println("Tools:")
+	for _, tool := range tools.Tools {
+		fmt.Printf("  %-24s %s\n", tool.Name, tool.Description)
+	}
+
+	resources, err := c.ListResources(ctx, mcp.ListResourcesRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to list resources: %w", err)
+	}
+	fmt.Println("Resources:")
+	for _, resource := range resources.Resources {
+		fmt.Printf("  %-24s %s\n", resource.URI, resource.Description)
+	}
+
+	templates, err := c.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to list resource templates: %w", err)
+	}
+	fmt.Println("Resource templates:")
+	for _, template := range templates.ResourceTemplates {
+		fmt.Printf("  %-24s %s\n", template.URITemplate.Raw(), template.Description)
+	}
+	return nil
+}
+
+// clientCall calls a tool and prints the text content of its result
+func clientCall(ctx context.Context, c *client.Client, tool, arguments string) error {
+	var args map[string]any
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return fmt.Errorf("invalid JSON arguments: %w", err)
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = tool
+	request.Params.Arguments = args
+	result, err := c.CallTool(ctx, request)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", tool, err)
+	}
+
+	text := toolResultText(result)
+	if result.IsError {
+		return fmt.Errorf("%s returned an error: %s", tool, text)
+	}
+	fmt.Println(text)
+	return nil
+}
+
+// clientRead reads a resource and prints its text contents
+func clientRead(ctx context.Context, c *client.Client, uri string) error {
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = uri
+	result, err := c.ReadResource(ctx, request)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", uri, err)
+	}
+
+	for _, content := range result.Contents {
+		if text, ok := content.(mcp.TextResourceContents); ok {
+			fmt.Println(text.Text)
+		}
+	}
+	return nil
+}
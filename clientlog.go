@@ -0,0 +1,157 @@
+package mcpserver
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// clientLogger is the logger name of the log records forwarded to clients
+const clientLogger = "server"
+
+// clientLogLevels maps the MCP logging levels to slog levels
+var clientLogLevels = map[mcp.LoggingLevel]slog.Level{
+	mcp.LoggingLevelDebug:     slog.LevelDebug,
+	mcp.LoggingLevelInfo:      slog.LevelInfo,
+	mcp.LoggingLevelNotice:    slog.LevelInfo + 2,
+	mcp.LoggingLevelWarning:   slog.LevelWarn,
+	mcp.LoggingLevelError:     slog.LevelError,
+	mcp.LoggingLevelCritical:  slog.LevelError + 4,
+	mcp.LoggingLevelAlert:     slog.LevelError + 8,
+	mcp.LoggingLevelEmergency: slog.LevelError + 12,
+}
+
+// mcpLogLevel returns the MCP logging level of a slog level
+func mcpLogLevel(level slog.Level) mcp.LoggingLevel {
+	switch {
+	case level >= slog.LevelError+12:
+		return mcp.LoggingLevelEmergency
+	case level >= slog.LevelError+8:
+		return mcp.LoggingLevelAlert
+	case level >= slog.LevelError+4:
+		return mcp.LoggingLevelCritical
+	case level >= slog.LevelError:
+		return mcp.LoggingLevelError
+	case level >= slog.LevelWarn:
+		return mcp.LoggingLevelWarning
+	case level >= slog.LevelInfo+2:
+		return mcp.LoggingLevelNotice
+	case level >= slog.LevelInfo:
+		return mcp.LoggingLevelInfo
+	default:
+		return mcp.LoggingLevelDebug
+	}
+}
+
+// clientLogForwarder forwards the records written through the default logger to the clients
+// that asked for them with logging/setLevel, as notifications/message. Each client receives
+// the records at or above the level it set, even below the level of the server log; clients
+// that never set a level receive none.
+type clientLogForwarder struct {
+	mu     sync.RWMutex
+	server *server.MCPServer
+	// levels holds the level set by each session
+	levels map[string]slog.Level
+}
+
+// newClientLogForwarder creates a forwarder that sends no records until attached to a server
+func newClientLogForwarder() *clientLogForwarder {
+	return &clientLogForwarder{levels: make(map[string]slog.Level)}
+}
+
+// attach sends the forwarded records to the clients of s
+func (f *clientLogForwarder) attach(s *server.MCPServer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.server = s
+}
+
+// afterSetLevel records the level a session set; it is registered as an AfterSetLevel hook
+func (f *clientLogForwarder) afterSetLevel(ctx context.Context, id any, message *mcp.SetLevelRequest, result *mcp.EmptyResult) {
+	session := sessionID(ctx)
+	if session == "" {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.levels[session] = clientLogLevels[message.Params.Level]
+}
+
+// onUnregister forgets the level of a session that ended
+func (f *clientLogForwarder) onUnregister(ctx context.Context, session server.ClientSession) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.levels, session.SessionID())
+}
+
+// wants reports whether any client asked for records of the given level
+func (f *clientLogForwarder) wants(level slog.Level) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, threshold := range f.levels {
+		if level >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// forward sends a log entry to the clients whose level it reaches. Failures are ignored
+// rather than logged, which would forward them in turn.
+func (f *clientLogForwarder) forward(level slog.Level, entry LogEntry) {
+	f.mu.RLock()
+	s := f.server
+	var sessions []string
+	for session, threshold := range f.levels {
+		if level >= threshold {
+			sessions = append(sessions, session)
+		}
+	}
+	f.mu.RUnlock()
+	if s == nil {
+		return
+	}
+
+	notification := mcp.NewLoggingMessageNotification(mcpLogLevel(level), clientLogger, entry)
+	for _, session := range sessions {
+		_ = s.SendLogMessageToSpecificClient(session, notification)
+	}
+}
+
+// Wrap returns a handler that forwards records to clients before passing those enabled in
+// next on to it
+func (f *clientLogForwarder) Wrap(next slog.Handler) slog.Handler {
+	return &clientLogHandler{forwarder: f, next: next}
+}
+
+// clientLogHandler is a slog.Handler feeding a clientLogForwarder
+type clientLogHandler struct {
+	forwarder *clientLogForwarder
+	next      slog.Handler
+	logAttrs
+}
+
+func (h *clientLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level) || h.forwarder.wants(level)
+}
+
+func (h *clientLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if h.forwarder.wants(record.Level) {
+		h.forwarder.forward(record.Level, h.entry(record))
+	}
+	if !h.next.Enabled(ctx, record.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h *clientLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &clientLogHandler{forwarder: h.forwarder, next: h.next.WithAttrs(attrs), logAttrs: h.withAttrs(attrs)}
+}
+
+func (h *clientLogHandler) WithGroup(name string) slog.Handler {
+	return &clientLogHandler{forwarder: h.forwarder, next: h.next.WithGroup(name), logAttrs: h.withGroup(name)}
+}
@@ -0,0 +1,104 @@
+// Command mcpserver serves the demo product catalog over MCP; see package mcpserver
+// for embedding the server in other programs.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"mcpserver"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "client" {
+		if err := mcpserver.RunClient(os.Args[2:]); err != nil {
+			log.Fatalf("Client error: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate-data" {
+		if err := mcpserver.RunMigrateData(os.Args[2:]); err != nil {
+			log.Fatalf("Data migration failed: %v", err)
+		}
+		return
+	}
+
+	selfTest := flag.Bool("self-test", false, "Exercise every tool and resource against a temporary database, or the empty database of SELF_TEST_DB_URL, and exit")
+	transport := flag.String("transport", mcpserver.TransportStdio, "Comma-separated transports to serve MCP over at the same time: stdio, and on the listen address http for Server-Sent Events, streamable for Streamable HTTP and websocket")
+	migrate := flag.Bool("migrate", false, "Apply pending database migrations and exit")
+	rollback := flag.Bool("rollback", false, "Revert the latest database migration and exit")
+	restore := flag.String("restore", "", "Restore the SQLite database from this backup file before serving")
+	force := flag.Bool("force", false, "Allow -restore to overwrite a database that holds products")
+	addr := flag.String("addr", "", "Listen address of the HTTP transports, such as :8080 (overrides HTTP_ADDR)")
+	dbPath := flag.String("db", "", "Database URL or SQLite file path (overrides DB_PATH)")
+	ephemeral := flag.Bool("ephemeral", false, "Use a seeded in-memory database that is discarded on exit (same as -db :memory:)")
+	flag.Parse()
+	selectedTransports, err := mcpserver.ParseTransports(*transport)
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+
+	// Load configuration for the selected environment profile
+	cfg, err := mcpserver.LoadConfig()
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	// Flags given on the command line take precedence over the environment
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.HTTPAddr = *addr
+		case "db":
+			cfg.DBPath = *dbPath
+		}
+	})
+	if *ephemeral {
+		if *dbPath != "" {
+			log.Fatalf("Configuration error: -ephemeral and -db are mutually exclusive")
+		}
+		cfg.DBPath = ":memory:"
+	}
+	mcpserver.SetupLogging(cfg)
+
+	switch {
+	case *migrate && *rollback:
+		log.Fatalf("Configuration error: -migrate and -rollback are mutually exclusive")
+	case *migrate:
+		applied, err := mcpserver.MigrateDatabase(cfg)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Printf("Applied %d migrations", len(applied))
+		return
+	case *rollback:
+		id, err := mcpserver.RollbackDatabase(cfg)
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		if id == "" {
+			log.Printf("No migration to roll back")
+		} else {
+			log.Printf("Rolled back migration %s", id)
+		}
+		return
+	}
+
+	if *selfTest {
+		if err := mcpserver.RunSelfTest(cfg); err != nil {
+			log.Fatalf("Self-test failed: %v", err)
+		}
+		return
+	}
+
+	if *restore != "" {
+		if err := mcpserver.RestoreDatabase(cfg, *restore, *force); err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+	}
+
+	if err := mcpserver.Run(cfg, selectedTransports); err != nil {
+		log.Printf("Server error: %v", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,183 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// methodCompletionComplete is the argument completion method, which the MCP library does not
+// handle itself
+const methodCompletionComplete = "completion/complete"
+
+// Reference types of completion requests
+const (
+	completionRefPrompt   = "ref/prompt"
+	completionRefResource = "ref/resource"
+)
+
+// maxCompletionValues caps the values of a completion response, as the protocol requires
+const maxCompletionValues = 100
+
+// completionSource returns the values of an argument starting with prefix, ignoring case, in
+// order and at most maxCompletionValues of them, with the number of matching values
+type completionSource func(ctx context.Context, app *App, prefix string) ([]string, int, error)
+
+// argumentCompletions maps argument names to the source of their values. Arguments are
+// completed by name, whichever prompt or resource template they belong to, so stored prompts
+// declaring a category or code argument get the same suggestions as the built-in ones.
+var argumentCompletions = map[string]completionSource{
+	"operation":     staticCompletion(calculatorOperations...),
+	"sort":          staticCompletion(productFieldNames()...),
+	outputFormatArg: staticCompletion(outputFormats...),
+	"entity":        staticCompletion(auditEntities...),
+	"action":        staticCompletion(auditActions...),
+	"category":      columnCompletion(&Category{}, "name"),
+	"tag":           columnCompletion(&Tag{}, "name"),
+	"code":          columnCompletion(&Product{}, "code"),
+	"table": func(ctx context.Context, app *App, prefix string) ([]string, int, error) {
+		values, total := matchPrefix(app.config.BrowsableTables, prefix)
+		return values, total, nil
+	},
+	tenantArg: func(ctx context.Context, app *App, prefix string) ([]string, int, error) {
+		values, total := matchPrefix(app.tenantNames(), prefix)
+		return values, total, nil
+	},
+}
+
+// staticCompletion completes an argument from a fixed list of values
+func staticCompletion(values ...string) completionSource {
+	return func(ctx context.Context, app *App, prefix string) ([]string, int, error) {
+		matched, total := matchPrefix(values, prefix)
+		return matched, total, nil
+	}
+}
+
+// matchPrefix returns the values starting with prefix, ignoring case, capped at
+// maxCompletionValues, with the number of matching values
+func matchPrefix(values []string, prefix string) ([]string, int) {
+	prefix = strings.ToLower(prefix)
+	matched := []string{}
+	for _, value := range values {
+		if strings.HasPrefix(strings.ToLower(value), prefix) {
+			matched = append(matched, value)
+		}
+	}
+	total := len(matched)
+	if total > maxCompletionValues {
+		matched = matched[:maxCompletionValues]
+	}
+	return matched, total
+}
+
+// columnCompletion completes an argument from the values of a column in the database of the
+// tenant of the session
+func columnCompletion(model any, column string) completionSource {
+	return func(ctx context.Context, app *App, prefix string) ([]string, int, error) {
+		ctx, err := app.tenantContext(ctx, "")
+		if err != nil {
+			return nil, 0, err
+		}
+		return app.dbService.CompleteColumn(ctx, model, column, prefix)
+	}
+}
+
+// CompleteColumn returns the values of column in the table of model that start with prefix,
+// ignoring case, in order and at most maxCompletionValues of them, with the number of
+// matching values. Soft-deleted rows are skipped.
+func (dbs *DBService) CompleteColumn(ctx context.Context, model any, column, prefix string) ([]string, int, error) {
+	var (
+		values []string
+		total  int64
+	)
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		matching := func() *gorm.DB {
+			return dbs.conn(ctx).Model(model).Where("LOWER("+column+") LIKE ?", strings.ToLower(prefix)+"%")
+		}
+		if err := matching().Count(&total).Error; err != nil {
+			return err
+		}
+		values = nil
+		return matching().Order(column).Limit(maxCompletionValues).Pluck(column, &values).Error
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to complete %s: %w", column, err)
+	}
+	// LIKE treats % and _ in the prefix as wildcards; drop the values they matched without
+	// starting with the prefix
+	matched, _ := matchPrefix(values, prefix)
+	total -= int64(len(values) - len(matched))
+	return matched, int(total), nil
+}
+
+// completeRequest handles completion/complete requests; it is registered as an
+// OnRequestInitialization hook. The library answers this method as not found, so the
+// suggestions are returned through the error mapper. Arguments without a completion source
+// get no suggestions.
+func (app *App) completeRequest(ctx context.Context, id any, message any) error {
+	raw, ok := message.(json.RawMessage)
+	if !ok || id == nil {
+		return nil
+	}
+	var request struct {
+		Method string `json:"method"`
+		Params struct {
+			Ref struct {
+				Type string `json:"type"`
+				Name string `json:"name"`
+				URI  string `json:"uri"`
+			} `json:"ref"`
+			Argument struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"argument"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(raw, &request); err != nil || request.Method != methodCompletionComplete {
+		return nil
+	}
+
+	ref := request.Params.Ref
+	switch {
+	case ref.Type == completionRefPrompt && ref.Name == "":
+		return fmt.Errorf("%s requires the name of the prompt", methodCompletionComplete)
+	case ref.Type == completionRefResource && ref.URI == "":
+		return fmt.Errorf("%s requires the uri of the resource", methodCompletionComplete)
+	case ref.Type != completionRefPrompt && ref.Type != completionRefResource:
+		return fmt.Errorf("unknown %s reference type %q (expected %s or %s)", methodCompletionComplete, ref.Type, completionRefPrompt, completionRefResource)
+	}
+	if request.Params.Argument.Name == "" {
+		return fmt.Errorf("%s requires the name of the argument", methodCompletionComplete)
+	}
+
+	result := mcp.CompleteResult{}
+	result.Completion.Values = []string{}
+	if source, ok := argumentCompletions[request.Params.Argument.Name]; ok {
+		values, total, err := source(ctx, app, request.Params.Argument.Value)
+		if err != nil {
+			return err
+		}
+		result.Completion.Values = values
+		result.Completion.Total = total
+		result.Completion.HasMore = total > len(values)
+	}
+
+	slog.Debug("Completed argument", "ref", ref.Type, "argument", request.Params.Argument.Name, "values", len(result.Completion.Values))
+	app.rpcErrors.answer(ctx, id, result)
+	return nil
+}
+
+// advertiseCompletions declares the completions capability in the initialize result; it is
+// registered as an AfterInitialize hook. The capabilities type of the library has no field
+// for it, so it is declared as experimental.
+func advertiseCompletions(ctx context.Context, id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
+	if result.Capabilities.Experimental == nil {
+		result.Capabilities.Experimental = make(map[string]any)
+	}
+	result.Capabilities.Experimental["completions"] = struct{}{}
+}
@@ -0,0 +1,688 @@
+package mcpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm/logger"
+
+	"mcpserver/storage"
+)
+
+// Config holds the runtime settings of the server
+type Config struct {
+	Env              string
+	LogLevel         slog.Level
+	SeedDatabase     bool
+	AutoMigrate      bool
+	DestructiveTools bool
+	PromptTools      bool
+	ToolAdmin        bool
+	DBPath           string
+	ReplicaDBPaths   []string
+	RedactFields     []string
+	RedactPatterns   []string
+	BackupDir        string
+	BackupInterval   time.Duration
+	BackupRetention  int
+	Quotas           QuotaLimits
+	Results          ResultLimits
+	SessionStore     string
+	SessionTTL       time.Duration
+	IdempotencyTTL   time.Duration
+	AdminAddr        string
+	// AdminPassword is the password of the admin dashboard, sent with HTTP basic
+	// authentication; the dashboard serves anyone reaching AdminAddr when it is empty and no
+	// client certificates are required
+	AdminPassword string
+	// HTTPAddr is the listen address of the HTTP transports
+	HTTPAddr string
+	// TLSCertFile and TLSKeyFile enable TLS on the HTTP transports
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSAutocertDomains enables TLS with certificates obtained from Let's Encrypt for these
+	// host names, cached in TLSAutocertCache; it excludes TLSCertFile
+	TLSAutocertDomains []string
+	TLSAutocertCache   string
+	// TLSClientCAFile requires clients of the HTTP transports to present a certificate
+	// signed by one of the CAs in this PEM file (mutual TLS)
+	TLSClientCAFile string
+	// WSMaxConnections caps concurrent WebSocket connections
+	WSMaxConnections int
+	// ListPageSize caps the tools, prompts, resources and resource templates of each list
+	// response, clients following its cursor for the rest; zero lists them all at once
+	ListPageSize int
+	// WSAllowedOrigins lists the browser origins allowed to open WebSocket connections ("*" for any)
+	WSAllowedOrigins []string
+	// ToolVersions lists the versions of versioned tools that are advertised
+	ToolVersions []string
+	// DefaultToolVersion is the version used when a versioned tool is called without a version
+	DefaultToolVersion string
+	// ToolsFile is a YAML file of declarative SQL tools registered at startup
+	ToolsFile string
+	// DisabledTools lists the tools hidden from clients and refusing calls, by name or as the
+	// write group of every tool not annotated read-only; it is re-read on SIGHUP
+	DisabledTools []string
+	// Instructions tell clients, and the models they drive, how to use the server; they are
+	// sent in the initialize result
+	Instructions string
+	// ServerTitle is the display name of the server sent in the initialize result, if set
+	ServerTitle string
+	// ToolDescriptions replaces the descriptions of the named tools in tool lists
+	ToolDescriptions map[string]string
+	// OTLPEndpoint is the base URL of an OTLP/HTTP collector; telemetry export is off when empty
+	OTLPEndpoint string
+	ServiceName  string
+	// PriceWatchInterval is how often price watches are checked; zero disables the checker
+	PriceWatchInterval time.Duration
+	// BrowsableTables lists the tables exposed read-only through db://tables
+	BrowsableTables []string
+	// SQLiteBusyTimeout is how long SQLite connections wait for a lock before failing
+	SQLiteBusyTimeout time.Duration
+	// DBRetry bounds the retries of database operations failing with transient errors
+	DBRetry RetryPolicy
+	// QueryTimeout bounds each database query of a tool call; zero disables the limit
+	QueryTimeout time.Duration
+	// DBLogLevel selects the SQL logged: silent, error (failed statements), warn (and slow
+	// statements) or info (every statement, at debug level)
+	DBLogLevel logger.LogLevel
+	// SlowQueryThreshold is the duration above which a statement is logged as slow
+	SlowQueryThreshold time.Duration
+	// MaintenanceSchedule is a standard five-field cron expression (or a descriptor such as
+	// @daily) for running MaintenanceOperations; empty disables scheduled maintenance
+	MaintenanceSchedule   string
+	MaintenanceOperations []string
+	// Tenants maps tenant names to the database URLs of the tenants; the configured
+	// database serves requests that select no tenant
+	Tenants map[string]string
+	// Currency is the ISO 4217 code of the currency of products created without one, and
+	// the base of ExchangeRates
+	Currency string
+	// ExchangeRates maps currency codes to the units of that currency worth one unit of Currency
+	ExchangeRates map[string]float64
+	// ImportDir is the directory import_products may read files from; empty disables file imports
+	ImportDir string
+	// LowStockThreshold is the stock below which products are listed by alerts://low-stock
+	LowStockThreshold int
+	// LowStockCheckInterval is how often stock is checked for products crossing the threshold,
+	// which clients are notified of; zero disables the notifications
+	LowStockCheckInterval time.Duration
+	// SelfTestDBURL is the URL of an empty MySQL or PostgreSQL database the self-test runs
+	// against instead of a temporary SQLite file; its migrations are reverted afterwards
+	SelfTestDBURL string
+	// logHandler is the handler installed by SetupLogging, which servers extend to keep and
+	// forward their records
+	logHandler slog.Handler
+}
+
+// profiles bundles the defaults for each supported APP_ENV value
+var profiles = map[string]Config{
+	"dev": {
+		LogLevel:           slog.LevelDebug,
+		SeedDatabase:       true,
+		AutoMigrate:        true,
+		DestructiveTools:   true,
+		PromptTools:        true,
+		ToolAdmin:          true,
+		DBPath:             "test.db",
+		SQLiteBusyTimeout:  defaultSQLiteBusyTimeout,
+		DBRetry:            defaultRetryPolicy,
+		QueryTimeout:       10 * time.Second,
+		DBLogLevel:         logger.Info,
+		SlowQueryThreshold: 200 * time.Millisecond,
+		SessionStore:       "memory",
+		SessionTTL:         24 * time.Hour,
+		IdempotencyTTL:     24 * time.Hour,
+		BackupDir:          "backups",
+		BackupRetention:    7,
+		ToolVersions:       []string{"v1", "v2"},
+		DefaultToolVersion: "v1",
+		PriceWatchInterval: 30 * time.Second,
+		Results:            defaultResultLimits,
+		BrowsableTables:    []string{"products", "session_states", "idempotency_records"},
+	},
+	"staging": {
+		LogLevel:           slog.LevelInfo,
+		SeedDatabase:       true,
+		AutoMigrate:        true,
+		DestructiveTools:   false,
+		PromptTools:        false,
+		ToolAdmin:          false,
+		DBPath:             "staging.db",
+		SQLiteBusyTimeout:  defaultSQLiteBusyTimeout,
+		DBRetry:            defaultRetryPolicy,
+		QueryTimeout:       10 * time.Second,
+		DBLogLevel:         logger.Warn,
+		SlowQueryThreshold: 200 * time.Millisecond,
+		SessionStore:       "memory",
+		SessionTTL:         24 * time.Hour,
+		IdempotencyTTL:     24 * time.Hour,
+		BackupDir:          "backups",
+		BackupRetention:    7,
+		ToolVersions:       []string{"v1", "v2"},
+		DefaultToolVersion: "v1",
+		PriceWatchInterval: 30 * time.Second,
+		Results:            defaultResultLimits,
+		BrowsableTables:    []string{"products"},
+	},
+	"prod": {
+		LogLevel:           slog.LevelInfo,
+		SeedDatabase:       false,
+		AutoMigrate:        false,
+		DestructiveTools:   false,
+		PromptTools:        false,
+		ToolAdmin:          false,
+		DBPath:             "data.db",
+		SQLiteBusyTimeout:  defaultSQLiteBusyTimeout,
+		DBRetry:            defaultRetryPolicy,
+		QueryTimeout:       10 * time.Second,
+		DBLogLevel:         logger.Warn,
+		SlowQueryThreshold: 200 * time.Millisecond,
+		SessionStore:       "memory",
+		SessionTTL:         24 * time.Hour,
+		IdempotencyTTL:     24 * time.Hour,
+		BackupDir:          "backups",
+		BackupInterval:     24 * time.Hour,
+		BackupRetention:    7,
+		ToolVersions:       []string{"v1", "v2"},
+		DefaultToolVersion: "v1",
+		PriceWatchInterval: 30 * time.Second,
+		Results:            defaultResultLimits,
+		BrowsableTables:    []string{"products"},
+	},
+}
+
+// defaultResultLimits bounds list results in every profile
+var defaultResultLimits = ResultLimits{
+	SoftRows:  200,
+	HardRows:  1000,
+	SoftBytes: 64 << 10,
+	HardBytes: 1 << 20,
+}
+
+// defaultSQLiteBusyTimeout bounds how long SQLite waits for locks in every profile
+const defaultSQLiteBusyTimeout = 5 * time.Second
+
+// defaultHTTPAddr is the listen address of the HTTP transports when HTTP_ADDR is not set
+const defaultHTTPAddr = "localhost:8080"
+
+// defaultLowStockThreshold is the low-stock threshold when LOW_STOCK_THRESHOLD is not set
+const defaultLowStockThreshold = 10
+
+// defaultListPageSize is the page size of list responses when LIST_PAGE_SIZE is not set
+const defaultListPageSize = 50
+
+// defaultWSMaxConnections caps WebSocket connections when WS_MAX_CONNECTIONS is not set
+const defaultWSMaxConnections = 64
+
+// defaultEnv is the profile used when APP_ENV is not set
+const defaultEnv = "dev"
+
+// LoadConfig selects the profile named by APP_ENV and applies individual
+// environment variable overrides on top of it. Every variable can also be
+// provided through a NAME_FILE secret file (see lookupEnv).
+func LoadConfig() (*Config, error) {
+	env, err := lookupEnv("APP_ENV")
+	if err != nil {
+		return nil, err
+	}
+	if env == "" {
+		env = defaultEnv
+	}
+
+	profile, ok := profiles[env]
+	if !ok {
+		return nil, fmt.Errorf("unknown APP_ENV %q (expected dev, staging or prod)", env)
+	}
+	cfg := profile
+	cfg.Env = env
+
+	if err := envString("DB_PATH", &cfg.DBPath); err != nil {
+		return nil, err
+	}
+	if cfg.ReplicaDBPaths, err = envList("DB_REPLICAS"); err != nil {
+		return nil, err
+	}
+	if err := envDuration("SQLITE_BUSY_TIMEOUT", &cfg.SQLiteBusyTimeout); err != nil {
+		return nil, err
+	}
+	if err := envInt("DB_RETRY_ATTEMPTS", &cfg.DBRetry.Attempts); err != nil {
+		return nil, err
+	}
+	if err := envDuration("DB_RETRY_BACKOFF", &cfg.DBRetry.Backoff); err != nil {
+		return nil, err
+	}
+	if err := envDuration("DB_RETRY_MAX_BACKOFF", &cfg.DBRetry.MaxBackoff); err != nil {
+		return nil, err
+	}
+	if cfg.DBRetry.Backoff < 0 || cfg.DBRetry.MaxBackoff < cfg.DBRetry.Backoff {
+		return nil, fmt.Errorf("DB_RETRY_BACKOFF must not be negative nor exceed DB_RETRY_MAX_BACKOFF")
+	}
+	if err := envDuration("QUERY_TIMEOUT", &cfg.QueryTimeout); err != nil {
+		return nil, err
+	}
+	if err := envDBLogLevel("DB_LOG_LEVEL", &cfg.DBLogLevel); err != nil {
+		return nil, err
+	}
+	if err := envDuration("DB_SLOW_QUERY_THRESHOLD", &cfg.SlowQueryThreshold); err != nil {
+		return nil, err
+	}
+	if err := envLogLevel("LOG_LEVEL", &cfg.LogLevel); err != nil {
+		return nil, err
+	}
+	if err := envBool("SEED_DATABASE", &cfg.SeedDatabase); err != nil {
+		return nil, err
+	}
+	if err := envBool("AUTO_MIGRATE", &cfg.AutoMigrate); err != nil {
+		return nil, err
+	}
+	if err := envBool("DESTRUCTIVE_TOOLS", &cfg.DestructiveTools); err != nil {
+		return nil, err
+	}
+	if err := envBool("PROMPT_TOOLS", &cfg.PromptTools); err != nil {
+		return nil, err
+	}
+	if err := envBool("TOOL_ADMIN", &cfg.ToolAdmin); err != nil {
+		return nil, err
+	}
+	if err := envString("BACKUP_DIR", &cfg.BackupDir); err != nil {
+		return nil, err
+	}
+	if err := envDuration("BACKUP_INTERVAL", &cfg.BackupInterval); err != nil {
+		return nil, err
+	}
+	if err := envInt("BACKUP_RETENTION", &cfg.BackupRetention); err != nil {
+		return nil, err
+	}
+	if err := envInt("QUOTA_MAX_TOOL_CALLS", &cfg.Quotas.MaxToolCalls); err != nil {
+		return nil, err
+	}
+	if err := envInt("QUOTA_MAX_ROWS", &cfg.Quotas.MaxRows); err != nil {
+		return nil, err
+	}
+	if err := envInt("QUOTA_MAX_MUTATIONS", &cfg.Quotas.MaxMutations); err != nil {
+		return nil, err
+	}
+	if err := envInt("RESULT_SOFT_ROWS", &cfg.Results.SoftRows); err != nil {
+		return nil, err
+	}
+	if err := envInt("RESULT_MAX_ROWS", &cfg.Results.HardRows); err != nil {
+		return nil, err
+	}
+	if err := envInt("RESULT_SOFT_BYTES", &cfg.Results.SoftBytes); err != nil {
+		return nil, err
+	}
+	if err := envInt("RESULT_MAX_BYTES", &cfg.Results.HardBytes); err != nil {
+		return nil, err
+	}
+	if err := envString("SESSION_STORE", &cfg.SessionStore); err != nil {
+		return nil, err
+	}
+	if err := envDuration("SESSION_TTL", &cfg.SessionTTL); err != nil {
+		return nil, err
+	}
+	if err := envDuration("IDEMPOTENCY_TTL", &cfg.IdempotencyTTL); err != nil {
+		return nil, err
+	}
+	if err := envDuration("PRICE_WATCH_INTERVAL", &cfg.PriceWatchInterval); err != nil {
+		return nil, err
+	}
+	if err := envString("MAINTENANCE_SCHEDULE", &cfg.MaintenanceSchedule); err != nil {
+		return nil, err
+	}
+	if cfg.MaintenanceSchedule != "" {
+		if _, err := cron.ParseStandard(cfg.MaintenanceSchedule); err != nil {
+			return nil, fmt.Errorf("invalid MAINTENANCE_SCHEDULE %q: %w", cfg.MaintenanceSchedule, err)
+		}
+	}
+	cfg.MaintenanceOperations = []string{"vacuum", "analyze"}
+	operations, err := envList("MAINTENANCE_OPERATIONS")
+	if err != nil {
+		return nil, err
+	}
+	if len(operations) > 0 {
+		cfg.MaintenanceOperations = operations
+	}
+	for _, op := range cfg.MaintenanceOperations {
+		if !slices.Contains(maintenanceOperations, op) {
+			return nil, fmt.Errorf("invalid MAINTENANCE_OPERATIONS: unsupported operation %q", op)
+		}
+	}
+	tenants, err := envList("TENANTS")
+	if err != nil {
+		return nil, err
+	}
+	for _, tenant := range tenants {
+		name, dbURL, ok := strings.Cut(tenant, "=")
+		name, dbURL = strings.TrimSpace(name), strings.TrimSpace(dbURL)
+		if !ok || name == "" || dbURL == "" {
+			return nil, fmt.Errorf("invalid TENANTS entry %q (expected name=url)", tenant)
+		}
+		if name == defaultTenant {
+			return nil, fmt.Errorf("invalid TENANTS entry %q: %s names the configured database", tenant, defaultTenant)
+		}
+		if cfg.Tenants == nil {
+			cfg.Tenants = make(map[string]string)
+		}
+		cfg.Tenants[name] = dbURL
+	}
+	cfg.Currency = defaultCurrency
+	if err := envString("CURRENCY", &cfg.Currency); err != nil {
+		return nil, err
+	}
+	cfg.Currency = strings.ToUpper(cfg.Currency)
+	if !validCurrencyCode(cfg.Currency) {
+		return nil, fmt.Errorf("invalid CURRENCY %q: %s", cfg.Currency, currencyFormatMessage)
+	}
+	rates, err := envList("EXCHANGE_RATES")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range rates {
+		code, value, ok := strings.Cut(entry, "=")
+		code = strings.ToUpper(strings.TrimSpace(code))
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || !validCurrencyCode(code) || err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid EXCHANGE_RATES entry %q (expected a currency code and a positive rate, e.g. EUR=0.92)", entry)
+		}
+		if code == cfg.Currency {
+			return nil, fmt.Errorf("invalid EXCHANGE_RATES entry %q: %s is the base currency", entry, code)
+		}
+		if cfg.ExchangeRates == nil {
+			cfg.ExchangeRates = make(map[string]float64)
+		}
+		cfg.ExchangeRates[code] = rate
+	}
+	if err := envString("IMPORT_DIR", &cfg.ImportDir); err != nil {
+		return nil, err
+	}
+	cfg.LowStockThreshold = defaultLowStockThreshold
+	if err := envInt("LOW_STOCK_THRESHOLD", &cfg.LowStockThreshold); err != nil {
+		return nil, err
+	}
+	if cfg.LowStockThreshold <= 0 {
+		return nil, fmt.Errorf("LOW_STOCK_THRESHOLD must be positive")
+	}
+	if err := envDuration("LOW_STOCK_CHECK_INTERVAL", &cfg.LowStockCheckInterval); err != nil {
+		return nil, err
+	}
+	if err := envString("SELF_TEST_DB_URL", &cfg.SelfTestDBURL); err != nil {
+		return nil, err
+	}
+	if err := envString("ADMIN_ADDR", &cfg.AdminAddr); err != nil {
+		return nil, err
+	}
+	cfg.HTTPAddr = defaultHTTPAddr
+	if err := envString("HTTP_ADDR", &cfg.HTTPAddr); err != nil {
+		return nil, err
+	}
+	if err := envString("TLS_CERT_FILE", &cfg.TLSCertFile); err != nil {
+		return nil, err
+	}
+	if err := envString("TLS_KEY_FILE", &cfg.TLSKeyFile); err != nil {
+		return nil, err
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.TLSAutocertDomains, err = envList("TLS_AUTOCERT_DOMAINS"); err != nil {
+		return nil, err
+	}
+	if cfg.TLSCertFile != "" && len(cfg.TLSAutocertDomains) > 0 {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS are mutually exclusive")
+	}
+	cfg.TLSAutocertCache = "autocert-cache"
+	if err := envString("TLS_AUTOCERT_CACHE", &cfg.TLSAutocertCache); err != nil {
+		return nil, err
+	}
+	if err := envString("TLS_CLIENT_CA_FILE", &cfg.TLSClientCAFile); err != nil {
+		return nil, err
+	}
+	if cfg.TLSClientCAFile != "" && cfg.TLSCertFile == "" && len(cfg.TLSAutocertDomains) == 0 {
+		return nil, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE or TLS_AUTOCERT_DOMAINS")
+	}
+	if err := envString("ADMIN_PASSWORD", &cfg.AdminPassword); err != nil {
+		return nil, err
+	}
+	// The dashboard shows data and sessions; beyond this host it must be encrypted and
+	// authenticated
+	if cfg.AdminAddr != "" && !loopbackAddr(cfg.AdminAddr) {
+		if cfg.TLSCertFile == "" && len(cfg.TLSAutocertDomains) == 0 {
+			return nil, fmt.Errorf("ADMIN_ADDR %s is not a loopback address, which requires TLS_CERT_FILE or TLS_AUTOCERT_DOMAINS", cfg.AdminAddr)
+		}
+		if cfg.TLSClientCAFile == "" && cfg.AdminPassword == "" {
+			return nil, fmt.Errorf("ADMIN_ADDR %s is not a loopback address, which requires TLS_CLIENT_CA_FILE or ADMIN_PASSWORD", cfg.AdminAddr)
+		}
+	}
+	cfg.WSMaxConnections = defaultWSMaxConnections
+	if err := envInt("WS_MAX_CONNECTIONS", &cfg.WSMaxConnections); err != nil {
+		return nil, err
+	}
+	if cfg.WSMaxConnections <= 0 {
+		return nil, fmt.Errorf("WS_MAX_CONNECTIONS must be positive")
+	}
+	cfg.ListPageSize = defaultListPageSize
+	if err := envInt("LIST_PAGE_SIZE", &cfg.ListPageSize); err != nil {
+		return nil, err
+	}
+	if cfg.ListPageSize < 0 {
+		return nil, fmt.Errorf("LIST_PAGE_SIZE must not be negative")
+	}
+	if err := envString("DEFAULT_TOOL_VERSION", &cfg.DefaultToolVersion); err != nil {
+		return nil, err
+	}
+	if err := envString("TOOLS_FILE", &cfg.ToolsFile); err != nil {
+		return nil, err
+	}
+	if err := envString("OTEL_EXPORTER_OTLP_ENDPOINT", &cfg.OTLPEndpoint); err != nil {
+		return nil, err
+	}
+	cfg.OTLPEndpoint = strings.TrimRight(cfg.OTLPEndpoint, "/")
+	cfg.Instructions = defaultInstructions
+	if err := envString("SERVER_INSTRUCTIONS", &cfg.Instructions); err != nil {
+		return nil, err
+	}
+	if err := envString("SERVER_TITLE", &cfg.ServerTitle); err != nil {
+		return nil, err
+	}
+	toolDescriptions, err := lookupEnv("TOOL_DESCRIPTIONS")
+	if err != nil {
+		return nil, err
+	}
+	if toolDescriptions = strings.TrimSpace(toolDescriptions); toolDescriptions != "" {
+		if err := json.Unmarshal([]byte(toolDescriptions), &cfg.ToolDescriptions); err != nil {
+			return nil, fmt.Errorf("invalid TOOL_DESCRIPTIONS (expected a JSON object mapping tool names to descriptions): %w", err)
+		}
+	}
+	cfg.ServiceName = "mcpserver"
+	if err := envString("OTEL_SERVICE_NAME", &cfg.ServiceName); err != nil {
+		return nil, err
+	}
+	toolVersions, err := envList("TOOL_VERSIONS")
+	if err != nil {
+		return nil, err
+	}
+	if len(toolVersions) > 0 {
+		cfg.ToolVersions = toolVersions
+	}
+	if cfg.WSAllowedOrigins, err = envList("WS_ALLOWED_ORIGINS"); err != nil {
+		return nil, err
+	}
+	if cfg.DisabledTools, err = envList("DISABLED_TOOLS"); err != nil {
+		return nil, err
+	}
+	browsableTables, err := envList("BROWSABLE_TABLES")
+	if err != nil {
+		return nil, err
+	}
+	if len(browsableTables) > 0 {
+		cfg.BrowsableTables = browsableTables
+	}
+
+	redactFields, err := envList("REDACT_FIELDS")
+	if err != nil {
+		return nil, err
+	}
+	redactPatterns, err := envList("REDACT_PATTERNS")
+	if err != nil {
+		return nil, err
+	}
+	cfg.RedactFields = append(defaultRedactFields, redactFields...)
+	cfg.RedactPatterns = append(defaultRedactPatterns, redactPatterns...)
+
+	return &cfg, nil
+}
+
+// Ephemeral reports whether the database is held in memory and lost on exit. Ephemeral
+// databases are always migrated and seeded.
+func (cfg *Config) Ephemeral() bool {
+	return storage.InMemory(cfg.DBPath)
+}
+
+// storageOptions returns the connection options of the configured database
+func (cfg *Config) storageOptions() storage.Options {
+	return storage.Options{
+		BusyTimeout: cfg.SQLiteBusyTimeout,
+		Logger:      newGormLogger(cfg),
+	}
+}
+
+// loopbackAddr reports whether the listen address addr only accepts connections from this
+// host; an address without a host listens on every interface
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// envString overrides *dst with the named setting, if set
+func envString(name string, dst *string) error {
+	v, err := lookupEnv(name)
+	if err != nil {
+		return err
+	}
+	if v != "" {
+		*dst = v
+	}
+	return nil
+}
+
+// envBool overrides *dst with the boolean value of the named setting, if set
+func envBool(name string, dst *bool) error {
+	v, err := lookupEnv(name)
+	if err != nil {
+		return err
+	}
+	if v = strings.TrimSpace(v); v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, v, err)
+	}
+	*dst = b
+	return nil
+}
+
+// envInt overrides *dst with the integer value of the named setting, if set
+func envInt(name string, dst *int) error {
+	v, err := lookupEnv(name)
+	if err != nil {
+		return err
+	}
+	if v = strings.TrimSpace(v); v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, v, err)
+	}
+	*dst = n
+	return nil
+}
+
+// envDuration overrides *dst with the duration value of the named setting, if set
+func envDuration(name string, dst *time.Duration) error {
+	v, err := lookupEnv(name)
+	if err != nil {
+		return err
+	}
+	if v = strings.TrimSpace(v); v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, v, err)
+	}
+	*dst = d
+	return nil
+}
+
+// envLogLevel overrides *dst with the log level named by the setting, if set
+func envLogLevel(name string, dst *slog.Level) error {
+	v, err := lookupEnv(name)
+	if err != nil {
+		return err
+	}
+	if v == "" {
+		return nil
+	}
+	if err := dst.UnmarshalText([]byte(v)); err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, v, err)
+	}
+	return nil
+}
+
+// envDBLogLevel overrides *dst with the GORM log level named by the setting, if set
+func envDBLogLevel(name string, dst *logger.LogLevel) error {
+	v, err := lookupEnv(name)
+	if err != nil {
+		return err
+	}
+	if v = strings.ToLower(strings.TrimSpace(v)); v == "" {
+		return nil
+	}
+	level, ok := gormLogLevels[v]
+	if !ok {
+		return fmt.Errorf("invalid %s %q (expected silent, error, warn or info)", name, v)
+	}
+	*dst = level
+	return nil
+}
+
+// envList splits a comma-separated setting into its non-empty items
+func envList(name string) ([]string, error) {
+	v, err := lookupEnv(name)
+	if err != nil {
+		return nil, err
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// SetupLogging installs a default logger honoring the configured level. The servers of an
+// application created with cfg then also keep its records for the admin dashboard and forward
+// them to the clients that set a logging level.
+func SetupLogging(cfg *Config) {
+	cfg.logHandler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: cfg.LogLevel})
+	slog.SetDefault(slog.New(cfg.logHandler))
+}
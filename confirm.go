@@ -0,0 +1,103 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// confirmationTokenArg is the argument carrying the token issued by the preview of a destructive tool
+const confirmationTokenArg = "confirmation_token"
+
+// confirmationTTL is how long a confirmation token stays valid
+const confirmationTTL = 5 * time.Minute
+
+// withConfirmationToken declares the confirmation token argument on a destructive tool
+func withConfirmationToken() mcp.ToolOption {
+	return mcp.WithString(confirmationTokenArg,
+		mcp.Description(fmt.Sprintf("One-time token returned by the preview; only a call with the same arguments and this token executes the operation (valid for %s)", confirmationTTL)),
+	)
+}
+
+// pendingConfirmation is a previewed destructive operation awaiting its confirming call
+type pendingConfirmation struct {
+	Tool        string
+	SessionID   string
+	RequestHash string
+	// Expected holds the ids of the records the preview reported
+	Expected  []uint
+	ExpiresAt time.Time
+}
+
+// confirmationStore issues and redeems the one-time tokens of two-phase destructive operations
+type confirmationStore struct {
+	mu      sync.Mutex
+	pending map[string]pendingConfirmation
+}
+
+// newConfirmationStore creates an empty confirmation store
+func newConfirmationStore() *confirmationStore {
+	return &confirmationStore{pending: make(map[string]pendingConfirmation)}
+}
+
+// issue records a previewed operation of tool with the given arguments, affecting the records
+// with the ids expected, and returns its token
+func (cs *confirmationStore) issue(ctx context.Context, tool string, args map[string]any, expected []uint) (string, time.Time, error) {
+	hash, err := requestHash(args)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate confirmation token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	expiresAt := time.Now().Add(confirmationTTL).UTC()
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	now := time.Now()
+	for t, p := range cs.pending {
+		if now.After(p.ExpiresAt) {
+			delete(cs.pending, t)
+		}
+	}
+	cs.pending[token] = pendingConfirmation{
+		Tool:        tool,
+		SessionID:   sessionID(ctx),
+		RequestHash: hash,
+		Expected:    expected,
+		ExpiresAt:   expiresAt,
+	}
+	return token, expiresAt, nil
+}
+
+// redeem consumes a token and returns the preview it was issued for. The token must have
+// been issued to the same session for the same tool and arguments and must not have expired.
+func (cs *confirmationStore) redeem(ctx context.Context, tool string, args map[string]any, token string) (pendingConfirmation, error) {
+	hash, err := requestHash(args)
+	if err != nil {
+		return pendingConfirmation{}, err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	p, ok := cs.pending[token]
+	if !ok || p.Tool != tool || p.SessionID != sessionID(ctx) {
+		return pendingConfirmation{}, fmt.Errorf("%w: unknown or already used confirmation token; run a preview first", ErrFailedPrecondition)
+	}
+	// A token is single-use, whether or not the confirming call succeeds
+	delete(cs.pending, token)
+	if time.Now().After(p.ExpiresAt) {
+		return pendingConfirmation{}, fmt.Errorf("%w: confirmation token expired; run a preview again", ErrFailedPrecondition)
+	}
+	if p.RequestHash != hash {
+		return pendingConfirmation{}, fmt.Errorf("%w: arguments differ from the previewed call; run a preview again", ErrFailedPrecondition)
+	}
+	return p, nil
+}
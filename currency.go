@@ -0,0 +1,182 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultCurrency is the currency of prices when CURRENCY is not set
+const defaultCurrency = "USD"
+
+// currencyFormatMessage describes the expected format of currency codes
+const currencyFormatMessage = "must be an ISO 4217 currency code, e.g. USD"
+
+// validCurrencyCode reports whether code has the form of an ISO 4217 currency code
+func validCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// backfillProductCurrency prices the products and versions written before currencies were
+// recorded in currency. It bypasses the hooks, which would record the update as a new version.
+func backfillProductCurrency(db *gorm.DB, currency string) error {
+	for _, table := range []string{"products", "product_versions"} {
+		if err := db.Exec("UPDATE ? SET currency = ? WHERE currency = ''", clause.Table{Name: table}, currency).Error; err != nil {
+			return fmt.Errorf("failed to backfill currency of %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// exchangeRate returns the units of currency worth one unit of the configured currency
+func (cfg *Config) exchangeRate(currency string) (float64, error) {
+	if currency == cfg.Currency {
+		return 1, nil
+	}
+	rate, ok := cfg.ExchangeRates[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: no exchange rate is configured for %s; add it to EXCHANGE_RATES", ErrFailedPrecondition, currency)
+	}
+	return rate, nil
+}
+
+// PriceConversion is the result of the convert_price tool
+type PriceConversion struct {
+	ProductID uint    `json:"product_id,omitempty"`
+	Code      string  `json:"code,omitempty"`
+	Amount    float64 `json:"amount"`
+	From      string  `json:"from"`
+	Converted float64 `json:"converted"`
+	To        string  `json:"to"`
+	// Rate is the units of To worth one unit of From
+	Rate float64 `json:"rate"`
+}
+
+// convertPrice converts amount from one currency to another through the configured currency.
+// The converted amount is rounded to two decimals.
+func (cfg *Config) convertPrice(amount float64, from, to string) (PriceConversion, error) {
+	fromRate, err := cfg.exchangeRate(from)
+	if err != nil {
+		return PriceConversion{}, err
+	}
+	toRate, err := cfg.exchangeRate(to)
+	if err != nil {
+		return PriceConversion{}, err
+	}
+
+	rate := toRate / fromRate
+	return PriceConversion{
+		Amount:    amount,
+		From:      from,
+		Converted: math.Round(amount*rate*100) / 100,
+		To:        to,
+		Rate:      rate,
+	}, nil
+}
+
+// requestCurrency returns the currency code in the argument name of a tool request in upper
+// case, or fallback if the argument is absent
+func requestCurrency(request mcp.CallToolRequest, name, fallback string) (string, error) {
+	code := strings.ToUpper(request.GetString(name, fallback))
+	if !validCurrencyCode(code) {
+		return "", invalidField(name, currencyFormatMessage)
+	}
+	return code, nil
+}
+
+// convertPriceHandler handles the convert_price tool request
+func (app *App) convertPriceHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	_, hasID := args["id"]
+	_, hasAmount := args["amount"]
+	if hasID == hasAmount {
+		return newToolError(CodeInvalidArgument, "provide either id or amount"), nil
+	}
+
+	if _, err := request.RequireString("to"); err != nil {
+		return argumentError("to", err), nil
+	}
+	to, err := requestCurrency(request, "to", "")
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	var conversion PriceConversion
+	if hasID {
+		id, err := request.RequireInt("id")
+		if err != nil {
+			return argumentError("id", err), nil
+		}
+		if id <= 0 {
+			return toolErrorResult(invalidField("id", "must be a positive integer"))
+		}
+		product, err := app.dbService.GetProduct(ctx, uint(id), "")
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		if conversion, err = app.config.convertPrice(product.Price, product.Currency, to); err != nil {
+			return toolErrorResult(err)
+		}
+		conversion.ProductID, conversion.Code = product.ID, product.Code
+	} else {
+		amount, err := request.RequireFloat("amount")
+		if err != nil {
+			return argumentError("amount", err), nil
+		}
+		from, err := requestCurrency(request, "from", app.config.Currency)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		if conversion, err = app.config.convertPrice(amount, from, to); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(conversion, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal price conversion to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// exchangeRatesHandler handles the exchange rates resource request
+func (app *App) exchangeRatesHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	rates := maps.Clone(app.config.ExchangeRates)
+	if rates == nil {
+		rates = make(map[string]float64)
+	}
+	rates[app.config.Currency] = 1
+
+	jsonData, err := json.MarshalIndent(map[string]any{
+		"base":       app.config.Currency,
+		"currencies": slices.Sorted(maps.Keys(rates)),
+		"rates":      rates,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal exchange rates to JSON: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      "currencies://rates",
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
@@ -0,0 +1,223 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// emailFormatMessage describes the expected format of email addresses
+const emailFormatMessage = "must be an email address such as jane@example.com"
+
+// maxCustomerSearchLimit caps the number of customers a single search may return
+const maxCustomerSearchLimit = 100
+
+// ErrCustomerNotFound is returned when no customer has the requested id
+var ErrCustomerNotFound = fmt.Errorf("customer %w", ErrNotFound)
+
+// Customer is a customer placing orders; customers are identified by their email address
+type Customer struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `gorm:"uniqueIndex" json:"email"`
+	Phone     string    `json:"phone,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CustomerSummary is a customer found by search_customers with a summary of their orders
+type CustomerSummary struct {
+	Customer
+	Orders int64 `json:"orders"`
+	// LastOrderAt is the time of the most recent order of the customer, omitted if they have none
+	LastOrderAt *time.Time `json:"last_order_at,omitempty"`
+}
+
+// validEmail reports whether s is a bare email address, without a display name
+func validEmail(s string) bool {
+	address, err := mail.ParseAddress(s)
+	return err == nil && address.Address == s
+}
+
+// validateCustomer checks the fields of a customer to be created
+func validateCustomer(c *Customer) error {
+	var fields []FieldError
+	if c.Name == "" {
+		fields = append(fields, FieldError{Field: "name", Message: "must not be empty"})
+	}
+	if !validEmail(c.Email) {
+		fields = append(fields, FieldError{Field: "email", Message: emailFormatMessage})
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// CreateCustomer inserts a new customer; email addresses must be unique
+func (dbs *DBService) CreateCustomer(ctx context.Context, customer *Customer) error {
+	if err := validateCustomer(customer); err != nil {
+		return err
+	}
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			var existing []Customer
+			if err := tx.Where("email = ?", customer.Email).Limit(1).Find(&existing).Error; err != nil {
+				return err
+			}
+			if len(existing) > 0 {
+				return fmt.Errorf("%w: email %s already belongs to customer %d", ErrConflict, customer.Email, existing[0].ID)
+			}
+
+			if err := tx.Create(customer).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, auditCustomer, customer.ID, auditCreate, nil, customer)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create customer: %w", err)
+	}
+	return nil
+}
+
+// SearchCustomers returns the customers whose name or email contains query, ignoring case,
+// ordered by name, with the number and time of their orders
+func (dbs *DBService) SearchCustomers(ctx context.Context, query string, limit int) ([]CustomerSummary, error) {
+	var customers []CustomerSummary
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		db := dbs.conn(ctx)
+		var found []Customer
+		pattern := "%" + strings.ToLower(query) + "%"
+		err := db.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ?", pattern, pattern).
+			Order("name").Order("id").
+			Limit(limit).
+			Find(&found).Error
+		if err != nil {
+			return err
+		}
+		ids := make([]uint, len(found))
+		for i, c := range found {
+			ids[i] = c.ID
+		}
+
+		// The last order of a customer is that with the highest id; MAX(created_at) would
+		// come back as text from SQLite
+		var counts []struct {
+			CustomerID  uint
+			Orders      int64
+			LastOrderID uint
+		}
+		err = db.Model(&Order{}).
+			Select("customer_id, COUNT(*) AS orders, MAX(id) AS last_order_id").
+			Where("customer_id IN ?", ids).
+			Group("customer_id").
+			Scan(&counts).Error
+		if err != nil {
+			return err
+		}
+		lastIDs := make([]uint, len(counts))
+		for i, c := range counts {
+			lastIDs[i] = c.LastOrderID
+		}
+		var last []Order
+		if err := db.Select("id, customer_id, created_at").Where("id IN ?", lastIDs).Find(&last).Error; err != nil {
+			return err
+		}
+
+		customers = make([]CustomerSummary, len(found))
+		for i, c := range found {
+			customers[i].Customer = c
+			for _, count := range counts {
+				if count.CustomerID == c.ID {
+					customers[i].Orders = count.Orders
+				}
+			}
+			for _, o := range last {
+				if *o.CustomerID == c.ID {
+					customers[i].LastOrderAt = &o.CreatedAt
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search customers: %w", err)
+	}
+	return customers, nil
+}
+
+// findCustomer checks in tx that the customer with the given id exists
+func findCustomer(tx *gorm.DB, id uint) error {
+	var count int64
+	if err := tx.Model(&Customer{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to retrieve customer: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: id %d", ErrCustomerNotFound, id)
+	}
+	return nil
+}
+
+// createCustomerHandler handles the create_customer tool request
+func (app *App) createCustomerHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := request.RequireString("name")
+	if err != nil {
+		return argumentError("name", err), nil
+	}
+	email, err := request.RequireString("email")
+	if err != nil {
+		return argumentError("email", err), nil
+	}
+
+	customer := &Customer{
+		Name:  strings.TrimSpace(name),
+		Email: strings.ToLower(strings.TrimSpace(email)),
+		Phone: strings.TrimSpace(request.GetString("phone", "")),
+	}
+	if err := app.dbService.CreateCustomer(ctx, customer); err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(customer, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal customer to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// searchCustomersHandler handles the search_customers tool request
+func (app *App) searchCustomersHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := request.RequireString("query")
+	if err != nil {
+		return argumentError("query", err), nil
+	}
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return toolErrorResult(invalidField("query", "must not be empty"))
+	}
+	limit := request.GetInt("limit", 20)
+	if limit < 1 || limit > maxCustomerSearchLimit {
+		return toolErrorResult(invalidField("limit", fmt.Sprintf("must be between 1 and %d", maxCustomerSearchLimit)))
+	}
+
+	customers, err := app.dbService.SearchCustomers(ctx, query, limit)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	if err := app.quotas.AddRows(ctx, len(customers)); err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(customers, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal customers to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,113 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+//go:embed dashboard/index.html
+var dashboardFiles embed.FS
+
+// dashboardHandler serves the read-only admin dashboard and its JSON endpoints
+func (app *App) dashboardHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, dashboardFiles, "dashboard/index.html")
+	})
+	mux.HandleFunc("GET /api/products", func(w http.ResponseWriter, r *http.Request) {
+		products, err := app.dbService.GetProducts(r.Context(), ProductQuery{Sort: "id"})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, products)
+	})
+	mux.HandleFunc("GET /api/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, app.stats.Snapshot())
+	})
+	mux.HandleFunc("GET /api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, app.activeSessions.Snapshot())
+	})
+	mux.HandleFunc("GET /api/logs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, app.recentLogs.Snapshot())
+	})
+
+	return app.dashboardAuth(mux)
+}
+
+// dashboardUser is the user name of HTTP basic authentication on the admin dashboard
+const dashboardUser = "admin"
+
+// dashboardAuth requires the admin password, if configured, for every request to next.
+// Client certificates, if required, are checked by the TLS handshake before.
+func (app *App) dashboardAuth(next http.Handler) http.Handler {
+	if app.config.AdminPassword == "" {
+		return next
+	}
+	want := sha256.Sum256([]byte(dashboardUser + ":" + app.config.AdminPassword))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		got := sha256.Sum256([]byte(user + ":" + password))
+		if !ok || subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="mcpserver admin", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("Failed to write dashboard response", "error", err)
+	}
+}
+
+// startDashboard serves the admin dashboard on the configured address until ctx is done.
+// It is served with the TLS configuration of the HTTP transports, client certificates
+// included, and LoadConfig refuses addresses beyond this host without TLS and authentication.
+func (app *App) startDashboard(ctx context.Context) {
+	if app.config.AdminAddr == "" {
+		return
+	}
+
+	srv := &http.Server{
+		Addr:              app.config.AdminAddr,
+		Handler:           app.dashboardHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         app.tlsConfig,
+	}
+
+	listen := srv.ListenAndServe
+	scheme := "http"
+	if app.tlsConfig != nil {
+		// The certificates are in TLSConfig
+		listen = func() error { return srv.ListenAndServeTLS("", "") }
+		scheme = "https"
+	}
+
+	go func() {
+		slog.Info("Serving admin dashboard", "addr", app.config.AdminAddr, "scheme", scheme,
+			"client_certs", app.config.TLSClientCAFile != "", "password", app.config.AdminPassword != "")
+		if err := listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Admin dashboard failed", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+}
@@ -0,0 +1,301 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"mcpserver/storage"
+)
+
+// migrateDataUsage describes the migrate-data subcommand
+const migrateDataUsage = `Usage: mcpserver migrate-data -from <sqlite-file> [-to <postgres-url>]
+
+Copies every row of a SQLite database into an empty PostgreSQL database, after
+bringing the schema of the target up to date, then verifies that the row count
+and checksum of every table match. The copy is made in a single transaction, so
+a failed run leaves the target empty. The target defaults to the configured
+database (DB_PATH).
+
+Flags:
+`
+
+// defaultMigrateDataBatch is the number of rows read and inserted at a time
+const defaultMigrateDataBatch = 500
+
+// TableCopy describes a table copied by MigrateData
+type TableCopy struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	// Checksum is the SHA-256 of the rows in primary key order, as verified in both databases
+	Checksum string `json:"checksum"`
+}
+
+// dataTable copies and checksums the rows of one table
+type dataTable struct {
+	name string
+	// serial is set for tables whose id is generated by a sequence in PostgreSQL
+	serial   bool
+	copy     func(ctx context.Context, src, dst *gorm.DB, batch int) (int64, error)
+	checksum func(ctx context.Context, db *gorm.DB, batch int) (int64, string, error)
+}
+
+// dataTables lists the tables copied by MigrateData in insertion order
+var dataTables = []dataTable{
+	tableOf[Category]("categories", "id", true),
+	tableOf[Supplier]("suppliers", "id", true),
+	tableOf[Tag]("tags", "id", true),
+	tableOf[Product]("products", "id", true),
+	tableOf[ProductTag]("product_tags", "product_id, tag_id", false),
+	tableOf[ProductVariant]("product_variants", "id", true),
+	tableOf[ProductVersion]("product_versions", "id", true),
+	tableOf[PriceChange]("price_changes", "id", true),
+	tableOf[ProductImage]("product_images", "product_id", false),
+	tableOf[AuditEntry]("audit_entries", "id", true),
+	tableOf[Promotion]("promotions", "id", true),
+	tableOf[Customer]("customers", "id", true),
+	tableOf[Order]("orders", "id", true),
+	tableOf[OrderItem]("order_items", "id", true),
+	tableOf[StoredPrompt]("stored_prompts", "id", true),
+	tableOf[SessionState]("session_states", "id", false),
+	tableOf[IdempotencyRecord]("idempotency_records", "tenant, session, tool, key", false),
+}
+
+// tableOf returns the copy and checksum functions of the table of model T, whose rows are
+// paged in the given primary key order, a comma-separated list of columns
+func tableOf[T any](name, order string, serial bool) dataTable {
+	// The columns are quoted, as some, such as key, are reserved words in MySQL
+	var orderBy clause.OrderBy
+	for _, column := range strings.Split(order, ", ") {
+		orderBy.Columns = append(orderBy.Columns, clause.OrderByColumn{Column: clause.Column{Name: column}})
+	}
+
+	// scan pages through every row of the table, soft-deleted ones included
+	scan := func(ctx context.Context, db *gorm.DB, batch int, fn func(rows []T) error) (int64, error) {
+		var n int64
+		for offset := 0; ; offset += batch {
+			var rows []T
+			if err := db.WithContext(ctx).Unscoped().Order(orderBy).Limit(batch).Offset(offset).Find(&rows).Error; err != nil {
+				return n, fmt.Errorf("failed to read table %s: %w", name, err)
+			}
+			if len(rows) == 0 {
+				return n, nil
+			}
+			if err := fn(rows); err != nil {
+				return n, err
+			}
+			n += int64(len(rows))
+		}
+	}
+
+	return dataTable{
+		name:   name,
+		serial: serial,
+		copy: func(ctx context.Context, src, dst *gorm.DB, batch int) (int64, error) {
+			// Hooks would record product versions a second time
+			dst = dst.WithContext(ctx).Session(&gorm.Session{SkipHooks: true})
+			return scan(ctx, src, batch, func(rows []T) error {
+				if err := dst.Create(&rows).Error; err != nil {
+					return fmt.Errorf("failed to write table %s: %w", name, err)
+				}
+				return nil
+			})
+		},
+		checksum: func(ctx context.Context, db *gorm.DB, batch int) (int64, string, error) {
+			h := sha256.New()
+			n, err := scan(ctx, db, batch, func(rows []T) error {
+				for i := range rows {
+					normalizeTimes(reflect.ValueOf(&rows[i]))
+					data, err := json.Marshal(rows[i])
+					if err != nil {
+						return fmt.Errorf("failed to encode row of table %s: %w", name, err)
+					}
+					h.Write(append(data, '\n'))
+				}
+				return nil
+			})
+			return n, hex.EncodeToString(h.Sum(nil)), err
+		},
+	}
+}
+
+// normalizeTimes converts the times reachable through v to UTC at the microsecond
+// precision of PostgreSQL, so that rows read back from either database encode alike
+func normalizeTimes(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			normalizeTimes(v.Elem())
+		}
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			if v.CanSet() {
+				v.Set(reflect.ValueOf(t.UTC().Truncate(time.Microsecond)))
+			}
+			return
+		}
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				normalizeTimes(v.Field(i))
+			}
+		}
+	}
+}
+
+// MigrateData copies every row of the SQLite database at from into the empty PostgreSQL
+// database at to, and verifies the copy. The source must be fully migrated; the schema of
+// the target is brought up to date first.
+func MigrateData(ctx context.Context, cfg *Config, from, to string, batch int) ([]TableCopy, error) {
+	if _, ok := storage.SQLiteFile(from); !ok {
+		return nil, fmt.Errorf("the source of a data migration must be a SQLite file, not %s", storage.Redact(from))
+	}
+	if _, err := os.Stat(from); err != nil {
+		return nil, fmt.Errorf("failed to open source database: %w", err)
+	}
+	dialector, err := storage.Dialector(to, cfg.storageOptions())
+	if err != nil {
+		return nil, err
+	}
+	if dialector.Name() != "postgres" {
+		return nil, fmt.Errorf("the target of a data migration must be a PostgreSQL database, not %s", dialector.Name())
+	}
+	if batch <= 0 {
+		batch = defaultMigrateDataBatch
+	}
+
+	srcOpts := cfg.storageOptions()
+	srcOpts.ReadOnly = true
+	src, err := storage.Open(from, srcOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer NewDBService(src).Close()
+
+	dst, err := storage.Open(to, cfg.storageOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer NewDBService(dst).Close()
+
+	// Both databases must have the same schema
+	if !src.Migrator().HasTable(&SchemaMigration{}) {
+		return nil, fmt.Errorf("%w: the source database has no migration history; run mcpserver -migrate against it first", ErrPendingMigrations)
+	}
+	pending, err := pendingMigrations(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) > 0 {
+		return nil, fmt.Errorf("%w in the source database (%d, starting with %s); run mcpserver -migrate against it first", ErrPendingMigrations, len(pending), pending[0].ID)
+	}
+	if _, err := migrate(dst.WithContext(ctx)); err != nil {
+		return nil, err
+	}
+
+	for _, table := range dataTables {
+		var count int64
+		if err := dst.WithContext(ctx).Table(table.name).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to count rows of target table %s: %w", table.name, err)
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("%w: target table %s already holds %d rows", ErrFailedPrecondition, table.name, count)
+		}
+	}
+
+	err = dst.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, table := range dataTables {
+			start := time.Now()
+			n, err := table.copy(ctx, src, tx, batch)
+			if err != nil {
+				return err
+			}
+			if table.serial {
+				// Rows were inserted with their ids; continue the sequence after them
+				err := tx.Exec("SELECT setval(pg_get_serial_sequence(?, 'id'), COALESCE(MAX(id), 0) + 1, false) FROM "+table.name, table.name).Error
+				if err != nil {
+					return fmt.Errorf("failed to reset id sequence of table %s: %w", table.name, err)
+				}
+			}
+			slog.Info("Copied table", "table", table.name, "rows", n, "duration", time.Since(start))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	copies := make([]TableCopy, 0, len(dataTables))
+	for _, table := range dataTables {
+		srcRows, srcSum, err := table.checksum(ctx, src, batch)
+		if err != nil {
+			return nil, err
+		}
+		dstRows, dstSum, err := table.checksum(ctx, dst, batch)
+		if err != nil {
+			return nil, err
+		}
+		if srcRows != dstRows {
+			return nil, fmt.Errorf("verification of table %s failed: %d rows in the source, %d in the target", table.name, srcRows, dstRows)
+		}
+		if srcSum != dstSum {
+			return nil, fmt.Errorf("verification of table %s failed: checksums differ", table.name)
+		}
+		copies = append(copies, TableCopy{Table: table.name, Rows: srcRows, Checksum: srcSum})
+	}
+	return copies, nil
+}
+
+// RunMigrateData implements the migrate-data subcommand
+func RunMigrateData(args []string) error {
+	fs := flag.NewFlagSet("migrate-data", flag.ContinueOnError)
+	from := fs.String("from", "", "SQLite database file to copy from")
+	to := fs.String("to", "", "PostgreSQL URL to copy into (overrides DB_PATH)")
+	batch := fs.Int("batch", defaultMigrateDataBatch, "Rows read and inserted at a time")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateDataUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" {
+		fs.Usage()
+		return fmt.Errorf("missing -from")
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	SetupLogging(cfg)
+	target := cfg.DBPath
+	if *to != "" {
+		target = *to
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	copies, err := MigrateData(ctx, cfg, *from, target, *batch)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Copied %s into %s:\n", *from, storage.Redact(target))
+	for _, c := range copies {
+		fmt.Printf("  %-22s %8d rows  sha256 %s\n", c.Table, c.Rows, c.Checksum)
+	}
+	return nil
+}
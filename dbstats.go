@@ -0,0 +1,149 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DatabaseStats describes the contents of the database
+type DatabaseStats struct {
+	Driver        string       `json:"driver"`
+	SizeBytes     int64        `json:"size_bytes"`
+	LastMigration string       `json:"last_migration,omitempty"`
+	Tables        []TableStats `json:"tables"`
+	CollectedAt   time.Time    `json:"collected_at"`
+}
+
+// TableStats describes a table and its indexes
+type TableStats struct {
+	Name    string       `json:"name"`
+	Rows    int64        `json:"rows"`
+	Indexes []IndexStats `json:"indexes"`
+}
+
+// IndexStats describes an index. Scans is the number of times the index was used, reported
+// only by backends that track it (PostgreSQL).
+type IndexStats struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+	Scans   *int64   `json:"scans,omitempty"`
+}
+
+// Stats collects row counts, indexes and size of the tables of the database
+func (dbs *DBService) Stats(ctx context.Context) (*DatabaseStats, error) {
+	db := dbs.primary(ctx)
+	stats := &DatabaseStats{
+		Driver:      db.Dialector.Name(),
+		Tables:      []TableStats{},
+		CollectedAt: time.Now().UTC(),
+	}
+
+	size, err := dbs.databaseSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.SizeBytes = size
+
+	if stats.LastMigration, err = dbs.lastMigration(ctx); err != nil {
+		return nil, err
+	}
+
+	scans, err := dbs.indexScans(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := db.Migrator().GetTables()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	for _, table := range tables {
+		ts := TableStats{Name: table, Indexes: []IndexStats{}}
+		if err := db.Table(table).Count(&ts.Rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to count rows of table %q: %w", table, err)
+		}
+
+		indexes, err := db.Migrator().GetIndexes(table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list indexes of table %q: %w", table, err)
+		}
+		for _, index := range indexes {
+			unique, _ := index.Unique()
+			is := IndexStats{Name: index.Name(), Columns: index.Columns(), Unique: unique}
+			if n, ok := scans[index.Name()]; ok {
+				is.Scans = &n
+			}
+			ts.Indexes = append(ts.Indexes, is)
+		}
+		stats.Tables = append(stats.Tables, ts)
+	}
+	return stats, nil
+}
+
+// indexScans returns the number of scans of each index, keyed by index name, where the
+// backend tracks index usage
+func (dbs *DBService) indexScans(ctx context.Context) (map[string]int64, error) {
+	db := dbs.primary(ctx)
+	if db.Dialector.Name() != "postgres" {
+		return nil, nil
+	}
+
+	var rows []struct {
+		IndexRelname string
+		IdxScan      int64
+	}
+	if err := db.Raw("SELECT indexrelname, idx_scan FROM pg_stat_user_indexes").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read index usage: %w", err)
+	}
+	scans := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		scans[row.IndexRelname] = row.IdxScan
+	}
+	return scans, nil
+}
+
+// lastMigration returns the id of the latest applied migration, or an empty id if
+// migrations have never run
+func (dbs *DBService) lastMigration(ctx context.Context) (string, error) {
+	db := dbs.primary(ctx)
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return "", nil
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return "", err
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if applied[migrations[i].ID] {
+			return migrations[i].ID, nil
+		}
+	}
+	return "", nil
+}
+
+// databaseStatsHandler handles the database statistics resource request
+func (app *App) databaseStatsHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	stats, err := app.dbService.Stats(ctx)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+
+	jsonData, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal database statistics to JSON: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      "stats://database",
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
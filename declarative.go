@@ -0,0 +1,325 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// DeclarativeArgument declares an argument of a declarative tool
+type DeclarativeArgument struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type"`
+	Description string `yaml:"description"`
+	Required    bool   `yaml:"required"`
+}
+
+// DeclarativeTool is a tool defined in the tools file. It either runs a parameterized
+// SQL statement, with arguments bound to @name placeholders, or an external command
+// (see subprocess.go).
+type DeclarativeTool struct {
+	Name        string                `yaml:"name"`
+	Description string                `yaml:"description"`
+	Arguments   []DeclarativeArgument `yaml:"arguments"`
+	SQL         string                `yaml:"sql"`
+	Command     []string              `yaml:"command"`
+	// Timeout and MaxOutputBytes limit commands; defaults apply when zero
+	Timeout        time.Duration `yaml:"timeout"`
+	MaxOutputBytes int           `yaml:"max_output_bytes"`
+	// Mutating must be set for statements other than SELECT, which are run on the primary,
+	// and for commands that change state
+	Mutating bool `yaml:"mutating"`
+	// Example holds arguments used by the self-test
+	Example map[string]any `yaml:"example"`
+}
+
+// declarativeToolsFile is the layout of the tools file
+type declarativeToolsFile struct {
+	Tools []DeclarativeTool `yaml:"tools"`
+}
+
+var (
+	toolNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	sqlParamPattern = regexp.MustCompile(`@(\w+)`)
+)
+
+// loadDeclarativeTools reads and validates the tool definitions in path
+func loadDeclarativeTools(path string) ([]DeclarativeTool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tools file: %w", err)
+	}
+
+	var file declarativeToolsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tools file %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range file.Tools {
+		tool := &file.Tools[i]
+		if err := validateDeclarativeTool(tool); err != nil {
+			return nil, fmt.Errorf("invalid tool %q in %s: %w", tool.Name, path, err)
+		}
+		if seen[tool.Name] {
+			return nil, fmt.Errorf("duplicate tool %q in %s", tool.Name, path)
+		}
+		seen[tool.Name] = true
+	}
+	return file.Tools, nil
+}
+
+// validateDeclarativeTool checks the definition of a declarative tool
+func validateDeclarativeTool(tool *DeclarativeTool) error {
+	if !toolNamePattern.MatchString(tool.Name) {
+		return fmt.Errorf("name must match %s", toolNamePattern)
+	}
+	if tool.Description == "" {
+		return fmt.Errorf("description is required")
+	}
+
+	declared := make(map[string]bool)
+	for _, arg := range tool.Arguments {
+		switch arg.Type {
+		case "string", "number", "integer", "boolean":
+		default:
+			return fmt.Errorf("argument %q has unsupported type %q (expected string, number, integer or boolean)", arg.Name, arg.Type)
+		}
+		if arg.Name == outputFormatArg {
+			return fmt.Errorf("argument name %q is reserved", arg.Name)
+		}
+		declared[arg.Name] = true
+	}
+
+	if len(tool.Command) > 0 {
+		if tool.SQL != "" {
+			return fmt.Errorf("sql and command are mutually exclusive")
+		}
+		if tool.Timeout < 0 || tool.MaxOutputBytes < 0 {
+			return fmt.Errorf("timeout and max_output_bytes must not be negative")
+		}
+		return nil
+	}
+
+	if _, err := readOnlyStatement(tool.SQL); err != nil && !tool.Mutating {
+		return fmt.Errorf("sql must be a single SELECT statement unless mutating is set")
+	}
+	for _, match := range sqlParamPattern.FindAllStringSubmatch(tool.SQL, -1) {
+		if !declared[match[1]] {
+			return fmt.Errorf("sql uses undeclared argument @%s", match[1])
+		}
+	}
+	return nil
+}
+
+// namedVars returns the statement variables for named parameters; a statement without
+// parameters must not be given an empty map
+func namedVars(params map[string]any) []any {
+	if len(params) == 0 {
+		return nil
+	}
+	return []any{params}
+}
+
+// QueryRows runs a read-only statement with named parameters and returns the column
+// names in select order and the rows as maps
+func (dbs *DBService) QueryRows(ctx context.Context, statement string, params map[string]any) ([]string, []map[string]any, error) {
+	var columns []string
+	var result []map[string]any
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		var err error
+		columns, result, err = queryRows(dbs.conn(ctx), statement, params)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return columns, result, nil
+}
+
+// queryRows runs a statement with named parameters and scans its rows as maps
+func queryRows(db *gorm.DB, statement string, params map[string]any) ([]string, []map[string]any, error) {
+	rows, err := db.Raw(statement, namedVars(params)...).Rows()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to run query: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read query columns: %w", err)
+	}
+	result := []map[string]any{}
+	for rows.Next() {
+		row := map[string]any{}
+		if err := db.ScanRows(rows, &row); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan query row: %w", err)
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to run query: %w", err)
+	}
+	return columns, result, nil
+}
+
+// ExecStatement runs a statement with named parameters on the primary and returns the number of affected rows
+func (dbs *DBService) ExecStatement(ctx context.Context, statement string, params map[string]any) (int64, error) {
+	var affected int64
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		result := dbs.primary(ctx).Exec(statement, namedVars(params)...)
+		affected = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to run statement: %w", err)
+	}
+	return affected, nil
+}
+
+// addDeclarativeTools registers the tools defined in the tools file once the built-in tools
+// are. A tool named like a registered one would replace it, so it fails instead.
+func (app *App) addDeclarativeTools(s *server.MCPServer) error {
+	registered, err := registeredTools(s)
+	if err != nil {
+		return err
+	}
+	for _, def := range app.declarativeTools {
+		if slices.ContainsFunc(registered, func(tool mcp.Tool) bool { return tool.Name == def.Name }) {
+			return fmt.Errorf("invalid tool %q in %s: a built-in tool has the same name; rename it", def.Name, app.config.ToolsFile)
+		}
+		options := []mcp.ToolOption{mcp.WithDescription(def.Description)}
+		for _, arg := range def.Arguments {
+			props := []mcp.PropertyOption{mcp.Description(arg.Description)}
+			if arg.Required {
+				props = append(props, mcp.Required())
+			}
+			switch arg.Type {
+			case "string":
+				options = append(options, mcp.WithString(arg.Name, props...))
+			case "number", "integer":
+				options = append(options, mcp.WithNumber(arg.Name, props...))
+			case "boolean":
+				options = append(options, mcp.WithBoolean(arg.Name, props...))
+			}
+		}
+
+		if def.Mutating {
+			options = append(options, writeTool(false))
+		} else {
+			options = append(options, readOnlyTool())
+			if len(def.Command) == 0 {
+				options = append(options, withOutputFormat())
+			}
+		}
+		handler := app.declarativeToolHandler(def)
+		if len(def.Command) > 0 {
+			handler = app.commandToolHandler(def)
+			// Commands run outside the server
+			options = append(options, mcp.WithOpenWorldHintAnnotation(true))
+		}
+		s.AddTool(mcp.NewTool(def.Name, options...), handler)
+	}
+	return nil
+}
+
+// declarativeArgs binds the arguments of a request to the parameters of a declarative tool
+func declarativeArgs(def DeclarativeTool, request mcp.CallToolRequest) (map[string]any, error) {
+	args := request.GetArguments()
+	params := make(map[string]any, len(def.Arguments))
+	var fields []FieldError
+	for _, arg := range def.Arguments {
+		value, ok := args[arg.Name]
+		if !ok || value == nil {
+			if arg.Required {
+				fields = append(fields, FieldError{Field: arg.Name, Message: "is required"})
+			}
+			params[arg.Name] = nil
+			continue
+		}
+
+		valid := false
+		switch arg.Type {
+		case "string":
+			_, valid = value.(string)
+		case "number":
+			_, valid = value.(float64)
+		case "integer":
+			if f, ok := value.(float64); ok && f == math.Trunc(f) {
+				value, valid = int64(f), true
+			}
+		case "boolean":
+			_, valid = value.(bool)
+		}
+		if !valid {
+			fields = append(fields, FieldError{Field: arg.Name, Message: "must be of type " + arg.Type})
+			continue
+		}
+		params[arg.Name] = value
+	}
+
+	if len(fields) > 0 {
+		return nil, &ValidationError{Fields: fields}
+	}
+	return params, nil
+}
+
+// declarativeToolHandler returns the handler running the statement of a declarative tool
+func (app *App) declarativeToolHandler(def DeclarativeTool) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		params, err := declarativeArgs(def, request)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+
+		if def.Mutating {
+			affected, err := app.dbService.ExecStatement(ctx, def.SQL, params)
+			if err != nil {
+				return toolErrorResult(err)
+			}
+			jsonData, err := json.MarshalIndent(map[string]int64{"rows_affected": affected}, "", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal %s result to JSON: %w", def.Name, err)
+			}
+			return mcp.NewToolResultText(string(jsonData)), nil
+		}
+
+		format, err := app.outputFormat(ctx, request)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+
+		columns, rows, err := app.dbService.QueryRows(ctx, def.SQL, params)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		// Statements cannot be paged, so rows past the limits are dropped without a cursor
+		limits := app.config.Results
+		total := len(rows)
+		if n := limits.fetchLimit(0); n > 0 && total > n {
+			rows = rows[:n]
+		}
+		render := renderJSON(func(page []map[string]any) any { return page })
+		if format == formatMarkdown {
+			render = func(page []map[string]any) ([]byte, error) { return []byte(markdownTable(columns, page)), nil }
+		}
+		data, n, truncation, err := limitResult(limits, rows, 0, total, 0, false, render)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		if err := app.quotas.AddRows(ctx, n); err != nil {
+			return toolErrorResult(err)
+		}
+		return truncatedToolResult(string(data), truncation)
+	}
+}
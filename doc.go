@@ -0,0 +1,35 @@
+// Package mcpserver implements the demo product catalog MCP server. The mcpserver
+// command serves it over stdio and the network transports; other Go programs can embed
+// it and drive it in-process, for example in tests:
+//
+//	cfg, err := mcpserver.LoadConfig()
+//	if err != nil {
+//		return err
+//	}
+//	db, err := mcpserver.InitializeDatabase(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	dbService := mcpserver.NewDBService(db)
+//	defer dbService.Close()
+//
+//	app, err := mcpserver.NewApp(cfg, dbService)
+//	if err != nil {
+//		return err
+//	}
+//	app.Start(ctx)
+//
+//	s, err := app.NewServer()
+//	if err != nil {
+//		return err
+//	}
+//	c, err := client.NewInProcessClient(s)
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//
+// The client comes from github.com/mark3labs/mcp-go/client and must be started and
+// initialized before use. Run serves the application over the given transports until
+// SIGINT or SIGTERM, as the command does.
+package mcpserver
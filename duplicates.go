@@ -0,0 +1,428 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bounds of the near-identical name comparison of find_duplicates
+const (
+	maxDuplicateDistance = 3
+	// minFuzzyNameLength is the length below which names only match when identical
+	minFuzzyNameLength = 5
+	// maxFuzzyProducts caps the catalog size up to which names are compared pairwise; larger
+	// catalogs only get identical names matched
+	maxFuzzyProducts = 5000
+)
+
+// duplicateFields lists the product fields find_duplicates compares
+var duplicateFields = []string{"code", "name"}
+
+// DuplicateCandidate is a product of a group of likely duplicates
+type DuplicateCandidate struct {
+	ID    uint    `json:"id"`
+	Code  string  `json:"code"`
+	Name  string  `json:"name,omitempty"`
+	Price float64 `json:"price"`
+	Stock int     `json:"stock"`
+}
+
+// DuplicateGroup is a set of products that are likely duplicates of each other, with the
+// merge_products call merging them into the oldest one
+type DuplicateGroup struct {
+	Products []DuplicateCandidate `json:"products"`
+	// Matches lists the fields on which products of the group matched
+	Matches  []string `json:"matches"`
+	KeepID   uint     `json:"keep_id"`
+	MergeIDs []uint   `json:"merge_ids"`
+	Merge    string   `json:"merge"`
+}
+
+// DuplicateReport is the result of the find_duplicates tool
+type DuplicateReport struct {
+	CheckedProducts int              `json:"checked_products"`
+	Groups          []DuplicateGroup `json:"groups"`
+	// FuzzySkipped is set when the catalog is too large for near-identical names to be matched
+	FuzzySkipped bool `json:"fuzzy_skipped,omitempty"`
+}
+
+// normalizeForComparison lower-cases s and drops everything but letters and digits, so that
+// "D-42" and "d42" compare equal
+func normalizeForComparison(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// editDistance returns the Levenshtein distance between a and b, or max+1 if it exceeds max
+func editDistance(a, b []rune, max int) int {
+	if d := len(a) - len(b); d > max || -d > max {
+		return max + 1
+	}
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// duplicateGroups groups products whose normalized codes are identical, or whose normalized
+// names are within maxDistance edits of each other, for the given fields. Codes are never
+// matched approximately: close codes such as D42 and D43 usually name different products.
+// It reports whether names were only matched when identical because of the catalog size, and
+// stops with the error of ctx once it is cancelled.
+func duplicateGroups(ctx context.Context, products []Product, fields []string, maxDistance int) ([]DuplicateGroup, bool, error) {
+	parent := make([]int, len(products))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	matches := make(map[int]map[string]bool)
+	union := func(i, j int, field string) {
+		ri, rj := find(i), find(j)
+		if ri != rj {
+			parent[rj] = ri
+			for f := range matches[rj] {
+				if matches[ri] == nil {
+					matches[ri] = make(map[string]bool)
+				}
+				matches[ri][f] = true
+			}
+			delete(matches, rj)
+		}
+		if matches[ri] == nil {
+			matches[ri] = make(map[string]bool)
+		}
+		matches[ri][field] = true
+	}
+
+	fuzzySkipped := false
+	for _, field := range fields {
+		values := make([]string, len(products))
+		first := make(map[string]int)
+		for i, p := range products {
+			value := p.Code
+			if field == "name" {
+				value = p.Name
+			}
+			values[i] = normalizeForComparison(value)
+			if values[i] == "" {
+				continue
+			}
+			if j, ok := first[values[i]]; ok {
+				union(j, i, field)
+			} else {
+				first[values[i]] = i
+			}
+		}
+		if field != "name" || maxDistance == 0 {
+			continue
+		}
+		if len(products) > maxFuzzyProducts {
+			fuzzySkipped = true
+			continue
+		}
+
+		// Compare each distinct name once with the other distinct names
+		var distinct [][]rune
+		var owners []int
+		for value, i := range first {
+			if len([]rune(value)) >= minFuzzyNameLength {
+				distinct = append(distinct, []rune(value))
+				owners = append(owners, i)
+			}
+		}
+		for a := range distinct {
+			if err := ctx.Err(); err != nil {
+				return nil, false, err
+			}
+			for b := a + 1; b < len(distinct); b++ {
+				if editDistance(distinct[a], distinct[b], maxDistance) <= maxDistance {
+					union(owners[a], owners[b], field)
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	for i := range products {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+	groups := []DuplicateGroup{}
+	for root, indexes := range members {
+		if len(indexes) < 2 {
+			continue
+		}
+		group := DuplicateGroup{}
+		for _, i := range indexes {
+			p := products[i]
+			group.Products = append(group.Products, DuplicateCandidate{ID: p.ID, Code: p.Code, Name: p.Name, Price: p.Price, Stock: p.Stock})
+		}
+		slices.SortFunc(group.Products, func(a, b DuplicateCandidate) int { return int(a.ID) - int(b.ID) })
+		for _, field := range fields {
+			if matches[root][field] {
+				group.Matches = append(group.Matches, field)
+			}
+		}
+		group.KeepID = group.Products[0].ID
+		for _, p := range group.Products[1:] {
+			group.MergeIDs = append(group.MergeIDs, p.ID)
+		}
+		ids, _ := json.Marshal(group.MergeIDs)
+		group.Merge = fmt.Sprintf(`merge_products {"keep_id": %d, "merge_ids": %s}`, group.KeepID, ids)
+		groups = append(groups, group)
+	}
+	slices.SortFunc(groups, func(a, b DuplicateGroup) int { return int(a.KeepID) - int(b.KeepID) })
+	return groups, fuzzySkipped, nil
+}
+
+// FindDuplicates returns the groups of likely duplicate products, deleted products excluded
+func (dbs *DBService) FindDuplicates(ctx context.Context, fields []string, maxDistance int) (*DuplicateReport, error) {
+	var products []Product
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.conn(ctx).Order("id").Find(&products).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve products: %w", err)
+	}
+	groups, fuzzySkipped, err := duplicateGroups(ctx, products, fields, maxDistance)
+	if err != nil {
+		return nil, err
+	}
+	return &DuplicateReport{CheckedProducts: len(products), Groups: groups, FuzzySkipped: fuzzySkipped}, nil
+}
+
+// MergeResult reports the merge previewed or made by merge_products
+type MergeResult struct {
+	KeepID   uint   `json:"keep_id"`
+	MergeIDs []uint `json:"merge_ids"`
+	// Stock is the stock of the kept product after the merge
+	Stock             int        `json:"stock"`
+	Confirmed         bool       `json:"confirmed"`
+	ConfirmationToken string     `json:"confirmation_token,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	Message           string     `json:"message"`
+}
+
+// mergeCandidates returns the product to keep and the products to merge into it, all of which
+// must exist and not be deleted
+func mergeCandidates(tx *gorm.DB, keepID uint, mergeIDs []uint) (Product, []Product, error) {
+	var keep []Product
+	if err := tx.Limit(1).Find(&keep, keepID).Error; err != nil {
+		return Product{}, nil, fmt.Errorf("failed to retrieve product: %w", err)
+	}
+	if len(keep) == 0 {
+		return Product{}, nil, fmt.Errorf("%w: id %d", ErrProductNotFound, keepID)
+	}
+	var merged []Product
+	if err := tx.Order("id").Find(&merged, mergeIDs).Error; err != nil {
+		return Product{}, nil, fmt.Errorf("failed to retrieve products: %w", err)
+	}
+	for _, id := range mergeIDs {
+		if !slices.ContainsFunc(merged, func(p Product) bool { return p.ID == id }) {
+			return Product{}, nil, fmt.Errorf("%w: id %d", ErrProductNotFound, id)
+		}
+	}
+	return keep[0], merged, nil
+}
+
+// mergedStock returns the stock of keep once the stock of merged is added to it
+func mergedStock(keep Product, merged []Product) int {
+	stock := keep.Stock
+	for _, p := range merged {
+		stock += p.Stock
+	}
+	return stock
+}
+
+// PreviewMergeProducts checks that the products can be merged and returns the stock the kept
+// product would have
+func (dbs *DBService) PreviewMergeProducts(ctx context.Context, keepID uint, mergeIDs []uint) (int, error) {
+	var stock int
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		keep, merged, err := mergeCandidates(dbs.primary(ctx).Session(&gorm.Session{}), keepID, mergeIDs)
+		stock = mergedStock(keep, merged)
+		return err
+	})
+	return stock, err
+}
+
+// MergeProducts merges products into the product keepID in a single transaction: their stock
+// is added to it, their tags are copied to it and their variants moved to it, then they are
+// soft-deleted. Their history, price history and images stay with them.
+func (dbs *DBService) MergeProducts(ctx context.Context, keepID uint, mergeIDs []uint) (*Product, error) {
+	var keep Product
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			var merged []Product
+			var err error
+			if keep, merged, err = mergeCandidates(tx, keepID, mergeIDs); err != nil {
+				return err
+			}
+
+			keep.Stock = mergedStock(keep, merged)
+			if err := tx.Save(&keep).Error; err != nil {
+				return fmt.Errorf("failed to update product: %w", err)
+			}
+
+			var tagIDs []uint
+			if err := tx.Model(&ProductTag{}).Where("product_id IN ?", mergeIDs).Distinct().Pluck("tag_id", &tagIDs).Error; err != nil {
+				return fmt.Errorf("failed to retrieve product tags: %w", err)
+			}
+			if len(tagIDs) > 0 {
+				links := make([]ProductTag, len(tagIDs))
+				for i, tagID := range tagIDs {
+					links[i] = ProductTag{ProductID: keepID, TagID: tagID}
+				}
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+					return fmt.Errorf("failed to copy product tags: %w", err)
+				}
+			}
+
+			err = tx.Model(&ProductVariant{}).Where("product_id IN ?", mergeIDs).Update("product_id", keepID).Error
+			if uniqueViolation(err) {
+				return fmt.Errorf("%w: products %d and %v have variants of the same size and color; change or remove one of them first", ErrConflict, keepID, mergeIDs)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to move product variants: %w", err)
+			}
+
+			if err := tx.Where("id IN ?", mergeIDs).Delete(&Product{}).Error; err != nil {
+				return fmt.Errorf("failed to delete merged products: %w", err)
+			}
+			if err := recordProductDeletions(tx, false, merged...); err != nil {
+				return err
+			}
+			return recordProductVersions(tx, true, time.Now(), merged...)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &keep, nil
+}
+
+// findDuplicatesHandler handles the find_duplicates tool request
+func (app *App) findDuplicatesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	fields := request.GetStringSlice("fields", duplicateFields)
+	for _, field := range fields {
+		if !slices.Contains(duplicateFields, field) {
+			return toolErrorResult(invalidField("fields", fmt.Sprintf("unknown field %q; expected code or name", field)))
+		}
+	}
+	maxDistance := request.GetInt("max_distance", 1)
+	if maxDistance < 0 || maxDistance > maxDuplicateDistance {
+		return toolErrorResult(invalidField("max_distance", fmt.Sprintf("must be between 0 and %d", maxDuplicateDistance)))
+	}
+
+	report, err := app.dbService.FindDuplicates(ctx, fields, maxDistance)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal duplicate report to JSON: %w", err)
+	}
+	result := mcp.NewToolResultText(string(jsonData))
+	for _, group := range report.Groups {
+		for _, p := range group.Products {
+			result.Content = append(result.Content, productLink(ctx, p.ID, p.Code, p.Name))
+		}
+	}
+	return result, nil
+}
+
+// mergeProductsHandler handles the merge_products tool request. Without a confirmation token
+// it only previews the merge and issues a token; with one it merges the products.
+func (app *App) mergeProductsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	keepID, err := request.RequireInt("keep_id")
+	if err != nil {
+		return argumentError("keep_id", err), nil
+	}
+	if keepID <= 0 {
+		return toolErrorResult(invalidField("keep_id", "must be a positive integer"))
+	}
+	ids, err := request.RequireIntSlice("merge_ids")
+	if err != nil {
+		return argumentError("merge_ids", err), nil
+	}
+	if len(ids) == 0 {
+		return toolErrorResult(invalidField("merge_ids", "must list at least one product"))
+	}
+	mergeIDs := make([]uint, len(ids))
+	for i, id := range ids {
+		if id <= 0 || id == keepID || slices.Contains(ids[:i], id) {
+			return toolErrorResult(invalidField("merge_ids", "must be distinct positive product ids other than keep_id"))
+		}
+		mergeIDs[i] = uint(id)
+	}
+
+	result := MergeResult{KeepID: uint(keepID), MergeIDs: mergeIDs}
+	if token := request.GetString(confirmationTokenArg, ""); token == "" {
+		if result.Stock, err = app.dbService.PreviewMergeProducts(ctx, uint(keepID), mergeIDs); err != nil {
+			return toolErrorResult(err)
+		}
+		token, expiresAt, err := app.confirmations.issue(ctx, request.Params.Name, request.GetArguments(), mergeIDs)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		result.ConfirmationToken = token
+		result.ExpiresAt = &expiresAt
+		result.Message = "Preview only; call again with the same arguments and this confirmation_token to merge these products"
+	} else {
+		if _, err := app.confirmations.redeem(ctx, request.Params.Name, request.GetArguments(), token); err != nil {
+			return toolErrorResult(err)
+		}
+		product, err := app.dbService.MergeProducts(ctx, uint(keepID), mergeIDs)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		result.Stock = product.Stock
+		result.Confirmed = true
+		result.Message = fmt.Sprintf("Merged %d products into product %d; restore_product brings a merged product back", len(mergeIDs), keepID)
+	}
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal merge result to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
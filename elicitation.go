@@ -0,0 +1,263 @@
+package mcpserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// methodElicitationCreate is the request asking the client to collect input from the user,
+// which the MCP library cannot send
+const methodElicitationCreate = "elicitation/create"
+
+// elicitationTimeout bounds the wait for the user to answer an elicitation request
+const elicitationTimeout = 2 * time.Minute
+
+// clientRequestIDField is the params field carrying the id of a request to the client from
+// the notification sending it to the error mapper, which turns it into a request
+const clientRequestIDField = "mcpserver/requestId"
+
+// clientRequestIDPrefix starts the ids of the requests sent to clients, which are strings so
+// that they never collide with the numeric ids of the sampling requests of the library
+const clientRequestIDPrefix = "mcpserver-"
+
+// elicitationAccept is the action of a user submitting the requested values, who may also
+// decline or cancel
+const elicitationAccept = "accept"
+
+// ElicitationResult is the answer of the client to an elicitation request
+type ElicitationResult struct {
+	Action  string         `json:"action"`
+	Content map[string]any `json:"content,omitempty"`
+}
+
+// clientResponse is the response of the client to a request sent to it
+type clientResponse struct {
+	result json.RawMessage
+	err    error
+}
+
+// pendingRequest is a request sent to the client of a session, waiting for its response
+type pendingRequest struct {
+	session   string
+	responses chan clientResponse
+}
+
+// ClientRequests sends clients the requests the library has no method for. A request is
+// sent as a notification carrying its id, which the error mapper turns into a request on its
+// way out; the transports hand the responses to deliver before the library sees them.
+type ClientRequests struct {
+	mu      sync.Mutex
+	next    int64
+	pending map[string]pendingRequest
+}
+
+// NewClientRequests creates a registry without pending requests
+func NewClientRequests() *ClientRequests {
+	return &ClientRequests{pending: make(map[string]pendingRequest)}
+}
+
+// request sends a request to the client of the session of ctx and waits for its result
+func (cr *ClientRequests) request(ctx context.Context, s *server.MCPServer, method string, params map[string]any) (json.RawMessage, error) {
+	cr.mu.Lock()
+	cr.next++
+	id := clientRequestIDPrefix + strconv.FormatInt(cr.next, 10)
+	responses := make(chan clientResponse, 1)
+	cr.pending[id] = pendingRequest{session: sessionID(ctx), responses: responses}
+	cr.mu.Unlock()
+	defer func() {
+		cr.mu.Lock()
+		delete(cr.pending, id)
+		cr.mu.Unlock()
+	}()
+
+	params[clientRequestIDField] = id
+	if err := s.SendNotificationToClient(ctx, method, params); err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", method, err)
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case response := <-responses:
+		return response.result, response.err
+	}
+}
+
+// idle reports whether no request waits for a response
+func (cr *ClientRequests) idle() bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	return len(cr.pending) == 0
+}
+
+// deliver hands a response of the client of session to the request waiting for it; it
+// reports whether message was such a response. Responses only reach requests sent to the
+// same session.
+func (cr *ClientRequests) deliver(session string, message []byte) bool {
+	if !bytes.Contains(message, []byte(clientRequestIDPrefix)) {
+		return false
+	}
+	var response struct {
+		ID     any             `json:"id"`
+		Method string          `json:"method"`
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(message, &response); err != nil || response.Method != "" {
+		return false
+	}
+	id, ok := response.ID.(string)
+	if !ok || (response.Result == nil && response.Error == nil) {
+		return false
+	}
+
+	cr.mu.Lock()
+	pending, ok := cr.pending[id]
+	cr.mu.Unlock()
+	if !ok || pending.session != session {
+		return false
+	}
+	delivered := clientResponse{result: response.Result}
+	if response.Error != nil {
+		delivered.err = fmt.Errorf("the client failed the request: %s (code %d)", response.Error.Message, response.Error.Code)
+	}
+	select {
+	case pending.responses <- delivered:
+	default:
+	}
+	return true
+}
+
+// Reader returns a reader of the messages of r, one per line, without the responses to
+// requests sent to the client of the stdio session, which are delivered instead
+func (cr *ClientRequests) Reader(r io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		lines := bufio.NewReader(r)
+		for {
+			line, err := lines.ReadBytes('\n')
+			if len(line) > 0 && !cr.deliver(stdioSessionID, line) {
+				if _, err := pw.Write(line); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+// deliverHTTP delivers a response posted by a client of an HTTP transport and accepts it;
+// it reports whether r was such a response, leaving other requests to be served
+func (cr *ClientRequests) deliverHTTP(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost || cr.idle() {
+		return false
+	}
+	// Streamable HTTP clients send their session in a header, SSE clients in the query
+	session := r.Header.Get(server.HeaderKeySessionID)
+	if session == "" {
+		session = r.URL.Query().Get("sessionId")
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil || !cr.deliver(session, body) {
+		return false
+	}
+	w.WriteHeader(http.StatusAccepted)
+	return true
+}
+
+// clientRequestMessage turns a notification carrying the id of a request to the client into
+// that request; ok is false for other messages
+func clientRequestMessage(message []byte) ([]byte, bool) {
+	if !bytes.Contains(message, []byte(clientRequestIDField)) {
+		return nil, false
+	}
+	var notification struct {
+		JSONRPC string                     `json:"jsonrpc"`
+		Method  string                     `json:"method"`
+		Params  map[string]json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(message, &notification); err != nil || notification.Method == "" {
+		return nil, false
+	}
+	id, ok := notification.Params[clientRequestIDField]
+	if !ok {
+		return nil, false
+	}
+	delete(notification.Params, clientRequestIDField)
+
+	request, err := json.Marshal(struct {
+		JSONRPC string                     `json:"jsonrpc"`
+		ID      json.RawMessage            `json:"id"`
+		Method  string                     `json:"method"`
+		Params  map[string]json.RawMessage `json:"params"`
+	}{notification.JSONRPC, id, notification.Method, notification.Params})
+	if err != nil {
+		return nil, false
+	}
+	return request, true
+}
+
+// elicit asks the user, through the client of the session of ctx, for the values described
+// by schema, a flat JSON object schema
+func (app *App) elicit(ctx context.Context, message string, schema map[string]any) (*ElicitationResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, elicitationTimeout)
+	defer cancel()
+	raw, err := app.clientRequests.request(ctx, app.server, methodElicitationCreate, map[string]any{
+		"message":         message,
+		"requestedSchema": schema,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result ElicitationResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("invalid %s result: %w", methodElicitationCreate, err)
+	}
+	return &result, nil
+}
+
+// elicitPrice asks the user for the missing price of the product to create with the given
+// code; an error reports a user declining or failing to answer
+func (app *App) elicitPrice(ctx context.Context, code string) (float64, error) {
+	result, err := app.elicit(ctx, fmt.Sprintf("No price was given for the new product %s. Enter its price.", code), map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"price": map[string]any{
+				"type":        "number",
+				"title":       "Price",
+				"description": fmt.Sprintf("Price of product %s", code),
+				"minimum":     0,
+			},
+		},
+		"required": []string{"price"},
+	})
+	if err != nil {
+		return 0, invalidField("price", fmt.Sprintf("is required and was not provided by the user: %v", err))
+	}
+	if result.Action != elicitationAccept {
+		return 0, invalidField("price", fmt.Sprintf("is required and the user chose to %s", strings.ToLower(result.Action)))
+	}
+	price, ok := result.Content["price"].(float64)
+	if !ok {
+		return 0, invalidField("price", "is required and the user did not enter a number")
+	}
+	return price, nil
+}
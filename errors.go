@@ -0,0 +1,535 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/mattn/go-sqlite3"
+)
+
+// JSON-RPC error codes used for infrastructure failures. Values in the
+// -32000 to -32099 range are reserved for implementation-defined server errors.
+const (
+	ErrCodeDatabaseUnavailable = -32001
+	ErrCodeDatabaseBusy        = -32002
+	ErrCodeTimeout             = -32003
+	ErrCodeRequestCancelled    = -32800
+)
+
+// InfraError is an infrastructure failure (database down, timeout) that is
+// reported to the client as a JSON-RPC error rather than as a tool result
+type InfraError struct {
+	Code      int
+	Message   string
+	Retryable bool
+	Err       error
+}
+
+// Error implements the error interface
+func (e *InfraError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *InfraError) Unwrap() error {
+	return e.Err
+}
+
+// MySQL server error numbers that indicate a transient condition
+const (
+	mysqlErrTooManyConnections = 1040
+	mysqlErrLockWaitTimeout    = 1205
+	mysqlErrDeadlock           = 1213
+)
+
+// Error codes of unique constraint violations in MySQL and PostgreSQL
+const (
+	mysqlErrDuplicateEntry = 1062
+	pgUniqueViolation      = "23505"
+)
+
+// uniqueViolation reports whether err is the violation of a unique constraint. Writes check
+// their unique values first; this catches concurrent writes that slip past the check.
+func uniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDuplicateEntry
+	}
+	// The PostgreSQL driver is not imported here; its errors expose their SQLSTATE
+	var pgErr interface{ SQLState() string }
+	return errors.As(err, &pgErr) && pgErr.SQLState() == pgUniqueViolation
+}
+
+// classifyError returns the infrastructure error err represents, or nil if err
+// is a domain error that should be reported as a tool error
+func classifyError(err error) *InfraError {
+	var infraErr *InfraError
+	if errors.As(err, &infraErr) {
+		return infraErr
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &InfraError{Code: ErrCodeTimeout, Message: "operation timed out", Retryable: true, Err: err}
+	case errors.Is(err, context.Canceled):
+		return &InfraError{Code: ErrCodeRequestCancelled, Message: "request cancelled", Err: err}
+	case errors.Is(err, sql.ErrConnDone), errors.Is(err, driver.ErrBadConn):
+		return &InfraError{Code: ErrCodeDatabaseUnavailable, Message: "database connection lost", Retryable: true, Err: err}
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code {
+		case sqlite3.ErrBusy, sqlite3.ErrLocked:
+			return &InfraError{Code: ErrCodeDatabaseBusy, Message: "database is busy", Retryable: true, Err: err}
+		case sqlite3.ErrCantOpen, sqlite3.ErrIoErr, sqlite3.ErrFull:
+			return &InfraError{Code: ErrCodeDatabaseUnavailable, Message: "database unavailable", Retryable: true, Err: err}
+		case sqlite3.ErrCorrupt, sqlite3.ErrNotADB:
+			return &InfraError{Code: ErrCodeDatabaseUnavailable, Message: "database is corrupt", Err: err}
+		}
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlErrLockWaitTimeout, mysqlErrDeadlock:
+			return &InfraError{Code: ErrCodeDatabaseBusy, Message: "database is busy", Retryable: true, Err: err}
+		case mysqlErrTooManyConnections:
+			return &InfraError{Code: ErrCodeDatabaseUnavailable, Message: "database unavailable", Retryable: true, Err: err}
+		}
+	}
+	if errors.Is(err, mysql.ErrInvalidConn) {
+		return &InfraError{Code: ErrCodeDatabaseUnavailable, Message: "database connection lost", Retryable: true, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return &InfraError{Code: ErrCodeDatabaseUnavailable, Message: "database unreachable", Retryable: true, Err: err}
+	}
+
+	return nil
+}
+
+// Codes used in the error envelope of tool results
+const (
+	CodeInvalidArgument    = "invalid_argument"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodeQuotaExceeded      = "quota_exceeded"
+	CodeUnsupported        = "unsupported"
+	CodeFailedPrecondition = "failed_precondition"
+	CodeQueryTimeout       = "query_timeout"
+	CodeInternal           = "internal"
+)
+
+// Sentinel domain errors; service methods wrap these so handlers can pick an error code
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrConflict      = errors.New("conflict")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrUnsupported   = errors.New("not supported")
+	// ErrFailedPrecondition is returned when the system is not in the state an operation requires
+	ErrFailedPrecondition = errors.New("failed precondition")
+	// ErrQueryTimeout is returned when a query runs longer than the configured query timeout
+	ErrQueryTimeout = errors.New("query timed out")
+)
+
+// errorDocsURIPrefix is the resource template serving remediation hints per error code
+const errorDocsURIPrefix = "docs://errors/"
+
+// errorCodeDocs describes each error code and how a client can recover from it
+var errorCodeDocs = map[string]struct {
+	Summary     string
+	Remediation string
+}{
+	CodeInvalidArgument:    {"An argument is missing or has an invalid value.", "Fix the arguments listed in `fields` and call the tool again."},
+	CodeNotFound:           {"The referenced record does not exist.", "Check the id or code, for example by reading products://list, before retrying."},
+	CodeConflict:           {"The request conflicts with existing data or an earlier request.", "Use a new idempotency key or change the conflicting values."},
+	CodeQuotaExceeded:      {"The session has used up its quota.", "Read quota://status to see the remaining budget; start a new session or ask an operator to raise the limits."},
+	CodeUnsupported:        {"The operation is not supported by this server or database backend.", "Do not retry; use a different operation."},
+	CodeFailedPrecondition: {"The system is not in a state required for the operation.", "Resolve the reported condition before retrying."},
+	CodeQueryTimeout:       {"A database query took longer than the server allows.", "Narrow the request, for example with a filter or a smaller limit, or retry later when the database is less busy."},
+	CodeInternal:           {"The server failed to process the request.", "Retry later; if the problem persists, report it to the operator."},
+}
+
+// FieldError describes a problem with a single argument
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError reports one or more invalid fields
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "invalid arguments: " + strings.Join(msgs, "; ")
+}
+
+// invalidField returns a validation error for a single field
+func invalidField(field, message string) error {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+// ToolError is the JSON envelope returned as the content of every tool error result
+type ToolError struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	Retryable bool         `json:"retryable"`
+	DocsURI   string       `json:"docs_uri"`
+}
+
+// newToolError builds a tool error result carrying the JSON error envelope
+func newToolError(code, message string, fields ...FieldError) *mcp.CallToolResult {
+	envelope := ToolError{
+		Code:      code,
+		Message:   message,
+		Fields:    fields,
+		Retryable: code == CodeInternal || code == CodeQueryTimeout,
+		DocsURI:   errorDocsURIPrefix + code,
+	}
+	jsonData, err := json.Marshal(envelope)
+	if err != nil {
+		return mcp.NewToolResultError(message)
+	}
+	return mcp.NewToolResultError(string(jsonData))
+}
+
+// argumentError builds an invalid_argument tool error for a missing or malformed argument
+func argumentError(field string, err error) *mcp.CallToolResult {
+	return newToolError(CodeInvalidArgument, err.Error(), FieldError{Field: field, Message: err.Error()})
+}
+
+// toolErrorResult converts err into the response of a tool handler: domain
+// errors become tool error results, infrastructure errors become protocol errors
+func toolErrorResult(err error) (*mcp.CallToolResult, error) {
+	if infraErr := classifyError(err); infraErr != nil {
+		return nil, infraErr
+	}
+
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return newToolError(CodeInvalidArgument, err.Error(), validationErr.Fields...), nil
+	case errors.Is(err, ErrNotFound):
+		return newToolError(CodeNotFound, err.Error()), nil
+	case errors.Is(err, ErrConflict):
+		return newToolError(CodeConflict, err.Error()), nil
+	case uniqueViolation(err):
+		return newToolError(CodeConflict, "a row with the same unique value was written concurrently; retry the call"), nil
+	case errors.Is(err, ErrQuotaExceeded):
+		return newToolError(CodeQuotaExceeded, err.Error()), nil
+	case errors.Is(err, ErrUnsupported):
+		return newToolError(CodeUnsupported, err.Error()), nil
+	case errors.Is(err, ErrFailedPrecondition):
+		return newToolError(CodeFailedPrecondition, err.Error()), nil
+	case errors.Is(err, ErrQueryTimeout):
+		return newToolError(CodeQueryTimeout, err.Error()), nil
+	default:
+		return newToolError(CodeInternal, err.Error()), nil
+	}
+}
+
+// errorDocsHandler handles the error documentation resource template
+func (app *App) errorDocsHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	code := strings.TrimPrefix(request.Params.URI, errorDocsURIPrefix)
+	doc, ok := errorCodeDocs[code]
+	if !ok {
+		return nil, fmt.Errorf("unknown error code %q", code)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "text/markdown",
+			Text:     fmt.Sprintf("# %s\n\n%s\n\n**Remediation:** %s\n", code, doc.Summary, doc.Remediation),
+		},
+	}, nil
+}
+
+// resourceError classifies an error returned by a resource handler; invalid
+// URI parameters are reported as INVALID_PARAMS rather than INTERNAL_ERROR
+func resourceError(err error) error {
+	if infraErr := classifyError(err); infraErr != nil {
+		return infraErr
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return &InfraError{Code: mcp.INVALID_PARAMS, Message: err.Error()}
+	}
+	return err
+}
+
+// rpcErrorMapper assigns the codes and retryability hints of infrastructure
+// errors to the JSON-RPC error responses written to the client. mcp-go always
+// reports handler errors as INTERNAL_ERROR, so failed requests are recorded by
+// session and id through the OnError hook and rewritten on their way out. Requests
+// for methods the library does not know but the server handles in a hook are
+// answered, their error response being replaced by the result of the hook. Tool
+// results and tool lists also get the structured content and output schemas the
+// library has no fields for (see outputschema.go).
+type rpcErrorMapper struct {
+	mu         sync.Mutex
+	errors     map[responseKey]*InfraError
+	answers    map[responseKey]any
+	structured map[responseKey]any
+	// serverTitle is added to the server information of initialize results
+	serverTitle string
+	// outputSchemas maps tool names to the output schemas added to tools/list results
+	outputSchemas *sync.Map
+}
+
+// responseKey identifies the response to a request: clients number their requests on
+// their own, so ids are only unique within a session
+type responseKey struct {
+	session string
+	id      string
+}
+
+// newResponseKey returns the key of the response to the request id of session
+func newResponseKey(session string, id any) responseKey {
+	return responseKey{session: session, id: mcp.NewRequestId(id).String()}
+}
+
+// newRPCErrorMapper creates an empty error mapper
+func newRPCErrorMapper() *rpcErrorMapper {
+	return &rpcErrorMapper{errors: make(map[responseKey]*InfraError), answers: make(map[responseKey]any), structured: make(map[responseKey]any)}
+}
+
+// acknowledge records that the request id of the session of ctx succeeded despite the
+// error response of the library
+func (m *rpcErrorMapper) acknowledge(ctx context.Context, id any) {
+	m.answer(ctx, id, mcp.EmptyResult{})
+}
+
+// answer records the result of the request id of the session of ctx, replacing the error
+// response of the library
+func (m *rpcErrorMapper) answer(ctx context.Context, id any, result any) {
+	m.mu.Lock()
+	m.answers[newResponseKey(sessionID(ctx), id)] = result
+	m.mu.Unlock()
+}
+
+// takeAnswer removes and returns the result recorded for a request id of session
+func (m *rpcErrorMapper) takeAnswer(session string, id any) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := newResponseKey(session, id)
+	result, ok := m.answers[key]
+	delete(m.answers, key)
+	return result, ok
+}
+
+// onError records infrastructure errors by session and request id; it is registered as an
+// OnError hook
+func (m *rpcErrorMapper) onError(ctx context.Context, id any, method mcp.MCPMethod, message any, err error) {
+	infraErr := classifyError(err)
+	if infraErr == nil || id == nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.errors[newResponseKey(sessionID(ctx), id)] = infraErr
+	m.mu.Unlock()
+}
+
+// take removes and returns the error recorded for a request id of session
+func (m *rpcErrorMapper) take(session string, id any) *InfraError {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := newResponseKey(session, id)
+	infraErr, ok := m.errors[key]
+	if ok {
+		delete(m.errors, key)
+	}
+	return infraErr
+}
+
+// onUnregister drops what was recorded for the requests of a session that ended
+func (m *rpcErrorMapper) onUnregister(ctx context.Context, session server.ClientSession) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, recorded := range []map[responseKey]any{m.answers, m.structured} {
+		for key := range recorded {
+			if key.session == session.SessionID() {
+				delete(recorded, key)
+			}
+		}
+	}
+	for key := range m.errors {
+		if key.session == session.SessionID() {
+			delete(m.errors, key)
+		}
+	}
+}
+
+// stdioSessionID is the id of the single session of the stdio transport of the library
+const stdioSessionID = "stdio"
+
+// Writer wraps w so that recorded errors are rewritten in the messages of the stdio session
+func (m *rpcErrorMapper) Writer(w io.Writer) io.Writer {
+	return &rpcErrorWriter{mapper: m, w: w}
+}
+
+// rpcErrorWriter rewrites JSON-RPC error responses written by the stdio transport
+type rpcErrorWriter struct {
+	mapper *rpcErrorMapper
+	w      io.Writer
+}
+
+// rpcErrorData is attached to rewritten error responses
+type rpcErrorData struct {
+	Retryable bool `json:"retryable"`
+}
+
+// Write implements io.Writer; each call carries one newline-terminated message
+func (rw *rpcErrorWriter) Write(p []byte) (int, error) {
+	rewritten, ok := rw.mapper.rewrite(stdioSessionID, bytes.TrimRight(p, "\n"))
+	if !ok {
+		return rw.w.Write(p)
+	}
+	if _, err := rw.w.Write(append(rewritten, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// sseMessagePrefix starts each message event written by the SSE transport
+const sseMessagePrefix = "event: message\ndata: "
+
+// sseEndpointPrefix starts the first event of an SSE stream, announcing the message endpoint
+// with the id of the session of the stream
+const sseEndpointPrefix = "event: endpoint\ndata: "
+
+// EventWriter wraps the response writer of an SSE stream so that recorded errors are
+// rewritten in its message events
+func (m *rpcErrorMapper) EventWriter(w http.ResponseWriter) http.ResponseWriter {
+	return &rpcErrorEventWriter{ResponseWriter: w, mapper: m}
+}
+
+// rpcErrorEventWriter rewrites JSON-RPC error responses written by the SSE transport
+type rpcErrorEventWriter struct {
+	http.ResponseWriter
+	mapper *rpcErrorMapper
+	// session is the id of the session of the stream, read from its endpoint event
+	session string
+}
+
+// Write implements http.ResponseWriter; each call carries one complete event
+func (rw *rpcErrorEventWriter) Write(p []byte) (int, error) {
+	if endpoint, ok := bytes.CutPrefix(p, []byte(sseEndpointPrefix)); ok {
+		if u, err := url.Parse(string(bytes.TrimSpace(endpoint))); err == nil {
+			rw.session = u.Query().Get("sessionId")
+		}
+		return rw.ResponseWriter.Write(p)
+	}
+	data, ok := bytes.CutPrefix(p, []byte(sseMessagePrefix))
+	if !ok || rw.session == "" {
+		return rw.ResponseWriter.Write(p)
+	}
+	rewritten, ok := rw.mapper.rewrite(rw.session, bytes.TrimRight(data, "\n"))
+	if !ok {
+		return rw.ResponseWriter.Write(p)
+	}
+	event := append([]byte(sseMessagePrefix), rewritten...)
+	if _, err := rw.ResponseWriter.Write(append(event, "\n\n"...)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush implements http.Flusher, which the SSE transport requires
+func (rw *rpcErrorEventWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// rewrite returns message, written to session, with the code, text and retryability of
+// its recorded infrastructure error, or the recorded result if its request was answered, or
+// with the structured content or output schemas of tools or the server title added, or the
+// request to the client a notification carries; ok is false if message needs none of these
+func (m *rpcErrorMapper) rewrite(session string, message []byte) ([]byte, bool) {
+	if request, ok := clientRequestMessage(message); ok {
+		return request, true
+	}
+	if rewritten, ok := m.addServerTitle(message); ok {
+		return rewritten, true
+	}
+	if rewritten, ok := m.addOutputs(session, message); ok {
+		return rewritten, true
+	}
+	if !bytes.Contains(message, []byte(`"error"`)) {
+		return nil, false
+	}
+
+	var response struct {
+		JSONRPC string `json:"jsonrpc"`
+		ID      any    `json:"id"`
+		Error   *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Data    any    `json:"data,omitempty"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(message, &response); err != nil || response.Error == nil {
+		return nil, false
+	}
+
+	if result, ok := m.takeAnswer(session, response.ID); ok {
+		rewritten, err := json.Marshal(mcp.JSONRPCResponse{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			ID:      mcp.NewRequestId(response.ID),
+			Result:  result,
+		})
+		if err != nil {
+			return nil, false
+		}
+		return rewritten, true
+	}
+
+	infraErr := m.take(session, response.ID)
+	if infraErr == nil {
+		return nil, false
+	}
+
+	response.Error.Code = infraErr.Code
+	response.Error.Message = infraErr.Error()
+	response.Error.Data = rpcErrorData{Retryable: infraErr.Retryable}
+
+	rewritten, err := json.Marshal(response)
+	if err != nil {
+		return nil, false
+	}
+	return rewritten, true
+}
@@ -0,0 +1,139 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// savedQuery is a named statement that can be explained without writing SQL
+type savedQuery struct {
+	SQL  string
+	Args []any
+}
+
+// savedQueries lists the named queries accepted by the explain_query tool;
+// they mirror the statements the server issues itself
+var savedQueries = map[string]savedQuery{
+	"list_products":           {SQL: "SELECT * FROM products WHERE deleted_at IS NULL"},
+	"product_by_id":           {SQL: "SELECT * FROM products WHERE id = ? AND deleted_at IS NULL LIMIT 1", Args: []any{1}},
+	"products_by_code":        {SQL: "SELECT * FROM products WHERE code = ? AND deleted_at IS NULL", Args: []any{"D42"}},
+	"products_by_price_range": {SQL: "SELECT * FROM products WHERE price BETWEEN ? AND ? AND deleted_at IS NULL ORDER BY price", Args: []any{0, 100}},
+}
+
+// savedQueryNames returns the names of the saved queries in sorted order
+func savedQueryNames() []string {
+	names := make([]string, 0, len(savedQueries))
+	for name := range savedQueries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// QueryPlan is the execution plan the database reports for a statement
+type QueryPlan struct {
+	Driver string   `json:"driver"`
+	Query  string   `json:"query"`
+	Plan   []string `json:"plan"`
+}
+
+// readOnlyStatement checks that query is a single SELECT statement and returns it without a trailing semicolon
+func readOnlyStatement(query string) (string, error) {
+	query = strings.TrimSpace(query)
+	query = strings.TrimSpace(strings.TrimSuffix(query, ";"))
+	if query == "" {
+		return "", invalidField("query", "must not be empty")
+	}
+	if strings.Contains(query, ";") {
+		return "", invalidField("query", "must be a single statement")
+	}
+
+	keyword := strings.ToUpper(strings.Fields(query)[0])
+	if keyword != "SELECT" && keyword != "WITH" {
+		return "", invalidField("query", "only read-only SELECT statements can be explained")
+	}
+	return query, nil
+}
+
+// ExplainQuery returns the query plan of a read-only statement; the statement itself is not executed
+func (dbs *DBService) ExplainQuery(ctx context.Context, query string, args ...any) (*QueryPlan, error) {
+	query, err := readOnlyStatement(query)
+	if err != nil {
+		return nil, err
+	}
+
+	db := dbs.conn(ctx)
+	plan := &QueryPlan{
+		Driver: db.Dialector.Name(),
+		Query:  query,
+	}
+
+	switch plan.Driver {
+	case "sqlite":
+		var rows []struct {
+			ID     int
+			Parent int
+			Detail string
+		}
+		if err := db.Raw("EXPLAIN QUERY PLAN "+query, args...).Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to explain query: %w", err)
+		}
+		// Rows form a tree through their parent ids; indent each step under its parent
+		depth := map[int]int{}
+		for _, row := range rows {
+			depth[row.ID] = depth[row.Parent] + 1
+			plan.Plan = append(plan.Plan, strings.Repeat("  ", depth[row.ID]-1)+row.Detail)
+		}
+	case "postgres":
+		if err := db.Raw("EXPLAIN "+query, args...).Scan(&plan.Plan).Error; err != nil {
+			return nil, fmt.Errorf("failed to explain query: %w", err)
+		}
+	case "mysql":
+		var tree string
+		if err := db.Raw("EXPLAIN FORMAT=TREE "+query, args...).Scan(&tree).Error; err != nil {
+			return nil, fmt.Errorf("failed to explain query: %w", err)
+		}
+		plan.Plan = strings.Split(strings.TrimRight(tree, "\n"), "\n")
+	default:
+		return nil, fmt.Errorf("query plans are %w for %s", ErrUnsupported, plan.Driver)
+	}
+
+	return plan, nil
+}
+
+// explainQueryHandler handles the explain_query tool request
+func (app *App) explainQueryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query := request.GetString("query", "")
+	name := request.GetString("saved_query", "")
+
+	var args []any
+	switch {
+	case query != "" && name != "":
+		return newToolError(CodeInvalidArgument, "provide either query or saved_query, not both"), nil
+	case name != "":
+		saved, ok := savedQueries[name]
+		if !ok {
+			return toolErrorResult(invalidField("saved_query", fmt.Sprintf("unknown saved query %q", name)))
+		}
+		query, args = saved.SQL, saved.Args
+	case query == "":
+		return newToolError(CodeInvalidArgument, "provide a query or a saved_query"), nil
+	}
+
+	plan, err := app.dbService.ExplainQuery(ctx, query, args...)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query plan to JSON: %w", err)
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
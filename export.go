@@ -0,0 +1,238 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// csvDelimiters maps the delimiter names accepted by export_products to their runes
+var csvDelimiters = map[string]rune{
+	"comma":     ',',
+	"semicolon": ';',
+	"tab":       '\t',
+}
+
+// Encodings of exported files
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF8BOM = "utf-8-bom"
+)
+
+// utf8BOM lets Excel detect that a CSV file is UTF-8
+const utf8BOM = "\ufeff"
+
+// csvHeaders holds the column headings of exported product fields per language
+var csvHeaders = map[string]map[string]string{
+	"en": {"id": "ID", "code": "Code", "name": "Name", "description": "Description", "category": "Category", "price": "Price", "currency": "Currency", "stock": "Stock", "supplier_id": "Supplier ID", "created_at": "Created at", "updated_at": "Updated at"},
+	"de": {"id": "ID", "code": "Code", "name": "Name", "description": "Beschreibung", "category": "Kategorie", "price": "Preis", "currency": "Währung", "stock": "Bestand", "supplier_id": "Lieferanten-ID", "created_at": "Erstellt am", "updated_at": "Geändert am"},
+	"fr": {"id": "ID", "code": "Code", "name": "Nom", "description": "Description", "category": "Catégorie", "price": "Prix", "currency": "Devise", "stock": "Stock", "supplier_id": "ID fournisseur", "created_at": "Créé le", "updated_at": "Modifié le"},
+	"es": {"id": "ID", "code": "Código", "name": "Nombre", "description": "Descripción", "category": "Categoría", "price": "Precio", "currency": "Moneda", "stock": "Existencias", "supplier_id": "ID de proveedor", "created_at": "Creado el", "updated_at": "Modificado el"},
+	"it": {"id": "ID", "code": "Codice", "name": "Nome", "description": "Descrizione", "category": "Categoria", "price": "Prezzo", "currency": "Valuta", "stock": "Giacenza", "supplier_id": "ID fornitore", "created_at": "Creato il", "updated_at": "Modificato il"},
+	"nl": {"id": "ID", "code": "Code", "name": "Naam", "description": "Beschrijving", "category": "Categorie", "price": "Prijs", "currency": "Valuta", "stock": "Voorraad", "supplier_id": "Leverancier-ID", "created_at": "Aangemaakt op", "updated_at": "Gewijzigd op"},
+}
+
+// csvHeaderLanguages returns the supported header languages in sorted order
+func csvHeaderLanguages() []string {
+	languages := make([]string, 0, len(csvHeaders))
+	for language := range csvHeaders {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// CSVOptions controls the dialect of a CSV export
+type CSVOptions struct {
+	Delimiter        rune
+	DecimalSeparator string
+	Encoding         string
+	HeaderLanguage   string
+}
+
+// parseCSVOptions reads and validates the CSV options of an export request
+func parseCSVOptions(request mcp.CallToolRequest) (CSVOptions, error) {
+	var fields []FieldError
+
+	delimiter, ok := csvDelimiters[request.GetString("delimiter", "comma")]
+	if !ok {
+		fields = append(fields, FieldError{Field: "delimiter", Message: "must be comma, semicolon or tab"})
+	}
+	decimal := request.GetString("decimal_separator", ".")
+	if decimal != "." && decimal != "," {
+		fields = append(fields, FieldError{Field: "decimal_separator", Message: `must be "." or ","`})
+	}
+	encoding := request.GetString("encoding", encodingUTF8)
+	if encoding != encodingUTF8 && encoding != encodingUTF8BOM {
+		fields = append(fields, FieldError{Field: "encoding", Message: fmt.Sprintf("must be %s or %s", encodingUTF8, encodingUTF8BOM)})
+	}
+	language := request.GetString("header_language", "en")
+	if _, ok := csvHeaders[language]; !ok {
+		fields = append(fields, FieldError{Field: "header_language", Message: "must be one of " + strings.Join(csvHeaderLanguages(), ", ")})
+	}
+
+	if len(fields) > 0 {
+		return CSVOptions{}, &ValidationError{Fields: fields}
+	}
+	return CSVOptions{Delimiter: delimiter, DecimalSeparator: decimal, Encoding: encoding, HeaderLanguage: language}, nil
+}
+
+// formatCSVValue renders a product field value for a CSV cell
+func formatCSVValue(v any, opts CSVOptions) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		s := strconv.FormatFloat(val, 'f', -1, 64)
+		if opts.DecimalSeparator != "." {
+			s = strings.Replace(s, ".", opts.DecimalSeparator, 1)
+		}
+		return s
+	case time.Time:
+		return val.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// productsCSV renders products as CSV with the given fields and options
+func productsCSV(products []Product, fields []string, opts CSVOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if opts.Encoding == encodingUTF8BOM {
+		buf.WriteString(utf8BOM)
+	}
+
+	w := csv.NewWriter(&buf)
+	w.Comma = opts.Delimiter
+	header := make([]string, len(fields))
+	for i, name := range fields {
+		header[i] = csvHeaders[opts.HeaderLanguage][name]
+	}
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for i := range products {
+		record := make([]string, len(fields))
+		for j, name := range fields {
+			record[j] = formatCSVValue(productFields[name].Value(&products[i]), opts)
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("failed to write CSV record: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Formats of exported files
+const (
+	exportFormatCSV  = "csv"
+	exportFormatJSON = "json"
+)
+
+// exportFiles maps the export formats to the URI and MIME type of the exported file
+var exportFiles = map[string]mcp.TextResourceContents{
+	exportFormatCSV:  {URI: "export://products.csv", MIMEType: "text/csv; charset=utf-8"},
+	exportFormatJSON: {URI: "export://products.json", MIMEType: "application/json"},
+}
+
+// parseExportQuery reads the category, tag, filter and cursor of an export request
+func parseExportQuery(request mcp.CallToolRequest) (ProductQuery, error) {
+	query := ProductQuery{
+		Sort:     "id",
+		Category: request.GetString("category", ""),
+		Tag:      strings.ToLower(strings.TrimSpace(request.GetString("tag", ""))),
+	}
+
+	if raw, ok := request.GetArguments()["filter"]; ok {
+		conditions, ok := raw.(map[string]any)
+		if !ok {
+			return ProductQuery{}, invalidField("filter", "must be an object")
+		}
+		filter, err := parseProductFilter("filter", conditions)
+		if err != nil {
+			return ProductQuery{}, err
+		}
+		query.Filter = filter
+	}
+
+	if cursor := request.GetString("cursor", ""); cursor != "" {
+		offset, err := decodeCursor("cursor", cursor)
+		if err != nil {
+			return ProductQuery{}, err
+		}
+		query.Offset = offset
+	}
+	return query, nil
+}
+
+// exportProductsHandler handles the export_products tool request. The file is returned as
+// an embedded resource so that clients can save it. A catalog above the result limits is
+// exported in chunks, each a complete file, continued with the cursor of the previous one.
+func (app *App) exportProductsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	format := request.GetString("format", exportFormatCSV)
+	file, ok := exportFiles[format]
+	if !ok {
+		return toolErrorResult(invalidField("format", "must be csv or json"))
+	}
+	opts, err := parseCSVOptions(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	fields, err := requestFields(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	if len(fields) == 0 && format == exportFormatCSV {
+		fields = productTableFields
+	}
+	query, err := parseExportQuery(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	limits := app.config.Results
+	query.Limit = limits.fetchLimit(0)
+	products, err := app.dbService.GetProducts(ctx, query)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	total, err := app.dbService.CountProducts(ctx, query)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	render := func(page []Product) ([]byte, error) { return productsCSV(page, fields, opts) }
+	if format == exportFormatJSON {
+		render = renderJSON(func(page []Product) any { return projectProducts(page, fields) })
+	}
+	data, n, truncation, err := limitResult(limits, products, query.Offset, total, 0, true, render)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	if err := app.quotas.AddRows(ctx, n); err != nil {
+		return toolErrorResult(err)
+	}
+
+	text := fmt.Sprintf("Exported %d products as %s", n, strings.ToUpper(format))
+	if query.Offset > 0 || (truncation != nil && truncation.Truncated) {
+		text = fmt.Sprintf("Exported products %d to %d of %d as %s", query.Offset+1, query.Offset+n, total, strings.ToUpper(format))
+	}
+	result, err := truncatedToolResult(text, truncation)
+	if err != nil {
+		return nil, err
+	}
+	file.Text = string(data)
+	result.Content = append(result.Content, mcp.NewEmbeddedResource(file))
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package mcpserver
+
+import (
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// filterOperators maps the operators of the filter DSL to SQL operators
+var filterOperators = map[string]string{
+	"eq":   "=",
+	"ne":   "<>",
+	"lt":   "<",
+	"lte":  "<=",
+	"gt":   ">",
+	"gte":  ">=",
+	"like": "LIKE",
+	"in":   "IN",
+}
+
+// FilterCondition compares one product field with a value
+type FilterCondition struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value any    `json:"value"`
+}
+
+// ProductFilter is a parsed filter DSL expression; a product matches when every condition holds
+type ProductFilter struct {
+	Conditions []FilterCondition `json:"conditions"`
+}
+
+// parseProductFilter parses a filter such as {"price": {"lt": 10}, "code": {"like": "TMP%"}}.
+// Keys are product fields and values map operators to operands; a bare value is
+// shorthand for eq. arg names the argument the filter came from.
+func parseProductFilter(arg string, raw map[string]any) (*ProductFilter, error) {
+	if len(raw) == 0 {
+		return nil, invalidField(arg, "must contain at least one condition")
+	}
+
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filter := &ProductFilter{}
+	var fields []FieldError
+	for _, name := range names {
+		if _, ok := productFields[name]; !ok {
+			fields = append(fields, FieldError{Field: arg + "." + name, Message: "unknown field"})
+			continue
+		}
+
+		ops, ok := raw[name].(map[string]any)
+		if !ok {
+			ops = map[string]any{"eq": raw[name]}
+		}
+		if len(ops) == 0 {
+			fields = append(fields, FieldError{Field: arg + "." + name, Message: "must contain at least one operator"})
+		}
+		for op, value := range ops {
+			if _, ok := filterOperators[op]; !ok {
+				fields = append(fields, FieldError{Field: arg + "." + name, Message: fmt.Sprintf("unknown operator %q", op)})
+				continue
+			}
+			if _, isList := value.([]any); isList != (op == "in") {
+				fields = append(fields, FieldError{Field: arg + "." + name, Message: fmt.Sprintf("operator %q takes a list only if it is in", op)})
+				continue
+			}
+			filter.Conditions = append(filter.Conditions, FilterCondition{Field: name, Op: op, Value: value})
+		}
+	}
+
+	if len(fields) > 0 {
+		return nil, &ValidationError{Fields: fields}
+	}
+	sort.SliceStable(filter.Conditions, func(i, j int) bool {
+		a, b := filter.Conditions[i], filter.Conditions[j]
+		return a.Field < b.Field || (a.Field == b.Field && a.Op < b.Op)
+	})
+	return filter, nil
+}
+
+// Apply adds the conditions of the filter to db
+func (f *ProductFilter) Apply(db *gorm.DB) *gorm.DB {
+	for _, c := range f.Conditions {
+		column := productFields[c.Field].Column
+		db = db.Where(fmt.Sprintf("%s %s (?)", column, filterOperators[c.Op]), c.Value)
+	}
+	return db
+}
@@ -0,0 +1,93 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// gormLogLevels maps the accepted DB_LOG_LEVEL values to GORM log levels
+var gormLogLevels = map[string]logger.LogLevel{
+	"silent": logger.Silent,
+	"error":  logger.Error,
+	"warn":   logger.Warn,
+	"info":   logger.Info,
+}
+
+// slogGormLogger routes GORM's log output into the default slog logger. Failed statements
+// are logged as errors, statements slower than the threshold as warnings and, at the info
+// level, every statement at debug level. Statements are logged with placeholders rather
+// than their arguments so that product data and secrets stay out of the logs.
+type slogGormLogger struct {
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+// newGormLogger returns a GORM logger writing to slog at the configured level
+func newGormLogger(cfg *Config) logger.Interface {
+	return &slogGormLogger{level: cfg.DBLogLevel, slowThreshold: cfg.SlowQueryThreshold}
+}
+
+// LogMode implements logger.Interface
+func (l *slogGormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+// Info implements logger.Interface
+func (l *slogGormLogger) Info(ctx context.Context, msg string, args ...any) {
+	if l.level >= logger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, args...), "component", "gorm")
+	}
+}
+
+// Warn implements logger.Interface
+func (l *slogGormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	if l.level >= logger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, args...), "component", "gorm")
+	}
+}
+
+// Error implements logger.Interface
+func (l *slogGormLogger) Error(ctx context.Context, msg string, args ...any) {
+	if l.level >= logger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, args...), "component", "gorm")
+	}
+}
+
+// Trace implements logger.Interface; it is called after every statement
+func (l *slogGormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.level >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "SQL statement failed", "component", "gorm", "sql", compactSQL(sql), "rows", rows, "duration", elapsed, "error", err)
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= logger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "Slow SQL statement", "component", "gorm", "sql", compactSQL(sql), "rows", rows, "duration", elapsed, "threshold", l.slowThreshold)
+	case l.level >= logger.Info:
+		sql, rows := fc()
+		slog.DebugContext(ctx, "SQL statement", "component", "gorm", "sql", compactSQL(sql), "rows", rows, "duration", elapsed)
+	}
+}
+
+// ParamsFilter implements gorm.ParamsFilter, keeping statement arguments out of the logs
+func (l *slogGormLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	return sql, nil
+}
+
+// compactSQL collapses the whitespace of a statement onto a single line
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
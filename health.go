@@ -0,0 +1,122 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Database health states
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+)
+
+// DatabaseHealth reports whether the database answers and how fast
+type DatabaseHealth struct {
+	Status      string              `json:"status"`
+	Driver      string              `json:"driver"`
+	PingMs      float64             `json:"ping_ms"`
+	QueryMs     float64             `json:"query_ms,omitempty"`
+	Error       string              `json:"error,omitempty"`
+	Connections DatabaseConnections `json:"connections"`
+	CheckedAt   time.Time           `json:"checked_at"`
+}
+
+// DatabaseConnections describes the connection pool of the primary database
+type DatabaseConnections struct {
+	Open      int   `json:"open"`
+	InUse     int   `json:"in_use"`
+	Idle      int   `json:"idle"`
+	MaxOpen   int   `json:"max_open"`
+	WaitCount int64 `json:"wait_count"`
+	WaitMs    int64 `json:"wait_ms"`
+}
+
+// Health pings the primary database and runs a trivial query against it. An unreachable
+// database is reported in the result rather than as an error.
+func (dbs *DBService) Health(ctx context.Context) (*DatabaseHealth, error) {
+	db := dbs.primary(ctx)
+	health := &DatabaseHealth{
+		Status:    healthOK,
+		Driver:    db.Dialector.Name(),
+		CheckedAt: time.Now().UTC(),
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database handle: %w", err)
+	}
+
+	start := time.Now()
+	err = sqlDB.PingContext(ctx)
+	health.PingMs = elapsedMs(start)
+	if err == nil {
+		var one int
+		start = time.Now()
+		err = db.Raw("SELECT 1").Scan(&one).Error
+		health.QueryMs = elapsedMs(start)
+	}
+	if err != nil {
+		health.Status = healthUnavailable
+		health.Error = err.Error()
+	}
+
+	stats := sqlDB.Stats()
+	health.Connections = DatabaseConnections{
+		Open:      stats.OpenConnections,
+		InUse:     stats.InUse,
+		Idle:      stats.Idle,
+		MaxOpen:   stats.MaxOpenConnections,
+		WaitCount: stats.WaitCount,
+		WaitMs:    stats.WaitDuration.Milliseconds(),
+	}
+	return health, nil
+}
+
+// elapsedMs returns the milliseconds since start with microsecond precision
+func elapsedMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+// dbHealthJSON checks the database and renders the report
+func (app *App) dbHealthJSON(ctx context.Context) (string, error) {
+	health, err := app.dbService.Health(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	jsonData, err := json.MarshalIndent(health, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal database health to JSON: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// dbHealthHandler handles the db_health tool request
+func (app *App) dbHealthHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	text, err := app.dbHealthJSON(ctx)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	return mcp.NewToolResultText(text), nil
+}
+
+// dbHealthResourceHandler handles the database health resource request
+func (app *App) dbHealthResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	text, err := app.dbHealthJSON(ctx)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      "health://db",
+			MIMEType: "application/json",
+			Text:     text,
+		},
+	}, nil
+}
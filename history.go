@@ -0,0 +1,117 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// asOfFormatMessage describes the expected format of as_of timestamps
+const asOfFormatMessage = "must be an RFC 3339 timestamp, e.g. 2024-01-15T00:00:00Z"
+
+// ProductVersion is a snapshot of a product taken every time it is written.
+// Together the versions of a product record its state at any point in time.
+type ProductVersion struct {
+	ID          uint `gorm:"primaryKey"`
+	ProductID   uint `gorm:"index"`
+	Code        string
+	Name        string
+	Description string
+	Category    string
+	Price       float64
+	Currency    string
+	Stock       int
+	Deleted     bool
+	ValidFrom   time.Time `gorm:"index"`
+}
+
+// AfterSave records a version, and a price change if the price changed, every time a product
+// is created or updated through GORM. Bulk deletes record their versions explicitly; raw SQL
+// writes bypass the history.
+func (p *Product) AfterSave(tx *gorm.DB) error {
+	if err := recordProductVersions(tx, false, p.UpdatedAt, *p); err != nil {
+		return err
+	}
+	return recordPriceChange(tx, *p)
+}
+
+// recordProductVersions appends a version for each product
+func recordProductVersions(tx *gorm.DB, deleted bool, at time.Time, products ...Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	versions := make([]ProductVersion, len(products))
+	for i, p := range products {
+		versions[i] = ProductVersion{
+			ProductID:   p.ID,
+			Code:        p.Code,
+			Name:        p.Name,
+			Description: p.Description,
+			Category:    stringOrEmpty(p.Category),
+			Price:       p.Price,
+			Currency:    p.Currency,
+			Stock:       p.Stock,
+			Deleted:     deleted,
+			ValidFrom:   at.UTC(),
+		}
+	}
+	if err := tx.Session(&gorm.Session{NewDB: true}).Create(&versions).Error; err != nil {
+		return fmt.Errorf("failed to record product history: %w", err)
+	}
+	return nil
+}
+
+// backfillProductHistory records a baseline version for products written before
+// history was kept, so that they appear in as-of queries from their last update on
+func backfillProductHistory(db *gorm.DB) error {
+	var products []Product
+	err := db.Unscoped().
+		Where("id NOT IN (?)", db.Model(&ProductVersion{}).Select("product_id")).
+		Find(&products).Error
+	if err != nil {
+		return fmt.Errorf("failed to find products without history: %w", err)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range products {
+			if err := recordProductVersions(tx, false, p.UpdatedAt, p); err != nil {
+				return err
+			}
+			if p.DeletedAt.Valid {
+				if err := recordProductVersions(tx, true, p.DeletedAt.Time, p); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// productsAsOf returns a query for the products as they were at the given time,
+// reconstructed from the product history. UpdatedAt of each record is the time its
+// reconstructed state was written.
+func productsAsOf(db *gorm.DB, at time.Time) *gorm.DB {
+	// Timestamps are stored in UTC and compared as such
+	latest := db.Model(&ProductVersion{}).Select("MAX(id)").Where("valid_from <= ?", at.UTC()).Group("product_id")
+
+	return db.Table("product_versions AS v").
+		Select("v.product_id AS id, p.created_at AS created_at, v.valid_from AS updated_at, v.code AS code, v.name AS name, v.description AS description, NULLIF(v.category, '') AS category, v.price AS price, v.currency AS currency, v.stock AS stock").
+		Joins("JOIN products AS p ON p.id = v.product_id").
+		Where("v.id IN (?) AND v.deleted = ?", latest, false)
+}
+
+// GetProductsAsOf reconstructs the product list as it was at the given time, filtered, ordered
+// and limited as requested
+func (dbs *DBService) GetProductsAsOf(ctx context.Context, at time.Time, q ProductQuery) ([]Product, error) {
+	var products []Product
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		db := dbs.conn(ctx)
+		return q.page(q.filter(db.Table("(?) AS r", productsAsOf(db, at)))).Scan(&products).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconstruct products: %w", err)
+	}
+	return products, nil
+}
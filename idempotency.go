@@ -0,0 +1,224 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// idempotencyKeyArg is the optional argument accepted by idempotent tools
+const idempotencyKeyArg = "idempotency_key"
+
+// idempotentTools lists the tools that accept an idempotency key
+var idempotentTools = map[string]bool{
+	"create_product":   true,
+	"update_product":   true,
+	"patch_product":    true,
+	"delete_product":   true,
+	"adjust_stock":     true,
+	"import_products":  true,
+	"restore_product":  true,
+	"create_promotion": true,
+	"create_variant":   true,
+	"place_order":      true,
+	"create_customer":  true,
+	"create_supplier":  true,
+	"update_supplier":  true,
+}
+
+// withIdempotencyKey declares the optional idempotency key argument on a tool
+func withIdempotencyKey() mcp.ToolOption {
+	return mcp.WithString(idempotencyKeyArg,
+		mcp.Description("Optional client-chosen key; retries with the same key and arguments return the original result instead of applying the change again"),
+	)
+}
+
+// IdempotencyRecord stores the result of a tool call made with an idempotency key. Keys
+// are chosen by clients, so they are scoped to the tenant and session of the call and a
+// key reused elsewhere never returns another client's result.
+type IdempotencyRecord struct {
+	// Tenant is empty for the default database
+	Tenant      string `gorm:"primaryKey"`
+	Session     string `gorm:"primaryKey"`
+	Tool        string `gorm:"primaryKey"`
+	Key         string `gorm:"primaryKey"`
+	RequestHash string
+	Result      string
+	CreatedAt   time.Time
+}
+
+// idempotencyStore looks up and records the results of idempotent tool calls in the
+// database of the tenant of each call
+type idempotencyStore struct {
+	dbService *DBService
+	ttl       time.Duration
+
+	// mu guards locks, which serialize the calls made with the same key so that concurrent
+	// retries run once while calls with other keys proceed
+	mu    sync.Mutex
+	locks map[idempotencyScope]*idempotencyLock
+}
+
+// idempotencyScope identifies the calls made with a key for a tool in a tenant and session
+type idempotencyScope struct {
+	tenant, session, tool, key string
+}
+
+// idempotencyLock is held by the running call made with a key; refs counts the calls
+// holding or waiting for it
+type idempotencyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// newIdempotencyStore creates a store keeping results in the databases of dbService for ttl
+func newIdempotencyStore(dbService *DBService, ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{dbService: dbService, ttl: ttl, locks: make(map[idempotencyScope]*idempotencyLock)}
+}
+
+// lock waits until no other call made with key for tool in the tenant and session of ctx
+// runs, and returns the function releasing the key
+func (st *idempotencyStore) lock(ctx context.Context, tool, key string) (unlock func()) {
+	id := idempotencyScope{tenant: tenantFromContext(ctx), session: sessionID(ctx), tool: tool, key: key}
+	st.mu.Lock()
+	l, ok := st.locks[id]
+	if !ok {
+		l = &idempotencyLock{}
+		st.locks[id] = l
+	}
+	l.refs++
+	st.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(st.locks, id)
+		}
+	}
+}
+
+// requestHash returns a stable hash of the tool arguments, excluding the idempotency key
+// and confirmation token
+func requestHash(args map[string]any) (string, error) {
+	filtered := make(map[string]any, len(args))
+	for k, v := range args {
+		if k != idempotencyKeyArg && k != confirmationTokenArg {
+			filtered[k] = v
+		}
+	}
+	// json.Marshal sorts map keys, giving a canonical encoding
+	data, err := json.Marshal(filtered)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// scope returns the columns identifying the record of key for tool in the tenant and
+// session of ctx
+func scope(ctx context.Context, tool, key string) map[string]any {
+	return map[string]any{"tenant": tenantFromContext(ctx), "session": sessionID(ctx), "tool": tool, "key": key}
+}
+
+// lookup returns the stored result for a key, or nil if the key has not been used in the
+// tenant and session of ctx or has expired
+func (st *idempotencyStore) lookup(ctx context.Context, tool, key, hash string) (*mcp.CallToolResult, error) {
+	var record IdempotencyRecord
+	// key is a reserved word in MySQL; conditions from a map have their columns quoted and,
+	// unlike a struct, keep the empty tenant and session. The key is read from the primary,
+	// as a lagging replica may not have the result of the call being retried yet.
+	result := st.dbService.primary(ctx).Where(scope(ctx, tool, key)).Limit(1).Find(&record)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to look up idempotency key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || (st.ttl > 0 && time.Since(record.CreatedAt) > st.ttl) {
+		return nil, nil
+	}
+	if record.RequestHash != hash {
+		return newToolError(CodeConflict, fmt.Sprintf("idempotency key %q was already used with different arguments", key),
+			FieldError{Field: idempotencyKeyArg, Message: "already used with different arguments"}), nil
+	}
+
+	raw := json.RawMessage(record.Result)
+	stored, err := mcp.ParseCallToolResult(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode stored result: %w", err)
+	}
+	return stored, nil
+}
+
+// save records the result of a successful call under key in the tenant and session of ctx
+func (st *idempotencyStore) save(ctx context.Context, tool, key, hash string, result *mcp.CallToolResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+	record := IdempotencyRecord{
+		Tenant:      tenantFromContext(ctx),
+		Session:     sessionID(ctx),
+		Tool:        tool,
+		Key:         key,
+		RequestHash: hash,
+		Result:      string(data),
+		CreatedAt:   time.Now(),
+	}
+	if err := st.dbService.primary(ctx).Save(&record).Error; err != nil {
+		return fmt.Errorf("failed to store idempotency key: %w", err)
+	}
+	return nil
+}
+
+// idempotencyMiddleware replays the original result when an idempotent tool is
+// retried with the same idempotency key and arguments in the same tenant and session; it
+// runs inside tenant routing
+func (app *App) idempotencyMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tool := request.Params.Name
+		key := request.GetString(idempotencyKeyArg, "")
+		if !idempotentTools[tool] || key == "" {
+			return next(ctx, request)
+		}
+
+		hash, err := requestHash(request.GetArguments())
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		// The preview of a confirmed operation and the call confirming it have the same
+		// arguments but are distinct calls, whose results are kept apart
+		if token := request.GetString(confirmationTokenArg, ""); token != "" {
+			key += "/" + token
+		}
+
+		st := app.idempotency
+		defer st.lock(ctx, tool, key)()
+
+		stored, err := st.lookup(ctx, tool, key, hash)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		if stored != nil {
+			return stored, nil
+		}
+
+		result, err := next(ctx, request)
+		if err != nil || result == nil || result.IsError {
+			// Failures are not recorded so the client can retry them
+			return result, err
+		}
+		if err := st.save(ctx, tool, key, hash, result); err != nil {
+			return toolErrorResult(err)
+		}
+		return result, nil
+	}
+}
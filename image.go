@@ -0,0 +1,278 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxImageBytes bounds the size of a product image
+const maxImageBytes = 5 << 20
+
+// productImageURISuffix ends the URI of the image resource of a product, products://{id}/image
+const productImageURISuffix = "/image"
+
+// imageMIMETypes lists the accepted image types, as detected from the content of images
+var imageMIMETypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// ErrProductImageNotFound is returned when a product has no image
+var ErrProductImageNotFound = fmt.Errorf("product image %w", ErrNotFound)
+
+// ProductImage is the image of a product, stored in the database
+type ProductImage struct {
+	ProductID uint `gorm:"primaryKey;autoIncrement:false"`
+	MIMEType  string
+	Data      []byte
+	UpdatedAt time.Time
+}
+
+// ProductImageInfo is the result of the set_product_image and delete_product_image tools
+type ProductImageInfo struct {
+	ProductID uint   `json:"product_id"`
+	URI       string `json:"uri,omitempty"`
+	MIMEType  string `json:"mime_type,omitempty"`
+	Size      int    `json:"size,omitempty"`
+	// Deleted is set by delete_product_image; false means the product had no image
+	Deleted *bool `json:"deleted,omitempty"`
+}
+
+// productImageURI returns the URI of the image resource of the product with the given id
+func productImageURI(id uint) string {
+	return fmt.Sprintf("products://%d%s", id, productImageURISuffix)
+}
+
+// detectImageType returns the MIME type of an image, detected from its content; field names
+// the argument it came from
+func detectImageType(field string, data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", invalidField(field, "must not be empty")
+	}
+	if len(data) > maxImageBytes {
+		return "", invalidField(field, fmt.Sprintf("must not be larger than %d bytes", maxImageBytes))
+	}
+	mimeType := http.DetectContentType(data)
+	if !slices.Contains(imageMIMETypes, mimeType) {
+		return "", invalidField(field, fmt.Sprintf("must be a PNG, JPEG, GIF or WebP image, not %s", mimeType))
+	}
+	return mimeType, nil
+}
+
+// findProduct checks that the product with the given id exists and is not deleted
+func findProduct(tx *gorm.DB, id uint) error {
+	var count int64
+	if err := tx.Model(&Product{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to retrieve product: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: id %d", ErrProductNotFound, id)
+	}
+	return nil
+}
+
+// auditDocument returns the image as recorded in the audit log, without its content
+func (img *ProductImage) auditDocument() any {
+	if img == nil {
+		return nil
+	}
+	return map[string]any{"mime_type": img.MIMEType, "size": len(img.Data), "updated_at": img.UpdatedAt}
+}
+
+// storedImage returns the image of the product with the given id in tx, or nil if it has none
+func storedImage(tx *gorm.DB, id uint) (*ProductImage, error) {
+	var images []ProductImage
+	if err := tx.Where("product_id = ?", id).Limit(1).Find(&images).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve product image: %w", err)
+	}
+	if len(images) == 0 {
+		return nil, nil
+	}
+	return &images[0], nil
+}
+
+// SetProductImage stores image as the image of its product, replacing any previous one
+func (dbs *DBService) SetProductImage(ctx context.Context, image *ProductImage) error {
+	return dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := findProduct(tx, image.ProductID); err != nil {
+				return err
+			}
+			previous, err := storedImage(tx, image.ProductID)
+			if err != nil {
+				return err
+			}
+			err = tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "product_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"mime_type", "data", "updated_at"}),
+			}).Create(image).Error
+			if err != nil {
+				return fmt.Errorf("failed to store product image: %w", err)
+			}
+			action := auditCreate
+			if previous != nil {
+				action = auditUpdate
+			}
+			return recordAudit(tx, auditProductImage, image.ProductID, action, previous.auditDocument(), image.auditDocument())
+		})
+	})
+}
+
+// GetProductImage returns the image of the product with the given id. Images of deleted
+// products are kept, for the product to be restored, but not returned.
+func (dbs *DBService) GetProductImage(ctx context.Context, id uint) (*ProductImage, error) {
+	var images []ProductImage
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		db := dbs.conn(ctx)
+		if err := findProduct(db, id); err != nil {
+			return err
+		}
+		return db.Where("product_id = ?", id).Limit(1).Find(&images).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("%w: product %d has no image", ErrProductImageNotFound, id)
+	}
+	return &images[0], nil
+}
+
+// DeleteProductImage removes the image of the product with the given id and reports whether
+// it had one
+func (dbs *DBService) DeleteProductImage(ctx context.Context, id uint) (bool, error) {
+	var deleted bool
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := findProduct(tx, id); err != nil {
+				return err
+			}
+			image, err := storedImage(tx, id)
+			if err != nil || image == nil {
+				return err
+			}
+			if err := tx.Where("product_id = ?", id).Delete(&ProductImage{}).Error; err != nil {
+				return fmt.Errorf("failed to delete product image: %w", err)
+			}
+			deleted = true
+			return recordAudit(tx, auditProductImage, id, auditDelete, image.auditDocument(), nil)
+		})
+	})
+	return deleted, err
+}
+
+// setProductImageHandler handles the set_product_image tool request
+func (app *App) setProductImageHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+
+	encoded := request.GetString("data", "")
+	file := request.GetString("file", "")
+	if (encoded == "") == (file == "") {
+		return newToolError(CodeInvalidArgument, "provide either data or file"), nil
+	}
+
+	var data []byte
+	field := "data"
+	if file != "" {
+		content, err := app.importFile(ctx, file)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		data, field = []byte(content), "file"
+	} else {
+		if len(encoded) > base64.StdEncoding.EncodedLen(maxImageBytes) {
+			return toolErrorResult(invalidField("data", fmt.Sprintf("must not be larger than %d bytes", maxImageBytes)))
+		}
+		if data, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return toolErrorResult(invalidField("data", "must be base64-encoded"))
+		}
+	}
+	mimeType, err := detectImageType(field, data)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	image := &ProductImage{ProductID: uint(id), MIMEType: mimeType, Data: data}
+	if err := app.dbService.SetProductImage(ctx, image); err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(ProductImageInfo{
+		ProductID: image.ProductID,
+		URI:       productImageURI(image.ProductID),
+		MIMEType:  image.MIMEType,
+		Size:      len(image.Data),
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal product image to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// deleteProductImageHandler handles the delete_product_image tool request
+func (app *App) deleteProductImageHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+
+	deleted, err := app.dbService.DeleteProductImage(ctx, uint(id))
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(ProductImageInfo{ProductID: uint(id), Deleted: &deleted}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal product image to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// productImageResourceHandler handles the product image resource template request. The
+// image is returned as a base64 blob with the MIME type detected when it was stored.
+func (app *App) productImageResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri, err := url.Parse(request.Params.URI)
+	if err != nil {
+		return nil, resourceError(invalidField("uri", err.Error()))
+	}
+	id, err := strconv.ParseUint(strings.TrimSuffix(uri.Host+uri.Path, productImageURISuffix), 10, 0)
+	if err != nil || id == 0 || !strings.HasSuffix(uri.Path, productImageURISuffix) {
+		return nil, resourceError(invalidField("uri", "expected products://{id}/image with a positive product id"))
+	}
+	for name := range uri.Query() {
+		if name != tenantArg {
+			return nil, resourceError(invalidField(name, "unknown parameter"))
+		}
+	}
+
+	image, err := app.dbService.GetProductImage(ctx, uint(id))
+	if err != nil {
+		return nil, resourceError(err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.BlobResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: image.MIMEType,
+			Blob:     base64.StdEncoding.EncodeToString(image.Data),
+		},
+	}, nil
+}
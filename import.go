@@ -0,0 +1,363 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// Bounds of a single product import
+const (
+	maxImportBytes = 10 << 20
+	maxImportRows  = 10000
+	// maxImportErrors caps the row errors reported; the failed count covers every row
+	maxImportErrors = 100
+)
+
+// importFields are the product fields that can be imported. The other product fields, such
+// as those of exported files, are ignored.
+var importFields = []string{"code", "name", "description", "category", "price", "currency", "stock"}
+
+// errImportDryRun rolls back the transaction of a dry run
+var errImportDryRun = errors.New("dry run")
+
+// ImportRowError describes a row that could not be imported
+type ImportRowError struct {
+	// Line is the line of the row in the CSV, the header being line 1
+	Line    int          `json:"line"`
+	Code    string       `json:"code,omitempty"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// ImportResult is the result of the import_products tool
+type ImportResult struct {
+	Rows    int  `json:"rows"`
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	Failed  int  `json:"failed"`
+	DryRun  bool `json:"dry_run,omitempty"`
+	// Errors lists the first maxImportErrors failed rows
+	Errors          []ImportRowError `json:"errors,omitempty"`
+	ErrorsTruncated bool             `json:"errors_truncated,omitempty"`
+}
+
+// addError records a failed row
+func (r *ImportResult) addError(line int, code string, err error) {
+	r.Failed++
+	if len(r.Errors) == maxImportErrors {
+		r.ErrorsTruncated = true
+		return
+	}
+	rowErr := ImportRowError{Line: line, Code: code, Message: err.Error()}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		rowErr.Fields = validationErr.Fields
+	}
+	r.Errors = append(r.Errors, rowErr)
+}
+
+// importRow holds the values of a CSV row keyed by product field
+type importRow struct {
+	line   int
+	values map[string]string
+}
+
+// importColumns maps the lower-cased column headings accepted in imports, the field names
+// and their headings in every export language, to product fields
+func importColumns() map[string]string {
+	columns := make(map[string]string)
+	for _, headers := range csvHeaders {
+		for field, heading := range headers {
+			columns[strings.ToLower(heading)] = field
+		}
+	}
+	for field := range productFields {
+		columns[field] = field
+	}
+	return columns
+}
+
+// parseImportCSV parses CSV content into rows keyed by product field. Rows whose number of
+// values differs from the header are reported in result rather than returned.
+func parseImportCSV(content string, delimiter rune, result *ImportResult) ([]importRow, error) {
+	r := csv.NewReader(strings.NewReader(strings.TrimPrefix(content, utf8BOM)))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, invalidField("content", "is empty; expected a header row and product rows")
+	}
+	if err != nil {
+		return nil, invalidField("content", err.Error())
+	}
+
+	accepted := importColumns()
+	columns := make([]string, len(header))
+	seen := make(map[string]bool)
+	for i, heading := range header {
+		field, ok := accepted[strings.ToLower(strings.TrimSpace(heading))]
+		if !ok {
+			return nil, invalidField("content", fmt.Sprintf("unknown column %q", heading))
+		}
+		if seen[field] {
+			return nil, invalidField("content", fmt.Sprintf("column %q appears twice", heading))
+		}
+		seen[field] = true
+		columns[i] = field
+	}
+	if !seen["code"] {
+		return nil, invalidField("content", "a code column is required to match products")
+	}
+
+	var rows []importRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, invalidField("content", err.Error())
+		}
+		result.Rows++
+		if result.Rows > maxImportRows {
+			return nil, invalidField("content", fmt.Sprintf("holds more than %d rows", maxImportRows))
+		}
+
+		line, _ := r.FieldPos(0)
+		if len(record) != len(columns) {
+			result.addError(line, "", fmt.Errorf("has %d values, expected %d", len(record), len(columns)))
+			continue
+		}
+		row := importRow{line: line, values: make(map[string]string, len(columns))}
+		for i, field := range columns {
+			row.values[field] = record[i]
+		}
+		rows = append(rows, row)
+	}
+}
+
+// importProduct creates or updates the product with the code of row in tx and reports
+// whether it was created. Columns present in the row replace the values of the product,
+// except that empty price, currency and stock values keep the current ones.
+func importProduct(tx *gorm.DB, row importRow, decimalSeparator, currency string) (bool, error) {
+	code := strings.TrimSpace(row.values["code"])
+	if code == "" {
+		return false, invalidField("code", "must not be empty")
+	}
+
+	var matches []Product
+	if err := tx.Where("code = ?", code).Limit(1).Find(&matches).Error; err != nil {
+		return false, fmt.Errorf("failed to look up product: %w", err)
+	}
+	product := Product{Code: code, Currency: currency}
+	if len(matches) == 1 {
+		product = matches[0]
+	}
+
+	var fields []FieldError
+	for _, field := range importFields {
+		value, ok := row.values[field]
+		if !ok || field == "code" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch field {
+		case "name":
+			product.Name = value
+		case "description":
+			product.Description = value
+		case "category":
+			product.Category = categoryRef(value)
+		case "price":
+			if value == "" {
+				continue
+			}
+			price, err := strconv.ParseFloat(strings.Replace(value, decimalSeparator, ".", 1), 64)
+			if err != nil {
+				fields = append(fields, FieldError{Field: "price", Message: "must be a number"})
+			}
+			product.Price = price
+		case "currency":
+			if value != "" {
+				product.Currency = strings.ToUpper(value)
+			}
+		case "stock":
+			if value == "" {
+				continue
+			}
+			stock, err := strconv.Atoi(value)
+			if err != nil {
+				fields = append(fields, FieldError{Field: "stock", Message: "must be an integer"})
+			}
+			product.Stock = stock
+		}
+	}
+	if product.ID == 0 && strings.TrimSpace(row.values["price"]) == "" {
+		fields = append(fields, FieldError{Field: "price", Message: "is required for new products"})
+	}
+	if len(fields) > 0 {
+		return false, &ValidationError{Fields: fields}
+	}
+	if err := validateProduct(&product); err != nil {
+		return false, err
+	}
+
+	if err := tx.Save(&product).Error; err != nil {
+		return false, err
+	}
+	return len(matches) == 0, nil
+}
+
+// ImportProducts creates or updates a product for each row, matching products by code, in a
+// single transaction. A row that fails is rolled back on its own and reported in the result;
+// the other rows are still imported. A dry run validates every row and rolls back all of them.
+func (dbs *DBService) ImportProducts(ctx context.Context, content string, opts CSVOptions, currency string, dryRun bool) (*ImportResult, error) {
+	var result *ImportResult
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		result = &ImportResult{DryRun: dryRun}
+		rows, err := parseImportCSV(content, opts.Delimiter, result)
+		if err != nil {
+			return err
+		}
+
+		err = dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows {
+				// A cancelled import stops here and rolls back the rows imported so far
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				savepoint := fmt.Sprintf("import_line_%d", row.line)
+				if err := tx.SavePoint(savepoint).Error; err != nil {
+					return err
+				}
+				created, err := importProduct(tx, row, opts.DecimalSeparator, currency)
+				if err != nil {
+					var validationErr *ValidationError
+					if !errors.As(err, &validationErr) && !errors.Is(err, ErrConflict) {
+						return err
+					}
+					if err := tx.RollbackTo(savepoint).Error; err != nil {
+						return err
+					}
+					result.addError(row.line, strings.TrimSpace(row.values["code"]), err)
+					continue
+				}
+				if created {
+					result.Created++
+				} else {
+					result.Updated++
+				}
+			}
+			if dryRun {
+				return errImportDryRun
+			}
+			return nil
+		})
+		if errors.Is(err, errImportDryRun) {
+			forgetProductChanges(ctx)
+			err = nil
+		}
+		slices.SortFunc(result.Errors, func(a, b ImportRowError) int { return a.Line - b.Line })
+		return err
+	})
+	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to import products: %w", err)
+	}
+	return result, nil
+}
+
+// importFile reads the file name from the import directory. Only relative paths that stay
+// within the directory, after resolving symbolic links, are accepted, and only within the
+// roots of the client of the session of ctx if it declared any.
+func (app *App) importFile(ctx context.Context, name string) (string, error) {
+	cfg := app.config
+	if cfg.ImportDir == "" {
+		return "", fmt.Errorf("%w: importing files is disabled; set IMPORT_DIR to enable it", ErrFailedPrecondition)
+	}
+	if !filepath.IsLocal(name) {
+		return "", invalidField("file", "must be a relative path within the import directory")
+	}
+
+	dir, err := filepath.EvalSymlinks(cfg.ImportDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to open import directory: %w", err)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("import file %w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to open import file: %w", err)
+	}
+	if rel, err := filepath.Rel(dir, path); err != nil || !filepath.IsLocal(rel) {
+		return "", invalidField("file", "must be a relative path within the import directory")
+	}
+	if err := app.checkRoots(ctx, "file", path); err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxImportBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read import file: %w", err)
+	}
+	if len(data) > maxImportBytes {
+		return "", invalidField("file", fmt.Sprintf("must not be larger than %d bytes", maxImportBytes))
+	}
+	return string(data), nil
+}
+
+// importProductsHandler handles the import_products tool request
+func (app *App) importProductsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	content := request.GetString("content", "")
+	file := request.GetString("file", "")
+	if (content == "") == (file == "") {
+		return newToolError(CodeInvalidArgument, "provide either content or file"), nil
+	}
+	if len(content) > maxImportBytes {
+		return toolErrorResult(invalidField("content", fmt.Sprintf("must not be larger than %d bytes", maxImportBytes)))
+	}
+
+	opts, err := parseCSVOptions(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	if file != "" {
+		if content, err = app.importFile(ctx, file); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+
+	result, err := app.dbService.ImportProducts(ctx, content, opts, app.config.Currency, request.GetBool("dry_run", false))
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal import result to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
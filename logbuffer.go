@@ -0,0 +1,132 @@
+package mcpserver
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// recentLogsSize is the number of log records kept for the admin dashboard
+const recentLogsSize = 200
+
+// LogEntry is a log record kept in the recent logs buffer
+type LogEntry struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// logBuffer keeps the most recent log records in a ring buffer
+type logBuffer struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	next    int
+	full    bool
+}
+
+// newLogBuffer creates a buffer holding up to size records
+func newLogBuffer(size int) *logBuffer {
+	return &logBuffer{entries: make([]LogEntry, size)}
+}
+
+// add appends an entry, overwriting the oldest one when the buffer is full
+func (lb *logBuffer) add(entry LogEntry) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	lb.entries[lb.next] = entry
+	lb.next = (lb.next + 1) % len(lb.entries)
+	if lb.next == 0 {
+		lb.full = true
+	}
+}
+
+// Snapshot returns the buffered entries, oldest first
+func (lb *logBuffer) Snapshot() []LogEntry {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if !lb.full {
+		return append([]LogEntry(nil), lb.entries[:lb.next]...)
+	}
+	return append(append([]LogEntry(nil), lb.entries[lb.next:]...), lb.entries[:lb.next]...)
+}
+
+// Wrap returns a handler that records entries in the buffer before passing them to next
+func (lb *logBuffer) Wrap(next slog.Handler) slog.Handler {
+	return &logBufferHandler{buffer: lb, next: next}
+}
+
+// logBufferHandler is a slog.Handler feeding a logBuffer
+type logBufferHandler struct {
+	buffer *logBuffer
+	next   slog.Handler
+	logAttrs
+}
+
+func (h *logBufferHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *logBufferHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.buffer.add(h.entry(record))
+	return h.next.Handle(ctx, record)
+}
+
+func (h *logBufferHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logBufferHandler{buffer: h.buffer, next: h.next.WithAttrs(attrs), logAttrs: h.withAttrs(attrs)}
+}
+
+func (h *logBufferHandler) WithGroup(name string) slog.Handler {
+	return &logBufferHandler{buffer: h.buffer, next: h.next.WithGroup(name), logAttrs: h.withGroup(name)}
+}
+
+// logAttrs holds the attributes and group a handler was derived with, to flatten records
+// into log entries
+type logAttrs struct {
+	attrs []slog.Attr
+	group string
+}
+
+// entry flattens a record into a log entry, its attributes keyed by their dotted group path
+func (la logAttrs) entry(record slog.Record) LogEntry {
+	entry := LogEntry{
+		Time:    record.Time,
+		Level:   record.Level.String(),
+		Message: record.Message,
+	}
+	addAttr := func(a slog.Attr) {
+		if entry.Attrs == nil {
+			entry.Attrs = make(map[string]any)
+		}
+		key := a.Key
+		if la.group != "" {
+			key = la.group + "." + key
+		}
+		entry.Attrs[key] = a.Value.Resolve().String()
+	}
+	for _, a := range la.attrs {
+		addAttr(a)
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		addAttr(a)
+		return true
+	})
+	return entry
+}
+
+// withAttrs returns the attributes extended with attrs
+func (la logAttrs) withAttrs(attrs []slog.Attr) logAttrs {
+	return logAttrs{attrs: append(append([]slog.Attr(nil), la.attrs...), attrs...), group: la.group}
+}
+
+// withGroup returns the attributes within the named group
+func (la logAttrs) withGroup(name string) logAttrs {
+	group := name
+	if la.group != "" {
+		group = la.group + "." + name
+	}
+	return logAttrs{attrs: la.attrs, group: group}
+}
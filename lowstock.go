@@ -0,0 +1,201 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// lowStockURI is the URI of the low-stock alert resource
+const lowStockURI = "alerts://low-stock"
+
+// LowStockAlert is the content of the low-stock alert resource: the products whose stock is
+// below the threshold, lowest stock first
+type LowStockAlert struct {
+	Threshold int          `json:"threshold"`
+	Products  []StockLevel `json:"products"`
+}
+
+// LowStockProducts returns a page of the products whose stock is below threshold, lowest
+// stock first, and the number of such products
+func (dbs *DBService) LowStockProducts(ctx context.Context, threshold, limit, offset int) ([]Product, int, error) {
+	var products []Product
+	var total int64
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		db := dbs.conn(ctx).Model(&Product{}).Where("stock < ?", threshold)
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return err
+		}
+		db = db.Order("stock").Order("id").Offset(offset)
+		if limit > 0 {
+			db = db.Limit(limit)
+		}
+		return db.Find(&products).Error
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve low-stock products: %w", err)
+	}
+	return products, int(total), nil
+}
+
+// lowStockIDs returns the ids of the products whose stock is below threshold
+func (dbs *DBService) lowStockIDs(ctx context.Context, threshold int) ([]uint, error) {
+	var ids []uint
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.conn(ctx).Model(&Product{}).Where("stock < ?", threshold).Pluck("id", &ids).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve low-stock products: %w", err)
+	}
+	return ids, nil
+}
+
+// LowStockMonitor periodically checks which products are below the low-stock threshold and
+// notifies the clients subscribed to the low-stock alert resource that it changed when a
+// product crosses it, in either direction. Only the configured database is checked, not those of tenants.
+type LowStockMonitor struct {
+	app       *App
+	interval  time.Duration
+	threshold int
+
+	mu sync.Mutex
+	// low holds the ids of the products below the threshold at the last check; it is nil
+	// until the first check, which sets the baseline without notifying
+	low map[uint]bool
+}
+
+// NewLowStockMonitor creates a monitor checking stock at the configured interval
+func NewLowStockMonitor(app *App) *LowStockMonitor {
+	return &LowStockMonitor{
+		app:       app,
+		interval:  app.config.LowStockCheckInterval,
+		threshold: app.config.LowStockThreshold,
+	}
+}
+
+// Start checks stock every interval until ctx is cancelled
+func (m *LowStockMonitor) Start(ctx context.Context) {
+	if m.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		m.check(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.check(ctx)
+			}
+		}
+	}()
+}
+
+// check compares the products below the threshold with those of the previous check and
+// notifies the subscribers if any product crossed the threshold
+func (m *LowStockMonitor) check(ctx context.Context) {
+	ids, err := m.app.dbService.lowStockIDs(ctx, m.threshold)
+	if err != nil {
+		slog.Warn("Failed to check low stock", "error", err)
+		return
+	}
+	low := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		low[id] = true
+	}
+
+	m.mu.Lock()
+	previous := m.low
+	m.low = low
+	m.mu.Unlock()
+	if previous == nil || maps.Equal(previous, low) {
+		return
+	}
+
+	var entered, left int
+	for id := range low {
+		if !previous[id] {
+			entered++
+		}
+	}
+	for id := range previous {
+		if !low[id] {
+			left++
+		}
+	}
+	slog.Info("Low stock changed", "below_threshold", len(low), "entered", entered, "left", left)
+	m.app.subscriptions.notify(lowStockURI)
+}
+
+// lowStockHandler handles the low-stock alert resource and template requests. The threshold
+// parameter overrides the configured threshold.
+func (app *App) lowStockHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri, err := url.Parse(request.Params.URI)
+	if err != nil {
+		return nil, resourceError(invalidField("uri", err.Error()))
+	}
+
+	values := uri.Query()
+	var fields []FieldError
+	for name := range values {
+		if name != "threshold" && name != "limit" && name != "cursor" && name != outputFormatArg && name != tenantArg {
+			fields = append(fields, FieldError{Field: name, Message: "unknown parameter"})
+		}
+	}
+	threshold := app.config.LowStockThreshold
+	if v := values.Get("threshold"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			fields = append(fields, FieldError{Field: "threshold", Message: "must be a positive integer"})
+		}
+		threshold = n
+	}
+	var requested, offset int
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxProductListLimit {
+			fields = append(fields, FieldError{Field: "limit", Message: fmt.Sprintf("must be an integer between 1 and %d", maxProductListLimit)})
+		}
+		requested = n
+	}
+	if cursor := values.Get("cursor"); cursor != "" {
+		if offset, err = decodeCursor("cursor", cursor); err != nil {
+			fields = append(fields, FieldError{Field: "cursor", Message: "is not a cursor returned by this server"})
+		}
+	}
+	if len(fields) > 0 {
+		return nil, resourceError(&ValidationError{Fields: fields})
+	}
+
+	limits := app.config.Results
+	products, total, err := app.dbService.LowStockProducts(ctx, threshold, limits.fetchLimit(requested), offset)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+
+	data, n, truncation, err := limitResult(limits, products, offset, total, requested, true, renderJSON(func(page []Product) any {
+		alert := LowStockAlert{Threshold: threshold, Products: make([]StockLevel, len(page))}
+		for i, p := range page {
+			alert.Products[i] = StockLevel{ProductID: p.ID, Code: p.Code, Stock: p.Stock}
+		}
+		return alert
+	}))
+	if err != nil {
+		return nil, resourceError(err)
+	}
+	if err := app.quotas.AddRows(ctx, n); err != nil {
+		return nil, resourceError(err)
+	}
+	return truncatedResourceContents(request.Params.URI, string(data), truncation)
+}
@@ -1,28 +1,65 @@
-package main
+package mcpserver
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"log/slog"
+	"net/url"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+
+	"mcpserver/storage"
+)
+
+// Name and version reported to clients and in telemetry
+const (
+	serverName    = "Demo"
+	serverVersion = "1.0.0"
 )
 
 // Product represents a product in the database
 type Product struct {
 	gorm.Model
-	Code  string
-	Price float64 // Changed to float64 for consistency with calculator
+	// Code is unique among all products, soft-deleted ones included
+	Code        string `gorm:"uniqueIndex"`
+	Name        string
+	Description string
+	// Category is the name of the category of the product, or nil if it is uncategorized
+	Category *string `gorm:"index"`
+	Price    float64 // Changed to float64 for consistency with calculator
+	// Stock is the quantity in stock, changed through adjust_stock; it is never negative
+	Stock int `gorm:"not null;default:0"`
+	// Currency is the ISO 4217 code of the currency of Price
+	Currency string
+	// SupplierID is the id of the supplier of the product, or nil if it has none
+	SupplierID *uint `gorm:"index"`
+
+	// stored is the product as stored before an update, kept for the audit log
+	stored *Product
 }
 
 // DBService encapsulates database operations
 type DBService struct {
 	db *gorm.DB
+	// retry bounds the retries of operations failing with transient errors; none by default
+	retry RetryPolicy
+	// queryTimeout bounds each attempt of an operation; zero means no limit
+	queryTimeout time.Duration
+	// tenants are the databases of the configured tenants, keyed by tenant name
+	tenants map[string]*gorm.DB
 }
 
 // NewDBService creates a new database service
@@ -30,64 +67,262 @@ func NewDBService(db *gorm.DB) *DBService {
 	return &DBService{db: db}
 }
 
-// GetProducts retrieves all products from the database
-func (dbs *DBService) GetProducts() ([]Product, error) {
+// primary returns a session pinned to the primary database of the tenant in ctx, bypassing
+// read replicas
+func (dbs *DBService) primary(ctx context.Context) *gorm.DB {
+	return dbs.conn(ctx).Clauses(dbresolver.Write)
+}
+
+// GetProducts retrieves products from the database, ordered, paged and narrowed as requested.
+// Queries with AsOf set are answered from the product history.
+func (dbs *DBService) GetProducts(ctx context.Context, q ProductQuery) ([]Product, error) {
+	if !q.AsOf.IsZero() {
+		return dbs.GetProductsAsOf(ctx, q.AsOf, q)
+	}
+
+	db := q.page(q.filter(dbs.conn(ctx)))
+	if len(q.Fields) > 0 {
+		// The id and code are always read, for the links to the listed products
+		columns := []string{"id", "code"}
+		for _, name := range q.Fields {
+			if column := productFields[name].Column; !slices.Contains(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+		db = db.Select(columns)
+	}
+
 	var products []Product
-	if err := dbs.db.Find(&products).Error; err != nil {
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return db.WithContext(ctx).Find(&products).Error
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve products: %w", err)
 	}
 	return products, nil
 }
 
+// CountProducts returns the number of products a query matches, ignoring its limit and offset
+func (dbs *DBService) CountProducts(ctx context.Context, q ProductQuery) (int, error) {
+	db := dbs.conn(ctx)
+	if !q.AsOf.IsZero() {
+		db = db.Table("(?) AS r", productsAsOf(db, q.AsOf))
+	} else {
+		db = db.Model(&Product{})
+	}
+	db = q.filter(db)
+
+	var count int64
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return db.WithContext(ctx).Count(&count).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count products: %w", err)
+	}
+	return int(count), nil
+}
+
 // App holds the application components
 type App struct {
-	dbService *DBService
+	config      *Config
+	dbService   *DBService
+	redactor    *Redactor
+	rpcErrors   *rpcErrorMapper
+	backups     *BackupScheduler
+	maintenance *MaintenanceScheduler
+	sessions    SessionStore
+	quotas      *QuotaTracker
+	idempotency *idempotencyStore
+	// confirmations holds the tokens of previewed destructive operations
+	confirmations *confirmationStore
+	stats         *ToolStats
+	// activeSessions tracks connected clients for the admin dashboard
+	activeSessions *SessionRegistry
+	priceWatcher   *PriceWatcher
+	lowStock       *LowStockMonitor
+	subscriptions  *ResourceSubscriptions
+	// toolSwitch enables and disables tools while the server runs
+	toolSwitch *ToolSwitch
+	// cancellations holds the running tool calls clients can cancel
+	cancellations *CancellableCalls
+	// clientCapabilities records the capabilities declared by the client of each session
+	clientCapabilities *SessionCapabilities
+	// roots caches the root directories of the clients declaring them
+	roots *ClientRoots
+	// clientRequests sends clients the requests the library cannot, such as elicitation
+	clientRequests *ClientRequests
+	// inflight counts running tool calls and backups, awaited on shutdown
+	inflight inflightWork
+	// tlsConfig secures the HTTP transports; nil serves plain HTTP
+	tlsConfig *tls.Config
+	// streamSessions tracks the sessions of the Streamable HTTP transport
+	streamSessions *streamSessionManager
+	// declarativeTools are the tools loaded from the tools file
+	declarativeTools []DeclarativeTool
+	// recentLogs keeps the latest log records for the admin dashboard
+	recentLogs *logBuffer
+	// clientLogs forwards log records to the clients that set a logging level
+	clientLogs *clientLogForwarder
+	// outputSchemas maps tool names to their output schemas
+	outputSchemas *sync.Map
+	server        *server.MCPServer
 }
 
 // NewApp creates a new application instance
-func NewApp(dbService *DBService) *App {
-	return &App{
-		dbService: dbService,
+func NewApp(config *Config, dbService *DBService) (*App, error) {
+	redactor, err := NewRedactor(config.RedactFields, config.RedactPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := newSessionStore(config, dbService.db)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := loadTLSConfig(config)
+	if err != nil {
+		return nil, err
 	}
+
+	// Retries and timeouts follow the configuration of the application
+	dbService.retry = config.DBRetry
+	dbService.queryTimeout = config.QueryTimeout
+
+	app := &App{
+		config:         config,
+		dbService:      dbService,
+		redactor:       redactor,
+		rpcErrors:      newRPCErrorMapper(),
+		confirmations:  newConfirmationStore(),
+		sessions:       sessions,
+		stats:          NewToolStats(),
+		activeSessions: NewSessionRegistry(),
+		streamSessions: newStreamSessionManager(config.SessionTTL),
+		tlsConfig:      tlsConfig,
+		quotas:         NewQuotaTracker(config.Quotas, sessions),
+		idempotency:    newIdempotencyStore(dbService, config.IdempotencyTTL),
+	}
+	app.rpcErrors.serverTitle = config.ServerTitle
+	app.backups = NewBackupScheduler(app)
+	app.maintenance = NewMaintenanceScheduler(app)
+	app.priceWatcher = NewPriceWatcher(app)
+	app.lowStock = NewLowStockMonitor(app)
+	app.subscriptions = NewResourceSubscriptions(app)
+	app.toolSwitch = NewToolSwitch()
+	app.cancellations = NewCancellableCalls()
+	app.clientCapabilities = NewSessionCapabilities()
+	app.clientRequests = NewClientRequests()
+	app.roots = NewClientRoots()
+
+	if config.ToolsFile != "" {
+		if app.declarativeTools, err = loadDeclarativeTools(config.ToolsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return app, nil
 }
 
-// initializeDatabase initializes the SQLite database and performs migrations
-func initializeDatabase() (*gorm.DB, error) {
-	// Get database path from environment variable or use default
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "test.db"
+// Start runs the background jobs of the application, scheduled backups, maintenance, price
+// watch and low-stock checks, until ctx is cancelled
+func (app *App) Start(ctx context.Context) {
+	app.backups.Start(ctx)
+	app.maintenance.Start(ctx)
+	app.priceWatcher.Start(ctx)
+	app.lowStock.Start(ctx)
+}
+
+// InitializeDatabase opens the database at the configured URL and applies pending migrations
+// if AutoMigrate is set or the database is ephemeral.
+// When read replicas are configured, queries are routed to them and writes to the primary.
+func InitializeDatabase(cfg *Config) (*gorm.DB, error) {
+	if cfg.Ephemeral() && len(cfg.ReplicaDBPaths) > 0 {
+		return nil, fmt.Errorf("read replicas cannot be used with an in-memory database")
 	}
 
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	db, err := storage.Open(cfg.DBPath, cfg.storageOptions())
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect database: %w", err)
+		return nil, err
+	}
+
+	// Bring the schema up to date, or refuse to run against an outdated one
+	if cfg.AutoMigrate || cfg.Ephemeral() {
+		if _, err := migrate(db); err != nil {
+			return nil, err
+		}
+	} else {
+		pending, err := pendingMigrations(db)
+		if err != nil {
+			return nil, err
+		}
+		if len(pending) > 0 {
+			return nil, fmt.Errorf("%w (%d, starting with %s); run mcpserver -migrate", ErrPendingMigrations, len(pending), pending[0].ID)
+		}
+	}
+	if err := backfillProductHistory(db); err != nil {
+		return nil, err
+	}
+	if err := backfillProductCurrency(db, cfg.Currency); err != nil {
+		return nil, err
 	}
 
-	// Migrate the schema
-	if err := db.AutoMigrate(&Product{}); err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	if len(cfg.ReplicaDBPaths) > 0 {
+		if err := storage.UseReplicas(db, cfg.ReplicaDBPaths, cfg.storageOptions()); err != nil {
+			return nil, err
+		}
 	}
 
 	return db, nil
 }
 
-// seedDatabase creates sample products if the database is empty
-func seedDatabase(db *gorm.DB) error {
+// SeedDatabase creates sample products if the database is empty
+func SeedDatabase(db *gorm.DB) error {
 	var count int64
 	db.Model(&Product{}).Count(&count)
 
 	if count == 0 {
-		// Create some sample products
+		// Create the categories of the sample products unless they exist
+		categories := []Category{
+			{Name: "widgets", Description: "Mechanical widgets"},
+			{Name: "gadgets", Description: "Electronic gadgets"},
+		}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&categories).Error; err != nil {
+			return fmt.Errorf("failed to seed database: %w", err)
+		}
+
+		// Create a sample supplier and some sample products from it
+		supplier := Supplier{Name: "Acme Supply", ContactName: "John Smith", Email: "orders@acme.example", Phone: "+1 555 0199"}
+		if err := db.Create(&supplier).Error; err != nil {
+			return fmt.Errorf("failed to seed database: %w", err)
+		}
 		products := []Product{
-			{Code: "D42", Price: 100.00},
-			{Code: "P99", Price: 200.00},
+			{Code: "D42", Name: "Deluxe Widget", Description: "Brushed steel widget with a lifetime warranty", Category: categoryRef("widgets"), Price: 100.00, Currency: defaultCurrency, Stock: 25, SupplierID: &supplier.ID},
+			{Code: "P99", Name: "Pro Gadget", Description: "Rechargeable gadget for professional workshops", Category: categoryRef("gadgets"), Price: 200.00, Currency: defaultCurrency, Stock: 10, SupplierID: &supplier.ID},
 		}
 
 		if err := db.CreateInBatches(products, len(products)).Error; err != nil {
 			return fmt.Errorf("failed to seed database: %w", err)
 		}
 
+		// And a sample customer with an order of them
+		customer := Customer{Name: "Jane Doe", Email: "jane@example.com"}
+		if err := db.Create(&customer).Error; err != nil {
+			return fmt.Errorf("failed to seed database: %w", err)
+		}
+		order := Order{
+			CustomerID: &customer.ID,
+			Currency:   defaultCurrency,
+			Total:      400.00,
+			Items: []OrderItem{
+				{ProductID: products[0].ID, Code: products[0].Code, Quantity: 2, UnitPrice: products[0].Price, Total: 200.00},
+				{ProductID: products[1].ID, Code: products[1].Code, Quantity: 1, UnitPrice: products[1].Price, Total: 200.00},
+			},
+		}
+		if err := db.Create(&order).Error; err != nil {
+			return fmt.Errorf("failed to seed database: %w", err)
+		}
+
 		log.Println("Database seeded with sample products")
 	}
 
@@ -98,7 +333,7 @@ func seedDatabase(db *gorm.DB) error {
 func (app *App) helloHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	name, err := request.RequireString("name")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return argumentError("name", err), nil
 	}
 
 	return mcp.NewToolResultText(fmt.Sprintf("Hello, %s!", name)), nil
@@ -106,75 +341,187 @@ func (app *App) helloHandler(ctx context.Context, request mcp.CallToolRequest) (
 
 // listProductsHandler handles the products resource request
 func (app *App) listProductsHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-	products, err := app.dbService.GetProducts()
+	uri, err := url.Parse(request.Params.URI)
 	if err != nil {
-		return nil, err
+		return nil, resourceError(invalidField("uri", err.Error()))
+	}
+	query, err := parseProductQuery(uri.Query())
+	if err != nil {
+		return nil, resourceError(err)
 	}
 
-	jsonData, err := json.MarshalIndent(products, "", "  ")
+	text, _, truncation, err := app.queryProducts(ctx, query, formatJSON)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal products to JSON: %w", err)
+		return nil, resourceError(err)
 	}
 
-	return []mcp.ResourceContents{
-		mcp.TextResourceContents{
-			URI:      "products://list",
-			MIMEType: "application/json",
-			Text:     string(jsonData),
-		},
-	}, nil
+	return truncatedResourceContents(request.Params.URI, text, truncation)
 }
 
-// calculateHandler handles the calculate tool request
-func (app *App) calculateHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// calculatorOperations lists the operations of the calculate tools
+var calculatorOperations = []string{"add", "subtract", "multiply", "divide"}
+
+// calculate applies an arithmetic operation to x and y
+func calculate(op string, x, y float64) (float64, error) {
+	switch op {
+	case "add":
+		return x + y, nil
+	case "subtract":
+		return x - y, nil
+	case "multiply":
+		return x * y, nil
+	case "divide":
+		if y == 0 {
+			return 0, invalidField("y", "cannot divide by zero")
+		}
+		return x / y, nil
+	default:
+		return 0, invalidField("operation", fmt.Sprintf("unsupported operation: %s", op))
+	}
+}
+
+// calculateArgs extracts the arguments of the calculate tool
+func calculateArgs(request mcp.CallToolRequest) (string, float64, float64, *mcp.CallToolResult) {
 	// Using helper functions for type-safe argument access
 	op, err := request.RequireString("operation")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return "", 0, 0, argumentError("operation", err)
 	}
 
 	x, err := request.RequireFloat("x")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return "", 0, 0, argumentError("x", err)
 	}
 
 	y, err := request.RequireFloat("y")
 	if err != nil {
-		return mcp.NewToolResultError(err.Error()), nil
+		return "", 0, 0, argumentError("y", err)
 	}
 
-	var result float64
-	switch op {
-	case "add":
-		result = x + y
-	case "subtract":
-		result = x - y
-	case "multiply":
-		result = x * y
-	case "divide":
-		if y == 0 {
-			return mcp.NewToolResultError("cannot divide by zero"), nil
-		}
-		result = x / y
-	default:
-		return mcp.NewToolResultError(fmt.Sprintf("unsupported operation: %s", op)), nil
+	return op, x, y, nil
+}
+
+// calculateHandler handles version 1 of the calculate tool, which returns the result as text rounded to two decimals
+func (app *App) calculateHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	op, x, y, errResult := calculateArgs(request)
+	if errResult != nil {
+		return errResult, nil
 	}
 
+	result, err := calculate(op, x, y)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	setStructuredContent(ctx, CalculationResult{Operation: op, X: x, Y: y, Result: result})
 	return mcp.NewToolResultText(fmt.Sprintf("%.2f", result)), nil
 }
 
-// setupServer creates and configures the MCP server with tools and resources
-func (app *App) setupServer() *server.MCPServer {
+// CalculationResult is the output of version 2 of the calculate tool
+type CalculationResult struct {
+	Operation string  `json:"operation"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Result    float64 `json:"result"`
+}
+
+// calculateV2Handler handles version 2 of the calculate tool, which returns the unrounded result as JSON
+func (app *App) calculateV2Handler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	op, x, y, errResult := calculateArgs(request)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	result, err := calculate(op, x, y)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	calculation := CalculationResult{Operation: op, X: x, Y: y, Result: result}
+	jsonData, err := json.Marshal(calculation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal calculation result to JSON: %w", err)
+	}
+	setStructuredContent(ctx, calculation)
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// listPagination pages list responses by size entries; zero disables paging, which the
+// library option cannot express
+func listPagination(size int) server.ServerOption {
+	if size == 0 {
+		return func(*server.MCPServer) {}
+	}
+	return server.WithPaginationLimit(size)
+}
+
+// NewServer creates and configures the MCP server with tools and resources. It fails if a
+// tool of the tools file is named like a built-in tool.
+func (app *App) NewServer() (*server.MCPServer, error) {
+	app.recentLogs = newLogBuffer(recentLogsSize)
+	app.clientLogs = newClientLogForwarder()
+	app.outputSchemas = &sync.Map{}
+	app.rpcErrors.outputSchemas = app.outputSchemas
+	if app.config.logHandler != nil {
+		slog.SetDefault(slog.New(app.clientLogs.Wrap(app.recentLogs.Wrap(app.config.logHandler))))
+	}
+
+	hooks := &server.Hooks{}
+	hooks.AddOnError(app.rpcErrors.onError)
+	hooks.AddOnRegisterSession(app.activeSessions.onRegister)
+	hooks.AddOnUnregisterSession(app.activeSessions.onUnregister)
+	hooks.AddAfterInitialize(app.activeSessions.afterInitialize)
+	hooks.AddOnRequestInitialization(app.subscriptions.onRequest)
+	hooks.AddOnRequestInitialization(app.completeRequest)
+	hooks.AddOnRequestInitialization(app.clientCapabilities.onRequest)
+	hooks.AddAfterInitialize(app.clientCapabilities.afterInitialize)
+	hooks.AddAfterInitialize(advertiseCompletions)
+	hooks.AddAfterSetLevel(app.clientLogs.afterSetLevel)
+	hooks.AddBeforeCallTool(app.cancellations.beforeCallTool)
+	// Session state outlives the listening streams of a Streamable HTTP session
+	sessionEndHooks := []server.OnUnregisterSessionHookFunc{app.sessionClosed, app.priceWatcher.onUnregister, app.subscriptions.onUnregister, app.clientLogs.onUnregister, app.clientCapabilities.onUnregister, app.roots.onUnregister, app.rpcErrors.onUnregister}
+	for _, hook := range sessionEndHooks {
+		hooks.AddOnUnregisterSession(app.streamSessions.unlessOpen(hook))
+	}
+	app.streamSessions.onClose = sessionEndHooks
+
 	// Create a new MCP server
 	s := server.NewMCPServer(
-		"Demo",
-		"1.0.0",
-		server.WithToolCapabilities(false),
+		serverName,
+		serverVersion,
+		server.WithToolCapabilities(true),
+		server.WithPromptCapabilities(true),
+		server.WithResourceCapabilities(true, false),
+		server.WithToolHandlerMiddleware(app.inflightMiddleware),
+		server.WithToolHandlerMiddleware(app.cancellations.middleware),
+		server.WithToolHandlerMiddleware(app.telemetryMiddleware),
+		server.WithToolHandlerMiddleware(app.loggingMiddleware),
+		server.WithToolHandlerMiddleware(app.toolSwitch.middleware),
+		server.WithToolHandlerMiddleware(app.formatMiddleware),
+		server.WithToolHandlerMiddleware(app.tenantMiddleware),
+		server.WithToolHandlerMiddleware(app.idempotencyMiddleware),
+		server.WithToolHandlerMiddleware(app.quotaMiddleware),
+		server.WithToolHandlerMiddleware(app.auditMiddleware),
+		server.WithToolHandlerMiddleware(app.subscriptions.middleware),
+		server.WithToolHandlerMiddleware(app.structuredOutputMiddleware),
+		server.WithToolFilter(app.toolSwitch.filter),
+		server.WithToolFilter(app.clientCapabilities.filter),
+		server.WithToolFilter(app.describeTools),
+		server.WithInstructions(app.config.Instructions),
+		listPagination(app.config.ListPageSize),
+		server.WithHooks(hooks),
+		server.WithLogging(),
 	)
+	app.server = s
+	app.clientLogs.attach(s)
+	s.AddNotificationHandler(methodNotificationCancelled, app.cancellations.onCancelled)
+	s.AddNotificationHandler(methodNotificationRootsChanged, app.roots.onChanged)
 
 	// Add hello_world tool
 	helloTool := mcp.NewTool("hello_world",
 		mcp.WithDescription("Say hello to someone"),
+		readOnlyTool(),
 		mcp.WithString("name",
 			mcp.Required(),
 			mcp.Description("Name of the person to greet"),
@@ -186,15 +533,229 @@ func (app *App) setupServer() *server.MCPServer {
 	productsResource := mcp.NewResource("products://list", "Product List",
 		mcp.WithResourceDescription("Lists all available products"),
 	)
-	s.AddResource(productsResource, app.listProductsHandler)
+	s.AddResource(productsResource, app.formatResource(app.listProductsHandler))
+
+	// Add products template accepting sort, limit and fields query parameters
+	productsQueryTemplate := mcp.NewResourceTemplate("products://list{?sort,limit,cursor,fields,as_of,category,tag,output_format,tenant}", "Product List Query",
+		mcp.WithTemplateDescription("Lists products; sort names a field (prefix - or suffix :desc, percent-encoded as %3Adesc, for descending), limit caps the rows, cursor continues a truncated listing, fields is a comma-separated list of id, code, name, description, category, price, currency, stock, created_at, updated_at, as_of (RFC 3339) returns the catalog as it was at that time, category and tag restrict it to the products of a category or carrying a tag, output_format (json, yaml or toml) overrides the session preference and tenant selects the database of a tenant"),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	s.AddResourceTemplate(productsQueryTemplate, app.formatResource(app.productsResourceHandler))
+
+	// Add single product templates, by id and by code
+	productTemplate := mcp.NewResourceTemplate("products://{id}{?fields,output_format,tenant}", "Product",
+		mcp.WithTemplateDescription("A single product by id, e.g. products://42; fields is a comma-separated list of the fields to return"),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	s.AddResourceTemplate(productTemplate, app.formatResource(app.productsResourceHandler))
+
+	productByCodeTemplate := mcp.NewResourceTemplate("products://"+productCodeURIHost+"/{code}{?fields,output_format,tenant}", "Product by Code",
+		mcp.WithTemplateDescription("A single product by code, e.g. products://code/D42, the code being percent-encoded; fields is a comma-separated list of the fields to return"),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	s.AddResourceTemplate(productByCodeTemplate, app.formatResource(app.productsResourceHandler))
+
+	// Add products tool mirroring the products resource for clients without resource support
+	listProductsTool := mcp.NewTool("list_products",
+		mcp.WithDescription("List products, optionally sorted, limited and restricted to some fields; the result ends with a link to the products://{id} resource of each product listed"),
+		readOnlyTool(),
+		mcp.WithString("sort",
+			mcp.Description("Field to sort by (id, code, name, description, category, price, currency, stock, created_at or updated_at), prefixed with - or suffixed with :desc for descending order, e.g. price:desc"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of products to return (at most %d)", maxProductListLimit)),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("next_cursor from the truncation metadata of a previous call, to fetch the following page"),
+		),
+		withFields(),
+		mcp.WithString("as_of",
+			mcp.Description("RFC 3339 timestamp; returns the products as they were at that time, reconstructed from the product history"),
+		),
+		mcp.WithString("category",
+			mcp.Description("Name of a category; only the products of that category are listed"),
+		),
+		mcp.WithString("tag",
+			mcp.Description("Name of a tag; only the products carrying that tag are listed"),
+		),
+		withOutputFormat(),
+	)
+	s.AddTool(listProductsTool, app.listProductsToolHandler)
+
+	// Add category tools
+	listCategoriesTool := mcp.NewTool("list_categories",
+		mcp.WithDescription("List the product categories with the number of products in each"),
+		readOnlyTool(),
+	)
+	s.AddTool(listCategoriesTool, app.listCategoriesHandler)
+
+	createCategoryTool := mcp.NewTool("create_category",
+		mcp.WithDescription("Create a product category; products are assigned to it by name with create_product or update_product"),
+		writeTool(false),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Unique name of the category, e.g. widgets"),
+		),
+		mcp.WithString("description",
+			mcp.Description("Description of the category"),
+		),
+	)
+	s.AddTool(createCategoryTool, app.createCategoryHandler)
+
+	// Add catalog statistics, aggregated by the database
+	productStatsTool := mcp.NewTool("product_stats",
+		mcp.WithDescription("Catalog statistics computed by the database: the number of products, the minimum, maximum and average price per currency, the number of products per category and the newest and oldest products"),
+		readOnlyTool(),
+		app.withOutputSchema(productStatsOutputSchema),
+	)
+	s.AddTool(productStatsTool, app.productStatsHandler)
+
+	findDuplicatesTool := mcp.NewTool("find_duplicates",
+		mcp.WithDescription("Find groups of likely duplicate products: codes identical once lower-cased and stripped of punctuation and spaces, and names identical or within max_distance edits of each other. Each group suggests a merge_products call keeping its oldest product, and the result ends with links to the resources of the products found"),
+		readOnlyTool(),
+		mcp.WithArray("fields",
+			mcp.Description("Fields to compare (default code and name)"),
+			mcp.WithStringEnumItems(duplicateFields),
+		),
+		mcp.WithNumber("max_distance",
+			mcp.Description(fmt.Sprintf("Maximum number of character edits between names still considered duplicates, 0 to %d (default 1); names shorter than %d characters must be identical", maxDuplicateDistance, minFuzzyNameLength)),
+		),
+	)
+	s.AddTool(findDuplicatesTool, app.findDuplicatesHandler)
+
+	// Add promotions, discounting the prices of products while they are valid
+	createPromotionTool := mcp.NewTool("create_promotion",
+		mcp.WithDescription("Create a promotion discounting the products of some categories, or of every category, by a percentage or a fixed amount during a validity window; get_effective_price applies it"),
+		writeTool(false),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Unique name of the promotion, e.g. summer-sale"),
+		),
+		mcp.WithString("type",
+			mcp.Required(),
+			mcp.Description("percentage takes value percent off the price, fixed takes value off in currency"),
+			mcp.Enum(promotionPercentage, promotionFixed),
+		),
+		mcp.WithNumber("value",
+			mcp.Required(),
+			mcp.Description("Percentage (at most 100) or amount of the discount"),
+		),
+		mcp.WithString("currency",
+			mcp.Description("ISO 4217 code of the currency of a fixed discount; defaults to the configured currency. Products priced in another currency get the discount converted at the configured exchange rates"),
+		),
+		mcp.WithArray("categories",
+			mcp.Description("Categories the promotion applies to; it applies to every product if omitted"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("valid_from",
+			mcp.Description("RFC 3339 timestamp the promotion starts at; defaults to now"),
+		),
+		mcp.WithString("valid_until",
+			mcp.Description("RFC 3339 timestamp the promotion ends at, excluded; it does not end if omitted"),
+		),
+		withIdempotencyKey(),
+	)
+	s.AddTool(createPromotionTool, app.createPromotionHandler)
+
+	listPromotionsTool := mcp.NewTool("list_promotions",
+		mcp.WithDescription("List the promotions, or those valid at a given time"),
+		readOnlyTool(),
+		mcp.WithBoolean("active",
+			mcp.Description("Only list the promotions valid now"),
+		),
+		mcp.WithString("at",
+			mcp.Description("RFC 3339 timestamp; only list the promotions valid at that time"),
+		),
+	)
+	s.AddTool(listPromotionsTool, app.listPromotionsHandler)
+
+	getEffectivePriceTool := mcp.NewTool("get_effective_price",
+		mcp.WithDescription("Get the price of a product after the promotions valid for it; promotions do not stack, the one giving the lowest price applies"),
+		readOnlyTool(),
+		mcp.WithNumber("id",
+			mcp.Description("ID of the product"),
+		),
+		mcp.WithString("code",
+			mcp.Description("Code of the product, instead of id"),
+		),
+		mcp.WithString("at",
+			mcp.Description("RFC 3339 timestamp to price the product at; defaults to now"),
+		),
+	)
+	s.AddTool(getEffectivePriceTool, app.getEffectivePriceHandler)
+
+	// Add CSV and JSON export; the CSV dialect options suit the spreadsheet locale
+	exportProductsTool := mcp.NewTool("export_products",
+		mcp.WithDescription("Export the catalog, optionally filtered, as a CSV or JSON file. For CSV the delimiter, decimal separator, encoding and header language can be chosen to suit the spreadsheet locale (e.g. semicolon, comma and utf-8-bom for European Excel). A catalog above the result limits is exported in chunks, each a complete file; pass next_cursor from the truncation metadata to export the next chunk"),
+		readOnlyTool(),
+		mcp.WithString("format",
+			mcp.Description("Format of the exported file (default csv)"),
+			mcp.Enum(exportFormatCSV, exportFormatJSON),
+		),
+		withFields(),
+		mcp.WithString("category",
+			mcp.Description("Name of a category; only the products of that category are exported"),
+		),
+		mcp.WithString("tag",
+			mcp.Description("Name of a tag; only the products carrying that tag are exported"),
+		),
+		mcp.WithObject("filter",
+			mcp.Description(`Conditions on product fields, all of which must hold, e.g. {"price": {"lt": 10}}. Operators: eq, ne, lt, lte, gt, gte, like, in; a bare value means eq`),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("next_cursor from the truncation metadata of a previous call, to export the following chunk"),
+		),
+		mcp.WithString("delimiter",
+			mcp.Description("Field delimiter"),
+			mcp.Enum("comma", "semicolon", "tab"),
+		),
+		mcp.WithString("decimal_separator",
+			mcp.Description("Decimal separator of prices"),
+			mcp.Enum(".", ","),
+		),
+		mcp.WithString("encoding",
+			mcp.Description("utf-8-bom prefixes a byte order mark so that Excel detects UTF-8"),
+			mcp.Enum(encodingUTF8, encodingUTF8BOM),
+		),
+		mcp.WithString("header_language",
+			mcp.Description("Language of the column headings"),
+			mcp.Enum(csvHeaderLanguages()...),
+		),
+	)
+	s.AddTool(exportProductsTool, app.exportProductsHandler)
+
+	importProductsTool := mcp.NewTool("import_products",
+		mcp.WithDescription("Import products from CSV, creating or updating one product per row matched by code. The header names the columns: code, name, description, category, price, currency and stock, or their headings in any export language; other export columns are ignored. Empty price, currency and stock values keep the current ones. Rows that fail are reported with their line and do not stop the others"),
+		writeTool(true),
+		mcp.WithString("content",
+			mcp.Description("CSV text including the header row"),
+		),
+		mcp.WithString("file",
+			mcp.Description("Path of a CSV file relative to the configured import directory, instead of content; clients declaring roots must include the file in one of them"),
+		),
+		mcp.WithString("delimiter",
+			mcp.Description("Field delimiter"),
+			mcp.Enum("comma", "semicolon", "tab"),
+		),
+		mcp.WithString("decimal_separator",
+			mcp.Description("Decimal separator of prices"),
+			mcp.Enum(".", ","),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Validate every row and report the result without saving anything"),
+		),
+		withIdempotencyKey(),
+	)
+	s.AddTool(importProductsTool, app.importProductsHandler)
 
-	// Add calculator tool
-	calculatorTool := mcp.NewTool("calculate",
-		mcp.WithDescription("Perform basic arithmetic operations"),
+	// Add calculator tool in both output versions
+	calculatorOptions := []mcp.ToolOption{
+		readOnlyTool(),
+		app.withOutputSchema(calculationOutputSchema),
 		mcp.WithString("operation",
 			mcp.Required(),
 			mcp.Description("The operation to perform (add, subtract, multiply, divide)"),
-			mcp.Enum("add", "subtract", "multiply", "divide"),
+			mcp.Enum(calculatorOperations...),
 		),
 		mcp.WithNumber("x",
 			mcp.Required(),
@@ -204,33 +765,966 @@ func (app *App) setupServer() *server.MCPServer {
 			mcp.Required(),
 			mcp.Description("Second number"),
 		),
+	}
+	app.addVersionedTool(s, "calculate", []toolVersion{
+		{
+			Version:     "v1",
+			Description: "Perform basic arithmetic operations; returns the result as text rounded to two decimals",
+			Options:     calculatorOptions,
+			Handler:     app.calculateHandler,
+		},
+		{
+			Version:     "v2",
+			Description: "Perform basic arithmetic operations; returns the operands and the unrounded result as JSON",
+			Options:     calculatorOptions,
+			Handler:     app.calculateV2Handler,
+		},
+	})
+
+	// Add product write tools
+	createProductTool := mcp.NewTool("create_product",
+		mcp.WithDescription("Create a new product"),
+		writeTool(false),
+		mcp.WithString("code",
+			mcp.Required(),
+			mcp.Description("Product code"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Display name of the product"),
+		),
+		mcp.WithString("description",
+			mcp.Description("Description of the product"),
+		),
+		mcp.WithString("category",
+			mcp.Description("Name of an existing category of the product, e.g. widgets; see list_categories"),
+		),
+		mcp.WithNumber("price",
+			mcp.Description("Product price; required unless the client supports elicitation, through which the user is then asked for it"),
+		),
+		mcp.WithString("currency",
+			mcp.Description("ISO 4217 code of the currency of the price; defaults to the configured currency"),
+		),
+		mcp.WithNumber("stock",
+			mcp.Description("Initial quantity in stock; later changes go through adjust_stock"),
+		),
+		mcp.WithNumber("supplier_id",
+			mcp.Description("ID of the supplier of the product; see list_suppliers"),
+		),
+		withFields(),
+		withIdempotencyKey(),
 	)
-	s.AddTool(calculatorTool, app.calculateHandler)
+	s.AddTool(createProductTool, app.createProductHandler)
 
-	return s
+	getProductTool := mcp.NewTool("get_product",
+		mcp.WithDescription("Look up a single product by id or by code, optionally with its image for multimodal clients"),
+		readOnlyTool(),
+		app.withOutputSchema(productOutputSchema),
+		mcp.WithNumber("id",
+			mcp.Description("ID of the product"),
+		),
+		mcp.WithString("code",
+			mcp.Description("Code of the product, if no id is given"),
+		),
+		mcp.WithBoolean("include_image",
+			mcp.Description("Also return the image of the product, if it has one, as image content after the JSON"),
+		),
+		withFields(),
+	)
+	s.AddTool(getProductTool, app.getProductHandler)
+
+	// Add description drafting by the client LLM through sampling
+	s.EnableSampling()
+	generateDescriptionTool := mcp.NewTool("generate_description",
+		mcp.WithDescription("Ask the client LLM, through MCP sampling, to draft a description of a product from its code, name, category and price, and optionally save it. Requires a client supporting sampling"),
+		writeTool(false),
+		mcp.WithOpenWorldHintAnnotation(true),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the product to describe"),
+		),
+		mcp.WithString("guidance",
+			mcp.Description("Optional guidance for the draft, e.g. tone or points to stress"),
+		),
+		mcp.WithBoolean("save",
+			mcp.Description("Save the draft as the description of the product (default false)"),
+		),
+		withFields(),
+	)
+	s.AddTool(generateDescriptionTool, app.generateDescriptionHandler)
+
+	updateProductTool := mcp.NewTool("update_product",
+		mcp.WithDescription("Update the code, name, description, category, price, currency and/or supplier of an existing product"),
+		writeTool(true),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the product to update"),
+		),
+		mcp.WithString("code",
+			mcp.Description("New product code"),
+		),
+		mcp.WithString("name",
+			mcp.Description("New display name"),
+		),
+		mcp.WithString("description",
+			mcp.Description("New description"),
+		),
+		mcp.WithString("category",
+			mcp.Description("Name of an existing category, or an empty string to uncategorize the product"),
+		),
+		mcp.WithNumber("price",
+			mcp.Description("New product price"),
+		),
+		mcp.WithString("currency",
+			mcp.Description("ISO 4217 code of the currency of the price"),
+		),
+		mcp.WithNumber("supplier_id",
+			mcp.Description("ID of the new supplier of the product, or 0 to remove its supplier"),
+		),
+		withFields(),
+		withIdempotencyKey(),
+	)
+	s.AddTool(updateProductTool, app.updateProductHandler)
+
+	patchProductTool := mcp.NewTool("patch_product",
+		mcp.WithDescription("Atomically apply a JSON Patch (RFC 6902) or JSON Merge Patch (RFC 7396) to a product; the patched fields are code, name, description, category, price and currency"),
+		writeTool(false),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the product to patch"),
+		),
+		mcp.WithArray("json_patch",
+			mcp.Description("JSON Patch operations, e.g. [{\"op\": \"replace\", \"path\": \"/price\", \"value\": 10}]; a failing test operation aborts the patch"),
+			mcp.Items(map[string]any{"type": "object"}),
+		),
+		mcp.WithObject("merge_patch",
+			mcp.Description("JSON Merge Patch document, e.g. {\"price\": 10}"),
+		),
+		withFields(),
+		withIdempotencyKey(),
+	)
+	s.AddTool(patchProductTool, app.patchProductHandler)
+
+	// Add stock tools
+	getStockTool := mcp.NewTool("get_stock",
+		mcp.WithDescription("Get the quantity in stock of a product, looked up by id or by code, with the stock of each of its variants"),
+		readOnlyTool(),
+		mcp.WithNumber("id",
+			mcp.Description("ID of the product"),
+		),
+		mcp.WithString("code",
+			mcp.Description("Code of the product, if no id is given"),
+		),
+	)
+	s.AddTool(getStockTool, app.getStockHandler)
+
+	adjustStockTool := mcp.NewTool("adjust_stock",
+		mcp.WithDescription("Add to or remove from the stock of a product; fails without changing it if the stock would become negative"),
+		writeTool(false),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the product"),
+		),
+		mcp.WithNumber("delta",
+			mcp.Required(),
+			mcp.Description("Quantity to add, or to remove if negative, e.g. -3 for three units sold"),
+		),
+		mcp.WithNumber("variant_id",
+			mcp.Description("ID of a variant of the product, as listed by list_variants, to adjust the stock of that variant instead"),
+		),
+		withIdempotencyKey(),
+	)
+	s.AddTool(adjustStockTool, app.adjustStockHandler)
+
+	// Add product variants, such as sizes and colors, each with its own SKU and stock
+	listVariantsTool := mcp.NewTool("list_variants",
+		mcp.WithDescription("List the variants of a product with their SKU, size, color, price and stock, and their total stock"),
+		readOnlyTool(),
+		mcp.WithNumber("product_id",
+			mcp.Required(),
+			mcp.Description("ID of the product"),
+		),
+	)
+	s.AddTool(listVariantsTool, app.listVariantsHandler)
+
+	createVariantTool := mcp.NewTool("create_variant",
+		mcp.WithDescription("Add a variant to a product; a product has at most one variant of each size and color, and SKUs are unique"),
+		writeTool(false),
+		mcp.WithNumber("product_id",
+			mcp.Required(),
+			mcp.Description("ID of the product"),
+		),
+		mcp.WithString("sku",
+			mcp.Required(),
+			mcp.Description("Unique stock keeping unit of the variant, e.g. P99-BLUE-L"),
+		),
+		mcp.WithString("size",
+			mcp.Description("Size of the variant, e.g. L; size or color must be given"),
+		),
+		mcp.WithString("color",
+			mcp.Description("Color of the variant, e.g. blue"),
+		),
+		mcp.WithNumber("price",
+			mcp.Description("Price of the variant, if it differs from that of the product"),
+		),
+		mcp.WithNumber("stock",
+			mcp.Description("Initial stock of the variant (default 0)"),
+		),
+		withIdempotencyKey(),
+	)
+	s.AddTool(createVariantTool, app.createVariantHandler)
+
+	// Add orders, which take the ordered quantities out of stock
+	placeOrderTool := mcp.NewTool("place_order",
+		mcp.WithDescription("Place an order for products, or variants of them, in stock; the quantities are taken out of stock in one transaction and the order fails as a whole if any item lacks stock. Items are priced at the current price with the best active promotion applied"),
+		writeTool(false),
+		mcp.WithArray("items",
+			mcp.Required(),
+			mcp.Description(fmt.Sprintf("Items ordered (at most %d), e.g. [{\"code\": \"P99\", \"quantity\": 2}]; each gives a product_id or code, a quantity and, for products with variants, the variant_id", maxOrderItems)),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"product_id": map[string]any{"type": "integer"},
+					"code":       map[string]any{"type": "string"},
+					"variant_id": map[string]any{"type": "integer"},
+					"quantity":   map[string]any{"type": "integer", "minimum": 1},
+				},
+				"required": []string{"quantity"},
+			}),
+		),
+		mcp.WithString("currency",
+			mcp.Description(fmt.Sprintf("ISO 4217 currency code of the order; prices in other currencies are converted (default %s)", app.config.Currency)),
+		),
+		mcp.WithNumber("customer_id",
+			mcp.Description("ID of the customer placing the order, as returned by create_customer or search_customers"),
+		),
+		withIdempotencyKey(),
+	)
+	s.AddTool(placeOrderTool, app.placeOrderHandler)
+
+	getOrderTool := mcp.NewTool("get_order",
+		mcp.WithDescription("Get an order with its items"),
+		readOnlyTool(),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the order"),
+		),
+	)
+	s.AddTool(getOrderTool, app.getOrderHandler)
+
+	listOrdersTool := mcp.NewTool("list_orders",
+		mcp.WithDescription("List orders with their items, most recent first; with customer_id, since and until it tells what a customer ordered in a period"),
+		readOnlyTool(),
+		mcp.WithNumber("customer_id",
+			mcp.Description("Only return the orders of this customer"),
+		),
+		mcp.WithNumber("product_id",
+			mcp.Description("Only return the orders with an item of this product"),
+		),
+		mcp.WithString("since",
+			mcp.Description("RFC 3339 timestamp; only orders placed from then on are returned"),
+		),
+		mcp.WithString("until",
+			mcp.Description("RFC 3339 timestamp; only orders placed before then are returned"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of orders to return (at most %d)", maxProductListLimit)),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("next_cursor from the truncation metadata of a previous call, to fetch the following page"),
+		),
+	)
+	s.AddTool(listOrdersTool, app.listOrdersHandler)
+
+	// Add customers, on whose behalf orders are placed
+	createCustomerTool := mcp.NewTool("create_customer",
+		mcp.WithDescription("Add a customer; email addresses are unique across customers"),
+		writeTool(false),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the customer"),
+		),
+		mcp.WithString("email",
+			mcp.Required(),
+			mcp.Description("Email address of the customer, e.g. jane@example.com"),
+		),
+		mcp.WithString("phone",
+			mcp.Description("Phone number of the customer"),
+		),
+		withIdempotencyKey(),
+	)
+	s.AddTool(createCustomerTool, app.createCustomerHandler)
+
+	searchCustomersTool := mcp.NewTool("search_customers",
+		mcp.WithDescription("Find customers whose name or email contains the query, ignoring case, with their number of orders and the time of their last one; list_orders with customer_id lists their orders"),
+		readOnlyTool(),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("Part of the name or email address of the customers, e.g. jane"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of customers to return (default 20, at most %d)", maxCustomerSearchLimit)),
+		),
+	)
+	s.AddTool(searchCustomersTool, app.searchCustomersHandler)
+
+	// Add full-text search, where the database has the index of migration 0016_product_search
+	if app.dbService.hasProductSearch() {
+		fulltextSearchTool := mcp.NewTool("fulltext_search",
+			mcp.WithDescription("Search the names and descriptions of products by relevance, e.g. \"steel widget\" or \"gadget NOT pro\"; far faster than like filters on large catalogs. Each hit has the product, its BM25 rank (lower is more relevant) and a snippet with the matched terms in brackets"),
+			readOnlyTool(),
+			mcp.WithString("query",
+				mcp.Required(),
+				mcp.Description("SQLite FTS5 query: terms, \"quoted phrases\", prefix* terms, AND, OR, NOT and column filters such as name:widget"),
+			),
+			withFields(),
+			mcp.WithNumber("limit",
+				mcp.Description(fmt.Sprintf("Maximum number of products to return (default and at most %d)", maxSearchResults)),
+			),
+			mcp.WithString("cursor",
+				mcp.Description("next_cursor from the truncation metadata of a previous call, to fetch the following page"),
+			),
+		)
+		s.AddTool(fulltextSearchTool, app.fulltextSearchHandler)
+	}
+
+	// Add suppliers of products, with their contact details as resources
+	supplierOptions := []mcp.ToolOption{
+		mcp.WithString("contact_name",
+			mcp.Description("Name of the contact person at the supplier"),
+		),
+		mcp.WithString("email",
+			mcp.Description("Email address of the supplier, e.g. orders@acme.example"),
+		),
+		mcp.WithString("phone",
+			mcp.Description("Phone number of the supplier"),
+		),
+		mcp.WithString("address",
+			mcp.Description("Postal address of the supplier"),
+		),
+		withIdempotencyKey(),
+	}
+	createSupplierTool := mcp.NewTool("create_supplier", append([]mcp.ToolOption{
+		mcp.WithDescription("Add a supplier with its contact details; supplier names are unique. create_product and update_product link products to it with supplier_id"),
+		writeTool(false),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the supplier"),
+		),
+	}, supplierOptions...)...)
+	s.AddTool(createSupplierTool, app.createSupplierHandler)
+
+	updateSupplierTool := mcp.NewTool("update_supplier", append([]mcp.ToolOption{
+		mcp.WithDescription("Update the name and/or contact details of a supplier; only the given fields change and an empty string clears a contact detail"),
+		writeTool(true),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the supplier to update"),
+		),
+		mcp.WithString("name",
+			mcp.Description("New name of the supplier"),
+		),
+	}, supplierOptions...)...)
+	s.AddTool(updateSupplierTool, app.updateSupplierHandler)
+
+	listSuppliersTool := mcp.NewTool("list_suppliers",
+		mcp.WithDescription("List the suppliers with their contact details and the number of products each supplies"),
+		readOnlyTool(),
+	)
+	s.AddTool(listSuppliersTool, app.listSuppliersHandler)
+
+	productsBySupplierTool := mcp.NewTool("products_by_supplier",
+		mcp.WithDescription("List the products of a supplier, ordered by id, with the supplier"),
+		readOnlyTool(),
+		mcp.WithNumber("supplier_id",
+			mcp.Required(),
+			mcp.Description("ID of the supplier"),
+		),
+		withFields(),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of products to return (at most %d)", maxProductListLimit)),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("next_cursor from the truncation metadata of a previous call, to fetch the following page"),
+		),
+	)
+	s.AddTool(productsBySupplierTool, app.productsBySupplierHandler)
+
+	suppliersResource := mcp.NewResource(suppliersListURI, "Suppliers",
+		mcp.WithResourceDescription("Contact details of every supplier with the number of products it supplies"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(suppliersResource, app.formatResource(app.suppliersResourceHandler))
+
+	supplierTemplate := mcp.NewResourceTemplate("suppliers://{id}{?output_format,tenant}", "Supplier",
+		mcp.WithTemplateDescription("Contact details of the supplier with the given id"),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	s.AddResourceTemplate(supplierTemplate, app.formatResource(app.suppliersResourceHandler))
+
+	// Add low-stock alerts; with LOW_STOCK_CHECK_INTERVAL set, clients are sent a resource
+	// update notification when a product crosses the threshold
+	lowStockResource := mcp.NewResource(lowStockURI, "Low Stock",
+		mcp.WithResourceDescription(fmt.Sprintf("Products whose stock is below the low-stock threshold (%d), lowest stock first", app.config.LowStockThreshold)),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(lowStockResource, app.formatResource(app.lowStockHandler))
+
+	lowStockTemplate := mcp.NewResourceTemplate(lowStockURI+"{?threshold,limit,cursor,output_format,tenant}", "Low Stock Query",
+		mcp.WithTemplateDescription(fmt.Sprintf("Products whose stock is below threshold (default %d), lowest stock first; limit (at most %d) caps the rows and cursor continues a truncated listing", app.config.LowStockThreshold, maxProductListLimit)),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	s.AddResourceTemplate(lowStockTemplate, app.formatResource(app.lowStockHandler))
+
+	// Add single and filtered bulk deletes, only where destructive tools are enabled
+	if app.config.DestructiveTools {
+		deleteProductTool := mcp.NewTool("delete_product",
+			mcp.WithDescription("Delete a product. By default it is soft-deleted: it disappears from listings but stays in the history and as-of queries; hard permanently removes it, its history and its price history. A hard delete first only previews the product and returns a confirmation_token; a second call with the same arguments and that token deletes it"),
+			destructiveTool(false),
+			mcp.WithNumber("id",
+				mcp.Required(),
+				mcp.Description("ID of the product to delete"),
+			),
+			mcp.WithBoolean("hard",
+				mcp.Description("Permanently remove the product, its history and its price history instead of soft-deleting it; also removes products that were already soft-deleted"),
+			),
+			withFields(),
+			withIdempotencyKey(),
+			withConfirmationToken(),
+		)
+		s.AddTool(deleteProductTool, app.deleteProductHandler)
+
+		deleteProductsTool := mcp.NewTool("delete_products_where",
+			mcp.WithDescription("Soft-delete all products matching a filter. The first call only previews the matches and returns a confirmation_token; a second call with the same filter and that token deletes them"),
+			destructiveTool(false),
+			mcp.WithObject("filter",
+				mcp.Required(),
+				mcp.Description(`Conditions on product fields, all of which must hold, e.g. {"code": {"like": "TMP%"}, "price": {"lt": 1}}. Operators: eq, ne, lt, lte, gt, gte, like, in; a bare value means eq`),
+			),
+			withConfirmationToken(),
+		)
+		s.AddTool(deleteProductsTool, app.deleteProductsWhereHandler)
+
+		mergeProductsTool := mcp.NewTool("merge_products",
+			mcp.WithDescription("Merge duplicate products, as found by find_duplicates, into one: their stock is added to it, their tags are copied to it and their variants moved to it, then they are soft-deleted. The first call only previews the merge and returns a confirmation_token; a second call with the same arguments and that token merges them"),
+			destructiveTool(false),
+			mcp.WithNumber("keep_id",
+				mcp.Required(),
+				mcp.Description("ID of the product to keep"),
+			),
+			mcp.WithArray("merge_ids",
+				mcp.Required(),
+				mcp.Description("IDs of the products to merge into it"),
+				mcp.Items(map[string]any{"type": "integer"}),
+			),
+			withConfirmationToken(),
+		)
+		s.AddTool(mergeProductsTool, app.mergeProductsHandler)
+
+		deleteSupplierTool := mcp.NewTool("delete_supplier",
+			mcp.WithDescription("Delete a supplier that no longer supplies any product and return it as it was. The first call only previews the supplier and returns a confirmation_token; a second call with the same id and that token deletes it"),
+			destructiveTool(false),
+			mcp.WithNumber("id",
+				mcp.Required(),
+				mcp.Description("ID of the supplier to delete"),
+			),
+			withConfirmationToken(),
+		)
+		s.AddTool(deleteSupplierTool, app.deleteSupplierHandler)
+	}
+
+	// Add recovery of soft-deleted products
+	listDeletedProductsTool := mcp.NewTool("list_deleted_products",
+		mcp.WithDescription("List the soft-deleted products, most recently deleted first, with the time of their deletion; restore_product brings one back"),
+		readOnlyTool(),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of products to return (at most %d)", maxProductListLimit)),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("next_cursor from the truncation metadata of a previous call, to fetch the following page"),
+		),
+	)
+	s.AddTool(listDeletedProductsTool, app.listDeletedProductsHandler)
+
+	restoreProductTool := mcp.NewTool("restore_product",
+		mcp.WithDescription("Restore a soft-deleted product, as listed by list_deleted_products, so that it appears in listings again; hard-deleted products cannot be restored"),
+		writeTool(true),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the product to restore"),
+		),
+		withFields(),
+		withIdempotencyKey(),
+	)
+	s.AddTool(restoreProductTool, app.restoreProductHandler)
+
+	// Add audit log of the changes made to products, variants, categories, images, tags and promotions
+	auditLogResource := mcp.NewResource("audit://log", "Audit Log",
+		mcp.WithResourceDescription("Every create, update and delete of products, product variants, categories, product images, product tags and promotions, most recent first, with the session, the tool and the entity before and after the change"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(auditLogResource, app.formatResource(app.auditLogHandler))
+
+	auditLogTemplate := mcp.NewResourceTemplate("audit://log{?entity,entity_id,action,tool,session_id,since,until,limit,cursor,output_format,tenant}", "Audit Log Query",
+		mcp.WithTemplateDescription(fmt.Sprintf("Audit log entries matching every given filter, most recent first; entity is product, category, product_image, product_tags, promotion, product_variant, order, customer, supplier or prompt, action is create, update, delete, hard_delete or restore, since and until (RFC 3339, percent-encoded) bound the time of the change, limit (at most %d) caps the entries and cursor continues a truncated read", maxAuditLogLimit)),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	s.AddResourceTemplate(auditLogTemplate, app.formatResource(app.auditLogHandler))
+
+	// Add catalog data-quality validation as a tool and a resource
+	validateCatalogTool := mcp.NewTool("validate_catalog",
+		mcp.WithDescription("Scan the product catalog for data-quality anomalies (duplicate codes, negative prices, blank codes, products in categories that do not exist, products out of stock) and suggest fixes"),
+		readOnlyTool(),
+	)
+	s.AddTool(validateCatalogTool, app.validateCatalogHandler)
+
+	catalogValidationResource := mcp.NewResource("catalog://validation", "Catalog Validation",
+		mcp.WithResourceDescription("Data-quality report of the product catalog"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(catalogValidationResource, app.formatResource(app.catalogValidationHandler))
+
+	// Add database maintenance tool
+	maintainTool := mcp.NewTool("maintain_database",
+		mcp.WithDescription("Run database maintenance (vacuum, analyze, integrity check) and report size and corruption findings"),
+		writeTool(true),
+		mcp.WithString("operation",
+			mcp.Description("The maintenance operation to run (defaults to all)"),
+			mcp.Enum("all", "vacuum", "analyze", "integrity_check"),
+		),
+	)
+	s.AddTool(maintainTool, app.maintainDatabaseHandler)
+
+	// Add database health check as a tool and a resource, so agents can verify the backend
+	dbHealthTool := mcp.NewTool("db_health",
+		mcp.WithDescription("Ping the database and run a trivial query; reports status, latency and connection pool state"),
+		readOnlyTool(),
+	)
+	s.AddTool(dbHealthTool, app.dbHealthHandler)
+
+	dbHealthResource := mcp.NewResource("health://db", "Database Health",
+		mcp.WithResourceDescription("Status, latency and connection pool state of the database"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(dbHealthResource, app.formatResource(app.dbHealthResourceHandler))
+
+	// Add database statistics so agents know what they are querying
+	databaseStatsResource := mcp.NewResource("stats://database", "Database Statistics",
+		mcp.WithResourceDescription("Row counts and indexes per table, database size and the latest applied migration"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(databaseStatsResource, app.formatResource(app.databaseStatsHandler))
+
+	maintenanceResource := mcp.NewResource("maintenance://status", "Maintenance Schedule",
+		mcp.WithResourceDescription("Status of scheduled database maintenance and the report of its last run"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(maintenanceResource, app.formatResource(app.maintenanceStatusHandler))
+
+	// Add query plan tool for diagnosing slow queries
+	explainTool := mcp.NewTool("explain_query",
+		mcp.WithDescription("Show the database query plan for a read-only SELECT statement or a saved query, without running it"),
+		readOnlyTool(),
+		mcp.WithString("query",
+			mcp.Description("A single read-only SELECT statement"),
+		),
+		mcp.WithString("saved_query",
+			mcp.Description("Name of a saved query to explain instead of a statement"),
+			mcp.Enum(savedQueryNames()...),
+		),
+	)
+	s.AddTool(explainTool, app.explainQueryHandler)
+
+	// Add on-demand online backups
+	backupTool := mcp.NewTool("backup_database",
+		mcp.WithDescription("Take an online backup of the SQLite database into the backup directory without stopping the server"),
+		writeTool(false),
+		mcp.WithBoolean("include_blob",
+			mcp.Description(fmt.Sprintf("Also return the backup file as a base64 blob (up to %d MiB)", maxInlineBackupBytes>>20)),
+		),
+	)
+	s.AddTool(backupTool, app.backupDatabaseHandler)
+
+	// Add backups resource listing backup files and the schedule status
+	backupsResource := mcp.NewResource("backups://list", "Database Backups",
+		mcp.WithResourceDescription("Lists database backups and the status of scheduled backups"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(backupsResource, app.formatResource(app.listBackupsHandler))
+
+	// Add read-only browsing of the allowlisted tables
+	tablesResource := mcp.NewResource("db://tables", "Browsable Tables",
+		mcp.WithResourceDescription("Lists the tables that can be browsed through db://tables/{table}/rows, with their columns"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(tablesResource, app.formatResource(app.tablesHandler))
+
+	tableRowsTemplate := mcp.NewResourceTemplate(tableRowsURIPrefix+"{table}/rows{?offset,limit,output_format,tenant}", "Table Rows",
+		mcp.WithTemplateDescription(fmt.Sprintf("Reads a page of rows of a browsable table in primary key order; limit defaults to %d (at most %d) and sensitive columns are masked", defaultTableRowsLimit, maxTableRowsLimit)),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	s.AddResourceTemplate(tableRowsTemplate, app.formatResource(app.tableRowsHandler))
+
+	// Add price alert subscriptions, checked in the background
+	watchPriceTool := mcp.NewTool("watch_price",
+		mcp.WithDescription("Subscribe to the price of a product; a notification (and optional webhook) is sent when the price rises above or falls below the threshold"),
+		writeTool(false),
+		mcp.WithOpenWorldHintAnnotation(true),
+		mcp.WithNumber("product_id",
+			mcp.Required(),
+			mcp.Description("ID of the product to watch"),
+		),
+		mcp.WithString("condition",
+			mcp.Required(),
+			mcp.Description("Whether to alert when the price goes above or below the threshold"),
+			mcp.Enum(priceAbove, priceBelow),
+		),
+		mcp.WithNumber("threshold",
+			mcp.Required(),
+			mcp.Description("Price threshold"),
+		),
+		mcp.WithString("webhook_url",
+			mcp.Description("Optional public http(s) URL that receives each alert as a JSON POST; loopback, private and link-local addresses are refused and redirects are not followed"),
+		),
+	)
+	s.AddTool(watchPriceTool, app.watchPriceHandler)
+
+	unwatchPriceTool := mcp.NewTool("unwatch_price",
+		mcp.WithDescription("Cancel a price watch created by this session"),
+		destructiveTool(true),
+		mcp.WithString("watch_id",
+			mcp.Required(),
+			mcp.Description("ID returned by watch_price"),
+		),
+	)
+	s.AddTool(unwatchPriceTool, app.unwatchPriceHandler)
+
+	priceWatchesResource := mcp.NewResource("watches://active", "Active Price Watches",
+		mcp.WithResourceDescription("Lists the price watches of the current session"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(priceWatchesResource, app.formatResource(app.priceWatchesHandler))
+
+	// Add the price history of products, for reasoning about pricing trends
+	priceHistoryTool := mcp.NewTool("price_history",
+		mcp.WithDescription("Get the price changes of a product in chronological order, with a summary of the trend (start, end, min, max, change and direction)"),
+		readOnlyTool(),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the product"),
+		),
+		mcp.WithString("since",
+			mcp.Description("RFC 3339 timestamp; only changes from then on are returned"),
+		),
+		mcp.WithString("until",
+			mcp.Description("RFC 3339 timestamp; only changes up to then are returned"),
+		),
+	)
+	s.AddTool(priceHistoryTool, app.priceHistoryHandler)
+
+	priceHistoryTemplate := mcp.NewResourceTemplate("products://{id}"+priceHistoryURISuffix+"{?since,until,output_format,tenant}", "Product Price History",
+		mcp.WithTemplateDescription("Price changes of a product in chronological order with a summary of the trend; since and until (RFC 3339, percent-encoded) bound the period"),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	s.AddResourceTemplate(priceHistoryTemplate, app.formatResource(app.productsResourceHandler))
+
+	// Add product images, served as binary resources
+	setProductImageTool := mcp.NewTool("set_product_image",
+		mcp.WithDescription(fmt.Sprintf("Store the image of a product, replacing any previous one; it is then served by the products://{id}/image resource. PNG, JPEG, GIF and WebP images of up to %d bytes are accepted", maxImageBytes)),
+		destructiveTool(true),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the product"),
+		),
+		mcp.WithString("data",
+			mcp.Description("Base64-encoded image"),
+		),
+		mcp.WithString("file",
+			mcp.Description("Path of an image file relative to the configured import directory, instead of data; clients declaring roots must include the file in one of them"),
+		),
+	)
+	s.AddTool(setProductImageTool, app.setProductImageHandler)
+
+	deleteProductImageTool := mcp.NewTool("delete_product_image",
+		mcp.WithDescription("Remove the image of a product; deleted is false if it had none"),
+		destructiveTool(true),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the product"),
+		),
+	)
+	s.AddTool(deleteProductImageTool, app.deleteProductImageHandler)
+
+	productImageTemplate := mcp.NewResourceTemplate("products://{id}"+productImageURISuffix+"{?tenant}", "Product Image",
+		mcp.WithTemplateDescription("Image of a product as a binary blob whose MIME type, e.g. image/png, is detected from the stored image"),
+	)
+	s.AddResourceTemplate(productImageTemplate, app.formatResource(app.productsResourceHandler))
+
+	// Add product tags; a product has any number of tags and a tag any number of products
+	listTagsTool := mcp.NewTool("list_tags",
+		mcp.WithDescription("List the product tags with the number of products carrying each; list_products filters by tag"),
+		readOnlyTool(),
+	)
+	s.AddTool(listTagsTool, app.listTagsHandler)
+
+	tagProductTool := mcp.NewTool("tag_product",
+		mcp.WithDescription(fmt.Sprintf("Add tags to a product, creating the tags that do not exist yet, and return all of its tags. Tags are lower-cased and at most %d characters long", maxTagLength)),
+		writeTool(true),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the product"),
+		),
+		mcp.WithArray("tags",
+			mcp.Required(),
+			mcp.Description("Tags to add, e.g. [\"sale\", \"new\"]"),
+			mcp.WithStringItems(),
+		),
+	)
+	s.AddTool(tagProductTool, app.tagProductHandler)
+
+	untagProductTool := mcp.NewTool("untag_product",
+		mcp.WithDescription("Remove tags from a product and return its remaining tags; tags the product does not have are ignored"),
+		destructiveTool(true),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the product"),
+		),
+		mcp.WithArray("tags",
+			mcp.Required(),
+			mcp.Description("Tags to remove"),
+			mcp.WithStringItems(),
+		),
+	)
+	s.AddTool(untagProductTool, app.untagProductHandler)
+
+	// Add currency conversion at the configured exchange rates
+	convertPriceTool := mcp.NewTool("convert_price",
+		mcp.WithDescription("Convert the price of a product, or an amount, into another currency at the configured exchange rates; see currencies://rates"),
+		readOnlyTool(),
+		mcp.WithNumber("id",
+			mcp.Description("ID of the product whose price to convert"),
+		),
+		mcp.WithNumber("amount",
+			mcp.Description("Amount to convert, if no id is given"),
+		),
+		mcp.WithString("from",
+			mcp.Description("ISO 4217 code of the currency of amount; defaults to the configured currency"),
+		),
+		mcp.WithString("to",
+			mcp.Required(),
+			mcp.Description("ISO 4217 code of the currency to convert into, e.g. EUR"),
+		),
+	)
+	s.AddTool(convertPriceTool, app.convertPriceHandler)
+
+	exchangeRatesResource := mcp.NewResource("currencies://rates", "Exchange Rates",
+		mcp.WithResourceDescription("The configured currency and the exchange rates of the other currencies against it"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(exchangeRatesResource, app.formatResource(app.exchangeRatesHandler))
+
+	// Add session preferences, such as the default output format of tabular results
+	setPreferencesTool := mcp.NewTool("set_preferences",
+		mcp.WithDescription("Set preferences of the current session and return all of them"),
+		writeTool(true),
+		mcp.WithString(outputFormatArg,
+			mcp.Description("Default rendering of tool results and resources; markdown applies to tabular tool results only"),
+			mcp.Enum(outputFormats...),
+		),
+		mcp.WithString(tenantArg,
+			mcp.Description("Tenant whose database serves the tool calls and resource reads of the session, as listed by tenants://list; default selects the configured database"),
+		),
+	)
+	s.AddTool(setPreferencesTool, app.setPreferencesHandler)
+
+	// Add tenants resource listing the databases a session can be routed to
+	tenantsResource := mcp.NewResource("tenants://list", "Tenants",
+		mcp.WithResourceDescription("Lists the tenants and the tenant of the current session; tools accept a tenant argument and resources a tenant query parameter to override it"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(tenantsResource, app.formatResource(app.listTenantsHandler))
+
+	// Add quota status resource so agents can see their remaining budget
+	quotaResource := mcp.NewResource("quota://status", "Quota Status",
+		mcp.WithResourceDescription("Usage quotas and remaining budget of the current session"),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(quotaResource, app.formatResource(app.quotaStatusHandler))
+
+	// Add error documentation referenced by the docs_uri of tool errors
+	errorDocsTemplate := mcp.NewResourceTemplate(errorDocsURIPrefix+"{code}", "Error Code Documentation",
+		mcp.WithTemplateDescription("Explains a tool error code and how to recover from it"),
+		mcp.WithTemplateMIMEType("text/markdown"),
+	)
+	s.AddResourceTemplate(errorDocsTemplate, app.errorDocsHandler)
+
+	// Add prompts grounded in live resource contents
+	app.addPrompts(s)
+
+	// Add prompt management, only where prompt tools are enabled
+	if app.config.PromptTools {
+		promptOptions := []mcp.ToolOption{
+			mcp.WithArray("arguments",
+				mcp.Description("Arguments of the prompt, e.g. [{\"name\": \"category\", \"description\": \"Category to review\", \"required\": true}]"),
+				mcp.Items(map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"name":        map[string]any{"type": "string"},
+						"description": map[string]any{"type": "string"},
+						"required":    map[string]any{"type": "boolean"},
+					},
+					"required": []string{"name"},
+				}),
+			),
+			mcp.WithArray("resources",
+				mcp.Description("URIs of the resources whose current contents are embedded before the instruction, e.g. [\"products://list\"]"),
+				mcp.WithStringItems(),
+			),
+		}
+		createPromptTool := mcp.NewTool("create_prompt", append([]mcp.ToolOption{
+			mcp.WithDescription("Store a prompt template in the database and offer it to every client; clients are notified that the list of prompts changed"),
+			writeTool(false),
+			mcp.WithString("name",
+				mcp.Required(),
+				mcp.Description("Unique name of the prompt, in lowercase letters, digits and underscores"),
+			),
+			mcp.WithString("description",
+				mcp.Required(),
+				mcp.Description("Description of the prompt shown to clients"),
+			),
+			mcp.WithString("template",
+				mcp.Required(),
+				mcp.Description("Instruction of the prompt as a Go text template over its arguments, e.g. \"Review the prices of the {{.category}} products\"; arguments not given are empty"),
+			),
+		}, promptOptions...)...)
+		s.AddTool(createPromptTool, app.createPromptHandler)
+
+		updatePromptTool := mcp.NewTool("update_prompt", append([]mcp.ToolOption{
+			mcp.WithDescription("Update a stored prompt; only the given fields change and clients are notified that the list of prompts changed. Built-in prompts cannot be changed"),
+			writeTool(true),
+			mcp.WithString("name",
+				mcp.Required(),
+				mcp.Description("Name of the stored prompt to update"),
+			),
+			mcp.WithString("description",
+				mcp.Description("New description of the prompt"),
+			),
+			mcp.WithString("template",
+				mcp.Description("New instruction template of the prompt"),
+			),
+		}, promptOptions...)...)
+		s.AddTool(updatePromptTool, app.updatePromptHandler)
+	}
+
+	if app.config.ToolAdmin {
+		setToolsEnabledTool := mcp.NewTool(toolAdminName,
+			mcp.WithDescription("Enable or disable tools for every client while the server runs; disabled tools are hidden from the tool list and refuse calls, and clients are notified that the list changed. The configuration replaces these changes when it is reloaded on SIGHUP"),
+			writeTool(true),
+			mcp.WithArray("tools",
+				mcp.Required(),
+				mcp.Description(fmt.Sprintf("Names of the tools to switch; %s stands for every tool not annotated read-only", toolGroupWrite)),
+				mcp.WithStringItems(),
+			),
+			mcp.WithBoolean("enabled",
+				mcp.Required(),
+				mcp.Description("Whether to enable the tools (true) or disable them (false)"),
+			),
+		)
+		s.AddTool(setToolsEnabledTool, app.setToolsEnabledHandler)
+	}
+
+	// Add tools defined declaratively in the tools file
+	if err := app.addDeclarativeTools(s); err != nil {
+		return nil, err
+	}
+
+	// Tools are switched off and counted as mutations once all of them are registered
+	if err := app.toolSwitch.load(s); err != nil {
+		slog.Error("Tools cannot be disabled", "error", err)
+	} else if err := app.toolSwitch.Reset(app.config.DisabledTools); err != nil {
+		slog.Error("Ignoring DISABLED_TOOLS", "error", err)
+	}
+	app.quotas.countMutations(app.toolSwitch.writeTools())
+	for name := range app.config.ToolDescriptions {
+		if !app.toolSwitch.registered(name) {
+			slog.Error("Ignoring TOOL_DESCRIPTIONS entry of an unknown tool", "tool", name)
+		}
+	}
+
+	return s, nil
 }
 
-func main() {
+// Run serves MCP over the selected transports until a transport stops or SIGINT or
+// SIGTERM arrives, reloading the disabled tools on SIGHUP. It then cancels the context of
+// in-flight tool calls, waits up to shutdownTimeout for them to return and closes the
+// database.
+func Run(cfg *Config, transports []string) error {
+	shutdownTelemetry, err := setupTelemetry(context.Background(), cfg)
+	if err != nil {
+		return fmt.Errorf("telemetry initialization failed: %w", err)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTelemetry(shutdownCtx); err != nil {
+			slog.Warn("Failed to flush telemetry", "error", err)
+		}
+	}()
+
+	log.Printf("Using %s profile with database %s (%d read replicas, %d tenants)", cfg.Env, storage.Redact(cfg.DBPath), len(cfg.ReplicaDBPaths), len(cfg.Tenants))
+
 	// Initialize database
-	db, err := initializeDatabase()
+	db, err := InitializeDatabase(cfg)
 	if err != nil {
-		log.Fatalf("Database initialization failed: %v", err)
+		return fmt.Errorf("database initialization failed: %w", err)
+	}
+	dbService := NewDBService(db)
+	tenants, err := InitializeTenants(cfg)
+	if err != nil {
+		dbService.Close()
+		return fmt.Errorf("database initialization failed: %w", err)
+	}
+	dbService.UseTenants(tenants)
+	defer func() {
+		if err := dbService.Close(); err != nil {
+			slog.Warn("Failed to close database", "error", err)
+		}
+	}()
+
+	// Seed database with sample data; an in-memory database would otherwise start empty
+	if cfg.SeedDatabase || cfg.Ephemeral() {
+		if err := SeedDatabase(db); err != nil {
+			log.Printf("Warning: Database seeding failed: %v", err)
+		}
 	}
 
-	// Seed database with sample data
-	if err := seedDatabase(db); err != nil {
-		log.Printf("Warning: Database seeding failed: %v", err)
+	// Create the application
+	app, err := NewApp(cfg, dbService)
+	if err != nil {
+		return fmt.Errorf("application initialization failed: %w", err)
 	}
 
-	// Create services and application
-	dbService := NewDBService(db)
-	app := NewApp(dbService)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
 
 	// Setup and start the MCP server
-	s := app.setupServer()
+	s, err := app.NewServer()
+	if err != nil {
+		return fmt.Errorf("server initialization failed: %w", err)
+	}
+	app.Start(ctx)
+	app.startDashboard(ctx)
+	app.reloadOnHangup(ctx)
+
+	log.Printf("Starting MCP server over %s...", strings.Join(transports, ", "))
+	serveErr := app.serve(ctx, s, transports)
 
-	log.Println("Starting MCP server...")
-	if err := server.ServeStdio(s); err != nil {
-		log.Fatalf("Server error: %v", err)
+	// A second signal terminates immediately
+	stop()
+	slog.Info("Shutting down")
+	waitCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := app.inflight.wait(waitCtx); err != nil {
+		slog.Warn("Closing the database with operations still running", "error", err)
 	}
+	return serveErr
 }
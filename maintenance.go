@@ -0,0 +1,360 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm/clause"
+)
+
+// MaintenanceReport describes the outcome of a database maintenance run
+type MaintenanceReport struct {
+	Driver          string   `json:"driver"`
+	Operations      []string `json:"operations"`
+	SizeBeforeBytes int64    `json:"size_before_bytes"`
+	SizeAfterBytes  int64    `json:"size_after_bytes"`
+	IntegrityOK     *bool    `json:"integrity_ok,omitempty"`
+	Findings        []string `json:"findings,omitempty"`
+	// Note explains an integrity check that could not run, leaving IntegrityOK unset
+	Note       string `json:"note,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// maintenanceOperations lists the operations accepted by the maintain_database tool
+var maintenanceOperations = []string{"vacuum", "analyze", "integrity_check"}
+
+// Maintain runs the requested maintenance operations against the database
+func (dbs *DBService) Maintain(ctx context.Context, operations []string) (*MaintenanceReport, error) {
+	start := time.Now()
+	db := dbs.primary(ctx)
+
+	report := &MaintenanceReport{
+		Driver:     db.Dialector.Name(),
+		Operations: operations,
+	}
+
+	before, err := dbs.databaseSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.SizeBeforeBytes = before
+
+	for _, op := range operations {
+		switch op {
+		case "vacuum":
+			if err := dbs.vacuum(ctx); err != nil {
+				return nil, err
+			}
+		case "analyze":
+			if err := db.Exec("ANALYZE").Error; err != nil {
+				return nil, fmt.Errorf("failed to analyze database: %w", err)
+			}
+		case "integrity_check":
+			findings, err := dbs.integrityCheck(ctx)
+			if errors.Is(err, errAmcheckMissing) {
+				report.Note = err.Error()
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			ok := len(findings) == 0
+			report.IntegrityOK = &ok
+			report.Findings = findings
+		default:
+			return nil, invalidField("operation", fmt.Sprintf("unsupported maintenance operation: %s", op))
+		}
+	}
+
+	after, err := dbs.databaseSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.SizeAfterBytes = after
+	report.DurationMs = time.Since(start).Milliseconds()
+
+	return report, nil
+}
+
+// databaseSize returns the size of the database in bytes as reported by the driver
+func (dbs *DBService) databaseSize(ctx context.Context) (int64, error) {
+	db := dbs.primary(ctx)
+
+	var size int64
+	var err error
+	switch db.Dialector.Name() {
+	case "sqlite":
+		err = db.Raw("SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&size).Error
+	case "postgres":
+		err = db.Raw("SELECT pg_database_size(current_database())").Scan(&size).Error
+	case "mysql":
+		err = db.Raw("SELECT COALESCE(SUM(data_length + index_length), 0) FROM information_schema.tables WHERE table_schema = DATABASE()").Scan(&size).Error
+	default:
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine database size: %w", err)
+	}
+	return size, nil
+}
+
+// vacuum reclaims unused space in the database; MySQL optimizes each table of the schema
+func (dbs *DBService) vacuum(ctx context.Context) error {
+	db := dbs.primary(ctx)
+
+	if db.Dialector.Name() != "mysql" {
+		if err := db.Exec("VACUUM").Error; err != nil {
+			return fmt.Errorf("failed to vacuum database: %w", err)
+		}
+		return nil
+	}
+
+	tables, err := db.Migrator().GetTables()
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+	for _, table := range tables {
+		if err := db.Exec("OPTIMIZE TABLE ?", clause.Table{Name: table}).Error; err != nil {
+			return fmt.Errorf("failed to optimize table %q: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// integrityCheck returns the problems reported by the driver's integrity check, if any.
+// MySQL checks each table of the schema.
+func (dbs *DBService) integrityCheck(ctx context.Context) ([]string, error) {
+	db := dbs.primary(ctx)
+
+	var findings []string
+	switch db.Dialector.Name() {
+	case "sqlite":
+		var rows []string
+		if err := db.Raw("PRAGMA integrity_check").Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to run integrity check: %w", err)
+		}
+		for _, row := range rows {
+			if row != "ok" {
+				findings = append(findings, row)
+			}
+		}
+	case "mysql":
+		tables, err := db.Migrator().GetTables()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		for _, table := range tables {
+			var rows []struct {
+				Table   string
+				MsgType string
+				MsgText string
+			}
+			if err := db.Raw("CHECK TABLE ?", clause.Table{Name: table}).Scan(&rows).Error; err != nil {
+				return nil, fmt.Errorf("failed to run integrity check of table %q: %w", table, err)
+			}
+			for _, row := range rows {
+				if row.MsgText != "OK" {
+					findings = append(findings, fmt.Sprintf("%s: %s: %s", row.Table, row.MsgType, row.MsgText))
+				}
+			}
+		}
+	case "postgres":
+		return dbs.checkPostgresIndexes(ctx)
+	default:
+		return nil, fmt.Errorf("integrity check is %w for %s", ErrUnsupported, db.Dialector.Name())
+	}
+	return findings, nil
+}
+
+// errAmcheckMissing is returned by integrity checks of PostgreSQL databases without the
+// amcheck extension, which the report notes instead of failing
+var errAmcheckMissing = errors.New("integrity check skipped: it requires the amcheck extension in PostgreSQL (CREATE EXTENSION amcheck)")
+
+// checkPostgresIndexes verifies the B-tree indexes of the current schema with amcheck; each
+// index reported as corrupt is a finding
+func (dbs *DBService) checkPostgresIndexes(ctx context.Context) ([]string, error) {
+	db := dbs.primary(ctx)
+
+	var installed int64
+	if err := db.Raw("SELECT COUNT(*) FROM pg_extension WHERE extname = 'amcheck'").Scan(&installed).Error; err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	if installed == 0 {
+		return nil, errAmcheckMissing
+	}
+
+	var indexes []string
+	err := db.Raw(`SELECT c.relname FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		JOIN pg_am am ON am.oid = c.relam
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE am.amname = 'btree' AND n.nspname = current_schema() AND i.indisready AND i.indisvalid
+		ORDER BY c.relname`).Scan(&indexes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %w", err)
+	}
+
+	var findings []string
+	for _, index := range indexes {
+		// amcheck raises an error describing the corruption it finds
+		if err := db.Exec("SELECT bt_index_check(?::regclass)", index).Error; err != nil {
+			if classifyError(err) != nil {
+				return nil, fmt.Errorf("failed to run integrity check: %w", err)
+			}
+			findings = append(findings, fmt.Sprintf("%s: %v", index, err))
+		}
+	}
+	return findings, nil
+}
+
+// maintainDatabaseHandler handles the maintain_database tool request
+func (app *App) maintainDatabaseHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	operations := maintenanceOperations
+	if op := request.GetString("operation", "all"); op != "all" {
+		operations = []string{op}
+	}
+
+	report, err := app.dbService.Maintain(ctx, operations)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal maintenance report to JSON: %w", err)
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// MaintenanceStatus reports the state of the maintenance scheduler
+type MaintenanceStatus struct {
+	Enabled    bool               `json:"enabled"`
+	Schedule   string             `json:"schedule,omitempty"`
+	Operations []string           `json:"operations,omitempty"`
+	LastRun    *time.Time         `json:"last_run,omitempty"`
+	LastError  string             `json:"last_error,omitempty"`
+	NextRun    *time.Time         `json:"next_run,omitempty"`
+	LastReport *MaintenanceReport `json:"last_report,omitempty"`
+}
+
+// MaintenanceScheduler runs database maintenance on a cron schedule
+type MaintenanceScheduler struct {
+	app        *App
+	schedule   cron.Schedule
+	operations []string
+
+	mu     sync.Mutex
+	status MaintenanceStatus
+}
+
+// NewMaintenanceScheduler creates a scheduler using the maintenance settings of the app
+// configuration; the schedule has been validated by LoadConfig
+func NewMaintenanceScheduler(app *App) *MaintenanceScheduler {
+	cfg := app.config
+	ms := &MaintenanceScheduler{
+		app:        app,
+		operations: cfg.MaintenanceOperations,
+		status: MaintenanceStatus{
+			Enabled:    cfg.MaintenanceSchedule != "",
+			Schedule:   cfg.MaintenanceSchedule,
+			Operations: cfg.MaintenanceOperations,
+		},
+	}
+	if cfg.MaintenanceSchedule != "" {
+		ms.schedule, _ = cron.ParseStandard(cfg.MaintenanceSchedule)
+	}
+	return ms
+}
+
+// Start runs maintenance at the scheduled times until ctx is cancelled; it does nothing
+// when no schedule is configured
+func (ms *MaintenanceScheduler) Start(ctx context.Context) {
+	if ms.schedule == nil {
+		return
+	}
+
+	go func() {
+		for {
+			next := ms.schedule.Next(time.Now())
+			ms.setNextRun(next)
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				ms.run(ctx)
+			}
+		}
+	}()
+}
+
+// run performs a single scheduled maintenance run
+func (ms *MaintenanceScheduler) run(ctx context.Context) {
+	ms.app.inflight.begin()
+	defer ms.app.inflight.end()
+
+	now := time.Now().UTC()
+	report, err := ms.app.dbService.Maintain(ctx, ms.operations)
+
+	ms.mu.Lock()
+	ms.status.LastRun = &now
+	ms.status.LastError = ""
+	if report != nil {
+		ms.status.LastReport = report
+	}
+	if err != nil {
+		ms.status.LastError = err.Error()
+	}
+	ms.mu.Unlock()
+
+	if err != nil {
+		slog.Error("Scheduled maintenance failed", "error", err)
+		ms.app.broadcastLog(mcp.LoggingLevelError, "maintenance", map[string]any{
+			"message": "scheduled maintenance failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+	slog.Info("Scheduled maintenance completed", "operations", report.Operations,
+		"size_before", report.SizeBeforeBytes, "size_after", report.SizeAfterBytes, "duration_ms", report.DurationMs)
+}
+
+// setNextRun records when the next maintenance run is due
+func (ms *MaintenanceScheduler) setNextRun(t time.Time) {
+	t = t.UTC()
+	ms.mu.Lock()
+	ms.status.NextRun = &t
+	ms.mu.Unlock()
+}
+
+// Status returns a snapshot of the scheduler state
+func (ms *MaintenanceScheduler) Status() MaintenanceStatus {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.status
+}
+
+// maintenanceStatusHandler handles the maintenance status resource request
+func (app *App) maintenanceStatusHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	jsonData, err := json.MarshalIndent(app.maintenance.Status(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal maintenance status to JSON: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      "maintenance://status",
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
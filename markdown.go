@@ -0,0 +1,186 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// outputFormatArg is the optional argument choosing how tool results and resources are rendered
+const outputFormatArg = "output_format"
+
+// Output formats; markdown applies to tabular tool results, yaml and toml re-encode
+// any JSON result or resource (see structured.go)
+const (
+	formatJSON     = "json"
+	formatMarkdown = "markdown"
+	formatYAML     = "yaml"
+	formatTOML     = "toml"
+)
+
+// outputFormats lists the supported output formats
+var outputFormats = []string{formatJSON, formatMarkdown, formatYAML, formatTOML}
+
+// outputFormatPreference is the session preference holding the default output format
+const outputFormatPreference = "output_format"
+
+// withOutputFormat declares the optional output format argument on a tool returning rows
+func withOutputFormat() mcp.ToolOption {
+	return mcp.WithString(outputFormatArg,
+		mcp.Description("Render rows as JSON, a Markdown table, YAML or TOML; defaults to the session preference (see set_preferences), else json"),
+		mcp.Enum(outputFormats...),
+	)
+}
+
+// validOutputFormat reports whether format is a supported output format
+func validOutputFormat(format string) bool {
+	return slices.Contains(outputFormats, format)
+}
+
+// invalidOutputFormat returns the validation error for an unsupported output format
+func invalidOutputFormat() error {
+	return invalidField(outputFormatArg, "must be one of "+strings.Join(outputFormats, ", "))
+}
+
+// outputFormat returns the output format of a tool call: the argument if given,
+// otherwise the preference of the session, otherwise JSON
+func (app *App) outputFormat(ctx context.Context, request mcp.CallToolRequest) (string, error) {
+	if format := request.GetString(outputFormatArg, ""); format != "" {
+		if !validOutputFormat(format) {
+			return "", invalidOutputFormat()
+		}
+		return format, nil
+	}
+
+	state, err := app.sessions.Load(ctx, sessionID(ctx))
+	if err != nil {
+		return "", err
+	}
+	if format := state.Preferences[outputFormatPreference]; format != "" {
+		return format, nil
+	}
+	return formatJSON, nil
+}
+
+// markdownTable renders rows as a Markdown table with the given columns; columns
+// missing from a row are left empty
+func markdownTable(columns []string, rows []map[string]any) string {
+	if len(rows) == 0 {
+		return "_No rows._\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("|")
+	for _, column := range columns {
+		b.WriteString(" " + markdownCell(column) + " |")
+	}
+	b.WriteString("\n|")
+	for range columns {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+	for _, row := range rows {
+		b.WriteString("|")
+		for _, column := range columns {
+			b.WriteString(" " + markdownCell(formatCell(row[column])) + " |")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// markdownCell escapes text for use inside a Markdown table cell
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// formatCell renders a value compactly for a table cell
+func formatCell(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case time.Time:
+		return val.UTC().Format(time.RFC3339)
+	case fmt.Stringer:
+		return val.String()
+	}
+	if data, err := json.Marshal(v); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// productTableFields are the product fields shown in a Markdown table when no fields were requested
+var productTableFields = []string{"id", "code", "name", "category", "price", "currency", "stock", "created_at", "updated_at"}
+
+// productsMarkdown renders products as a Markdown table restricted to fields, if any
+func productsMarkdown(products []Product, fields []string) string {
+	if len(fields) == 0 {
+		fields = productTableFields
+	}
+	columns := make([]string, len(fields))
+	for i, name := range fields {
+		columns[i] = productFields[name].JSONKey
+	}
+	rows := make([]map[string]any, len(products))
+	for i := range products {
+		rows[i] = projectProduct(&products[i], fields).(map[string]any)
+	}
+	return markdownTable(columns, rows)
+}
+
+// setPreferencesHandler handles the set_preferences tool request
+func (app *App) setPreferencesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	format := request.GetString(outputFormatArg, "")
+	if format != "" && !validOutputFormat(format) {
+		return toolErrorResult(invalidOutputFormat())
+	}
+	tenant := request.GetString(tenantArg, "")
+	if tenant != "" && !app.validTenant(tenant) {
+		return toolErrorResult(app.unknownTenant(tenant))
+	}
+
+	var preferences map[string]string
+	err := app.quotas.update(ctx, func(state *SessionState) error {
+		if state.Preferences == nil {
+			state.Preferences = make(map[string]string)
+		}
+		if format != "" {
+			state.Preferences[outputFormatPreference] = format
+		}
+		switch tenant {
+		case "":
+		case defaultTenant:
+			delete(state.Preferences, tenantPreference)
+		default:
+			state.Preferences[tenantPreference] = tenant
+		}
+		preferences = maps.Clone(state.Preferences)
+		return nil
+	})
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(preferences, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal preferences to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
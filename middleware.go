@@ -0,0 +1,37 @@
+package mcpserver
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// loggingMiddleware logs every tool call with its arguments scrubbed by the redactor
+func (app *App) loggingMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		args := app.redactor.Redact(request.GetArguments())
+
+		result, err := next(ctx, request)
+		app.stats.Record(request.Params.Name, time.Since(start), err != nil || (result != nil && result.IsError))
+
+		attrs := []any{
+			"tool", request.Params.Name,
+			"arguments", args,
+			"duration", time.Since(start),
+		}
+		switch {
+		case err != nil:
+			slog.Error("Tool call failed", append(attrs, "error", err)...)
+		case result != nil && result.IsError:
+			slog.Warn("Tool call returned an error", append(attrs, "error", toolResultText(result))...)
+		default:
+			slog.Debug("Tool call", attrs...)
+		}
+
+		return result, err
+	}
+}
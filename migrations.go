@@ -0,0 +1,875 @@
+package mcpserver
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"mcpserver/storage"
+)
+
+// SchemaMigration records a migration applied to the database
+type SchemaMigration struct {
+	ID        string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+// migration is a versioned schema change. Migrations describe the tables as they were
+// when the migration was written, never through the current models, so that replaying
+// them produces the same schema on every deployment.
+type migration struct {
+	ID       string
+	Migrate  func(tx *gorm.DB) error
+	Rollback func(tx *gorm.DB) error
+}
+
+// migrations lists every schema change in the order it is applied; append new ones
+// at the end and never edit one that has been released
+var migrations = []migration{
+	{
+		ID:       "0001_initial",
+		Migrate:  migrateInitialSchema,
+		Rollback: rollbackInitialSchema,
+	},
+	{
+		ID:       "0002_product_details",
+		Migrate:  migrateProductDetails,
+		Rollback: rollbackProductDetails,
+	},
+	{
+		ID:       "0003_categories",
+		Migrate:  migrateCategories,
+		Rollback: rollbackCategories,
+	},
+	{
+		ID:       "0004_product_stock",
+		Migrate:  migrateProductStock,
+		Rollback: rollbackProductStock,
+	},
+	{
+		ID:       "0005_price_history",
+		Migrate:  migratePriceHistory,
+		Rollback: rollbackPriceHistory,
+	},
+	{
+		ID:       "0006_product_currency",
+		Migrate:  migrateProductCurrency,
+		Rollback: rollbackProductCurrency,
+	},
+	{
+		ID:       "0007_product_images",
+		Migrate:  migrateProductImages,
+		Rollback: rollbackProductImages,
+	},
+	{
+		ID:       "0008_audit_log",
+		Migrate:  migrateAuditLog,
+		Rollback: rollbackAuditLog,
+	},
+	{
+		ID:       "0009_unique_product_code",
+		Migrate:  migrateUniqueProductCode,
+		Rollback: rollbackUniqueProductCode,
+	},
+	{
+		ID:       "0010_product_tags",
+		Migrate:  migrateProductTags,
+		Rollback: rollbackProductTags,
+	},
+	{
+		ID:       "0011_promotions",
+		Migrate:  migratePromotions,
+		Rollback: rollbackPromotions,
+	},
+	{
+		ID:       "0012_product_variants",
+		Migrate:  migrateProductVariants,
+		Rollback: rollbackProductVariants,
+	},
+	{
+		ID:       "0013_orders",
+		Migrate:  migrateOrders,
+		Rollback: rollbackOrders,
+	},
+	{
+		ID:       "0014_customers",
+		Migrate:  migrateCustomers,
+		Rollback: rollbackCustomers,
+	},
+	{
+		ID:       "0015_suppliers",
+		Migrate:  migrateSuppliers,
+		Rollback: rollbackSuppliers,
+	},
+	{
+		ID:       "0016_product_search",
+		Migrate:  migrateProductSearch,
+		Rollback: rollbackProductSearch,
+	},
+	{
+		ID:       "0017_stored_prompts",
+		Migrate:  migrateStoredPrompts,
+		Rollback: rollbackStoredPrompts,
+	},
+	{
+		ID:       "0018_scoped_idempotency_keys",
+		Migrate:  migrateScopedIdempotencyKeys,
+		Rollback: rollbackScopedIdempotencyKeys,
+	},
+}
+
+// initialSchema returns the tables of the initial migration. It creates the tables of
+// databases from before versioned migrations only if they are missing.
+func initialSchema() []any {
+	type product struct {
+		gorm.Model
+		Code  string
+		Price float64
+	}
+	type productVersion struct {
+		ID        uint `gorm:"primaryKey"`
+		ProductID uint `gorm:"index"`
+		Code      string
+		Price     float64
+		Deleted   bool
+		ValidFrom time.Time `gorm:"index"`
+	}
+	type sessionState struct {
+		ID          string `gorm:"primaryKey"`
+		Quota       string
+		Preferences string
+		UpdatedAt   time.Time
+	}
+	type idempotencyRecord struct {
+		Tool        string `gorm:"primaryKey"`
+		Key         string `gorm:"primaryKey"`
+		RequestHash string
+		Result      string
+		CreatedAt   time.Time
+	}
+	return []any{&product{}, &productVersion{}, &sessionState{}, &idempotencyRecord{}}
+}
+
+func migrateInitialSchema(tx *gorm.DB) error {
+	return tx.AutoMigrate(initialSchema()...)
+}
+
+func rollbackInitialSchema(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(initialSchema()...)
+}
+
+// productDetailsSchema returns the columns added to products and their history by
+// 0002_product_details
+func productDetailsSchema() []any {
+	type product struct {
+		Name        string
+		Description string
+		Category    string `gorm:"index"`
+	}
+	type productVersion struct {
+		Name        string
+		Description string
+		Category    string
+	}
+	return []any{&product{}, &productVersion{}}
+}
+
+// productDetailColumns are the columns added by 0002_product_details
+var productDetailColumns = []string{"name", "description", "category"}
+
+func migrateProductDetails(tx *gorm.DB) error {
+	return tx.AutoMigrate(productDetailsSchema()...)
+}
+
+// rollbackProductDetails drops the columns with ALTER TABLE rather than the migrator, which
+// rebuilds SQLite tables without their other indexes
+func rollbackProductDetails(tx *gorm.DB) error {
+	if err := tx.Migrator().DropIndex(productDetailsSchema()[0], "Category"); err != nil {
+		return err
+	}
+	for _, table := range []string{"products", "product_versions"} {
+		for _, column := range productDetailColumns {
+			if err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: column}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// categoriesSchema returns the categories table of 0003_categories and the products table
+// with its foreign key to it, through which products reference their category by name
+func categoriesSchema() (any, any) {
+	type category struct {
+		ID          uint   `gorm:"primaryKey"`
+		Name        string `gorm:"uniqueIndex"`
+		Description string
+		CreatedAt   time.Time
+		UpdatedAt   time.Time
+	}
+	type product struct {
+		Category    *string   `gorm:"index"`
+		CategoryRef *category `gorm:"foreignKey:Category;references:Name;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	}
+	return &category{}, &product{}
+}
+
+func migrateCategories(tx *gorm.DB) error {
+	category, product := categoriesSchema()
+	if err := tx.AutoMigrate(category); err != nil {
+		return err
+	}
+
+	// Every category named by a product becomes a category; uncategorized products
+	// hold NULL rather than an empty name, which the foreign key would reject
+	now := time.Now().UTC()
+	err := tx.Exec("INSERT INTO categories (name, description, created_at, updated_at) SELECT DISTINCT category, '', ?, ? FROM products WHERE category <> ''", now, now).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Exec("UPDATE products SET category = NULL WHERE category = ''").Error; err != nil {
+		return err
+	}
+
+	// SQLite cannot add a foreign key to an existing table; products check their category
+	// when they are saved instead
+	if tx.Dialector.Name() == "sqlite" {
+		return nil
+	}
+	return tx.Migrator().CreateConstraint(product, "CategoryRef")
+}
+
+func rollbackCategories(tx *gorm.DB) error {
+	category, product := categoriesSchema()
+	if tx.Dialector.Name() != "sqlite" {
+		if err := tx.Migrator().DropConstraint(product, "CategoryRef"); err != nil {
+			return err
+		}
+	}
+	if err := tx.Exec("UPDATE products SET category = '' WHERE category IS NULL").Error; err != nil {
+		return err
+	}
+	return tx.Migrator().DropTable(category)
+}
+
+// productStockSchema returns the stock column added to products and their history by
+// 0004_product_stock; existing products start out of stock
+func productStockSchema() []any {
+	type product struct {
+		Stock int `gorm:"not null;default:0"`
+	}
+	type productVersion struct {
+		Stock int `gorm:"not null;default:0"`
+	}
+	return []any{&product{}, &productVersion{}}
+}
+
+func migrateProductStock(tx *gorm.DB) error {
+	return tx.AutoMigrate(productStockSchema()...)
+}
+
+func rollbackProductStock(tx *gorm.DB) error {
+	for _, table := range []string{"products", "product_versions"} {
+		if err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: "stock"}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// priceHistorySchema returns the price_changes table of 0005_price_history
+func priceHistorySchema() any {
+	type priceChange struct {
+		ID            uint `gorm:"primaryKey"`
+		ProductID     uint `gorm:"index"`
+		Price         float64
+		PreviousPrice *float64
+		ChangedAt     time.Time `gorm:"index"`
+	}
+	return &priceChange{}
+}
+
+// migratePriceHistory creates the price_changes table and fills it with the price changes
+// found in the product history
+func migratePriceHistory(tx *gorm.DB) error {
+	if err := tx.AutoMigrate(priceHistorySchema()); err != nil {
+		return err
+	}
+
+	type productVersion struct {
+		ProductID uint
+		Price     float64
+		ValidFrom time.Time
+	}
+	var versions []productVersion
+	err := tx.Model(&productVersion{}).
+		Where("deleted = ?", false).
+		Order("product_id, id").
+		Find(&versions).Error
+	if err != nil {
+		return err
+	}
+
+	type priceChange struct {
+		ProductID     uint
+		Price         float64
+		PreviousPrice *float64
+		ChangedAt     time.Time
+	}
+	var changes []priceChange
+	for i, v := range versions {
+		change := priceChange{ProductID: v.ProductID, Price: v.Price, ChangedAt: v.ValidFrom}
+		if i > 0 && versions[i-1].ProductID == v.ProductID {
+			if versions[i-1].Price == v.Price {
+				continue
+			}
+			change.PreviousPrice = &versions[i-1].Price
+		}
+		changes = append(changes, change)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&changes, 500).Error
+}
+
+func rollbackPriceHistory(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(priceHistorySchema())
+}
+
+// productCurrencySchema returns the currency column added to products and their history by
+// 0006_product_currency. Existing rows are left without a currency, which the server fills in
+// with the configured currency at startup.
+func productCurrencySchema() []any {
+	type product struct {
+		Currency string `gorm:"not null;default:''"`
+	}
+	type productVersion struct {
+		Currency string `gorm:"not null;default:''"`
+	}
+	return []any{&product{}, &productVersion{}}
+}
+
+func migrateProductCurrency(tx *gorm.DB) error {
+	return tx.AutoMigrate(productCurrencySchema()...)
+}
+
+func rollbackProductCurrency(tx *gorm.DB) error {
+	for _, table := range []string{"products", "product_versions"} {
+		if err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: "currency"}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// productImagesSchema returns the product_images table of 0007_product_images
+func productImagesSchema() any {
+	type productImage struct {
+		ProductID uint `gorm:"primaryKey;autoIncrement:false"`
+		MIMEType  string
+		Data      []byte
+		UpdatedAt time.Time
+	}
+	return &productImage{}
+}
+
+func migrateProductImages(tx *gorm.DB) error {
+	return tx.AutoMigrate(productImagesSchema())
+}
+
+func rollbackProductImages(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(productImagesSchema())
+}
+
+// auditLogSchema returns the audit_entries table of 0008_audit_log
+func auditLogSchema() any {
+	type auditEntry struct {
+		ID        uint      `gorm:"primaryKey"`
+		At        time.Time `gorm:"index"`
+		SessionID string    `gorm:"index"`
+		Tool      string
+		Entity    string `gorm:"index:idx_audit_entries_entity"`
+		EntityID  uint   `gorm:"index:idx_audit_entries_entity"`
+		Action    string
+		Before    string
+		After     string
+	}
+	return &auditEntry{}
+}
+
+func migrateAuditLog(tx *gorm.DB) error {
+	return tx.AutoMigrate(auditLogSchema())
+}
+
+func rollbackAuditLog(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(auditLogSchema())
+}
+
+// uniqueProductCodeSchema returns the products table of 0009_unique_product_code
+func uniqueProductCodeSchema() any {
+	type product struct {
+		Code string `gorm:"uniqueIndex"`
+	}
+	return &product{}
+}
+
+// migrateUniqueProductCode adds a unique index on the product code. It fails without changing
+// anything if products, soft-deleted ones included, already share a code.
+func migrateUniqueProductCode(tx *gorm.DB) error {
+	var shared []string
+	err := tx.Table("products").Group("code").Having("COUNT(*) > 1").Order("code").Pluck("code", &shared).Error
+	if err != nil {
+		return err
+	}
+	if len(shared) > 0 {
+		return fmt.Errorf("%w: product codes %s are each used by several products, soft-deleted ones included; rename or hard-delete the duplicates and migrate again",
+			ErrFailedPrecondition, strings.Join(shared, ", "))
+	}
+	return tx.Migrator().CreateIndex(uniqueProductCodeSchema(), "Code")
+}
+
+func rollbackUniqueProductCode(tx *gorm.DB) error {
+	return tx.Migrator().DropIndex(uniqueProductCodeSchema(), "Code")
+}
+
+// productTagsSchema returns the tags and product_tags tables of 0010_product_tags
+func productTagsSchema() []any {
+	type tag struct {
+		ID        uint   `gorm:"primaryKey"`
+		Name      string `gorm:"uniqueIndex"`
+		CreatedAt time.Time
+	}
+	type productTag struct {
+		ProductID uint `gorm:"primaryKey;autoIncrement:false"`
+		TagID     uint `gorm:"primaryKey;autoIncrement:false;index"`
+		CreatedAt time.Time
+	}
+	return []any{&tag{}, &productTag{}}
+}
+
+func migrateProductTags(tx *gorm.DB) error {
+	return tx.AutoMigrate(productTagsSchema()...)
+}
+
+func rollbackProductTags(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(productTagsSchema()...)
+}
+
+// promotionsSchema returns the promotions table of 0011_promotions
+func promotionsSchema() any {
+	type promotion struct {
+		ID         uint   `gorm:"primaryKey"`
+		Name       string `gorm:"uniqueIndex"`
+		Type       string
+		Value      float64
+		Currency   string
+		Categories string
+		ValidFrom  time.Time  `gorm:"index"`
+		ValidUntil *time.Time `gorm:"index"`
+		CreatedAt  time.Time
+	}
+	return &promotion{}
+}
+
+func migratePromotions(tx *gorm.DB) error {
+	return tx.AutoMigrate(promotionsSchema())
+}
+
+func rollbackPromotions(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(promotionsSchema())
+}
+
+// productVariantsSchema returns the product_variants table of 0012_product_variants
+func productVariantsSchema() any {
+	type productVariant struct {
+		ID        uint   `gorm:"primaryKey"`
+		ProductID uint   `gorm:"index;uniqueIndex:idx_product_variants_options"`
+		SKU       string `gorm:"uniqueIndex"`
+		Size      string `gorm:"uniqueIndex:idx_product_variants_options"`
+		Color     string `gorm:"uniqueIndex:idx_product_variants_options"`
+		Price     *float64
+		Stock     int
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	return &productVariant{}
+}
+
+func migrateProductVariants(tx *gorm.DB) error {
+	return tx.AutoMigrate(productVariantsSchema())
+}
+
+func rollbackProductVariants(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(productVariantsSchema())
+}
+
+// ordersSchema returns the orders and order_items tables of 0013_orders
+func ordersSchema() []any {
+	type orderItem struct {
+		ID          uint `gorm:"primaryKey"`
+		OrderID     uint `gorm:"index"`
+		ProductID   uint `gorm:"index"`
+		VariantID   *uint
+		Code        string
+		SKU         string
+		Quantity    int
+		UnitPrice   float64
+		PromotionID *uint
+		Total       float64
+	}
+	type order struct {
+		ID        uint `gorm:"primaryKey"`
+		Currency  string
+		Total     float64
+		Items     []orderItem `gorm:"constraint:OnDelete:CASCADE"`
+		CreatedAt time.Time   `gorm:"index"`
+	}
+	return []any{&order{}, &orderItem{}}
+}
+
+func migrateOrders(tx *gorm.DB) error {
+	return tx.AutoMigrate(ordersSchema()...)
+}
+
+func rollbackOrders(tx *gorm.DB) error {
+	schema := ordersSchema()
+	return tx.Migrator().DropTable(schema[1], schema[0])
+}
+
+// customersSchema returns the customers table of 0014_customers and the orders table with
+// the customer who placed each order
+func customersSchema() (any, any) {
+	type customer struct {
+		ID        uint `gorm:"primaryKey"`
+		Name      string
+		Email     string `gorm:"uniqueIndex"`
+		Phone     string
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type order struct {
+		CustomerID *uint `gorm:"index"`
+	}
+	return &customer{}, &order{}
+}
+
+func migrateCustomers(tx *gorm.DB) error {
+	customer, order := customersSchema()
+	return tx.AutoMigrate(customer, order)
+}
+
+func rollbackCustomers(tx *gorm.DB) error {
+	customer, order := customersSchema()
+	if err := tx.Migrator().DropIndex(order, "CustomerID"); err != nil {
+		return err
+	}
+	if err := tx.Migrator().DropColumn(order, "CustomerID"); err != nil {
+		return err
+	}
+	return tx.Migrator().DropTable(customer)
+}
+
+// suppliersSchema returns the suppliers table of 0015_suppliers and the products table with
+// the supplier of each product
+func suppliersSchema() (any, any) {
+	type supplier struct {
+		ID          uint   `gorm:"primaryKey"`
+		Name        string `gorm:"uniqueIndex"`
+		ContactName string
+		Email       string
+		Phone       string
+		Address     string
+		CreatedAt   time.Time
+		UpdatedAt   time.Time
+	}
+	type product struct {
+		SupplierID *uint `gorm:"index"`
+	}
+	return &supplier{}, &product{}
+}
+
+func migrateSuppliers(tx *gorm.DB) error {
+	supplier, product := suppliersSchema()
+	return tx.AutoMigrate(supplier, product)
+}
+
+func rollbackSuppliers(tx *gorm.DB) error {
+	supplier, product := suppliersSchema()
+	if err := tx.Migrator().DropIndex(product, "SupplierID"); err != nil {
+		return err
+	}
+	if err := tx.Migrator().DropColumn(product, "SupplierID"); err != nil {
+		return err
+	}
+	return tx.Migrator().DropTable(supplier)
+}
+
+// productSearchStatements create the product_search table of 0016_product_search, an FTS5
+// index of the names and descriptions of products. It is an external content table kept in
+// sync with products by triggers, so that every write path, raw SQL included, updates it.
+var productSearchStatements = []string{
+	"CREATE VIRTUAL TABLE product_search USING fts5(name, description, content='products', content_rowid='id')",
+	`CREATE TRIGGER product_search_insert AFTER INSERT ON products BEGIN
+		INSERT INTO product_search(rowid, name, description) VALUES (new.id, new.name, new.description);
+	END`,
+	`CREATE TRIGGER product_search_delete AFTER DELETE ON products BEGIN
+		INSERT INTO product_search(product_search, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+	END`,
+	`CREATE TRIGGER product_search_update AFTER UPDATE OF name, description ON products BEGIN
+		INSERT INTO product_search(product_search, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+		INSERT INTO product_search(rowid, name, description) VALUES (new.id, new.name, new.description);
+	END`,
+	"INSERT INTO product_search(product_search) VALUES ('rebuild')",
+}
+
+// migrateProductSearch indexes products for full-text search on SQLite databases with FTS5.
+// Other databases, and SQLite without FTS5, are left without the index; rolling back and
+// reapplying the migration with a server built with FTS5 creates it.
+func migrateProductSearch(tx *gorm.DB) error {
+	available, err := fts5Available(tx)
+	if err != nil {
+		return err
+	}
+	if !available {
+		slog.Warn("Full-text search unavailable: the database is not SQLite with FTS5; build with -tags sqlite_fts5 to enable it")
+		return nil
+	}
+	for _, statement := range productSearchStatements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func rollbackProductSearch(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "sqlite" {
+		return nil
+	}
+	for _, trigger := range []string{"product_search_insert", "product_search_delete", "product_search_update"} {
+		if err := tx.Exec("DROP TRIGGER IF EXISTS " + trigger).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Exec("DROP TABLE IF EXISTS product_search").Error
+}
+
+// storedPromptsSchema returns the stored_prompts table of 0017_stored_prompts
+func storedPromptsSchema() any {
+	type storedPrompt struct {
+		ID          uint   `gorm:"primaryKey"`
+		Name        string `gorm:"uniqueIndex"`
+		Description string
+		Template    string
+		Arguments   string
+		Resources   string
+		CreatedAt   time.Time
+		UpdatedAt   time.Time
+	}
+	return &storedPrompt{}
+}
+
+func migrateStoredPrompts(tx *gorm.DB) error {
+	return tx.AutoMigrate(storedPromptsSchema())
+}
+
+func rollbackStoredPrompts(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(storedPromptsSchema())
+}
+
+// scopedIdempotencySchema returns the idempotency_records table of
+// 0018_scoped_idempotency_keys, keyed by tenant and session as well
+func scopedIdempotencySchema() any {
+	type idempotencyRecord struct {
+		Tenant      string `gorm:"primaryKey"`
+		Session     string `gorm:"primaryKey"`
+		Tool        string `gorm:"primaryKey"`
+		Key         string `gorm:"primaryKey"`
+		RequestHash string
+		Result      string
+		CreatedAt   time.Time
+	}
+	return &idempotencyRecord{}
+}
+
+// unscopedIdempotencySchema returns the idempotency_records table of 0001_initial
+func unscopedIdempotencySchema() any {
+	type idempotencyRecord struct {
+		Tool        string `gorm:"primaryKey"`
+		Key         string `gorm:"primaryKey"`
+		RequestHash string
+		Result      string
+		CreatedAt   time.Time
+	}
+	return &idempotencyRecord{}
+}
+
+// migrateScopedIdempotencyKeys recreates idempotency_records with its new primary key.
+// The records only let retries replay recent results, and those of earlier versions cannot
+// be attributed to a session, so they are dropped.
+func migrateScopedIdempotencyKeys(tx *gorm.DB) error {
+	if err := tx.Migrator().DropTable(unscopedIdempotencySchema()); err != nil {
+		return err
+	}
+	return tx.Migrator().CreateTable(scopedIdempotencySchema())
+}
+
+func rollbackScopedIdempotencyKeys(tx *gorm.DB) error {
+	if err := tx.Migrator().DropTable(scopedIdempotencySchema()); err != nil {
+		return err
+	}
+	return tx.Migrator().CreateTable(unscopedIdempotencySchema())
+}
+
+// ErrPendingMigrations is returned at startup when migrations are pending and
+// automatic migration is disabled
+var ErrPendingMigrations = errors.New("database has pending migrations")
+
+// appliedMigrations returns the ids of the migrations applied to db, creating the
+// migrations table if needed
+func appliedMigrations(db *gorm.DB) (map[string]bool, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var ids []string
+	if err := db.Model(&SchemaMigration{}).Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	applied := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		applied[id] = true
+	}
+	return applied, nil
+}
+
+// pendingMigrations returns the migrations not yet applied to db
+func pendingMigrations(db *gorm.DB) ([]migration, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []migration
+	for _, m := range migrations {
+		if !applied[m.ID] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// migrate applies the pending migrations of db in order and returns their ids
+func migrate(db *gorm.DB) ([]string, error) {
+	pending, err := pendingMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, m := range pending {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Migrate(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{ID: m.ID, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return ids, fmt.Errorf("failed to apply migration %s: %w", m.ID, err)
+		}
+		slog.Info("Applied migration", "id", m.ID)
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
+
+// rollback reverts the latest applied migration of db and returns its id, or an
+// empty id if no migration is applied
+func rollback(db *gorm.DB) (string, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if !applied[m.ID] {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Rollback(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{ID: m.ID}).Error
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to roll back migration %s: %w", m.ID, err)
+		}
+		slog.Info("Rolled back migration", "id", m.ID)
+		return m.ID, nil
+	}
+	return "", nil
+}
+
+// MigrateDatabase applies the pending migrations to the configured database and the
+// databases of the tenants, and returns the ids of the migrations applied to any of them
+func MigrateDatabase(cfg *Config) ([]string, error) {
+	var applied []string
+	err := forEachDatabase(cfg, func(db *gorm.DB) error {
+		ids, err := migrate(db)
+		applied = append(applied, ids...)
+		return err
+	})
+	return applied, err
+}
+
+// RollbackDatabase reverts the latest migration applied to the configured database and to
+// the databases of the tenants, and returns the id reverted in the configured database, or
+// an empty id if none is applied
+func RollbackDatabase(cfg *Config) (string, error) {
+	var reverted string
+	first := true
+	err := forEachDatabase(cfg, func(db *gorm.DB) error {
+		id, err := rollback(db)
+		if first {
+			reverted, first = id, false
+		}
+		return err
+	})
+	return reverted, err
+}
+
+// forEachDatabase opens the configured database, then the database of each tenant in
+// name order, and calls fn with each until it fails
+func forEachDatabase(cfg *Config, fn func(db *gorm.DB) error) error {
+	names := append([]string{""}, slices.Sorted(maps.Keys(cfg.Tenants))...)
+	for _, name := range names {
+		dbCfg := cfg
+		if name != "" {
+			dbCfg = cfg.tenantConfig(name)
+			slog.Info("Opening tenant database", "tenant", name)
+		}
+		db, err := storage.Open(dbCfg.DBPath, dbCfg.storageOptions())
+		if err != nil {
+			return err
+		}
+		err = fn(db)
+		NewDBService(db).Close()
+		if err != nil {
+			if name != "" {
+				return fmt.Errorf("tenant %s: %w", name, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
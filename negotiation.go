@@ -0,0 +1,144 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// samplingTools lists the tools that only work with clients supporting sampling
+var samplingTools = []string{"generate_description"}
+
+// ClientFeatures are the capabilities a client declared in its initialize request
+type ClientFeatures struct {
+	Sampling    bool
+	Elicitation bool
+	Roots       bool
+	// RootsListChanged is set if the client notifies changes to its roots
+	RootsListChanged bool
+}
+
+// names returns the names of the declared capabilities
+func (f ClientFeatures) names() []string {
+	names := []string{}
+	for _, declared := range []struct {
+		name string
+		ok   bool
+	}{{"sampling", f.Sampling}, {"elicitation", f.Elicitation}, {"roots", f.Roots}, {"roots.listChanged", f.RootsListChanged}} {
+		if declared.ok {
+			names = append(names, declared.name)
+		}
+	}
+	return names
+}
+
+// SessionCapabilities records the capabilities the client of each session declared, which
+// the server adapts to: tools the client cannot use are hidden from it and requests it cannot
+// answer are not sent. The capabilities type of the library lacks elicitation, so they are
+// read from the raw initialize request.
+type SessionCapabilities struct {
+	mu       sync.RWMutex
+	sessions map[string]ClientFeatures
+}
+
+// NewSessionCapabilities creates a registry without sessions
+func NewSessionCapabilities() *SessionCapabilities {
+	return &SessionCapabilities{sessions: make(map[string]ClientFeatures)}
+}
+
+// onRequest records the capabilities of the initialize request of a session; it is
+// registered as an OnRequestInitialization hook
+func (sc *SessionCapabilities) onRequest(ctx context.Context, id any, message any) error {
+	raw, ok := message.(json.RawMessage)
+	if !ok || sessionID(ctx) == "" || !bytes.Contains(raw, []byte(`"`+mcp.MethodInitialize+`"`)) {
+		return nil
+	}
+	var request struct {
+		Method string `json:"method"`
+		Params struct {
+			Capabilities struct {
+				Sampling    json.RawMessage `json:"sampling"`
+				Elicitation json.RawMessage `json:"elicitation"`
+				Roots       *struct {
+					ListChanged bool `json:"listChanged"`
+				} `json:"roots"`
+			} `json:"capabilities"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(raw, &request); err != nil || request.Method != string(mcp.MethodInitialize) {
+		return nil
+	}
+	capabilities := request.Params.Capabilities
+	features := ClientFeatures{
+		Sampling:    capabilities.Sampling != nil,
+		Elicitation: capabilities.Elicitation != nil,
+		Roots:       capabilities.Roots != nil,
+	}
+	if capabilities.Roots != nil {
+		features.RootsListChanged = capabilities.Roots.ListChanged
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.sessions[sessionID(ctx)] = features
+	return nil
+}
+
+// afterInitialize logs what was negotiated with the client of a session; it is registered
+// as an AfterInitialize hook
+func (sc *SessionCapabilities) afterInitialize(ctx context.Context, id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
+	features, _ := sc.features(ctx)
+	var hidden []string
+	if !features.Sampling {
+		hidden = samplingTools
+	}
+	slog.Info("Negotiated with client",
+		"session", sessionID(ctx),
+		"client", message.Params.ClientInfo.Name+" "+message.Params.ClientInfo.Version,
+		"requested_protocol", message.Params.ProtocolVersion,
+		"protocol", result.ProtocolVersion,
+		"client_capabilities", features.names(),
+		"hidden_tools", hidden,
+	)
+}
+
+// onUnregister forgets the capabilities of a session that ended
+func (sc *SessionCapabilities) onUnregister(ctx context.Context, session server.ClientSession) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	delete(sc.sessions, session.SessionID())
+}
+
+// features returns the capabilities declared by the client of the session of ctx; ok is
+// false outside sessions that sent an initialize request
+func (sc *SessionCapabilities) features(ctx context.Context) (features ClientFeatures, ok bool) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	features, ok = sc.sessions[sessionID(ctx)]
+	return features, ok
+}
+
+// supportsElicitation reports whether the client of the session of ctx declared the
+// elicitation capability
+func (sc *SessionCapabilities) supportsElicitation(ctx context.Context) bool {
+	features, _ := sc.features(ctx)
+	return features.Elicitation
+}
+
+// filter hides the tools the client of the session cannot use; it is registered as a tool
+// filter. Listings outside sessions, such as the tool switch loading the tools, see them all.
+func (sc *SessionCapabilities) filter(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	features, ok := sc.features(ctx)
+	if !ok || features.Sampling {
+		return tools
+	}
+	return slices.DeleteFunc(tools, func(tool mcp.Tool) bool {
+		return slices.Contains(samplingTools, tool.Name)
+	})
+}
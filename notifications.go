@@ -0,0 +1,17 @@
+package mcpserver
+
+import (
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// broadcastLog sends a log message notification to every connected client
+func (app *App) broadcastLog(level mcp.LoggingLevel, logger string, data any) {
+	if app.server == nil {
+		return
+	}
+	app.server.SendNotificationToAllClients("notifications/message", map[string]any{
+		"level":  level,
+		"logger": logger,
+		"data":   data,
+	})
+}
@@ -0,0 +1,435 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxOrderItems caps the number of items of a single order
+const maxOrderItems = 100
+
+// ErrOrderNotFound is returned when no order has the requested id
+var ErrOrderNotFound = fmt.Errorf("order %w", ErrNotFound)
+
+// Order is an order placed against the catalog. Its items keep the code and price of the
+// products when it was placed, so that later changes to the catalog do not alter it.
+type Order struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// CustomerID is the customer who placed the order, if known
+	CustomerID *uint  `gorm:"index" json:"customer_id,omitempty"`
+	Currency   string `json:"currency"`
+	// Total is the sum of the totals of the items
+	Total     float64     `json:"total"`
+	Items     []OrderItem `json:"items"`
+	CreatedAt time.Time   `gorm:"index" json:"created_at"`
+}
+
+// OrderItem is a product, or a variant of it, ordered in some quantity
+type OrderItem struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	OrderID   uint   `gorm:"index" json:"order_id"`
+	ProductID uint   `gorm:"index" json:"product_id"`
+	VariantID *uint  `json:"variant_id,omitempty"`
+	Code      string `json:"code"`
+	SKU       string `json:"sku,omitempty"`
+	Quantity  int    `json:"quantity"`
+	// UnitPrice is the price of the product or variant in the currency of the order, less the
+	// promotion applied if any
+	UnitPrice   float64 `json:"unit_price"`
+	PromotionID *uint   `json:"promotion_id,omitempty"`
+	Total       float64 `json:"total"`
+}
+
+// OrderLine is an item requested by place_order; the product is given by id or by code
+type OrderLine struct {
+	ProductID uint
+	Code      string
+	VariantID uint
+	Quantity  int
+}
+
+// OrderQuery selects the orders returned by list_orders
+type OrderQuery struct {
+	// CustomerID, if set, keeps the orders of that customer
+	CustomerID uint
+	// ProductID, if set, keeps the orders with an item of that product
+	ProductID uint
+	// Since and Until, if set, bound the time orders were placed, Until excluded
+	Since, Until time.Time
+	Limit        int
+	Offset       int
+}
+
+// orderPricer returns the unit price of product, in the currency of the order, and the id of
+// the promotion applied if any. The price of the product is that of the variant ordered, if any.
+type orderPricer func(product *Product) (float64, *uint, error)
+
+// roundCents rounds an amount to two decimals
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// PlaceOrder places an order for lines in currency on behalf of the customer with the given
+// id, if not zero, taking the ordered quantities out of the
+// stock of the products, or of their variants, in the same transaction. The order fails as a
+// whole if any product lacks the stock. Products with variants must be ordered by variant.
+func (dbs *DBService) PlaceOrder(ctx context.Context, customerID uint, currency string, lines []OrderLine, price orderPricer) (*Order, error) {
+	var order *Order
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		order = &Order{Currency: currency}
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			if customerID != 0 {
+				if err := findCustomer(tx, customerID); err != nil {
+					return err
+				}
+				order.CustomerID = &customerID
+			}
+			for i, line := range lines {
+				item, err := orderItem(tx, i, line, price)
+				if err != nil {
+					return err
+				}
+				order.Items = append(order.Items, *item)
+				order.Total += item.Total
+			}
+			order.Total = roundCents(order.Total)
+
+			if err := tx.Create(order).Error; err != nil {
+				return fmt.Errorf("failed to create order: %w", err)
+			}
+			return recordAudit(tx, auditOrder, order.ID, auditCreate, nil, order)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// forUpdate locks the rows read with tx until its transaction ends, so that concurrent
+// transactions cannot change the stock between its check and its update. SQLite, which
+// serializes writing transactions, has no row locks and ignores it.
+func forUpdate(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate})
+}
+
+// orderItem takes the quantity of line, the i-th of an order, out of stock in tx and returns
+// the item of the order. The product and its variants stay locked until tx ends.
+func orderItem(tx *gorm.DB, i int, line OrderLine, price orderPricer) (*OrderItem, error) {
+	var products []Product
+	var err error
+	if line.ProductID != 0 {
+		err = forUpdate(tx).Limit(1).Find(&products, line.ProductID).Error
+	} else {
+		err = forUpdate(tx).Where("code = ?", line.Code).Limit(1).Find(&products).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve product: %w", err)
+	}
+	switch {
+	case len(products) == 0 && line.ProductID != 0:
+		return nil, fmt.Errorf("%w: id %d", ErrProductNotFound, line.ProductID)
+	case len(products) == 0:
+		return nil, fmt.Errorf("%w: code %q", ErrProductNotFound, line.Code)
+	}
+	product := products[0]
+	item := &OrderItem{ProductID: product.ID, Code: product.Code, Quantity: line.Quantity}
+
+	var variants []ProductVariant
+	if err := forUpdate(tx).Where("product_id = ?", product.ID).Order("id").Find(&variants).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve variants: %w", err)
+	}
+	priced := product
+	if line.VariantID == 0 {
+		if len(variants) > 0 {
+			return nil, invalidField(fmt.Sprintf("items[%d].variant_id", i), fmt.Sprintf("product %d has variants; give the id of the variant ordered, as listed by list_variants", product.ID))
+		}
+		err := updateProductTx(tx, &Product{}, product.ID, func(p *Product) error {
+			if p.Stock < line.Quantity {
+				return fmt.Errorf("%w: product %d has %d in stock, cannot order %d", ErrFailedPrecondition, p.ID, p.Stock, line.Quantity)
+			}
+			p.Stock -= line.Quantity
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		index := slices.IndexFunc(variants, func(v ProductVariant) bool { return v.ID == line.VariantID })
+		if index < 0 {
+			return nil, fmt.Errorf("%w: product %d has no variant %d", ErrVariantNotFound, product.ID, line.VariantID)
+		}
+		variant := variants[index]
+		if variant.Stock < line.Quantity {
+			return nil, fmt.Errorf("%w: variant %d has %d in stock, cannot order %d", ErrFailedPrecondition, variant.ID, variant.Stock, line.Quantity)
+		}
+		before := variant
+		variant.Stock -= line.Quantity
+		if err := tx.Save(&variant).Error; err != nil {
+			return nil, fmt.Errorf("failed to update variant: %w", err)
+		}
+		if err := recordAudit(tx, auditProductVariant, variant.ID, auditUpdate, &before, &variant); err != nil {
+			return nil, err
+		}
+		item.VariantID = &variant.ID
+		item.SKU = variant.SKU
+		if variant.Price != nil {
+			priced.Price = *variant.Price
+		}
+	}
+
+	unitPrice, promotionID, err := price(&priced)
+	if err != nil {
+		return nil, err
+	}
+	item.UnitPrice = unitPrice
+	item.PromotionID = promotionID
+	item.Total = roundCents(unitPrice * float64(line.Quantity))
+	return item, nil
+}
+
+// GetOrder returns the order with the given id and its items
+func (dbs *DBService) GetOrder(ctx context.Context, id uint) (*Order, error) {
+	var orders []Order
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		orders = nil
+		return dbs.conn(ctx).Preload("Items", orderItemsByID).Limit(1).Find(&orders, id).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve order: %w", err)
+	}
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("%w: id %d", ErrOrderNotFound, id)
+	}
+	return &orders[0], nil
+}
+
+// ListOrders returns a page of the orders matching query with their items, most recent first,
+// and the number of matching orders
+func (dbs *DBService) ListOrders(ctx context.Context, query OrderQuery) ([]Order, int, error) {
+	orders := []Order{}
+	var total int64
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		db := dbs.conn(ctx).Model(&Order{})
+		if query.CustomerID != 0 {
+			db = db.Where("customer_id = ?", query.CustomerID)
+		}
+		if query.ProductID != 0 {
+			db = db.Where("id IN (?)", dbs.conn(ctx).Model(&OrderItem{}).Select("order_id").Where("product_id = ?", query.ProductID))
+		}
+		if !query.Since.IsZero() {
+			db = db.Where("created_at >= ?", query.Since)
+		}
+		if !query.Until.IsZero() {
+			db = db.Where("created_at < ?", query.Until)
+		}
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return err
+		}
+		db = db.Preload("Items", orderItemsByID).Order("created_at DESC").Order("id DESC").Offset(query.Offset)
+		if query.Limit > 0 {
+			db = db.Limit(query.Limit)
+		}
+		return db.Find(&orders).Error
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve orders: %w", err)
+	}
+	return orders, int(total), nil
+}
+
+// orderItemsByID orders the preloaded items of orders as they were placed
+func orderItemsByID(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
+// parseOrderLines parses the items argument of place_order, a list of objects giving a
+// product_id or code, an optional variant_id and a quantity
+func parseOrderLines(raw any) ([]OrderLine, error) {
+	items, ok := raw.([]any)
+	if !ok || len(items) == 0 {
+		return nil, invalidField("items", "must list at least one item")
+	}
+	if len(items) > maxOrderItems {
+		return nil, invalidField("items", fmt.Sprintf("must list at most %d items", maxOrderItems))
+	}
+
+	// positive returns the value of key in item if it is a positive integer
+	positive := func(item map[string]any, key string) (int, bool) {
+		n, ok := item[key].(float64)
+		return int(n), ok && n >= 1 && n == math.Trunc(n)
+	}
+
+	lines := make([]OrderLine, len(items))
+	var fields []FieldError
+	for i, raw := range items {
+		name := fmt.Sprintf("items[%d]", i)
+		item, ok := raw.(map[string]any)
+		if !ok {
+			fields = append(fields, FieldError{Field: name, Message: "must be an object"})
+			continue
+		}
+		for key := range item {
+			if key != "product_id" && key != "code" && key != "variant_id" && key != "quantity" {
+				fields = append(fields, FieldError{Field: name + "." + key, Message: "unknown field"})
+			}
+		}
+
+		_, hasID := item["product_id"]
+		code, _ := item["code"].(string)
+		code = strings.TrimSpace(code)
+		if hasID == (code != "") {
+			fields = append(fields, FieldError{Field: name, Message: "provide either product_id or code"})
+		} else if hasID {
+			id, ok := positive(item, "product_id")
+			if !ok {
+				fields = append(fields, FieldError{Field: name + ".product_id", Message: "must be a positive integer"})
+			}
+			lines[i].ProductID = uint(id)
+		}
+		lines[i].Code = code
+
+		if _, ok := item["variant_id"]; ok {
+			id, ok := positive(item, "variant_id")
+			if !ok {
+				fields = append(fields, FieldError{Field: name + ".variant_id", Message: "must be a positive integer"})
+			}
+			lines[i].VariantID = uint(id)
+		}
+
+		quantity, ok := positive(item, "quantity")
+		if !ok {
+			fields = append(fields, FieldError{Field: name + ".quantity", Message: "must be a positive integer"})
+		}
+		lines[i].Quantity = quantity
+	}
+	if len(fields) > 0 {
+		return nil, &ValidationError{Fields: fields}
+	}
+	return lines, nil
+}
+
+// placeOrderHandler handles the place_order tool request. Items are priced at the current
+// price of their product or variant with the best active promotion applied, converted to
+// the currency of the order.
+func (app *App) placeOrderHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	lines, err := parseOrderLines(request.GetArguments()["items"])
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	currency, err := requestCurrency(request, "currency", app.config.Currency)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	customerID := request.GetInt("customer_id", 0)
+	if customerID < 0 {
+		return toolErrorResult(invalidField("customer_id", "must be a positive integer"))
+	}
+
+	now := time.Now().UTC()
+	promotions, err := app.dbService.ListPromotions(ctx, now)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	price := func(product *Product) (float64, *uint, error) {
+		effective, err := app.config.effectivePrice(product, promotions, now)
+		if err != nil {
+			return 0, nil, err
+		}
+		conversion, err := app.config.convertPrice(effective.EffectivePrice, product.Currency, currency)
+		if err != nil {
+			return 0, nil, err
+		}
+		if effective.Promotion == nil {
+			return conversion.Converted, nil, nil
+		}
+		return conversion.Converted, &effective.Promotion.ID, nil
+	}
+
+	order, err := app.dbService.PlaceOrder(ctx, uint(customerID), currency, lines, price)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(order, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal order to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// getOrderHandler handles the get_order tool request
+func (app *App) getOrderHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+
+	order, err := app.dbService.GetOrder(ctx, uint(id))
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(order, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal order to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// listOrdersHandler handles the list_orders tool request
+func (app *App) listOrdersHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	customerID := request.GetInt("customer_id", 0)
+	if customerID < 0 {
+		return toolErrorResult(invalidField("customer_id", "must be a positive integer"))
+	}
+	productID := request.GetInt("product_id", 0)
+	if productID < 0 {
+		return toolErrorResult(invalidField("product_id", "must be a positive integer"))
+	}
+	query := OrderQuery{CustomerID: uint(customerID), ProductID: uint(productID)}
+	var err error
+	if query.Since, err = requestTime(request, "since", time.Time{}); err != nil {
+		return toolErrorResult(err)
+	}
+	if query.Until, err = requestTime(request, "until", time.Time{}); err != nil {
+		return toolErrorResult(err)
+	}
+	requested := request.GetInt("limit", 0)
+	if requested < 0 || requested > maxProductListLimit {
+		return toolErrorResult(invalidField("limit", fmt.Sprintf("must be between 1 and %d", maxProductListLimit)))
+	}
+	if cursor := request.GetString("cursor", ""); cursor != "" {
+		if query.Offset, err = decodeCursor("cursor", cursor); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+
+	limits := app.config.Results
+	query.Limit = limits.fetchLimit(requested)
+	orders, total, err := app.dbService.ListOrders(ctx, query)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	data, n, truncation, err := limitResult(limits, orders, query.Offset, total, requested, true, renderJSON(func(page []Order) any { return page }))
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	if err := app.quotas.AddRows(ctx, n); err != nil {
+		return toolErrorResult(err)
+	}
+	return truncatedToolResult(string(data), truncation)
+}
@@ -0,0 +1,228 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Tools returning data declare the JSON schema of their result as outputSchema and send the
+// result as structuredContent besides the JSON text, so that typed clients need not parse the
+// text. The MCP library has neither field, so both are added to the messages on their way out
+// by the error mapper, which every transport writes through; in-process clients only get the
+// text.
+
+// withOutputSchema declares the output schema of a tool
+func (app *App) withOutputSchema(schema map[string]any) mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		app.outputSchemas.Store(t.Name, schema)
+	}
+}
+
+// calculationOutputSchema describes CalculationResult
+var calculationOutputSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"operation": map[string]any{"type": "string", "enum": calculatorOperations},
+		"x":         map[string]any{"type": "number"},
+		"y":         map[string]any{"type": "number"},
+		"result":    map[string]any{"type": "number", "description": "Unrounded result of the operation"},
+	},
+	"required": []string{"operation", "x", "y", "result"},
+}
+
+// productOutputSchema describes a product, of which the fields argument may select only some
+// fields
+var productOutputSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"ID":          map[string]any{"type": "integer"},
+		"Code":        map[string]any{"type": "string"},
+		"Name":        map[string]any{"type": "string"},
+		"Description": map[string]any{"type": "string"},
+		"Category":    map[string]any{"type": []string{"string", "null"}},
+		"Price":       map[string]any{"type": "number"},
+		"Currency":    map[string]any{"type": "string"},
+		"Stock":       map[string]any{"type": "integer"},
+		"SupplierID":  map[string]any{"type": []string{"integer", "null"}},
+		"CreatedAt":   map[string]any{"type": "string", "format": "date-time"},
+		"UpdatedAt":   map[string]any{"type": "string", "format": "date-time"},
+		"DeletedAt":   map[string]any{"type": []string{"string", "null"}, "format": "date-time"},
+	},
+}
+
+// productRefOutputSchema describes a ProductRef
+var productRefOutputSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"id":         map[string]any{"type": "integer"},
+		"code":       map[string]any{"type": "string"},
+		"name":       map[string]any{"type": "string"},
+		"created_at": map[string]any{"type": "string", "format": "date-time"},
+	},
+	"required": []string{"id", "code", "created_at"},
+}
+
+// productStatsOutputSchema describes ProductStats
+var productStatsOutputSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"products": map[string]any{"type": "integer"},
+		"prices": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"currency": map[string]any{"type": "string"},
+					"products": map[string]any{"type": "integer"},
+					"min":      map[string]any{"type": "number"},
+					"max":      map[string]any{"type": "number"},
+					"avg":      map[string]any{"type": "number"},
+				},
+				"required": []string{"currency", "products", "min", "max", "avg"},
+			},
+		},
+		"categories": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"category": map[string]any{"type": []string{"string", "null"}, "description": "Name of the category, null for uncategorized products"},
+					"products": map[string]any{"type": "integer"},
+				},
+				"required": []string{"category", "products"},
+			},
+		},
+		"newest": productRefOutputSchema,
+		"oldest": productRefOutputSchema,
+	},
+	"required": []string{"products", "prices", "categories"},
+}
+
+// structuredContentKey is the context key of the structured content of a tool call
+type structuredContentKey struct{}
+
+// structuredContent holds the structured content a tool handler sets
+type structuredContent struct {
+	value any
+}
+
+// setStructuredContent sets the structured content of the tool call of ctx; it is sent only
+// if the call succeeds
+func setStructuredContent(ctx context.Context, value any) {
+	if content, ok := ctx.Value(structuredContentKey{}).(*structuredContent); ok {
+		content.value = value
+	}
+}
+
+// structuredOutputMiddleware records the structured content set by a successful tool call
+// so that it is added to the response
+func (app *App) structuredOutputMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id := callID(ctx)
+		if id == "" {
+			return next(ctx, request)
+		}
+		content := &structuredContent{}
+		result, err := next(context.WithValue(ctx, structuredContentKey{}, content), request)
+		if err == nil && result != nil && !result.IsError && content.value != nil {
+			app.rpcErrors.structure(ctx, id, content.value)
+		}
+		return result, err
+	}
+}
+
+// structure records the structured content of the response to the tool call id of the
+// session of ctx, id being a string form of its JSON-RPC id
+func (m *rpcErrorMapper) structure(ctx context.Context, id string, value any) {
+	m.mu.Lock()
+	m.structured[responseKey{session: sessionID(ctx), id: id}] = value
+	m.mu.Unlock()
+}
+
+// takeStructured removes and returns the structured content recorded for a request id of
+// session
+func (m *rpcErrorMapper) takeStructured(session string, id any) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.structured) == 0 {
+		return nil, false
+	}
+
+	key := newResponseKey(session, id)
+	value, ok := m.structured[key]
+	delete(m.structured, key)
+	return value, ok
+}
+
+// addOutputs returns message, written to session, with the structured content recorded for
+// its request, or with the output schemas of the tools it lists; ok is false if message is no
+// such response
+func (m *rpcErrorMapper) addOutputs(session string, message []byte) ([]byte, bool) {
+	if !bytes.Contains(message, []byte(`"result"`)) {
+		return nil, false
+	}
+	var response struct {
+		JSONRPC string                     `json:"jsonrpc"`
+		ID      any                        `json:"id"`
+		Result  map[string]json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(message, &response); err != nil || response.Result == nil {
+		return nil, false
+	}
+
+	if value, ok := m.takeStructured(session, response.ID); ok {
+		structured, err := json.Marshal(value)
+		if err != nil {
+			return nil, false
+		}
+		response.Result["structuredContent"] = structured
+	} else if tools, ok := response.Result["tools"]; !ok || m.outputSchemas == nil || !addOutputSchemas(m.outputSchemas, response.Result, tools) {
+		return nil, false
+	}
+
+	rewritten, err := json.Marshal(response)
+	if err != nil {
+		return nil, false
+	}
+	return rewritten, true
+}
+
+// addOutputSchemas sets the tools of a tools/list result to tools with their output schemas,
+// which schemas maps tool names to, added; it reports whether any tool has one
+func addOutputSchemas(schemas *sync.Map, result map[string]json.RawMessage, tools json.RawMessage) bool {
+	var listed []map[string]json.RawMessage
+	if err := json.Unmarshal(tools, &listed); err != nil {
+		return false
+	}
+	added := false
+	for _, tool := range listed {
+		var name string
+		if err := json.Unmarshal(tool["name"], &name); err != nil {
+			continue
+		}
+		schema, ok := schemas.Load(name)
+		if !ok {
+			continue
+		}
+		encoded, err := json.Marshal(schema)
+		if err != nil {
+			continue
+		}
+		tool["outputSchema"] = encoded
+		added = true
+	}
+	if !added {
+		return false
+	}
+	encoded, err := json.Marshal(listed)
+	if err != nil {
+		return false
+	}
+	result["tools"] = encoded
+	return true
+}
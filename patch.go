@@ -0,0 +1,138 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// productDocument is the view of a product that patch documents are applied to
+type productDocument struct {
+	Code        *string  `json:"code"`
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	Category    *string  `json:"category"`
+	Price       *float64 `json:"price"`
+	Currency    *string  `json:"currency"`
+}
+
+// applyProductPatch applies an RFC 6902 JSON Patch (jsonPatch) or an RFC 7396
+// merge patch (mergePatch) to p; exactly one of them must be set
+func applyProductPatch(p *Product, jsonPatch, mergePatch []byte) error {
+	doc, err := json.Marshal(productDocument{Code: &p.Code, Name: &p.Name, Description: &p.Description, Category: p.Category, Price: &p.Price, Currency: &p.Currency})
+	if err != nil {
+		return fmt.Errorf("failed to encode product: %w", err)
+	}
+
+	var patched []byte
+	switch {
+	case jsonPatch != nil:
+		patch, err := jsonpatch.DecodePatch(jsonPatch)
+		if err != nil {
+			return invalidField("json_patch", err.Error())
+		}
+		patched, err = patch.Apply(doc)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		if err != nil {
+			return invalidField("json_patch", err.Error())
+		}
+	case mergePatch != nil:
+		patched, err = jsonpatch.MergePatch(doc, mergePatch)
+		if err != nil {
+			return invalidField("merge_patch", err.Error())
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	var result productDocument
+	if err := decoder.Decode(&result); err != nil {
+		return invalidField("patch", fmt.Sprintf("patched product is invalid: %v", err))
+	}
+
+	var fields []FieldError
+	if result.Code == nil {
+		fields = append(fields, FieldError{Field: "code", Message: "must not be removed"})
+	}
+	if result.Price == nil {
+		fields = append(fields, FieldError{Field: "price", Message: "must not be removed"})
+	}
+	if result.Currency == nil {
+		fields = append(fields, FieldError{Field: "currency", Message: "must not be removed"})
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+
+	p.Code = *result.Code
+	p.Price = *result.Price
+	p.Currency = strings.ToUpper(*result.Currency)
+	// The descriptive fields are optional; removing one clears it
+	p.Name = stringOrEmpty(result.Name)
+	p.Description = stringOrEmpty(result.Description)
+	p.Category = categoryRef(stringOrEmpty(result.Category))
+	return nil
+}
+
+// stringOrEmpty returns *s, or an empty string if s is nil
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// PatchProduct atomically applies a JSON Patch or merge patch document to the product with the given id
+func (dbs *DBService) PatchProduct(ctx context.Context, id uint, jsonPatch, mergePatch []byte) (*Product, error) {
+	return dbs.UpdateProduct(ctx, id, func(p *Product) error {
+		return applyProductPatch(p, jsonPatch, mergePatch)
+	})
+}
+
+// patchProductHandler handles the patch_product tool request
+func (app *App) patchProductHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+
+	args := request.GetArguments()
+	rawJSONPatch, hasJSONPatch := args["json_patch"]
+	rawMergePatch, hasMergePatch := args["merge_patch"]
+	if hasJSONPatch == hasMergePatch {
+		return newToolError(CodeInvalidArgument, "provide exactly one of json_patch or merge_patch"), nil
+	}
+
+	fields, err := requestFields(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	var jsonPatch, mergePatch []byte
+	if hasJSONPatch {
+		jsonPatch, err = json.Marshal(rawJSONPatch)
+	} else {
+		mergePatch, err = json.Marshal(rawMergePatch)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode patch document: %w", err)
+	}
+
+	product, err := app.dbService.PatchProduct(ctx, uint(id), jsonPatch, mergePatch)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	return productResult(product, fields)
+}
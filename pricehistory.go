@@ -0,0 +1,246 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// priceHistoryURISuffix ends the URI of the price history resource of a product,
+// products://{id}/price-history
+const priceHistoryURISuffix = "/price-history"
+
+// PriceChange records a change of the price of a product. The first price of a product is
+// recorded as a change without a previous price.
+type PriceChange struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ProductID     uint      `gorm:"index" json:"product_id"`
+	Price         float64   `json:"price"`
+	PreviousPrice *float64  `json:"previous_price,omitempty"`
+	ChangedAt     time.Time `gorm:"index" json:"changed_at"`
+}
+
+// recordPriceChange records the price of p if it differs from the last recorded price.
+// Like the product history, it is kept by GORM hooks; raw SQL writes bypass it.
+func recordPriceChange(tx *gorm.DB, p Product) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+
+	var last []PriceChange
+	if err := db.Where("product_id = ?", p.ID).Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+		return fmt.Errorf("failed to look up price history: %w", err)
+	}
+	change := PriceChange{ProductID: p.ID, Price: p.Price, ChangedAt: p.UpdatedAt.UTC()}
+	if len(last) > 0 {
+		if last[0].Price == p.Price {
+			return nil
+		}
+		change.PreviousPrice = &last[0].Price
+	}
+	if err := db.Create(&change).Error; err != nil {
+		return fmt.Errorf("failed to record price change: %w", err)
+	}
+	return nil
+}
+
+// PriceTrend summarizes the price changes of a period
+type PriceTrend struct {
+	// Start is the price before the first change of the period, or its first price
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	// Change is End minus Start; ChangePercent is relative to Start, unless Start is zero
+	Change        float64  `json:"change"`
+	ChangePercent *float64 `json:"change_percent,omitempty"`
+	// Direction is up, down or flat
+	Direction string `json:"direction"`
+}
+
+// PriceHistory is the result of the price_history tool and resource
+type PriceHistory struct {
+	ProductID uint          `json:"product_id"`
+	Code      string        `json:"code"`
+	Price     float64       `json:"price"`
+	Currency  string        `json:"currency"`
+	Changes   []PriceChange `json:"changes"`
+	// Trend is omitted if the period holds no change
+	Trend *PriceTrend `json:"trend,omitempty"`
+}
+
+// priceTrend summarizes changes, which are in chronological order
+func priceTrend(changes []PriceChange) *PriceTrend {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	first := changes[0]
+	trend := &PriceTrend{Start: first.Price, End: changes[len(changes)-1].Price}
+	if first.PreviousPrice != nil {
+		trend.Start = *first.PreviousPrice
+	}
+	trend.Min, trend.Max = trend.Start, trend.Start
+	for _, c := range changes {
+		trend.Min = min(trend.Min, c.Price)
+		trend.Max = max(trend.Max, c.Price)
+	}
+
+	trend.Change = trend.End - trend.Start
+	if trend.Start != 0 {
+		percent := trend.Change / trend.Start * 100
+		trend.ChangePercent = &percent
+	}
+	switch {
+	case trend.Change > 0:
+		trend.Direction = "up"
+	case trend.Change < 0:
+		trend.Direction = "down"
+	default:
+		trend.Direction = "flat"
+	}
+	return trend
+}
+
+// PriceChanges returns the price changes of the product with the given id made between since
+// and until, either of which may be zero for an open end, in chronological order
+func (dbs *DBService) PriceChanges(ctx context.Context, id uint, since, until time.Time) ([]PriceChange, error) {
+	var changes []PriceChange
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		db := dbs.conn(ctx).Where("product_id = ?", id)
+		if !since.IsZero() {
+			db = db.Where("changed_at >= ?", since.UTC())
+		}
+		if !until.IsZero() {
+			db = db.Where("changed_at <= ?", until.UTC())
+		}
+		return db.Order("changed_at, id").Find(&changes).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve price history: %w", err)
+	}
+	return changes, nil
+}
+
+// priceHistory returns the price history of the product with the given id between since and
+// until, charging its changes to the quota
+func (app *App) priceHistory(ctx context.Context, id uint, since, until time.Time) (*PriceHistory, error) {
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		return nil, invalidField("until", "must not be before since")
+	}
+
+	product, err := app.dbService.GetProduct(ctx, id, "")
+	if err != nil {
+		return nil, err
+	}
+	changes, err := app.dbService.PriceChanges(ctx, id, since, until)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.quotas.AddRows(ctx, len(changes)); err != nil {
+		return nil, err
+	}
+
+	return &PriceHistory{
+		ProductID: product.ID,
+		Code:      product.Code,
+		Price:     product.Price,
+		Currency:  product.Currency,
+		Changes:   changes,
+		Trend:     priceTrend(changes),
+	}, nil
+}
+
+// parseTimeRange parses the optional since and until timestamps of a price history query
+func parseTimeRange(since, until string) (time.Time, time.Time, error) {
+	var fields []FieldError
+	var from, to time.Time
+	var err error
+	if since != "" {
+		if from, err = time.Parse(time.RFC3339, since); err != nil {
+			fields = append(fields, FieldError{Field: "since", Message: asOfFormatMessage})
+		}
+	}
+	if until != "" {
+		if to, err = time.Parse(time.RFC3339, until); err != nil {
+			fields = append(fields, FieldError{Field: "until", Message: asOfFormatMessage})
+		}
+	}
+	if len(fields) > 0 {
+		return time.Time{}, time.Time{}, &ValidationError{Fields: fields}
+	}
+	return from, to, nil
+}
+
+// priceHistoryHandler handles the price_history tool request
+func (app *App) priceHistoryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+
+	since, until, err := parseTimeRange(request.GetString("since", ""), request.GetString("until", ""))
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	history, err := app.priceHistory(ctx, uint(id), since, until)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal price history to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// priceHistoryResourceHandler handles the price history resource template request
+func (app *App) priceHistoryResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri, err := url.Parse(request.Params.URI)
+	if err != nil {
+		return nil, resourceError(invalidField("uri", err.Error()))
+	}
+	id, err := strconv.ParseUint(strings.TrimSuffix(uri.Host+uri.Path, priceHistoryURISuffix), 10, 0)
+	if err != nil || id == 0 || !strings.HasSuffix(uri.Path, priceHistoryURISuffix) {
+		return nil, resourceError(invalidField("uri", "expected products://{id}/price-history with a positive product id"))
+	}
+
+	values := uri.Query()
+	for name := range values {
+		if name != "since" && name != "until" && name != outputFormatArg && name != tenantArg {
+			return nil, resourceError(invalidField(name, "unknown parameter"))
+		}
+	}
+	since, until, err := parseTimeRange(values.Get("since"), values.Get("until"))
+	if err != nil {
+		return nil, resourceError(err)
+	}
+
+	history, err := app.priceHistory(ctx, uint(id), since, until)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+
+	jsonData, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal price history to JSON: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
@@ -0,0 +1,424 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// webhookTimeout bounds a single price alert webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// errInternalAddress is returned for webhooks to addresses only reachable from the server
+var errInternalAddress = errors.New("webhooks cannot be sent to loopback, private or link-local addresses")
+
+// sharedAddressSpace is the range of carrier-grade NAT, as internal as the private ranges
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// internalAddress reports whether addr is a loopback, private, link-local, multicast or
+// unspecified address
+func internalAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr)
+}
+
+// refuseInternalAddress is a net.Dialer control function refusing connections to internal
+// addresses; it sees the address the host name resolved to
+func refuseInternalAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if internalAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", errInternalAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// newWebhookClient creates the client posting price alerts. Webhook URLs are chosen by
+// clients, so that connections to the services next to the server, such as the admin
+// dashboard or cloud metadata, are refused; redirects are not followed and no proxy is used,
+// either of which would bypass the check.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: refuseInternalAddress}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Conditions a price watch can wait for
+const (
+	priceAbove = "above"
+	priceBelow = "below"
+)
+
+// PriceWatch is a client subscription to the price of a product
+type PriceWatch struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id,omitempty"`
+	ProductID uint      `json:"product_id"`
+	Condition string    `json:"condition"`
+	Threshold float64   `json:"threshold"`
+	Webhook   string    `json:"webhook_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Triggered is set while the condition holds; the watch fires again once
+	// the price has moved back across the threshold and triggers anew
+	Triggered       bool       `json:"triggered"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
+}
+
+// matches reports whether price satisfies the condition of the watch
+func (w *PriceWatch) matches(price float64) bool {
+	if w.Condition == priceAbove {
+		return price > w.Threshold
+	}
+	return price < w.Threshold
+}
+
+// PriceAlert is sent to the client, and to the webhook if any, when a watch triggers
+type PriceAlert struct {
+	WatchID     string    `json:"watch_id"`
+	ProductID   uint      `json:"product_id"`
+	Code        string    `json:"code"`
+	Price       float64   `json:"price"`
+	Condition   string    `json:"condition"`
+	Threshold   float64   `json:"threshold"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// PriceWatcher holds the active price watches and periodically checks them
+type PriceWatcher struct {
+	app      *App
+	interval time.Duration
+	client   *http.Client
+
+	mu      sync.Mutex
+	nextID  int
+	watches map[string]*PriceWatch
+}
+
+// NewPriceWatcher creates a watcher checking prices at the configured interval
+func NewPriceWatcher(app *App) *PriceWatcher {
+	return &PriceWatcher{
+		app:      app,
+		interval: app.config.PriceWatchInterval,
+		client:   newWebhookClient(),
+		watches:  make(map[string]*PriceWatch),
+	}
+}
+
+// Add registers a watch and returns it with its id assigned
+func (pw *PriceWatcher) Add(w PriceWatch) PriceWatch {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	pw.nextID++
+	w.ID = "watch-" + strconv.Itoa(pw.nextID)
+	w.CreatedAt = time.Now().UTC()
+	pw.watches[w.ID] = &w
+	return w
+}
+
+// Remove deletes a watch owned by sessionID and reports whether it existed
+func (pw *PriceWatcher) Remove(sessionID, id string) bool {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	w, ok := pw.watches[id]
+	if !ok || w.SessionID != sessionID {
+		return false
+	}
+	delete(pw.watches, id)
+	return true
+}
+
+// List returns the watches owned by sessionID ordered by creation
+func (pw *PriceWatcher) List(sessionID string) []PriceWatch {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	watches := []PriceWatch{}
+	for _, w := range pw.watches {
+		if w.SessionID == sessionID {
+			watches = append(watches, *w)
+		}
+	}
+	sort.Slice(watches, func(i, j int) bool { return watches[i].CreatedAt.Before(watches[j].CreatedAt) })
+	return watches
+}
+
+// onUnregister drops the watches of a disconnected client; it is registered as an OnUnregisterSession hook
+func (pw *PriceWatcher) onUnregister(ctx context.Context, session server.ClientSession) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	for id, w := range pw.watches {
+		if w.SessionID == session.SessionID() {
+			delete(pw.watches, id)
+		}
+	}
+}
+
+// Start checks the watches every interval until ctx is cancelled
+func (pw *PriceWatcher) Start(ctx context.Context) {
+	if pw.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(pw.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pw.check(ctx)
+			}
+		}
+	}()
+}
+
+// check compares every watch with the current price of its product and delivers
+// an alert for each watch whose condition became true
+func (pw *PriceWatcher) check(ctx context.Context) {
+	pw.mu.Lock()
+	ids := make([]uint, 0, len(pw.watches))
+	for _, w := range pw.watches {
+		ids = append(ids, w.ProductID)
+	}
+	pw.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+
+	products, err := pw.app.dbService.ProductsByID(ctx, ids)
+	if err != nil {
+		slog.Warn("Failed to check price watches", "error", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	var triggered []PriceWatch
+	pw.mu.Lock()
+	for _, w := range pw.watches {
+		product, ok := products[w.ProductID]
+		if !ok {
+			continue
+		}
+		if !w.matches(product.Price) {
+			w.Triggered = false
+			continue
+		}
+		if w.Triggered {
+			continue
+		}
+		w.Triggered = true
+		w.LastTriggeredAt = &now
+		triggered = append(triggered, *w)
+	}
+	pw.mu.Unlock()
+
+	for _, w := range triggered {
+		product := products[w.ProductID]
+		pw.deliver(ctx, w, PriceAlert{
+			WatchID:     w.ID,
+			ProductID:   product.ID,
+			Code:        product.Code,
+			Price:       product.Price,
+			Condition:   w.Condition,
+			Threshold:   w.Threshold,
+			TriggeredAt: now,
+		})
+	}
+}
+
+// deliver sends an alert to the client that created the watch and to its webhook
+func (pw *PriceWatcher) deliver(ctx context.Context, w PriceWatch, alert PriceAlert) {
+	slog.Info("Price watch triggered", "watch", w.ID, "product", alert.ProductID, "price", alert.Price)
+
+	if w.SessionID != "" && pw.app.server != nil {
+		err := pw.app.server.SendNotificationToSpecificClient(w.SessionID, "notifications/message", map[string]any{
+			"level":  mcp.LoggingLevelNotice,
+			"logger": "price_watch",
+			"data":   alert,
+		})
+		if err != nil {
+			slog.Warn("Failed to notify client of price alert", "watch", w.ID, "session", w.SessionID, "error", err)
+		}
+	}
+
+	if w.Webhook != "" {
+		if err := pw.postWebhook(ctx, w.Webhook, alert); err != nil {
+			slog.Warn("Failed to deliver price alert webhook", "watch", w.ID, "error", err)
+		}
+	}
+}
+
+// postWebhook posts an alert as JSON to url
+func (pw *PriceWatcher) postWebhook(ctx context.Context, url string, alert PriceAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal price alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := pw.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %s", resp.Status)
+	}
+	return nil
+}
+
+// ProductsByID returns the products with the given ids keyed by id; missing ids are skipped
+func (dbs *DBService) ProductsByID(ctx context.Context, ids []uint) (map[uint]Product, error) {
+	var products []Product
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.conn(ctx).Find(&products, ids).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve products: %w", err)
+	}
+
+	byID := make(map[uint]Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+	return byID, nil
+}
+
+// validateWebhookURL checks that a webhook URL is an absolute http(s) URL whose host is not
+// obviously internal; host names resolving to internal addresses are refused on delivery
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalidField("webhook_url", "must be an absolute http or https URL")
+	}
+	host := strings.ToLower(u.Hostname())
+	addr, err := netip.ParseAddr(host)
+	if (err == nil && internalAddress(addr)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return invalidField("webhook_url", "must not point to a loopback, private or link-local address")
+	}
+	return nil
+}
+
+// watchPriceHandler handles the watch_price tool request
+func (app *App) watchPriceHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("product_id")
+	if err != nil {
+		return argumentError("product_id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("product_id", "must be a positive integer"))
+	}
+
+	condition, err := request.RequireString("condition")
+	if err != nil {
+		return argumentError("condition", err), nil
+	}
+	if condition != priceAbove && condition != priceBelow {
+		return toolErrorResult(invalidField("condition", fmt.Sprintf("must be %q or %q", priceAbove, priceBelow)))
+	}
+
+	threshold, err := request.RequireFloat("threshold")
+	if err != nil {
+		return argumentError("threshold", err), nil
+	}
+
+	webhook := request.GetString("webhook_url", "")
+	if webhook != "" {
+		if err := validateWebhookURL(webhook); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+
+	session := sessionID(ctx)
+	if session == "" && webhook == "" {
+		return newToolError(CodeFailedPrecondition, "this connection has no session to notify; provide a webhook_url"), nil
+	}
+
+	products, err := app.dbService.ProductsByID(ctx, []uint{uint(id)})
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	if _, ok := products[uint(id)]; !ok {
+		return toolErrorResult(fmt.Errorf("%w: id %d", ErrProductNotFound, id))
+	}
+
+	watch := app.priceWatcher.Add(PriceWatch{
+		SessionID: session,
+		ProductID: uint(id),
+		Condition: condition,
+		Threshold: threshold,
+		Webhook:   webhook,
+	})
+
+	jsonData, err := json.MarshalIndent(watch, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal price watch to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// unwatchPriceHandler handles the unwatch_price tool request; removing an unknown watch is not an error
+func (app *App) unwatchPriceHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireString("watch_id")
+	if err != nil {
+		return argumentError("watch_id", err), nil
+	}
+
+	removed := app.priceWatcher.Remove(sessionID(ctx), id)
+	jsonData, err := json.Marshal(map[string]any{"watch_id": id, "removed": removed})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// priceWatchesHandler handles the active price watches resource request
+func (app *App) priceWatchesHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	jsonData, err := json.MarshalIndent(app.priceWatcher.List(sessionID(ctx)), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal price watches to JSON: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      "watches://active",
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
@@ -0,0 +1,946 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrProductNotFound is returned when a product lookup matches no row
+var ErrProductNotFound = fmt.Errorf("product %w", ErrNotFound)
+
+// validateProduct checks the fields of a product before it is written
+func validateProduct(p *Product) error {
+	var fields []FieldError
+	if strings.TrimSpace(p.Code) == "" {
+		fields = append(fields, FieldError{Field: "code", Message: "must not be empty"})
+	}
+	if p.Price < 0 {
+		fields = append(fields, FieldError{Field: "price", Message: "must not be negative"})
+	}
+	if !validCurrencyCode(p.Currency) {
+		fields = append(fields, FieldError{Field: "currency", Message: currencyFormatMessage})
+	}
+	if p.Stock < 0 {
+		fields = append(fields, FieldError{Field: "stock", Message: "must not be negative"})
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// maxProductListLimit caps the number of products a single list read may return
+const maxProductListLimit = 1000
+
+// productField describes a product field that can be sorted on or selected in a list query
+type productField struct {
+	Column  string
+	JSONKey string
+	Value   func(p *Product) any
+}
+
+// productFields maps the field names accepted in list queries to their columns and values
+var productFields = map[string]productField{
+	"id":          {Column: "id", JSONKey: "ID", Value: func(p *Product) any { return p.ID }},
+	"code":        {Column: "code", JSONKey: "Code", Value: func(p *Product) any { return p.Code }},
+	"name":        {Column: "name", JSONKey: "Name", Value: func(p *Product) any { return p.Name }},
+	"description": {Column: "description", JSONKey: "Description", Value: func(p *Product) any { return p.Description }},
+	"category":    {Column: "category", JSONKey: "Category", Value: func(p *Product) any { return p.categoryValue() }},
+	"price":       {Column: "price", JSONKey: "Price", Value: func(p *Product) any { return p.Price }},
+	"currency":    {Column: "currency", JSONKey: "Currency", Value: func(p *Product) any { return p.Currency }},
+	"stock":       {Column: "stock", JSONKey: "Stock", Value: func(p *Product) any { return p.Stock }},
+	"supplier_id": {Column: "supplier_id", JSONKey: "SupplierID", Value: func(p *Product) any { return p.supplierValue() }},
+	"created_at":  {Column: "created_at", JSONKey: "CreatedAt", Value: func(p *Product) any { return p.CreatedAt }},
+	"updated_at":  {Column: "updated_at", JSONKey: "UpdatedAt", Value: func(p *Product) any { return p.UpdatedAt }},
+}
+
+// productFieldNames returns the names of the selectable product fields in sorted order
+func productFieldNames() []string {
+	names := make([]string, 0, len(productFields))
+	for name := range productFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateProductFields checks that every name is a selectable product field; arg names
+// the argument the list came from
+func validateProductFields(arg string, names []string) []FieldError {
+	var fields []FieldError
+	for _, name := range names {
+		if _, ok := productFields[name]; !ok {
+			fields = append(fields, FieldError{Field: arg, Message: fmt.Sprintf("unknown field %q", name)})
+		}
+	}
+	return fields
+}
+
+// withFields declares the optional fields argument restricting the product fields a tool returns
+func withFields() mcp.ToolOption {
+	return mcp.WithArray("fields",
+		mcp.Description("Product fields to return; all fields are returned if omitted"),
+		mcp.WithStringEnumItems(productFieldNames()),
+	)
+}
+
+// requestFields returns the fields argument of a tool request after validating it
+func requestFields(request mcp.CallToolRequest) ([]string, error) {
+	names := request.GetStringSlice("fields", nil)
+	if fields := validateProductFields("fields", names); len(fields) > 0 {
+		return nil, &ValidationError{Fields: fields}
+	}
+	return names, nil
+}
+
+// ProductQuery controls the order, page and fields of a product listing
+type ProductQuery struct {
+	Sort   string
+	Desc   bool
+	Limit  int
+	Offset int
+	Fields []string
+	// AsOf, if set, asks for the products as they were at that time
+	AsOf time.Time
+	// Category, if set, restricts the listing to the products of that category
+	Category string
+	// Tag, if set, restricts the listing to the products currently carrying that tag
+	Tag string
+	// Filter, if set, restricts the listing to the products matching it
+	Filter *ProductFilter
+}
+
+// filter applies the conditions of the query to db
+func (q ProductQuery) filter(db *gorm.DB) *gorm.DB {
+	if q.Category != "" {
+		db = db.Where("category = ?", q.Category)
+	}
+	if q.Tag != "" {
+		db = db.Where("id IN (?)", taggedProducts(db, q.Tag))
+	}
+	if q.Filter != nil {
+		db = q.Filter.Apply(db)
+	}
+	return db
+}
+
+// page applies the order, limit and offset of the query to db. Products are ordered by
+// id after the sort field so that pages are stable.
+func (q ProductQuery) page(db *gorm.DB) *gorm.DB {
+	if q.Sort != "" {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: productFields[q.Sort].Column}, Desc: q.Desc})
+	}
+	db = db.Order("id")
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+	if q.Offset > 0 {
+		db = db.Offset(q.Offset)
+	}
+	return db
+}
+
+// sortDirections maps the direction suffixes accepted in sort orders to descending
+var sortDirections = map[string]bool{"asc": false, "desc": true}
+
+// parseProductSort parses a sort order: a product field, either prefixed with "-" or
+// suffixed with ":desc" for descending order, or suffixed with ":asc". Only known fields
+// are accepted, so the order never reaches SQL as written by the caller.
+func parseProductSort(value string) (field string, desc bool, err error) {
+	field, direction, hasDirection := strings.Cut(value, ":")
+	if hasDirection {
+		var ok bool
+		if desc, ok = sortDirections[direction]; !ok {
+			return "", false, invalidField("sort", fmt.Sprintf("unknown direction %q (expected asc or desc)", direction))
+		}
+	} else if strings.HasPrefix(field, "-") {
+		field, desc = field[1:], true
+	}
+	if _, ok := productFields[field]; !ok {
+		return "", false, invalidField("sort", fmt.Sprintf("unknown field %q (expected one of %s)", field, strings.Join(productFieldNames(), ", ")))
+	}
+	return field, desc, nil
+}
+
+// parseProductQuery parses and validates the sort, limit, cursor, fields, as_of, category and tag parameters of a list query.
+// sort is parsed by parseProductSort.
+func parseProductQuery(values url.Values) (ProductQuery, error) {
+	var q ProductQuery
+	var fields []FieldError
+
+	for name := range values {
+		if name != "sort" && name != "limit" && name != "cursor" && name != "fields" && name != "as_of" && name != "category" && name != "tag" && name != outputFormatArg && name != tenantArg {
+			fields = append(fields, FieldError{Field: name, Message: "unknown parameter"})
+		}
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		var err error
+		if q.Sort, q.Desc, err = parseProductSort(sort); err != nil {
+			fields = append(fields, err.(*ValidationError).Fields...)
+		}
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxProductListLimit {
+			fields = append(fields, FieldError{Field: "limit", Message: fmt.Sprintf("must be an integer between 1 and %d", maxProductListLimit)})
+		}
+		q.Limit = n
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		offset, err := decodeCursor("cursor", cursor)
+		if err != nil {
+			fields = append(fields, FieldError{Field: "cursor", Message: "is not a cursor returned by this server"})
+		}
+		q.Offset = offset
+	}
+
+	if list := values.Get("fields"); list != "" {
+		for _, name := range strings.Split(list, ",") {
+			q.Fields = append(q.Fields, strings.TrimSpace(name))
+		}
+		fields = append(fields, validateProductFields("fields", q.Fields)...)
+	}
+
+	if asOf := values.Get("as_of"); asOf != "" {
+		at, err := time.Parse(time.RFC3339, asOf)
+		if err != nil {
+			fields = append(fields, FieldError{Field: "as_of", Message: asOfFormatMessage})
+		}
+		q.AsOf = at
+	}
+
+	q.Category = values.Get("category")
+	q.Tag = strings.ToLower(strings.TrimSpace(values.Get("tag")))
+
+	if len(fields) > 0 {
+		return ProductQuery{}, &ValidationError{Fields: fields}
+	}
+	return q, nil
+}
+
+// projectProduct renders a product with only the requested fields, or in full if none were requested
+func projectProduct(product *Product, fields []string) any {
+	if len(fields) == 0 {
+		return product
+	}
+
+	row := make(map[string]any, len(fields))
+	for _, name := range fields {
+		field := productFields[name]
+		row[field.JSONKey] = field.Value(product)
+	}
+	return row
+}
+
+// projectProducts renders products with only the requested fields, or in full if none were requested
+func projectProducts(products []Product, fields []string) any {
+	if len(fields) == 0 {
+		return products
+	}
+
+	projected := make([]any, len(products))
+	for i := range products {
+		projected[i] = projectProduct(&products[i], fields)
+	}
+	return projected
+}
+
+// GetProduct returns the product with the given id or, if id is zero, the product with the
+// given code
+func (dbs *DBService) GetProduct(ctx context.Context, id uint, code string) (*Product, error) {
+	var products []Product
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		products = nil
+		if id != 0 {
+			return dbs.conn(ctx).Limit(1).Find(&products, id).Error
+		}
+		return dbs.conn(ctx).Where("code = ?", code).Limit(1).Find(&products).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve product: %w", err)
+	}
+
+	switch {
+	case len(products) == 0 && id != 0:
+		return nil, fmt.Errorf("%w: id %d", ErrProductNotFound, id)
+	case len(products) == 0:
+		return nil, fmt.Errorf("%w: code %q", ErrProductNotFound, code)
+	}
+	return &products[0], nil
+}
+
+// BeforeSave checks that the code of a product is free and that its category and supplier
+// exist, so that clients get an actionable error rather than a constraint violation
+func (p *Product) BeforeSave(tx *gorm.DB) error {
+	tx = tx.Session(&gorm.Session{NewDB: true})
+	if err := p.checkCode(tx); err != nil {
+		return err
+	}
+	if err := p.checkCategory(tx); err != nil {
+		return err
+	}
+	return p.checkSupplier(tx)
+}
+
+// checkCode checks that no other product, soft-deleted ones included, has the code of p
+func (p *Product) checkCode(tx *gorm.DB) error {
+	var others []Product
+	err := tx.Unscoped().Where("code = ? AND id <> ?", p.Code, p.ID).Limit(1).Find(&others).Error
+	if err != nil {
+		return fmt.Errorf("failed to look up product code: %w", err)
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	if other := others[0]; other.DeletedAt.Valid {
+		return fmt.Errorf("%w: product code %s already exists on soft-deleted product %d; restore it with restore_product or choose another code", ErrConflict, p.Code, other.ID)
+	}
+	return fmt.Errorf("%w: product code %s already exists on product %d; update that product or choose another code", ErrConflict, p.Code, others[0].ID)
+}
+
+// CreateProduct inserts a new product
+func (dbs *DBService) CreateProduct(ctx context.Context, product *Product) error {
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Create(product).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create product: %w", err)
+	}
+	return nil
+}
+
+// UpdateProduct applies changes to the product with the given id and returns the updated row
+func (dbs *DBService) UpdateProduct(ctx context.Context, id uint, changes func(p *Product) error) (*Product, error) {
+	var product Product
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		product = Product{}
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			return updateProductTx(tx, &product, id, changes)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// updateProductTx loads the product with the given id into product, applies changes and saves
+// it. The row stays locked until tx ends, so that concurrent updates are applied one after
+// the other rather than overwriting each other.
+func updateProductTx(tx *gorm.DB, product *Product, id uint, changes func(p *Product) error) error {
+	result := forUpdate(tx).Limit(1).Find(product, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to retrieve product: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: id %d", ErrProductNotFound, id)
+	}
+
+	if err := changes(product); err != nil {
+		return err
+	}
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+	if err := tx.Save(product).Error; err != nil {
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+	return nil
+}
+
+// DeleteProduct deletes the product with the given id and returns it as it was. By default the
+// product is soft-deleted and its history records the deletion; a hard delete removes the
+// product, its history and its price history permanently, and also applies to soft-deleted products.
+func (dbs *DBService) DeleteProduct(ctx context.Context, id uint, hard bool) (*Product, error) {
+	var product Product
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		product = Product{}
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			if hard {
+				tx = tx.Unscoped().Session(&gorm.Session{})
+			}
+			result := tx.Limit(1).Find(&product, id)
+			if result.Error != nil {
+				return fmt.Errorf("failed to retrieve product: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: id %d", ErrProductNotFound, id)
+			}
+
+			if err := tx.Delete(&product).Error; err != nil {
+				return fmt.Errorf("failed to delete product: %w", err)
+			}
+			if err := recordProductDeletions(tx, hard, product); err != nil {
+				return err
+			}
+			if hard {
+				if err := tx.Where("product_id = ?", id).Delete(&ProductVersion{}).Error; err != nil {
+					return fmt.Errorf("failed to delete product history: %w", err)
+				}
+				if err := tx.Where("product_id = ?", id).Delete(&PriceChange{}).Error; err != nil {
+					return fmt.Errorf("failed to delete price history: %w", err)
+				}
+				if err := tx.Where("product_id = ?", id).Delete(&ProductImage{}).Error; err != nil {
+					return fmt.Errorf("failed to delete product image: %w", err)
+				}
+				if err := tx.Where("product_id = ?", id).Delete(&ProductTag{}).Error; err != nil {
+					return fmt.Errorf("failed to delete product tags: %w", err)
+				}
+				if err := tx.Where("product_id = ?", id).Delete(&ProductVariant{}).Error; err != nil {
+					return fmt.Errorf("failed to delete product variants: %w", err)
+				}
+				return nil
+			}
+			return recordProductVersions(tx, true, time.Now(), product)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// PreviewHardDeleteProduct returns the product with the given id, soft-deleted or not, that a
+// hard delete would remove
+func (dbs *DBService) PreviewHardDeleteProduct(ctx context.Context, id uint) (*Product, error) {
+	var products []Product
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		products = nil
+		return dbs.primary(ctx).Unscoped().Limit(1).Find(&products, id).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve product: %w", err)
+	}
+	if len(products) == 0 {
+		return nil, fmt.Errorf("%w: id %d", ErrProductNotFound, id)
+	}
+	return &products[0], nil
+}
+
+// GetDeletedProducts returns a page of the soft-deleted products, most recently deleted first,
+// and the number of soft-deleted products
+func (dbs *DBService) GetDeletedProducts(ctx context.Context, limit, offset int) ([]Product, int, error) {
+	var products []Product
+	var total int64
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		db := dbs.conn(ctx).Unscoped().Model(&Product{}).Where("deleted_at IS NOT NULL")
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return err
+		}
+		db = db.Order("deleted_at DESC").Order("id").Offset(offset)
+		if limit > 0 {
+			db = db.Limit(limit)
+		}
+		return db.Find(&products).Error
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve deleted products: %w", err)
+	}
+	return products, int(total), nil
+}
+
+// RestoreProduct undeletes the soft-deleted product with the given id and returns it. The
+// history records the restored product as a new version.
+func (dbs *DBService) RestoreProduct(ctx context.Context, id uint) (*Product, error) {
+	var product Product
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		product = Product{}
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			tx = tx.Unscoped().Session(&gorm.Session{})
+			result := tx.Limit(1).Find(&product, id)
+			if result.Error != nil {
+				return fmt.Errorf("failed to retrieve product: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: id %d", ErrProductNotFound, id)
+			}
+			if !product.DeletedAt.Valid {
+				return fmt.Errorf("%w: product %d is not deleted", ErrFailedPrecondition, id)
+			}
+
+			product.DeletedAt = gorm.DeletedAt{}
+			if err := tx.Save(&product).Error; err != nil {
+				return fmt.Errorf("failed to restore product: %w", err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// productResult renders a product, restricted to fields if any, as the JSON text result of a tool
+func productResult(product *Product, fields []string) (*mcp.CallToolResult, error) {
+	jsonData, err := json.MarshalIndent(projectProduct(product, fields), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal product to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// getProductHandler handles the get_product tool request
+func (app *App) getProductHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	_, hasID := args["id"]
+	code := request.GetString("code", "")
+	if hasID == (code != "") {
+		return newToolError(CodeInvalidArgument, "provide either id or code"), nil
+	}
+
+	var id int
+	if hasID {
+		var err error
+		if id, err = request.RequireInt("id"); err != nil {
+			return argumentError("id", err), nil
+		}
+		if id <= 0 {
+			return toolErrorResult(invalidField("id", "must be a positive integer"))
+		}
+	}
+
+	fields, err := requestFields(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	product, err := app.dbService.GetProduct(ctx, uint(id), code)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	setStructuredContent(ctx, projectProduct(product, fields))
+	result, err := productResult(product, fields)
+	if err != nil || !request.GetBool("include_image", false) {
+		return result, err
+	}
+
+	image, err := app.dbService.GetProductImage(ctx, product.ID)
+	if errors.Is(err, ErrProductImageNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	result.Content = append(result.Content, mcp.NewImageContent(base64.StdEncoding.EncodeToString(image.Data), image.MIMEType))
+	return result, nil
+}
+
+// productCodeURIHost is the host of the URIs of products read by code, products://code/{code}
+const productCodeURIHost = "code"
+
+// productResourceHandler handles the products://{id} and products://code/{code} resource
+// template requests, returning the single product as JSON. The fields parameter is a
+// comma-separated list restricting the product to those fields.
+func (app *App) productResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri, err := url.Parse(request.Params.URI)
+	if err != nil {
+		return nil, resourceError(invalidField("uri", err.Error()))
+	}
+
+	var id uint64
+	var code string
+	if uri.Host == productCodeURIHost {
+		if code = strings.TrimPrefix(uri.Path, "/"); code == "" {
+			return nil, resourceError(invalidField("uri", "expected products://code/{code} with a product code, percent-encoded"))
+		}
+	} else if id, err = strconv.ParseUint(uri.Host, 10, 0); err != nil || id == 0 || uri.Path != "" {
+		return nil, resourceError(invalidField("uri", "expected products://{id} with a positive product id"))
+	}
+
+	values := uri.Query()
+	var fields []FieldError
+	for name := range values {
+		if name != "fields" && name != outputFormatArg && name != tenantArg {
+			fields = append(fields, FieldError{Field: name, Message: "unknown parameter"})
+		}
+	}
+	var names []string
+	if list := values.Get("fields"); list != "" {
+		for _, name := range strings.Split(list, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+		fields = append(fields, validateProductFields("fields", names)...)
+	}
+	if len(fields) > 0 {
+		return nil, resourceError(&ValidationError{Fields: fields})
+	}
+
+	product, err := app.dbService.GetProduct(ctx, uint(id), code)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+
+	jsonData, err := json.MarshalIndent(projectProduct(product, names), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal product to JSON: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
+
+// productsResourceHandler serves every products:// resource template. The templates overlap,
+// products://list?sort=price also matching products://{id} for instance, and the server picks
+// any template matching a URI, so requests are routed here by the form of the URI.
+func (app *App) productsResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri, err := url.Parse(request.Params.URI)
+	if err != nil {
+		return nil, resourceError(invalidField("uri", err.Error()))
+	}
+	switch {
+	case uri.Host == "list" && uri.Path == "":
+		return app.listProductsHandler(ctx, request)
+	case uri.Host == productCodeURIHost:
+		return app.productResourceHandler(ctx, request)
+	case uri.Path == productImageURISuffix:
+		return app.productImageResourceHandler(ctx, request)
+	case uri.Path == priceHistoryURISuffix:
+		return app.priceHistoryResourceHandler(ctx, request)
+	default:
+		return app.productResourceHandler(ctx, request)
+	}
+}
+
+// createProductHandler handles the create_product tool request
+func (app *App) createProductHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	code, err := request.RequireString("code")
+	if err != nil {
+		return argumentError("code", err), nil
+	}
+
+	price, err := request.RequireFloat("price")
+	if _, given := request.GetArguments()["price"]; !given && app.clientCapabilities.supportsElicitation(ctx) {
+		if price, err = app.elicitPrice(ctx, code); err != nil {
+			return toolErrorResult(err)
+		}
+	} else if err != nil {
+		return argumentError("price", err), nil
+	}
+
+	fields, err := requestFields(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	product := &Product{
+		Code:        code,
+		Name:        request.GetString("name", ""),
+		Description: request.GetString("description", ""),
+		Category:    categoryRef(request.GetString("category", "")),
+		Price:       price,
+		Currency:    strings.ToUpper(request.GetString("currency", app.config.Currency)),
+		Stock:       request.GetInt("stock", 0),
+		SupplierID:  supplierRef(request.GetInt("supplier_id", 0)),
+	}
+	if err := app.dbService.CreateProduct(ctx, product); err != nil {
+		return toolErrorResult(err)
+	}
+
+	return productResult(product, fields)
+}
+
+// updateProductHandler handles the update_product tool request
+func (app *App) updateProductHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+
+	args := request.GetArguments()
+	_, hasCode := args["code"]
+	_, hasPrice := args["price"]
+	_, hasName := args["name"]
+	_, hasDescription := args["description"]
+	_, hasCategory := args["category"]
+	_, hasCurrency := args["currency"]
+	_, hasSupplier := args["supplier_id"]
+	if !hasCode && !hasPrice && !hasName && !hasDescription && !hasCategory && !hasCurrency && !hasSupplier {
+		return newToolError(CodeInvalidArgument, "nothing to update: provide code, name, description, category, price, currency and/or supplier_id"), nil
+	}
+
+	fields, err := requestFields(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	code := request.GetString("code", "")
+	price := request.GetFloat("price", 0)
+	product, err := app.dbService.UpdateProduct(ctx, uint(id), func(p *Product) error {
+		if hasCode {
+			p.Code = code
+		}
+		if hasPrice {
+			p.Price = price
+		}
+		if hasName {
+			p.Name = request.GetString("name", "")
+		}
+		if hasDescription {
+			p.Description = request.GetString("description", "")
+		}
+		if hasCategory {
+			p.Category = categoryRef(request.GetString("category", ""))
+		}
+		if hasCurrency {
+			p.Currency = strings.ToUpper(request.GetString("currency", ""))
+		}
+		if hasSupplier {
+			p.SupplierID = supplierRef(request.GetInt("supplier_id", 0))
+		}
+		return nil
+	})
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	return productResult(product, fields)
+}
+
+// DeleteProductResult is the result of the delete_product tool
+type DeleteProductResult struct {
+	Product any  `json:"product"`
+	Hard    bool `json:"hard"`
+	// Confirmed is false for the preview of a hard delete
+	Confirmed         bool       `json:"confirmed"`
+	ConfirmationToken string     `json:"confirmation_token,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	Message           string     `json:"message"`
+}
+
+// deleteProductHandler handles the delete_product tool request. A soft delete, which
+// restore_product undoes, applies at once; a hard delete without a confirmation token only
+// previews the product and issues a one-time token, and a second call with the same
+// arguments and that token deletes it.
+func (app *App) deleteProductHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+
+	fields, err := requestFields(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	hard := request.GetBool("hard", false)
+	var result DeleteProductResult
+	if token := request.GetString(confirmationTokenArg, ""); hard && token == "" {
+		product, err := app.dbService.PreviewHardDeleteProduct(ctx, uint(id))
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		token, expiresAt, err := app.confirmations.issue(ctx, request.Params.Name, request.GetArguments(), []uint{product.ID})
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		result = DeleteProductResult{
+			Product:           projectProduct(product, fields),
+			Hard:              true,
+			ConfirmationToken: token,
+			ExpiresAt:         &expiresAt,
+			Message:           "Preview only; call again with the same arguments and this confirmation_token to permanently delete this product and its history",
+		}
+	} else {
+		if hard {
+			if _, err := app.confirmations.redeem(ctx, request.Params.Name, request.GetArguments(), token); err != nil {
+				return toolErrorResult(err)
+			}
+		}
+		product, err := app.dbService.DeleteProduct(ctx, uint(id), hard)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		result = DeleteProductResult{
+			Product:   projectProduct(product, fields),
+			Hard:      hard,
+			Confirmed: true,
+			Message:   fmt.Sprintf("Soft-deleted product %d; it no longer appears in listings but remains in the history", id),
+		}
+		if hard {
+			result.Message = fmt.Sprintf("Permanently deleted product %d and its history", id)
+		}
+	}
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal delete result to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// listDeletedProductsHandler handles the list_deleted_products tool request. Products are
+// returned in full so that their deletion time is included.
+func (app *App) listDeletedProductsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	requested := request.GetInt("limit", 0)
+	if requested < 0 || requested > maxProductListLimit {
+		return toolErrorResult(invalidField("limit", fmt.Sprintf("must be between 1 and %d", maxProductListLimit)))
+	}
+	var offset int
+	if cursor := request.GetString("cursor", ""); cursor != "" {
+		var err error
+		if offset, err = decodeCursor("cursor", cursor); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+
+	limits := app.config.Results
+	products, total, err := app.dbService.GetDeletedProducts(ctx, limits.fetchLimit(requested), offset)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	data, n, truncation, err := limitResult(limits, products, offset, total, requested, true, renderJSON(func(page []Product) any { return page }))
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	if err := app.quotas.AddRows(ctx, n); err != nil {
+		return toolErrorResult(err)
+	}
+	return truncatedToolResult(string(data), truncation)
+}
+
+// restoreProductHandler handles the restore_product tool request
+func (app *App) restoreProductHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+
+	fields, err := requestFields(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	product, err := app.dbService.RestoreProduct(ctx, uint(id))
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	return productResult(product, fields)
+}
+
+// listProductsToolHandler handles the list_products tool request
+func (app *App) listProductsToolHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	fields, err := requestFields(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	query := ProductQuery{Fields: fields}
+
+	if sort := request.GetString("sort", ""); sort != "" {
+		if query.Sort, query.Desc, err = parseProductSort(sort); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+
+	query.Limit = request.GetInt("limit", 0)
+	if query.Limit < 0 || query.Limit > maxProductListLimit {
+		return toolErrorResult(invalidField("limit", fmt.Sprintf("must be between 1 and %d", maxProductListLimit)))
+	}
+
+	if asOf := request.GetString("as_of", ""); asOf != "" {
+		if query.AsOf, err = time.Parse(time.RFC3339, asOf); err != nil {
+			return toolErrorResult(invalidField("as_of", asOfFormatMessage))
+		}
+	}
+
+	query.Category = request.GetString("category", "")
+	query.Tag = strings.ToLower(strings.TrimSpace(request.GetString("tag", "")))
+
+	if cursor := request.GetString("cursor", ""); cursor != "" {
+		if query.Offset, err = decodeCursor("cursor", cursor); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+
+	format, err := app.outputFormat(ctx, request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	text, products, truncation, err := app.queryProducts(ctx, query, format)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	result, err := truncatedToolResult(text, truncation)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range products {
+		result.Content = append(result.Content, productLink(ctx, p.ID, p.Code, p.Name))
+	}
+	return result, nil
+}
+
+// productLink returns a link to the resource of a product, on the tenant of ctx, for clients
+// to read the product rather than query it again
+func productLink(ctx context.Context, id uint, code, name string) mcp.ResourceLink {
+	uri := fmt.Sprintf("products://%d", id)
+	if tenant := tenantFromContext(ctx); tenant != "" {
+		uri += "?" + tenantArg + "=" + url.QueryEscape(tenant)
+	}
+	return mcp.NewResourceLink(uri, code, name, "application/json")
+}
+
+// queryProducts runs a product listing within the configured result limits and returns
+// it rendered in format with the products returned and truncation metadata, charging the
+// returned rows to the quota
+func (app *App) queryProducts(ctx context.Context, query ProductQuery, format string) (string, []Product, *Truncation, error) {
+	limits := app.config.Results
+	requested := query.Limit
+	query.Limit = limits.fetchLimit(requested)
+
+	products, err := app.dbService.GetProducts(ctx, query)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	total, err := app.dbService.CountProducts(ctx, query)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	render := renderJSON(func(page []Product) any { return projectProducts(page, query.Fields) })
+	if format == formatMarkdown {
+		render = func(page []Product) ([]byte, error) { return []byte(productsMarkdown(page, query.Fields)), nil }
+	}
+	data, n, truncation, err := limitResult(limits, products, query.Offset, total, requested, true, render)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if err := app.quotas.AddRows(ctx, n); err != nil {
+		return "", nil, nil, err
+	}
+	return string(data), products[:n], truncation, nil
+}
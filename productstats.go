@@ -0,0 +1,110 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// PriceStats aggregates the prices of the products in one currency; prices in different
+// currencies are not comparable, so they are not aggregated together
+type PriceStats struct {
+	Currency string  `json:"currency"`
+	Products int64   `json:"products"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	Avg      float64 `json:"avg"`
+}
+
+// CategoryCount is the number of products in a category; a nil category counts the
+// products without one
+type CategoryCount struct {
+	Category *string `json:"category"`
+	Products int64   `json:"products"`
+}
+
+// ProductRef identifies a product in the catalog statistics
+type ProductRef struct {
+	ID        uint      `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProductStats is the result of the product_stats tool. Deleted products are not counted.
+type ProductStats struct {
+	Products   int64           `json:"products"`
+	Prices     []PriceStats    `json:"prices"`
+	Categories []CategoryCount `json:"categories"`
+	// Newest and Oldest are the most and least recently created products; they are omitted
+	// from an empty catalog
+	Newest *ProductRef `json:"newest,omitempty"`
+	Oldest *ProductRef `json:"oldest,omitempty"`
+}
+
+// ProductStats computes the catalog statistics in the database
+func (dbs *DBService) ProductStats(ctx context.Context) (*ProductStats, error) {
+	var stats *ProductStats
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		stats = &ProductStats{Prices: []PriceStats{}, Categories: []CategoryCount{}}
+		db := dbs.conn(ctx)
+		products := func() *gorm.DB { return db.Model(&Product{}) }
+
+		if err := products().Count(&stats.Products).Error; err != nil {
+			return err
+		}
+		err := products().
+			Select("currency, COUNT(*) AS products, MIN(price) AS min, MAX(price) AS max, AVG(price) AS avg").
+			Group("currency").
+			Order("currency").
+			Scan(&stats.Prices).Error
+		if err != nil {
+			return err
+		}
+		err = products().
+			Select("category, COUNT(*) AS products").
+			Group("category").
+			Order("category").
+			Scan(&stats.Categories).Error
+		if err != nil {
+			return err
+		}
+
+		for _, end := range []struct {
+			order string
+			dst   **ProductRef
+		}{{"created_at DESC, id DESC", &stats.Newest}, {"created_at, id", &stats.Oldest}} {
+			var refs []ProductRef
+			if err := products().Select("id, code, name, created_at").Order(end.order).Limit(1).Scan(&refs).Error; err != nil {
+				return err
+			}
+			if len(refs) > 0 {
+				*end.dst = &refs[0]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute product statistics: %w", err)
+	}
+	return stats, nil
+}
+
+// productStatsHandler handles the product_stats tool request
+func (app *App) productStatsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	stats, err := app.dbService.ProductStats(ctx)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal product statistics to JSON: %w", err)
+	}
+	setStructuredContent(ctx, stats)
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
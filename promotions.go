@@ -0,0 +1,321 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// Types of promotion discounts
+const (
+	promotionPercentage = "percentage"
+	promotionFixed      = "fixed"
+)
+
+// Promotion discounts the products of some categories, or of every category, during a
+// validity window
+type Promotion struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"uniqueIndex" json:"name"`
+	// Type is percentage, Value being the percent taken off the price, or fixed, Value being
+	// the amount taken off in Currency
+	Type     string  `json:"type"`
+	Value    float64 `json:"value"`
+	Currency string  `json:"currency,omitempty"`
+	// Categories lists the categories the promotion applies to; it applies to every product,
+	// those without a category included, if empty
+	Categories []string  `gorm:"serializer:json" json:"categories"`
+	ValidFrom  time.Time `gorm:"index" json:"valid_from"`
+	// ValidUntil, if set, is the end of the validity window, excluded
+	ValidUntil *time.Time `gorm:"index" json:"valid_until,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// activeAt reports whether the promotion is valid at the given time
+func (p *Promotion) activeAt(at time.Time) bool {
+	return !at.Before(p.ValidFrom) && (p.ValidUntil == nil || at.Before(*p.ValidUntil))
+}
+
+// appliesTo reports whether the promotion covers the category of product
+func (p *Promotion) appliesTo(product *Product) bool {
+	if len(p.Categories) == 0 {
+		return true
+	}
+	return product.Category != nil && slices.Contains(p.Categories, *product.Category)
+}
+
+// EffectivePrice is the result of the get_effective_price tool
+type EffectivePrice struct {
+	ProductID uint    `json:"product_id"`
+	Code      string  `json:"code"`
+	Currency  string  `json:"currency"`
+	Price     float64 `json:"price"`
+	// EffectivePrice is Price less Discount, rounded to two decimals and never negative
+	EffectivePrice float64   `json:"effective_price"`
+	Discount       float64   `json:"discount"`
+	At             time.Time `json:"at"`
+	// Promotion is the promotion applied, omitted if none is active for the product
+	Promotion *Promotion `json:"promotion,omitempty"`
+}
+
+// validatePromotion checks the fields of a promotion to be created
+func validatePromotion(p *Promotion) error {
+	var fields []FieldError
+	if strings.TrimSpace(p.Name) == "" {
+		fields = append(fields, FieldError{Field: "name", Message: "must not be empty"})
+	}
+	switch p.Type {
+	case promotionPercentage:
+		if p.Value <= 0 || p.Value > 100 {
+			fields = append(fields, FieldError{Field: "value", Message: "must be a percentage greater than 0 and at most 100"})
+		}
+	case promotionFixed:
+		if p.Value <= 0 {
+			fields = append(fields, FieldError{Field: "value", Message: "must be positive"})
+		}
+		if !validCurrencyCode(p.Currency) {
+			fields = append(fields, FieldError{Field: "currency", Message: currencyFormatMessage})
+		}
+	default:
+		fields = append(fields, FieldError{Field: "type", Message: "must be percentage or fixed"})
+	}
+	if p.ValidUntil != nil && !p.ValidUntil.After(p.ValidFrom) {
+		fields = append(fields, FieldError{Field: "valid_until", Message: "must be after valid_from"})
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// CreatePromotion inserts a new promotion; names must be unique and categories must exist
+func (dbs *DBService) CreatePromotion(ctx context.Context, promotion *Promotion) error {
+	if err := validatePromotion(promotion); err != nil {
+		return err
+	}
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&Promotion{}).Where("name = ?", promotion.Name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: promotion %q already exists", ErrConflict, promotion.Name)
+			}
+
+			var known []string
+			if err := tx.Model(&Category{}).Where("name IN ?", promotion.Categories).Pluck("name", &known).Error; err != nil {
+				return err
+			}
+			for _, name := range promotion.Categories {
+				if !slices.Contains(known, name) {
+					return invalidField("categories", fmt.Sprintf("unknown category %q; create it with create_category first", name))
+				}
+			}
+
+			if err := tx.Create(promotion).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, auditPromotion, promotion.ID, auditCreate, nil, promotion)
+		})
+	})
+	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			return err
+		}
+		return fmt.Errorf("failed to create promotion: %w", err)
+	}
+	return nil
+}
+
+// ListPromotions returns the promotions active at the given time, or every promotion if it
+// is zero, ordered by id
+func (dbs *DBService) ListPromotions(ctx context.Context, at time.Time) ([]Promotion, error) {
+	promotions := []Promotion{}
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		db := dbs.conn(ctx)
+		if !at.IsZero() {
+			at = at.UTC()
+			db = db.Where("valid_from <= ? AND (valid_until IS NULL OR valid_until > ?)", at, at)
+		}
+		return db.Order("id").Find(&promotions).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve promotions: %w", err)
+	}
+	return promotions, nil
+}
+
+// effectivePrice applies the best of promotions, those giving the lowest price, to the price
+// of product. Promotions do not stack. Fixed discounts in another currency than the product
+// are converted at the configured exchange rates.
+func (cfg *Config) effectivePrice(product *Product, promotions []Promotion, at time.Time) (*EffectivePrice, error) {
+	result := &EffectivePrice{
+		ProductID:      product.ID,
+		Code:           product.Code,
+		Currency:       product.Currency,
+		Price:          product.Price,
+		EffectivePrice: product.Price,
+		At:             at.UTC(),
+	}
+	for i := range promotions {
+		promotion := &promotions[i]
+		if !promotion.activeAt(at) || !promotion.appliesTo(product) {
+			continue
+		}
+		var discount float64
+		if promotion.Type == promotionPercentage {
+			discount = product.Price * promotion.Value / 100
+		} else {
+			conversion, err := cfg.convertPrice(promotion.Value, promotion.Currency, product.Currency)
+			if err != nil {
+				return nil, err
+			}
+			discount = conversion.Converted
+		}
+		price := math.Round(math.Max(product.Price-discount, 0)*100) / 100
+		if price < result.EffectivePrice {
+			result.EffectivePrice = price
+			result.Promotion = promotion
+		}
+	}
+	result.Discount = math.Round((result.Price-result.EffectivePrice)*100) / 100
+	return result, nil
+}
+
+// requestTime parses the optional RFC 3339 timestamp in the argument name of a tool request,
+// returning fallback if it is absent
+func requestTime(request mcp.CallToolRequest, name string, fallback time.Time) (time.Time, error) {
+	value := request.GetString(name, "")
+	if value == "" {
+		return fallback, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, invalidField(name, asOfFormatMessage)
+	}
+	return t.UTC(), nil
+}
+
+// createPromotionHandler handles the create_promotion tool request
+func (app *App) createPromotionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := request.RequireString("name")
+	if err != nil {
+		return argumentError("name", err), nil
+	}
+	kind, err := request.RequireString("type")
+	if err != nil {
+		return argumentError("type", err), nil
+	}
+	value, err := request.RequireFloat("value")
+	if err != nil {
+		return argumentError("value", err), nil
+	}
+
+	promotion := &Promotion{
+		Name:       strings.TrimSpace(name),
+		Type:       kind,
+		Value:      value,
+		Categories: request.GetStringSlice("categories", []string{}),
+	}
+	if kind == promotionFixed {
+		if promotion.Currency, err = requestCurrency(request, "currency", app.config.Currency); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+	if promotion.ValidFrom, err = requestTime(request, "valid_from", time.Now().UTC()); err != nil {
+		return toolErrorResult(err)
+	}
+	if until, err := requestTime(request, "valid_until", time.Time{}); err != nil {
+		return toolErrorResult(err)
+	} else if !until.IsZero() {
+		promotion.ValidUntil = &until
+	}
+
+	if err := app.dbService.CreatePromotion(ctx, promotion); err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(promotion, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal promotion to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// listPromotionsHandler handles the list_promotions tool request
+func (app *App) listPromotionsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var at time.Time
+	if request.GetBool("active", false) {
+		at = time.Now()
+	}
+	at, err := requestTime(request, "at", at)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	promotions, err := app.dbService.ListPromotions(ctx, at)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(promotions, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal promotions to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// getEffectivePriceHandler handles the get_effective_price tool request
+func (app *App) getEffectivePriceHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	_, hasID := args["id"]
+	code := request.GetString("code", "")
+	if hasID == (code != "") {
+		return newToolError(CodeInvalidArgument, "provide either id or code"), nil
+	}
+
+	var id int
+	if hasID {
+		var err error
+		if id, err = request.RequireInt("id"); err != nil {
+			return argumentError("id", err), nil
+		}
+		if id <= 0 {
+			return toolErrorResult(invalidField("id", "must be a positive integer"))
+		}
+	}
+
+	at, err := requestTime(request, "at", time.Now().UTC())
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	product, err := app.dbService.GetProduct(ctx, uint(id), code)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	promotions, err := app.dbService.ListPromotions(ctx, at)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	price, err := app.config.effectivePrice(product, promotions, at)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(price, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal effective price to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
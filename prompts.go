@@ -0,0 +1,212 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// promptDefinition describes a prompt whose messages embed the current contents of resources
+type promptDefinition struct {
+	Name        string
+	Description string
+	Arguments   []mcp.PromptOption
+	// Build returns the instruction text and the URIs of the resources to embed; the
+	// instruction may interpolate data read from the database of the request
+	Build func(ctx context.Context, dbs *DBService, args map[string]string) (string, []string, error)
+}
+
+// maxPriceReviewProducts caps the number of products listed in the price_review prompt
+const maxPriceReviewProducts = 50
+
+// promptDefinitions lists the prompts registered by NewServer
+var promptDefinitions = []promptDefinition{
+	{
+		Name:        "analyze_catalog",
+		Description: "Analyze the product catalog, grounded in the current product list and data-quality report",
+		Arguments: []mcp.PromptOption{
+			mcp.WithArgument("focus",
+				mcp.ArgumentDescription("Optional aspect to focus on, e.g. pricing or data quality"),
+			),
+		},
+		Build: func(ctx context.Context, dbs *DBService, args map[string]string) (string, []string, error) {
+			instruction := "Analyze the product catalog below. Summarize its size and price range, point out outliers and explain the data-quality issues reported by the validation, with suggested fixes."
+			if focus := strings.TrimSpace(args["focus"]); focus != "" {
+				instruction += " Focus on: " + focus + "."
+			}
+			return instruction, []string{"products://list", "catalog://validation"}, nil
+		},
+	},
+	{
+		Name:        "compare_catalog",
+		Description: "Compare the product catalog at a past time with the current catalog",
+		Arguments: []mcp.PromptOption{
+			mcp.WithArgument("since",
+				mcp.RequiredArgument(),
+				mcp.ArgumentDescription("RFC 3339 timestamp of the earlier catalog"),
+			),
+		},
+		Build: func(ctx context.Context, dbs *DBService, args map[string]string) (string, []string, error) {
+			since := args["since"]
+			if _, err := time.Parse(time.RFC3339, since); err != nil {
+				return "", nil, invalidField("since", asOfFormatMessage)
+			}
+			instruction := fmt.Sprintf("The first resource is the product catalog as of %s, the second is the current catalog. List the products that were added, removed or repriced since then and summarize the overall price change.", since)
+			return instruction, []string{"products://list?as_of=" + url.QueryEscape(since), "products://list"}, nil
+		},
+	},
+	{
+		Name:        "summarize_catalog",
+		Description: "Summarize the product catalog from its current statistics: size, categories and price ranges",
+		Arguments: []mcp.PromptOption{
+			mcp.WithArgument("audience",
+				mcp.ArgumentDescription("Optional audience of the summary, e.g. customers or management"),
+			),
+		},
+		Build: func(ctx context.Context, dbs *DBService, args map[string]string) (string, []string, error) {
+			stats, err := dbs.ProductStats(ctx)
+			if err != nil {
+				return "", nil, err
+			}
+
+			var b strings.Builder
+			fmt.Fprintf(&b, "The product catalog holds %d products.", stats.Products)
+			if len(stats.Categories) > 0 {
+				categories := make([]string, len(stats.Categories))
+				for i, c := range stats.Categories {
+					name := "uncategorized"
+					if c.Category != nil {
+						name = *c.Category
+					}
+					categories[i] = fmt.Sprintf("%s (%d)", name, c.Products)
+				}
+				fmt.Fprintf(&b, " Products per category: %s.", strings.Join(categories, ", "))
+			}
+			for _, p := range stats.Prices {
+				fmt.Fprintf(&b, " %d products are priced in %s, from %.2f to %.2f, averaging %.2f.", p.Products, p.Currency, p.Min, p.Max, p.Avg)
+			}
+			if stats.Newest != nil {
+				fmt.Fprintf(&b, " The newest product is %s (%s), added %s.", stats.Newest.Code, stats.Newest.Name, stats.Newest.CreatedAt.UTC().Format(time.RFC3339))
+			}
+			b.WriteString("\n\nWrite a short summary of the catalog from these figures, highlighting its strongest and weakest categories.")
+			if audience := strings.TrimSpace(args["audience"]); audience != "" {
+				b.WriteString(" Address it to: " + audience + ".")
+			}
+			return b.String(), nil, nil
+		},
+	},
+	{
+		Name:        "price_review",
+		Description: fmt.Sprintf("Review the current prices of up to %d products, most expensive first, and suggest adjustments", maxPriceReviewProducts),
+		Arguments: []mcp.PromptOption{
+			mcp.WithArgument("category",
+				mcp.ArgumentDescription("Optional category to restrict the review to"),
+			),
+		},
+		Build: func(ctx context.Context, dbs *DBService, args map[string]string) (string, []string, error) {
+			category := strings.TrimSpace(args["category"])
+			products, err := dbs.GetProducts(ctx, ProductQuery{Sort: "price", Desc: true, Limit: maxPriceReviewProducts, Category: category})
+			if err != nil {
+				return "", nil, err
+			}
+			if len(products) == 0 {
+				if category != "" {
+					return "", nil, invalidField("category", "has no products")
+				}
+				return "", nil, fmt.Errorf("%w: the catalog has no products to review", ErrFailedPrecondition)
+			}
+
+			var b strings.Builder
+			b.WriteString("Current prices")
+			if category != "" {
+				fmt.Fprintf(&b, " in category %s", category)
+			}
+			b.WriteString(":\n")
+			for _, p := range products {
+				fmt.Fprintf(&b, "- %s %s: %.2f %s, %d in stock\n", p.Code, p.Name, p.Price, p.Currency, p.Stock)
+			}
+			b.WriteString("\nReview these prices. Flag products priced out of line with similar products, consider the stock levels, and suggest new prices with a one-line reason each.")
+			return b.String(), nil, nil
+		},
+	},
+}
+
+// embedRequestID numbers the internal resource reads made while building prompts
+var embedRequestID atomic.Int64
+
+// readResource reads a resource through the server so that templates, quotas and
+// hooks apply exactly as for a client read
+func (app *App) readResource(ctx context.Context, uri string) ([]mcp.ResourceContents, error) {
+	id := fmt.Sprintf("prompt-embed-%d", embedRequestID.Add(1))
+	message, err := json.Marshal(mcp.JSONRPCRequest{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      mcp.NewRequestId(id),
+		Request: mcp.Request{Method: string(mcp.MethodResourcesRead)},
+		Params:  map[string]any{"uri": uri},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode resource read: %w", err)
+	}
+
+	switch response := app.server.HandleMessage(ctx, message).(type) {
+	case mcp.JSONRPCResponse:
+		result, ok := response.Result.(mcp.ReadResourceResult)
+		if !ok {
+			return nil, fmt.Errorf("unexpected result reading %s", uri)
+		}
+		return result.Contents, nil
+	case mcp.JSONRPCError:
+		// Errors recorded for the client response are not needed for internal reads
+		app.rpcErrors.take(sessionID(ctx), id)
+		return nil, fmt.Errorf("failed to read %s: %s", uri, response.Error.Message)
+	default:
+		return nil, fmt.Errorf("unexpected response reading %s", uri)
+	}
+}
+
+// promptHandler returns the handler building the messages of a prompt: the current
+// contents of its resources as embedded resources, followed by the instruction
+func (app *App) promptHandler(def promptDefinition) server.PromptHandlerFunc {
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		instruction, uris, err := def.Build(ctx, app.dbService, request.Params.Arguments)
+		if err != nil {
+			return nil, resourceError(err)
+		}
+
+		var messages []mcp.PromptMessage
+		for _, uri := range uris {
+			contents, err := app.readResource(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			for _, content := range contents {
+				messages = append(messages, mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(content)))
+			}
+		}
+		messages = append(messages, mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(instruction)))
+
+		return mcp.NewGetPromptResult(def.Description, messages), nil
+	}
+}
+
+// addPrompts registers the prompts of promptDefinitions, followed by those stored in the
+// database
+func (app *App) addPrompts(s *server.MCPServer) {
+	for _, def := range promptDefinitions {
+		app.addPrompt(s, def)
+	}
+	app.addStoredPrompts(context.Background())
+}
+
+// addPrompt registers a prompt, replacing any prompt of the same name
+func (app *App) addPrompt(s *server.MCPServer, def promptDefinition) {
+	options := append([]mcp.PromptOption{mcp.WithPromptDescription(def.Description)}, def.Arguments...)
+	s.AddPrompt(mcp.NewPrompt(def.Name, options...), app.promptHandler(def))
+}
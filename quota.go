@@ -0,0 +1,200 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// QuotaLimits holds the per-session limits; zero means unlimited
+type QuotaLimits struct {
+	MaxToolCalls int `json:"max_tool_calls"`
+	MaxRows      int `json:"max_rows"`
+	MaxMutations int `json:"max_mutations"`
+}
+
+// QuotaUsage holds what a session has consumed so far
+type QuotaUsage struct {
+	ToolCalls int `json:"tool_calls"`
+	Rows      int `json:"rows"`
+	Mutations int `json:"mutations"`
+}
+
+// QuotaStatus reports the limits, usage and remaining budget of a session
+type QuotaStatus struct {
+	SessionID string      `json:"session_id"`
+	Limits    QuotaLimits `json:"limits"`
+	Used      QuotaUsage  `json:"used"`
+	Remaining QuotaUsage  `json:"remaining"`
+}
+
+// QuotaTracker enforces usage quotas per client session, keeping usage in the session store
+type QuotaTracker struct {
+	limits QuotaLimits
+	store  SessionStore
+
+	// mu serializes load-modify-save cycles on the store
+	mu sync.Mutex
+	// mutating holds the tools that count against the mutation quota
+	mutating map[string]bool
+}
+
+// NewQuotaTracker creates a tracker enforcing the given limits
+func NewQuotaTracker(limits QuotaLimits, store SessionStore) *QuotaTracker {
+	return &QuotaTracker{
+		limits:   limits,
+		store:    store,
+		mutating: make(map[string]bool),
+	}
+}
+
+// countMutations sets the tools whose calls count against the mutation quota, which are
+// the registered tools not annotated read-only
+func (qt *QuotaTracker) countMutations(tools []string) {
+	qt.mu.Lock()
+	defer qt.mu.Unlock()
+	qt.mutating = make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		qt.mutating[tool] = true
+	}
+}
+
+// sessionID returns the id of the client session in ctx, or an empty string
+func sessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// exceeded reports whether adding n to used would go over limit
+func exceeded(limit, used, n int) bool {
+	return limit > 0 && used+n > limit
+}
+
+// update applies fn to the state of the session in ctx and saves the result unless
+// fn returns an error. Other writers of session state go through it as well so that
+// their changes do not race with quota accounting.
+func (qt *QuotaTracker) update(ctx context.Context, fn func(state *SessionState) error) error {
+	qt.mu.Lock()
+	defer qt.mu.Unlock()
+
+	state, err := qt.store.Load(ctx, sessionID(ctx))
+	if err != nil {
+		return err
+	}
+	if err := fn(state); err != nil {
+		return err
+	}
+	return qt.store.Save(ctx, state)
+}
+
+// charge applies fn to the usage of the session in ctx and saves the result
+// unless fn returns an error
+func (qt *QuotaTracker) charge(ctx context.Context, fn func(usage *QuotaUsage) error) error {
+	return qt.update(ctx, func(state *SessionState) error {
+		return fn(&state.Quota)
+	})
+}
+
+// beginToolCall charges a tool call to the session in ctx
+func (qt *QuotaTracker) beginToolCall(ctx context.Context, tool string) error {
+	return qt.charge(ctx, func(usage *QuotaUsage) error {
+		if exceeded(qt.limits.MaxToolCalls, usage.ToolCalls, 1) {
+			return fmt.Errorf("%w: session has used all %d tool calls", ErrQuotaExceeded, qt.limits.MaxToolCalls)
+		}
+		if qt.mutating[tool] && exceeded(qt.limits.MaxMutations, usage.Mutations, 1) {
+			return fmt.Errorf("%w: session has used all %d mutations", ErrQuotaExceeded, qt.limits.MaxMutations)
+		}
+
+		usage.ToolCalls++
+		if qt.mutating[tool] {
+			usage.Mutations++
+		}
+		return nil
+	})
+}
+
+// AddRows charges n returned rows to the session in ctx
+func (qt *QuotaTracker) AddRows(ctx context.Context, n int) error {
+	return qt.charge(ctx, func(usage *QuotaUsage) error {
+		if exceeded(qt.limits.MaxRows, usage.Rows, n) {
+			return fmt.Errorf("%w: returning %d rows would exceed the session limit of %d (%d used)", ErrQuotaExceeded, n, qt.limits.MaxRows, usage.Rows)
+		}
+		usage.Rows += n
+		return nil
+	})
+}
+
+// Status returns the quota status of the session in ctx
+func (qt *QuotaTracker) Status(ctx context.Context) (QuotaStatus, error) {
+	qt.mu.Lock()
+	defer qt.mu.Unlock()
+
+	state, err := qt.store.Load(ctx, sessionID(ctx))
+	if err != nil {
+		return QuotaStatus{}, err
+	}
+	usage := state.Quota
+	remaining := func(limit, used int) int {
+		if limit <= 0 {
+			return -1
+		}
+		return max(limit-used, 0)
+	}
+
+	return QuotaStatus{
+		SessionID: state.ID,
+		Limits:    qt.limits,
+		Used:      usage,
+		Remaining: QuotaUsage{
+			ToolCalls: remaining(qt.limits.MaxToolCalls, usage.ToolCalls),
+			Rows:      remaining(qt.limits.MaxRows, usage.Rows),
+			Mutations: remaining(qt.limits.MaxMutations, usage.Mutations),
+		},
+	}, nil
+}
+
+// sessionClosed notifies the session store that a client disconnected; it is
+// registered as an OnUnregisterSession hook
+func (app *App) sessionClosed(ctx context.Context, session server.ClientSession) {
+	if err := app.sessions.SessionClosed(ctx, session.SessionID()); err != nil {
+		slog.Warn("Failed to release session state", "session", session.SessionID(), "error", err)
+	}
+}
+
+// quotaMiddleware rejects tool calls once the session has exhausted its quota
+func (app *App) quotaMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := app.quotas.beginToolCall(ctx, request.Params.Name); err != nil {
+			return toolErrorResult(err)
+		}
+		return next(ctx, request)
+	}
+}
+
+// quotaStatusHandler handles the quota status resource request
+func (app *App) quotaStatusHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	status, err := app.quotas.Status(ctx)
+	if err != nil {
+		return nil, resourceError(err)
+	}
+
+	jsonData, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal quota status to JSON: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      "quota://status",
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
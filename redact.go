@@ -0,0 +1,95 @@
+package mcpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces the value of any scrubbed field
+const redactedValue = "[REDACTED]"
+
+// defaultRedactFields lists argument names that are always scrubbed
+var defaultRedactFields = []string{"password", "secret", "token", "api_key", "apikey", "authorization", "dsn"}
+
+// defaultRedactPatterns matches field names that look like credentials
+var defaultRedactPatterns = []string{`(?i)(passw|secret|token|credential|private_?key|api_?key)`}
+
+// Redactor scrubs sensitive fields from data before it is logged or persisted
+type Redactor struct {
+	fields   map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor creates a redactor for the given field names and field name patterns
+func NewRedactor(fields, patterns []string) (*Redactor, error) {
+	r := &Redactor{fields: make(map[string]bool, len(fields))}
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			r.fields[strings.ToLower(f)] = true
+		}
+	}
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// isSensitive reports whether a field with the given name must be scrubbed
+func (r *Redactor) isSensitive(name string) bool {
+	if r.fields[strings.ToLower(name)] {
+		return true
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns a copy of v with sensitive fields replaced, descending into
+// nested maps and slices. The input is never modified.
+func (r *Redactor) Redact(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			if r.isSensitive(k) {
+				out[k] = redactedValue
+				continue
+			}
+			out[k] = r.Redact(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = r.Redact(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// redactJSON returns the JSON document data with sensitive fields replaced; numbers keep
+// their exact text
+func (r *Redactor) redactJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to decode document to redact: %w", err)
+	}
+	return json.Marshal(r.Redact(v))
+}
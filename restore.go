@@ -0,0 +1,128 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"mcpserver/storage"
+)
+
+// RestoreDatabase replaces the configured SQLite database with the backup file at path.
+// The backup is copied next to the database and checked for integrity before it takes
+// the place of the database, so a failed restore leaves the database untouched. A database
+// holding products is only overwritten if force is set. The server must not be running.
+func RestoreDatabase(cfg *Config, path string, force bool) error {
+	target, ok := storage.SQLiteFile(cfg.DBPath)
+	if !ok {
+		return fmt.Errorf("restoring is %w for %s", ErrUnsupported, storage.Redact(cfg.DBPath))
+	}
+
+	if !force {
+		products, err := countStoredProducts(cfg, target)
+		if err != nil {
+			return err
+		}
+		if products > 0 {
+			return fmt.Errorf("%w: %s holds %d products; restore with -force to overwrite it", ErrFailedPrecondition, target, products)
+		}
+	}
+
+	staged := target + ".restore"
+	if err := copyFile(path, staged); err != nil {
+		return err
+	}
+	defer os.Remove(staged)
+
+	if err := checkBackup(cfg, staged); err != nil {
+		return fmt.Errorf("backup %s is not usable: %w", path, err)
+	}
+
+	// A journal left beside the old database would be replayed into the restored one
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(target + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove journal of %s: %w", target, err)
+		}
+	}
+	if err := os.Rename(staged, target); err != nil {
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+
+	slog.Info("Database restored", "backup", path, "database", target)
+	return nil
+}
+
+// countStoredProducts returns the number of products, deleted or not, in the database
+// file at path; a missing file or table holds none
+func countStoredProducts(cfg *Config, path string) (int64, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	db, err := storage.Open(path, cfg.storageOptions())
+	if err != nil {
+		return 0, err
+	}
+	defer NewDBService(db).Close()
+
+	if !db.Migrator().HasTable(&Product{}) {
+		return 0, nil
+	}
+	var count int64
+	if err := db.Unscoped().Model(&Product{}).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count products: %w", err)
+	}
+	return count, nil
+}
+
+// checkBackup verifies that the database file at path passes SQLite's integrity check
+// and holds a product catalog
+func checkBackup(cfg *Config, path string) error {
+	db, err := storage.Open(path, cfg.storageOptions())
+	if err != nil {
+		return err
+	}
+	dbs := NewDBService(db)
+	defer dbs.Close()
+
+	findings, err := dbs.integrityCheck(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("integrity check failed: %v", findings)
+	}
+	if !db.Migrator().HasTable(&Product{}) {
+		return fmt.Errorf("no products table")
+	}
+	return nil
+}
+
+// copyFile copies the file at src to dst, replacing dst, and syncs it to disk
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,184 @@
+package mcpserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ResultLimits bounds the size of list results; zero means unlimited. Results
+// above the soft limits are returned in full with a warning, results above the
+// hard limits are truncated.
+type ResultLimits struct {
+	SoftRows  int
+	HardRows  int
+	SoftBytes int
+	HardBytes int
+}
+
+// Reasons a result holds fewer rows than are available
+const (
+	truncatedByLimit    = "limit"
+	truncatedByMaxRows  = "max_rows"
+	truncatedByMaxBytes = "max_bytes"
+)
+
+// Truncation tells the client that a result is partial or large and how to fetch the rest
+type Truncation struct {
+	Truncated      bool   `json:"truncated"`
+	Reason         string `json:"reason,omitempty"`
+	Returned       int    `json:"returned"`
+	TotalAvailable int    `json:"total_available"`
+	NextCursor     string `json:"next_cursor,omitempty"`
+	Warning        string `json:"warning,omitempty"`
+}
+
+// fetchLimit returns how many rows to read for a request asking for requested rows (0 for all)
+func (l ResultLimits) fetchLimit(requested int) int {
+	if l.HardRows > 0 && (requested <= 0 || requested > l.HardRows) {
+		return l.HardRows
+	}
+	return requested
+}
+
+// cursorPrefix marks the offset encoded in a pagination cursor
+const cursorPrefix = "offset:"
+
+// encodeCursor returns the opaque cursor continuing a listing at offset
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset encoded in a cursor; field names the argument it came from
+func decodeCursor(field, cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if n, ok := strings.CutPrefix(string(raw), cursorPrefix); ok {
+			if offset, err := strconv.Atoi(n); err == nil && offset >= 0 {
+				return offset, nil
+			}
+		}
+	}
+	return 0, invalidField(field, "is not a cursor returned by this server")
+}
+
+// renderJSON returns a renderer encoding the projection of items as indented JSON
+func renderJSON[T any](project func([]T) any) func([]T) ([]byte, error) {
+	return func(items []T) ([]byte, error) {
+		data, err := json.MarshalIndent(project(items), "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal result to JSON: %w", err)
+		}
+		return data, nil
+	}
+}
+
+// limitResult renders items within the hard byte limit and describes any truncation.
+// items starts at offset of total available rows and was read with a limit of requested
+// rows (0 for none); paginated adds a cursor for the next page. It returns the rendered
+// bytes, the number of items kept and nil metadata if the result is complete and small.
+func limitResult[T any](limits ResultLimits, items []T, offset, total, requested int, paginated bool, render func([]T) ([]byte, error)) ([]byte, int, *Truncation, error) {
+	encode := func(n int) ([]byte, error) {
+		return render(items[:n])
+	}
+
+	n := len(items)
+	data, err := encode(n)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	reason := ""
+	if limits.HardBytes > 0 && len(data) > limits.HardBytes {
+		// Find the largest prefix of items that fits
+		lo, hi := 0, n-1
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			candidate, err := encode(mid)
+			if err != nil {
+				return nil, 0, nil, err
+			}
+			if len(candidate) <= limits.HardBytes {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		n = lo
+		if data, err = encode(n); err != nil {
+			return nil, 0, nil, err
+		}
+		reason = truncatedByMaxBytes
+	} else if offset+n < total {
+		reason = truncatedByMaxRows
+		if requested > 0 && n == requested {
+			reason = truncatedByLimit
+		}
+	}
+
+	var warning string
+	if (limits.SoftRows > 0 && n > limits.SoftRows) || (limits.SoftBytes > 0 && len(data) > limits.SoftBytes) {
+		warning = fmt.Sprintf("large result (%d rows, %d bytes); consider requesting fewer rows or fields", n, len(data))
+	}
+
+	if reason == "" && warning == "" {
+		return data, n, nil, nil
+	}
+	truncation := &Truncation{
+		Truncated:      offset+n < total,
+		Reason:         reason,
+		Returned:       n,
+		TotalAvailable: total,
+		Warning:        warning,
+	}
+	if truncation.Truncated && paginated {
+		truncation.NextCursor = encodeCursor(offset + n)
+	}
+	return data, n, truncation, nil
+}
+
+// truncatedToolResult builds a tool result from rendered text; truncation metadata, if any,
+// follows in a second text block and in _meta
+func truncatedToolResult(text string, truncation *Truncation) (*mcp.CallToolResult, error) {
+	result := mcp.NewToolResultText(text)
+	if truncation == nil {
+		return result, nil
+	}
+
+	meta, err := json.Marshal(map[string]any{"truncation": truncation})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal truncation metadata to JSON: %w", err)
+	}
+	result.Content = append(result.Content, mcp.NewTextContent(string(meta)))
+	result.Meta = map[string]any{"truncation": truncation}
+	return result, nil
+}
+
+// truncatedResourceContents builds resource contents from JSON text; truncation
+// metadata, if any, follows as a second content entry
+func truncatedResourceContents(uri, text string, truncation *Truncation) ([]mcp.ResourceContents, error) {
+	contents := []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: "application/json",
+			Text:     text,
+		},
+	}
+	if truncation == nil {
+		return contents, nil
+	}
+
+	meta, err := json.MarshalIndent(map[string]any{"truncation": truncation}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal truncation metadata to JSON: %w", err)
+	}
+	return append(contents, mcp.TextResourceContents{
+		URI:      uri,
+		MIMEType: "application/json",
+		Text:     string(meta),
+	}), nil
+}
@@ -0,0 +1,84 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy bounds the retries of database operations that fail with a transient
+// error, such as a locked database or a dropped connection
+type RetryPolicy struct {
+	// Attempts is the total number of tries; one or less disables retries
+	Attempts int
+	// Backoff is the delay before the first retry; it doubles on every retry up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// defaultRetryPolicy retries database operations in every profile
+var defaultRetryPolicy = RetryPolicy{
+	Attempts:   3,
+	Backoff:    50 * time.Millisecond,
+	MaxBackoff: time.Second,
+}
+
+// withRetry runs op until it succeeds, fails with an error that is not transient or the
+// retry policy is exhausted. op must be safe to repeat, typically a single statement or a
+// transaction. The error of the last attempt is returned, and is reported to the client as
+// an infrastructure error. Each attempt is bounded by the query timeout.
+func (dbs *DBService) withRetry(ctx context.Context, op func(ctx context.Context) error) error {
+	backoff := dbs.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := dbs.withQueryTimeout(ctx, op)
+		if err == nil || !transientError(ctx, err) {
+			return err
+		}
+		if attempt >= dbs.retry.Attempts {
+			if attempt > 1 {
+				return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		// Full jitter keeps concurrent retries of the same conflict apart
+		delay := time.Duration(rand.Int64N(int64(backoff) + 1))
+		slog.Debug("Retrying database operation", "attempt", attempt, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		backoff = min(2*backoff, dbs.retry.MaxBackoff)
+	}
+}
+
+// transientError reports whether err is an infrastructure error worth retrying while
+// the request is still live
+func transientError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	infraErr := classifyError(err)
+	return infraErr != nil && infraErr.Retryable && infraErr.Code != ErrCodeTimeout
+}
+
+// withQueryTimeout runs op with a context cancelled after the query timeout, if one is set.
+// A query cut off by the timeout fails with ErrQueryTimeout; cancellation and deadlines of
+// ctx itself are reported as such.
+func (dbs *DBService) withQueryTimeout(ctx context.Context, op func(ctx context.Context) error) error {
+	if dbs.queryTimeout <= 0 {
+		return op(ctx)
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, dbs.queryTimeout)
+	defer cancel()
+	err := op(queryCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: query did not complete within %s", ErrQueryTimeout, dbs.queryTimeout)
+	}
+	return err
+}
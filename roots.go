@@ -0,0 +1,125 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Methods of client roots, which the MCP library does not handle itself
+const (
+	methodRootsList                = "roots/list"
+	methodNotificationRootsChanged = "notifications/roots/list_changed"
+)
+
+// rootsTimeout bounds the wait for the client to list its roots
+const rootsTimeout = 10 * time.Second
+
+// ClientRoots caches the root directories of the clients declaring the roots capability.
+// Files are only read within both the import directory and one of the roots of the client;
+// the roots are listed on first use and again after the client notifies that they changed.
+type ClientRoots struct {
+	mu sync.Mutex
+	// sessions maps session ids to the root directories of their client
+	sessions map[string][]string
+}
+
+// NewClientRoots creates an empty roots cache
+func NewClientRoots() *ClientRoots {
+	return &ClientRoots{sessions: make(map[string][]string)}
+}
+
+// onChanged forgets the roots of a session whose client notified that they changed; it is
+// registered as a notification handler
+func (cr *ClientRoots) onChanged(ctx context.Context, notification mcp.JSONRPCNotification) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	delete(cr.sessions, sessionID(ctx))
+}
+
+// onUnregister forgets the roots of a session that ended
+func (cr *ClientRoots) onUnregister(ctx context.Context, session server.ClientSession) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	delete(cr.sessions, session.SessionID())
+}
+
+// rootDirectories returns the directories of the file roots of a client, resolving symbolic
+// links; roots that are not file URIs are skipped
+func rootDirectories(roots []mcp.Root) []string {
+	dirs := []string{}
+	for _, root := range roots {
+		u, err := url.Parse(root.URI)
+		if err != nil || u.Scheme != "file" || u.Path == "" {
+			slog.Debug("Ignoring client root", "uri", root.URI)
+			continue
+		}
+		dir := filepath.Clean(filepath.FromSlash(u.Path))
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// clientRoots returns the root directories of the client of the session of ctx, listing them
+// if they are not cached; ok is false if the client did not declare the roots capability
+func (app *App) clientRoots(ctx context.Context) (dirs []string, ok bool, err error) {
+	if features, _ := app.clientCapabilities.features(ctx); !features.Roots {
+		return nil, false, nil
+	}
+	session := sessionID(ctx)
+	app.roots.mu.Lock()
+	dirs, ok = app.roots.sessions[session]
+	app.roots.mu.Unlock()
+	if ok {
+		return dirs, true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rootsTimeout)
+	defer cancel()
+	raw, err := app.clientRequests.request(ctx, app.server, methodRootsList, map[string]any{})
+	if err != nil {
+		return nil, true, fmt.Errorf("%w: the client did not list its roots: %v", ErrFailedPrecondition, err)
+	}
+	var result mcp.ListRootsResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, true, fmt.Errorf("%w: invalid %s result: %v", ErrFailedPrecondition, methodRootsList, err)
+	}
+	dirs = rootDirectories(result.Roots)
+	slog.Debug("Listed client roots", "session", session, "roots", dirs)
+
+	app.roots.mu.Lock()
+	app.roots.sessions[session] = dirs
+	app.roots.mu.Unlock()
+	return dirs, true, nil
+}
+
+// checkRoots verifies that path, a resolved file path, is within one of the roots of the
+// client of the session of ctx, if it declared the roots capability; field names the
+// argument the path came from
+func (app *App) checkRoots(ctx context.Context, field, path string) error {
+	dirs, ok, err := app.clientRoots(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	for _, dir := range dirs {
+		if rel, err := filepath.Rel(dir, path); err == nil && filepath.IsLocal(rel) {
+			return nil
+		}
+	}
+	if len(dirs) == 0 {
+		return invalidField(field, "is not readable: the client declared no file roots")
+	}
+	return invalidField(field, fmt.Sprintf("must be within the roots of the client (%s)", strings.Join(dirs, ", ")))
+}
@@ -0,0 +1,150 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// samplingTimeout bounds the wait for the client to answer a sampling request, which may
+// include the user reviewing it
+const samplingTimeout = 2 * time.Minute
+
+// descriptionMaxTokens caps the length of the descriptions drafted by the client LLM
+const descriptionMaxTokens = 300
+
+// descriptionSystemPrompt instructs the client LLM drafting product descriptions
+const descriptionSystemPrompt = "You write product descriptions for an online catalog. Reply with the description only: one or two plain sentences, no markdown, no quotes, and no claims that the product data does not support."
+
+// GeneratedDescription is the result of the generate_description tool
+type GeneratedDescription struct {
+	ProductID   uint   `json:"product_id"`
+	Description string `json:"description"`
+	// Model is the model the client used to draft the description
+	Model string `json:"model,omitempty"`
+	Saved bool   `json:"saved"`
+	// Product is the product with the saved description, omitted unless saved
+	Product any `json:"product,omitempty"`
+}
+
+// clientSupportsSampling reports whether the client of the session declared the sampling
+// capability; sessions that do not record client capabilities are assumed to support it,
+// sampling then failing if they do not
+func clientSupportsSampling(ctx context.Context) bool {
+	session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo)
+	if !ok {
+		return true
+	}
+	return session.GetClientCapabilities().Sampling != nil
+}
+
+// samplingText returns the trimmed text of the content of a sampled message, which is decoded
+// as a map when it comes from a remote client; ok is false for other content types
+func samplingText(content any) (string, bool) {
+	if raw, ok := content.(map[string]any); ok {
+		parsed, err := mcp.ParseContent(raw)
+		if err != nil {
+			return "", false
+		}
+		content = parsed
+	}
+	switch c := content.(type) {
+	case mcp.TextContent:
+		return strings.TrimSpace(c.Text), true
+	case *mcp.TextContent:
+		return strings.TrimSpace(c.Text), true
+	}
+	return "", false
+}
+
+// descriptionRequest builds the sampling request drafting a description of the product
+func descriptionRequest(p *Product, guidance string) mcp.CreateMessageRequest {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Draft a description for the product %s", p.Code)
+	if p.Name != "" {
+		fmt.Fprintf(&b, " named %q", p.Name)
+	}
+	if p.Category != nil {
+		fmt.Fprintf(&b, " in the category %s", *p.Category)
+	}
+	fmt.Fprintf(&b, ", priced at %.2f %s.", p.Price, p.Currency)
+	if p.Description != "" {
+		fmt.Fprintf(&b, " Its current description is: %s", p.Description)
+	}
+	if guidance != "" {
+		fmt.Fprintf(&b, "\n\nGuidance: %s", guidance)
+	}
+
+	request := mcp.CreateMessageRequest{}
+	request.Messages = []mcp.SamplingMessage{{Role: mcp.RoleUser, Content: mcp.NewTextContent(b.String())}}
+	request.SystemPrompt = descriptionSystemPrompt
+	request.MaxTokens = descriptionMaxTokens
+	request.Temperature = 0.7
+	return request
+}
+
+// generateDescriptionHandler handles the generate_description tool request: it asks the
+// client LLM, through MCP sampling, to draft a description of a product and saves it if
+// requested
+func (app *App) generateDescriptionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+	guidance := strings.TrimSpace(request.GetString("guidance", ""))
+	save := request.GetBool("save", false)
+	fields, err := requestFields(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	if !clientSupportsSampling(ctx) {
+		return toolErrorResult(fmt.Errorf("sampling is %w by the client: generate_description asks the client LLM to draft the description, which requires a client declaring the sampling capability", ErrUnsupported))
+	}
+	product, err := app.dbService.GetProduct(ctx, uint(id), "")
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	samplingCtx, cancel := context.WithTimeout(ctx, samplingTimeout)
+	defer cancel()
+	sampled, err := app.server.RequestSampling(samplingCtx, descriptionRequest(product, guidance))
+	if err != nil {
+		return toolErrorResult(fmt.Errorf("%w: the client did not draft a description: %v", ErrFailedPrecondition, err))
+	}
+	text, ok := samplingText(sampled.Content)
+	if !ok || text == "" {
+		return toolErrorResult(fmt.Errorf("%w: the client returned no text description", ErrFailedPrecondition))
+	}
+
+	result := GeneratedDescription{
+		ProductID:   product.ID,
+		Description: text,
+		Model:       sampled.Model,
+	}
+	if save {
+		product, err = app.dbService.UpdateProduct(ctx, product.ID, func(p *Product) error {
+			p.Description = result.Description
+			return nil
+		})
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		result.Saved = true
+		result.Product = projectProduct(product, fields)
+	}
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal generated description to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
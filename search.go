@@ -0,0 +1,141 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// productSearchTable is the SQLite FTS5 table indexing the names and descriptions of products
+const productSearchTable = "product_search"
+
+// fts5QueryErrors are the starts of the errors of FTS5 for malformed queries
+var fts5QueryErrors = []string{"fts5:", "unterminated string", "no such column", "unknown special query"}
+
+// maxSearchResults caps the number of products a single full-text search may return
+const maxSearchResults = 100
+
+// fts5Available reports whether db is a SQLite database built with FTS5, which the SQLite
+// driver only includes when built with the sqlite_fts5 tag
+func fts5Available(db *gorm.DB) (bool, error) {
+	if db.Dialector.Name() != "sqlite" {
+		return false, nil
+	}
+	var used int
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used).Error; err != nil {
+		return false, err
+	}
+	return used == 1, nil
+}
+
+// hasProductSearch reports whether the database has the full-text index of products
+func (dbs *DBService) hasProductSearch() bool {
+	return dbs.db.Migrator().HasTable(productSearchTable)
+}
+
+// SearchHit is a product matching a full-text search
+type SearchHit struct {
+	Product any `json:"product"`
+	// Rank is the BM25 relevance of the product, lower being more relevant
+	Rank float64 `json:"rank"`
+	// Snippet is an excerpt of the name or description with the matched terms in brackets
+	Snippet string `json:"snippet"`
+}
+
+// productSearchRow is a row of a full-text search
+type productSearchRow struct {
+	Product
+	Rank    float64
+	Snippet string
+}
+
+// SearchProducts returns a page of the products whose name or description match the FTS5
+// query, most relevant first, and the number of matching products
+func (dbs *DBService) SearchProducts(ctx context.Context, query string, limit, offset int) ([]productSearchRow, int, error) {
+	var rows []productSearchRow
+	var total int64
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		db := dbs.conn(ctx)
+		if !db.Migrator().HasTable(productSearchTable) {
+			return fmt.Errorf("full-text search is %w by this database; it requires SQLite with FTS5, which the server includes when built with -tags sqlite_fts5, and is indexed by migration 0016_product_search", ErrUnsupported)
+		}
+
+		matches := func() *gorm.DB {
+			return db.Table(productSearchTable).
+				Joins("JOIN products ON products.id = product_search.rowid").
+				Where("product_search MATCH ? AND products.deleted_at IS NULL", query)
+		}
+		if err := matches().Count(&total).Error; err != nil {
+			return err
+		}
+		search := matches().
+			Select("products.*, bm25(product_search) AS rank, snippet(product_search, -1, '[', ']', '…', 12) AS snippet").
+			Order("rank").Order("products.id").
+			Offset(offset)
+		if limit > 0 {
+			search = search.Limit(limit)
+		}
+		return search.Scan(&rows).Error
+	})
+	if err != nil {
+		for _, prefix := range fts5QueryErrors {
+			if strings.HasPrefix(err.Error(), prefix) {
+				return nil, 0, invalidField("query", fmt.Sprintf("is not a valid full-text query: %v", err))
+			}
+		}
+		return nil, 0, fmt.Errorf("failed to search products: %w", err)
+	}
+	return rows, int(total), nil
+}
+
+// fulltextSearchHandler handles the fulltext_search tool request
+func (app *App) fulltextSearchHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := request.RequireString("query")
+	if err != nil {
+		return argumentError("query", err), nil
+	}
+	if query = strings.TrimSpace(query); query == "" {
+		return toolErrorResult(invalidField("query", "must not be empty"))
+	}
+	fields, err := requestFields(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	requested := request.GetInt("limit", 0)
+	if requested < 0 || requested > maxSearchResults {
+		return toolErrorResult(invalidField("limit", fmt.Sprintf("must be between 1 and %d", maxSearchResults)))
+	}
+	if requested == 0 {
+		requested = maxSearchResults
+	}
+	var offset int
+	if cursor := request.GetString("cursor", ""); cursor != "" {
+		if offset, err = decodeCursor("cursor", cursor); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+
+	limits := app.config.Results
+	rows, total, err := app.dbService.SearchProducts(ctx, query, limits.fetchLimit(requested), offset)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	data, n, truncation, err := limitResult(limits, rows, offset, total, requested, true, renderJSON(func(page []productSearchRow) any {
+		hits := make([]SearchHit, len(page))
+		for i := range page {
+			hits[i] = SearchHit{Product: projectProduct(&page[i].Product, fields), Rank: page[i].Rank, Snippet: page[i].Snippet}
+		}
+		return hits
+	}))
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	if err := app.quotas.AddRows(ctx, n); err != nil {
+		return toolErrorResult(err)
+	}
+	return truncatedToolResult(string(data), truncation)
+}
@@ -0,0 +1,67 @@
+package mcpserver
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxSecretFileSize bounds the size of a secret file read through a *_FILE variable
+const maxSecretFileSize = 64 * 1024
+
+// lookupEnv returns the value of the named setting. The value is taken from the
+// environment variable itself or, when NAME_FILE is set instead, from the file it
+// points to (e.g. a Docker or Kubernetes secret mount), so credentials never have
+// to appear in the environment.
+func lookupEnv(name string) (string, error) {
+	value := os.Getenv(name)
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("both %s and %s_FILE are set", name, name)
+	}
+
+	secret, err := readSecretFile(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s_FILE: %w", name, err)
+	}
+	return secret, nil
+}
+
+// readSecretFile reads a secret from path after checking that it is a regular
+// file that cannot be modified by other users
+func readSecretFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	if perm := info.Mode().Perm(); perm&0o022 != 0 {
+		return "", fmt.Errorf("%s has insecure permissions %#o (must not be group or world writable)", path, perm)
+	}
+	if info.Size() > maxSecretFileSize {
+		return "", fmt.Errorf("%s is larger than %d bytes", path, maxSecretFileSize)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxSecretFileSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return secret, nil
+}
@@ -0,0 +1,375 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"mcpserver/storage"
+)
+
+// selfTestTimeout bounds the whole self-test run
+const selfTestTimeout = 30 * time.Second
+
+// selfTestImage is a base64-encoded 1x1 PNG stored by set_product_image
+const selfTestImage = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAIAAACQd1PeAAAADElEQVR4nGP438AAAAQBAYDFKhhdAAAAAElFTkSuQmCC"
+
+// selfTestToolArgs holds the canned arguments used to exercise each registered tool.
+// Every tool registered in NewServer needs an entry here, otherwise the self-test fails.
+var selfTestToolArgs = map[string]map[string]any{
+	"hello_world":           {"name": "self-test"},
+	"list_products":         {"sort": "-price", "limit": 10, "fields": []any{"code", "price"}, "output_format": "markdown"},
+	"calculate":             {"operation": "divide", "x": 10, "y": 4},
+	"calculate_v1":          {"operation": "add", "x": 1, "y": 2},
+	"calculate_v2":          {"operation": "divide", "x": 1, "y": 3},
+	"list_categories":       {},
+	"product_stats":         {},
+	"find_duplicates":       {},
+	"merge_products":        {"keep_id": 1, "merge_ids": []any{2}},
+	"create_promotion":      {"name": "self-test", "type": "percentage", "value": 10, "categories": []any{"widgets"}},
+	"list_promotions":       {"active": true},
+	"get_effective_price":   {"id": 1},
+	"create_category":       {"name": "self-test", "description": "Created by the self-test"},
+	"create_product":        {"code": "SELFTEST", "price": 9.99, "idempotency_key": "self-test"},
+	"update_product":        {"id": 1, "price": 120},
+	"get_product":           {"code": "D42", "fields": []any{"id", "price"}, "include_image": true},
+	"set_product_image":     {"id": 1, "data": selfTestImage},
+	"delete_product_image":  {"id": 2},
+	"list_tags":             {},
+	"tag_product":           {"id": 1, "tags": []any{"sale", "new"}},
+	"untag_product":         {"id": 1, "tags": []any{"new"}},
+	"create_variant":        {"product_id": 2, "sku": "P99-BLUE-L", "size": "L", "color": "blue", "stock": 4},
+	"list_variants":         {"product_id": 2},
+	"get_stock":             {"code": "P99"},
+	"place_order":           {"items": []any{map[string]any{"code": "D42", "quantity": 2}}, "idempotency_key": "self-test"},
+	"get_order":             {"id": 1},
+	"list_orders":           {"product_id": 1, "since": "2000-01-01T00:00:00Z"},
+	"create_customer":       {"name": "Self Test", "email": "self-test@example.com", "idempotency_key": "self-test"},
+	"search_customers":      {"query": "example.com"},
+	"create_supplier":       {"name": "Self Test Supplies", "email": "orders@example.com", "idempotency_key": "self-test"},
+	"set_tools_enabled":     {"tools": []string{"hello_world"}, "enabled": true},
+	"generate_description":  {"id": 1, "guidance": "keep it short", "save": true},
+	"create_prompt":         {"name": "review_category", "description": "Review a category", "template": "Review the {{.category}} products.", "arguments": []any{map[string]any{"name": "category", "required": true}}, "resources": []any{"products://list"}},
+	"update_prompt":         {"name": "review_category", "template": "Review the prices of the {{.category}} products."},
+	"update_supplier":       {"id": 1, "phone": "+1 555 0100"},
+	"list_suppliers":        {},
+	"fulltext_search":       {"query": "widget", "fields": []any{"id", "code", "name"}},
+	"products_by_supplier":  {"supplier_id": 1, "fields": []any{"id", "code"}},
+	"delete_supplier":       {"id": 2},
+	"adjust_stock":          {"id": 1, "delta": -5, "idempotency_key": "self-test"},
+	"price_history":         {"id": 1},
+	"convert_price":         {"id": 1, "to": "EUR"},
+	"patch_product":         {"id": 2, "json_patch": []any{map[string]any{"op": "replace", "path": "/price", "value": 210}}},
+	"maintain_database":     {"operation": "all"},
+	"delete_products_where": {"filter": map[string]any{"code": "SELFTEST"}},
+	"delete_product":        {"id": 3},
+	"list_deleted_products": {},
+	"restore_product":       {"id": 3},
+	"validate_catalog":      {},
+	"db_health":             {},
+	"backup_database":       {"include_blob": true},
+	"explain_query":         {"saved_query": "products_by_code"},
+	"watch_price":           {"product_id": 1, "condition": "below", "threshold": 50, "webhook_url": "https://example.com/price-alerts"},
+	"unwatch_price":         {"watch_id": "watch-1"},
+	"set_preferences":       {"output_format": "json"},
+	"import_products":       {"content": "code,name,price,stock\nIMPORTED,Imported Widget,5,3\nD42,Deluxe Widget,99.5,\n"},
+	"export_products":       {"delimiter": "semicolon", "decimal_separator": ",", "encoding": "utf-8-bom", "header_language": "de", "filter": map[string]any{"price": map[string]any{"gt": 0}}},
+}
+
+// selfTestPromptArgs holds the canned arguments used to get each registered prompt
+var selfTestPromptArgs = map[string]map[string]string{
+	"analyze_catalog":   {"focus": "pricing"},
+	"compare_catalog":   {"since": "2000-01-01T00:00:00Z"},
+	"summarize_catalog": {"audience": "management"},
+	"review_category":   {"category": "widgets"},
+	"price_review":      {"category": "widgets"},
+}
+
+// selfTestSampler answers the sampling requests of the self-test with a canned message
+type selfTestSampler struct{}
+
+// CreateMessage implements client.SamplingHandler
+func (selfTestSampler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	return &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{Role: mcp.RoleAssistant, Content: mcp.NewTextContent("A sturdy widget drafted by the self-test.")},
+		Model:           "self-test",
+		StopReason:      "endTurn",
+	}, nil
+}
+
+// selfTestResult records the outcome of a single self-test check
+type selfTestResult struct {
+	Kind string
+	Name string
+	Err  error
+	// Skipped explains why a tool the database backend does not support was not checked
+	Skipped  string
+	Duration time.Duration
+}
+
+// useSelfTestDatabase prepares the database at rawURL for the self-test, refusing one that
+// is not empty, and returns the function reverting its migrations when the self-test is done
+func useSelfTestDatabase(rawURL string, opts storage.Options) (revert func(), err error) {
+	if _, ok := storage.SQLiteFile(rawURL); ok || storage.InMemory(rawURL) {
+		return nil, fmt.Errorf("SELF_TEST_DB_URL must name a MySQL or PostgreSQL database")
+	}
+	db, err := storage.Open(rawURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) > 0 {
+		return nil, fmt.Errorf("the self-test database %s is not empty (%d migrations applied)", storage.Redact(rawURL), len(applied))
+	}
+
+	return func() {
+		for {
+			id, err := rollback(db)
+			if err != nil {
+				slog.Warn("Failed to empty the self-test database", "url", storage.Redact(rawURL), "error", err)
+				break
+			}
+			if id == "" {
+				break
+			}
+		}
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}, nil
+}
+
+// RunSelfTest boots the server against a temporary database, exercises every
+// registered tool and resource through an in-process client and prints a report.
+// Seeding and destructive tools are always enabled so that every tool is covered.
+// With SELF_TEST_DB_URL set, the database is an empty MySQL or PostgreSQL database, such
+// as one in a container, instead of a SQLite file; tools the backend does not support are
+// skipped. It returns an error if any check failed.
+func RunSelfTest(cfg *Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), selfTestTimeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "mcpserver-selftest-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCfg := *cfg
+	testCfg.DBPath = filepath.Join(dir, "selftest.db")
+	if cfg.SelfTestDBURL != "" {
+		testCfg.DBPath = cfg.SelfTestDBURL
+		revert, err := useSelfTestDatabase(testCfg.DBPath, cfg.storageOptions())
+		if err != nil {
+			return err
+		}
+		defer revert()
+	}
+	testCfg.SeedDatabase = true
+	testCfg.AutoMigrate = true
+	testCfg.DestructiveTools = true
+	testCfg.PromptTools = true
+	testCfg.ToolAdmin = true
+	testCfg.DisabledTools = nil
+	testCfg.ReplicaDBPaths = nil
+	testCfg.Tenants = nil
+	testCfg.Currency = defaultCurrency
+	testCfg.ExchangeRates = map[string]float64{"EUR": 0.9}
+	testCfg.BackupDir = filepath.Join(dir, "backups")
+	testCfg.BackupInterval = 0
+
+	db, err := InitializeDatabase(&testCfg)
+	if err != nil {
+		return err
+	}
+	if err := SeedDatabase(db); err != nil {
+		return err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	app, err := NewApp(&testCfg, NewDBService(db))
+	if err != nil {
+		return err
+	}
+	s, err := app.NewServer()
+	if err != nil {
+		return err
+	}
+
+	c, err := client.NewInProcessClientWithSamplingHandler(s, selfTestSampler{})
+	if err != nil {
+		return fmt.Errorf("failed to create in-process client: %w", err)
+	}
+	defer c.Close()
+
+	if err := c.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start in-process client: %w", err)
+	}
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "self-test", Version: "1.0.0"}
+	if _, err := c.Initialize(ctx, initRequest); err != nil {
+		return fmt.Errorf("failed to initialize server: %w", err)
+	}
+
+	var results []selfTestResult
+
+	// Declarative tools bring their own example arguments
+	args := maps.Clone(selfTestToolArgs)
+	for _, tool := range app.declarativeTools {
+		if _, ok := args[tool.Name]; !ok {
+			args[tool.Name] = tool.Example
+			if tool.Example == nil {
+				args[tool.Name] = map[string]any{}
+			}
+		}
+	}
+
+	tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to list tools: %w", err)
+	}
+	for _, tool := range tools.Tools {
+		results = append(results, selfTestTool(ctx, c, tool.Name, args[tool.Name]))
+	}
+
+	resources, err := c.ListResources(ctx, mcp.ListResourcesRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to list resources: %w", err)
+	}
+	for _, resource := range resources.Resources {
+		results = append(results, selfTestResource(ctx, c, resource.URI))
+	}
+
+	prompts, err := c.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to list prompts: %w", err)
+	}
+	for _, prompt := range prompts.Prompts {
+		results = append(results, selfTestPrompt(ctx, c, prompt.Name, selfTestPromptArgs[prompt.Name]))
+	}
+
+	failed := 0
+	for _, result := range results {
+		status := "PASS"
+		detail := ""
+		switch {
+		case result.Skipped != "":
+			status = "SKIP"
+			detail = ": " + result.Skipped
+		case result.Err != nil:
+			status = "FAIL"
+			detail = ": " + result.Err.Error()
+			failed++
+		}
+		fmt.Printf("%s %-8s %s (%s)%s\n", status, result.Kind, result.Name, result.Duration.Round(time.Millisecond), detail)
+	}
+	fmt.Printf("%d checks, %d failed\n", len(results), failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+// selfTestTool calls a tool with its canned arguments
+func selfTestTool(ctx context.Context, c *client.Client, name string, args map[string]any) selfTestResult {
+	start := time.Now()
+	result := selfTestResult{Kind: "tool", Name: name}
+
+	if args == nil {
+		result.Err = fmt.Errorf("no canned input registered")
+		return result
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = name
+	request.Params.Arguments = args
+
+	res, err := c.CallTool(ctx, request)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if res.IsError {
+		var envelope ToolError
+		if err := json.Unmarshal([]byte(toolResultText(res)), &envelope); err == nil && envelope.Code == CodeUnsupported {
+			result.Skipped = envelope.Message
+			return result
+		}
+		result.Err = fmt.Errorf("tool returned an error: %s", toolResultText(res))
+	}
+	return result
+}
+
+// selfTestResource reads a resource and checks that it returned content
+func selfTestResource(ctx context.Context, c *client.Client, uri string) selfTestResult {
+	start := time.Now()
+	result := selfTestResult{Kind: "resource", Name: uri}
+
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = uri
+
+	res, err := c.ReadResource(ctx, request)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if len(res.Contents) == 0 {
+		result.Err = fmt.Errorf("resource returned no contents")
+	}
+	return result
+}
+
+// selfTestPrompt gets a prompt with its canned arguments and checks that it returned messages
+func selfTestPrompt(ctx context.Context, c *client.Client, name string, args map[string]string) selfTestResult {
+	start := time.Now()
+	result := selfTestResult{Kind: "prompt", Name: name}
+
+	if args == nil {
+		result.Err = fmt.Errorf("no canned input registered")
+		return result
+	}
+
+	request := mcp.GetPromptRequest{}
+	request.Params.Name = name
+	request.Params.Arguments = args
+
+	res, err := c.GetPrompt(ctx, request)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if len(res.Messages) == 0 {
+		result.Err = fmt.Errorf("prompt returned no messages")
+	}
+	return result
+}
+
+// toolResultText concatenates the text content of a tool result
+func toolResultText(result *mcp.CallToolResult) string {
+	var text string
+	for _, content := range result.Content {
+		if tc, ok := content.(mcp.TextContent); ok {
+			text += tc.Text
+		}
+	}
+	return text
+}
@@ -0,0 +1,62 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultInstructions tell clients how to use the server when SERVER_INSTRUCTIONS is not set
+const defaultInstructions = `This server manages a product catalog: products with their categories, tags, variants, images, prices and stock, as well as suppliers, customers, orders and promotions.
+Read products with list_products and get_product or the products:// resources; list results link to the products://{id} resource of each product, which is cheaper to read than querying again.
+Create and change data with the write tools; destructive tools first return a preview with a confirmation_token to pass back to actually apply the change.
+Tool errors are JSON objects with a code and a message; fix the arguments named in fields before retrying, and retry only errors marked retryable.`
+
+// describeTools replaces the descriptions of the tools named in TOOL_DESCRIPTIONS; it is
+// registered as a tool filter
+func (app *App) describeTools(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	if len(app.config.ToolDescriptions) == 0 {
+		return tools
+	}
+	for i, tool := range tools {
+		if description, ok := app.config.ToolDescriptions[tool.Name]; ok {
+			tools[i].Description = description
+		}
+	}
+	return tools
+}
+
+// addServerTitle returns the initialize result message with the configured title added to
+// its server information, which the library has no field for; ok is false if message is no
+// initialize result or no title is configured
+func (m *rpcErrorMapper) addServerTitle(message []byte) ([]byte, bool) {
+	if m.serverTitle == "" || !bytes.Contains(message, []byte(`"serverInfo"`)) {
+		return nil, false
+	}
+	var response struct {
+		JSONRPC string                     `json:"jsonrpc"`
+		ID      any                        `json:"id"`
+		Result  map[string]json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(message, &response); err != nil || response.Result == nil {
+		return nil, false
+	}
+	var info map[string]any
+	if err := json.Unmarshal(response.Result["serverInfo"], &info); err != nil || info == nil {
+		return nil, false
+	}
+	info["title"] = m.serverTitle
+
+	encoded, err := json.Marshal(info)
+	if err != nil {
+		return nil, false
+	}
+	response.Result["serverInfo"] = encoded
+	rewritten, err := json.Marshal(response)
+	if err != nil {
+		return nil, false
+	}
+	return rewritten, true
+}
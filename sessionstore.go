@@ -0,0 +1,112 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SessionState is the per-session state that must survive reconnects to another replica
+type SessionState struct {
+	ID          string            `gorm:"primaryKey" json:"id"`
+	Quota       QuotaUsage        `gorm:"serializer:json" json:"quota"`
+	Preferences map[string]string `gorm:"serializer:json" json:"preferences,omitempty"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// SessionStore persists session state keyed by session id
+type SessionStore interface {
+	// Load returns the state of a session, or a fresh state if none is stored
+	Load(ctx context.Context, id string) (*SessionState, error)
+	// Save stores the state of a session
+	Save(ctx context.Context, state *SessionState) error
+	// SessionClosed is called when a client disconnects from this replica
+	SessionClosed(ctx context.Context, id string) error
+}
+
+// newSessionStore creates the session store selected by the configuration
+func newSessionStore(cfg *Config, db *gorm.DB) (SessionStore, error) {
+	switch cfg.SessionStore {
+	case "", "memory":
+		return newMemorySessionStore(), nil
+	case "sql":
+		return &sqlSessionStore{db: db, ttl: cfg.SessionTTL}, nil
+	default:
+		return nil, fmt.Errorf("unknown session store %q (expected memory or sql)", cfg.SessionStore)
+	}
+}
+
+// memorySessionStore keeps session state in process; state is lost when the client disconnects
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]SessionState
+}
+
+// newMemorySessionStore creates an empty in-memory session store
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]SessionState)}
+}
+
+func (s *memorySessionStore) Load(ctx context.Context, id string) (*SessionState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.sessions[id]
+	if !ok {
+		return &SessionState{ID: id}, nil
+	}
+	return &state, nil
+}
+
+func (s *memorySessionStore) Save(ctx context.Context, state *SessionState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.UpdatedAt = time.Now()
+	s.sessions[state.ID] = *state
+	return nil
+}
+
+func (s *memorySessionStore) SessionClosed(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+// sqlSessionStore keeps session state in the application database so that any
+// replica can resume a session; state expires after ttl without activity
+type sqlSessionStore struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+func (s *sqlSessionStore) Load(ctx context.Context, id string) (*SessionState, error) {
+	var state SessionState
+	result := s.db.WithContext(ctx).Where("id = ?", id).Limit(1).Find(&state)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to load session state: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || (s.ttl > 0 && time.Since(state.UpdatedAt) > s.ttl) {
+		return &SessionState{ID: id}, nil
+	}
+	return &state, nil
+}
+
+func (s *sqlSessionStore) Save(ctx context.Context, state *SessionState) error {
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(state).Error
+	if err != nil {
+		return fmt.Errorf("failed to save session state: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlSessionStore) SessionClosed(ctx context.Context, id string) error {
+	// State is kept so the client can resume on any replica until it expires
+	return nil
+}
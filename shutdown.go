@@ -0,0 +1,82 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// shutdownTimeout bounds how long shutdown waits for in-flight work once its context is cancelled
+const shutdownTimeout = 10 * time.Second
+
+// inflightWork counts running tool calls and background jobs so that shutdown can wait
+// for them before closing the database
+type inflightWork struct {
+	mu    sync.Mutex
+	count int
+	idle  chan struct{}
+}
+
+// begin records the start of a unit of work
+func (w *inflightWork) begin() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.count == 0 {
+		w.idle = make(chan struct{})
+	}
+	w.count++
+}
+
+// end records the end of a unit of work started with begin
+func (w *inflightWork) end() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.count--
+	if w.count == 0 {
+		close(w.idle)
+	}
+}
+
+// wait blocks until no work is running or ctx is done
+func (w *inflightWork) wait(ctx context.Context) error {
+	w.mu.Lock()
+	if w.count == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	idle, count := w.idle, w.count
+	w.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("gave up waiting for %d in-flight operations: %w", count, ctx.Err())
+	}
+}
+
+// inflightMiddleware counts running tool calls; handlers see their context cancelled on shutdown
+func (app *App) inflightMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		app.inflight.begin()
+		defer app.inflight.end()
+		return next(ctx, request)
+	}
+}
+
+// Close closes the connection to the primary database and the databases of the tenants
+func (dbs *DBService) Close() error {
+	closeTenants(dbs.tenants)
+	sqlDB, err := dbs.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database handle: %w", err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package mcpserver
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ToolStat aggregates the calls made to one tool
+type ToolStat struct {
+	Tool          string    `json:"tool"`
+	Calls         int       `json:"calls"`
+	Errors        int       `json:"errors"`
+	TotalDuration int64     `json:"total_duration_ms"`
+	LastCall      time.Time `json:"last_call"`
+}
+
+// ToolStats collects per-tool call counts, error counts and durations
+type ToolStats struct {
+	mu    sync.Mutex
+	tools map[string]*ToolStat
+}
+
+// NewToolStats creates an empty statistics collector
+func NewToolStats() *ToolStats {
+	return &ToolStats{tools: make(map[string]*ToolStat)}
+}
+
+// Record adds one call of tool to the statistics
+func (ts *ToolStats) Record(tool string, duration time.Duration, failed bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	stat, ok := ts.tools[tool]
+	if !ok {
+		stat = &ToolStat{Tool: tool}
+		ts.tools[tool] = stat
+	}
+	stat.Calls++
+	if failed {
+		stat.Errors++
+	}
+	stat.TotalDuration += duration.Milliseconds()
+	stat.LastCall = time.Now()
+}
+
+// Snapshot returns a copy of the statistics sorted by tool name
+func (ts *ToolStats) Snapshot() []ToolStat {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	stats := make([]ToolStat, 0, len(ts.tools))
+	for _, stat := range ts.tools {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Tool < stats[j].Tool })
+	return stats
+}
+
+// SessionInfo describes a connected client session
+type SessionInfo struct {
+	ID          string    `json:"id"`
+	Client      string    `json:"client,omitempty"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// SessionRegistry tracks the client sessions connected to this server
+type SessionRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*SessionInfo
+}
+
+// NewSessionRegistry creates an empty session registry
+func NewSessionRegistry() *SessionRegistry {
+	return &SessionRegistry{sessions: make(map[string]*SessionInfo)}
+}
+
+// onRegister records a new session; it is registered as an OnRegisterSession hook
+func (sr *SessionRegistry) onRegister(ctx context.Context, session server.ClientSession) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.sessions[session.SessionID()] = &SessionInfo{ID: session.SessionID(), ConnectedAt: time.Now()}
+}
+
+// onUnregister forgets a session; it is registered as an OnUnregisterSession hook
+func (sr *SessionRegistry) onUnregister(ctx context.Context, session server.ClientSession) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	delete(sr.sessions, session.SessionID())
+}
+
+// afterInitialize records the client name of a session; it is registered as an AfterInitialize hook
+func (sr *SessionRegistry) afterInitialize(ctx context.Context, id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
+	sessionID := sessionID(ctx)
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if info, ok := sr.sessions[sessionID]; ok {
+		info.Client = message.Params.ClientInfo.Name + " " + message.Params.ClientInfo.Version
+	}
+}
+
+// Snapshot returns the connected sessions ordered by connection time
+func (sr *SessionRegistry) Snapshot() []SessionInfo {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	sessions := make([]SessionInfo, 0, len(sr.sessions))
+	for _, info := range sr.sessions {
+		sessions = append(sessions, *info)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ConnectedAt.Before(sessions[j].ConnectedAt) })
+	return sessions
+}
@@ -0,0 +1,152 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// StockLevel is the quantity of a product, or of one of its variants, in stock
+type StockLevel struct {
+	ProductID uint   `json:"product_id"`
+	Code      string `json:"code"`
+	VariantID uint   `json:"variant_id,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Stock     int    `json:"stock"`
+	// Variants lists the stock of the variants of the product, whose total is VariantStock
+	Variants     []VariantStock `json:"variants,omitempty"`
+	VariantStock *int           `json:"variant_stock,omitempty"`
+}
+
+// StockAdjustment is the result of the adjust_stock tool
+type StockAdjustment struct {
+	StockLevel
+	Previous int `json:"previous"`
+	Delta    int `json:"delta"`
+}
+
+// AdjustStock adds delta, which may be negative, to the stock of the product with the given
+// id and returns the product with the stock it had before. A delta that would take the stock
+// below zero fails without changing it; concurrent adjustments are applied one after the
+// other.
+func (dbs *DBService) AdjustStock(ctx context.Context, id uint, delta int) (*Product, int, error) {
+	var previous int
+	product, err := dbs.UpdateProduct(ctx, id, func(p *Product) error {
+		previous = p.Stock
+		if p.Stock+delta < 0 {
+			return fmt.Errorf("%w: product %d has %d in stock, cannot remove %d", ErrFailedPrecondition, id, p.Stock, -delta)
+		}
+		p.Stock += delta
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return product, previous, nil
+}
+
+// getStockHandler handles the get_stock tool request
+func (app *App) getStockHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	_, hasID := args["id"]
+	code := request.GetString("code", "")
+	if hasID == (code != "") {
+		return newToolError(CodeInvalidArgument, "provide either id or code"), nil
+	}
+
+	var id int
+	if hasID {
+		var err error
+		if id, err = request.RequireInt("id"); err != nil {
+			return argumentError("id", err), nil
+		}
+		if id <= 0 {
+			return toolErrorResult(invalidField("id", "must be a positive integer"))
+		}
+	}
+
+	product, err := app.dbService.GetProduct(ctx, uint(id), code)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	variants, err := app.dbService.ListVariants(ctx, product.ID)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	level := StockLevel{ProductID: product.ID, Code: product.Code, Stock: product.Stock}
+	if len(variants) > 0 {
+		level.Variants = variantStocks(variants)
+		total := 0
+		for _, v := range variants {
+			total += v.Stock
+		}
+		level.VariantStock = &total
+	}
+	jsonData, err := json.MarshalIndent(level, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stock level to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// adjustStockHandler handles the adjust_stock tool request
+func (app *App) adjustStockHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+
+	delta, err := request.RequireInt("delta")
+	if err != nil {
+		return argumentError("delta", err), nil
+	}
+	if delta == 0 {
+		return toolErrorResult(invalidField("delta", "must not be zero"))
+	}
+
+	var adjustment StockAdjustment
+	if _, ok := request.GetArguments()["variant_id"]; ok {
+		variantID, err := request.RequireInt("variant_id")
+		if err != nil {
+			return argumentError("variant_id", err), nil
+		}
+		if variantID <= 0 {
+			return toolErrorResult(invalidField("variant_id", "must be a positive integer"))
+		}
+		product, err := app.dbService.GetProduct(ctx, uint(id), "")
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		variant, previous, err := app.dbService.AdjustVariantStock(ctx, product.ID, uint(variantID), delta)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		adjustment = StockAdjustment{
+			StockLevel: StockLevel{ProductID: product.ID, Code: product.Code, VariantID: variant.ID, SKU: variant.SKU, Stock: variant.Stock},
+			Previous:   previous,
+			Delta:      delta,
+		}
+	} else {
+		product, previous, err := app.dbService.AdjustStock(ctx, uint(id), delta)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		adjustment = StockAdjustment{
+			StockLevel: StockLevel{ProductID: product.ID, Code: product.Code, Stock: product.Stock},
+			Previous:   previous,
+			Delta:      delta,
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(adjustment, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stock adjustment to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,375 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ErrPromptNotFound is returned when no stored prompt has the requested name
+var ErrPromptNotFound = fmt.Errorf("prompt %w", ErrNotFound)
+
+// StoredPromptArgument declares an argument of a stored prompt
+type StoredPromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// StoredPrompt is a prompt kept in the database and managed with the create_prompt and
+// update_prompt tools. Its template is a Go text template executed with the arguments of
+// the prompt, e.g. "Review the prices of the {{.category}} products"; the resources it lists
+// are embedded before the instruction, as for the built-in prompts. Stored prompts live in
+// the configured database, whichever tenant manages them, since prompts are server-wide.
+type StoredPrompt struct {
+	ID          uint                   `gorm:"primaryKey" json:"id"`
+	Name        string                 `gorm:"uniqueIndex" json:"name"`
+	Description string                 `json:"description"`
+	Template    string                 `json:"template"`
+	Arguments   []StoredPromptArgument `gorm:"serializer:json" json:"arguments"`
+	Resources   []string               `gorm:"serializer:json" json:"resources"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+// parseTemplate parses the template of the prompt; arguments not given are empty
+func (p *StoredPrompt) parseTemplate() (*template.Template, error) {
+	return template.New(p.Name).Option("missingkey=zero").Parse(p.Template)
+}
+
+// definition returns the prompt definition registered for the stored prompt
+func (p *StoredPrompt) definition() (promptDefinition, error) {
+	tmpl, err := p.parseTemplate()
+	if err != nil {
+		return promptDefinition{}, err
+	}
+	arguments := make([]mcp.PromptOption, len(p.Arguments))
+	for i, arg := range p.Arguments {
+		options := []mcp.ArgumentOption{mcp.ArgumentDescription(arg.Description)}
+		if arg.Required {
+			options = append(options, mcp.RequiredArgument())
+		}
+		arguments[i] = mcp.WithArgument(arg.Name, options...)
+	}
+	required := make([]string, 0, len(p.Arguments))
+	for _, arg := range p.Arguments {
+		if arg.Required {
+			required = append(required, arg.Name)
+		}
+	}
+	resources := slices.Clone(p.Resources)
+
+	return promptDefinition{
+		Name:        p.Name,
+		Description: p.Description,
+		Arguments:   arguments,
+		Build: func(ctx context.Context, dbs *DBService, args map[string]string) (string, []string, error) {
+			var fields []FieldError
+			for _, name := range required {
+				if strings.TrimSpace(args[name]) == "" {
+					fields = append(fields, FieldError{Field: name, Message: "is required"})
+				}
+			}
+			if len(fields) > 0 {
+				return "", nil, &ValidationError{Fields: fields}
+			}
+			var b bytes.Buffer
+			if err := tmpl.Execute(&b, args); err != nil {
+				return "", nil, fmt.Errorf("failed to render prompt %s: %w", p.Name, err)
+			}
+			return b.String(), resources, nil
+		},
+	}, nil
+}
+
+// validateStoredPrompt checks the fields of a stored prompt to be saved
+func validateStoredPrompt(p *StoredPrompt) error {
+	var fields []FieldError
+	if !toolNamePattern.MatchString(p.Name) {
+		fields = append(fields, FieldError{Field: "name", Message: fmt.Sprintf("must match %s", toolNamePattern)})
+	}
+	if strings.TrimSpace(p.Description) == "" {
+		fields = append(fields, FieldError{Field: "description", Message: "must not be empty"})
+	}
+	if strings.TrimSpace(p.Template) == "" {
+		fields = append(fields, FieldError{Field: "template", Message: "must not be empty"})
+	} else if _, err := p.parseTemplate(); err != nil {
+		fields = append(fields, FieldError{Field: "template", Message: fmt.Sprintf("is not a valid template: %v", err)})
+	}
+	seen := make(map[string]bool)
+	for i, arg := range p.Arguments {
+		name := fmt.Sprintf("arguments[%d].name", i)
+		switch {
+		case !toolNamePattern.MatchString(arg.Name):
+			fields = append(fields, FieldError{Field: name, Message: fmt.Sprintf("must match %s", toolNamePattern)})
+		case seen[arg.Name]:
+			fields = append(fields, FieldError{Field: name, Message: fmt.Sprintf("duplicates argument %s", arg.Name)})
+		}
+		seen[arg.Name] = true
+	}
+	for i, resource := range p.Resources {
+		if u, err := url.Parse(resource); err != nil || u.Scheme == "" {
+			fields = append(fields, FieldError{Field: fmt.Sprintf("resources[%d]", i), Message: "must be a resource URI such as products://list"})
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// isBuiltinPrompt reports whether name is the name of a built-in prompt
+func isBuiltinPrompt(name string) bool {
+	return slices.ContainsFunc(promptDefinitions, func(def promptDefinition) bool { return def.Name == name })
+}
+
+// prompts returns the configured database for stored prompts, which ignore tenants
+func (dbs *DBService) prompts(ctx context.Context) *gorm.DB {
+	return dbs.db.WithContext(ctx).Clauses(dbresolver.Write)
+}
+
+// StoredPrompts returns the stored prompts ordered by name
+func (dbs *DBService) StoredPrompts(ctx context.Context) ([]StoredPrompt, error) {
+	var prompts []StoredPrompt
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.prompts(ctx).Order("name").Find(&prompts).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve stored prompts: %w", err)
+	}
+	return prompts, nil
+}
+
+// CreatePrompt inserts a new stored prompt; names must be unique and differ from those of
+// the built-in prompts
+func (dbs *DBService) CreatePrompt(ctx context.Context, prompt *StoredPrompt) error {
+	if err := validateStoredPrompt(prompt); err != nil {
+		return err
+	}
+	if isBuiltinPrompt(prompt.Name) {
+		return fmt.Errorf("%w: %s is a built-in prompt", ErrConflict, prompt.Name)
+	}
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.prompts(ctx).Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&StoredPrompt{}).Where("name = ?", prompt.Name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: prompt %s already exists", ErrConflict, prompt.Name)
+			}
+
+			if err := tx.Create(prompt).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, auditPrompt, prompt.ID, auditCreate, nil, prompt)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create prompt: %w", err)
+	}
+	return nil
+}
+
+// UpdatePrompt applies changes to the stored prompt with the given name and saves it
+func (dbs *DBService) UpdatePrompt(ctx context.Context, name string, changes func(p *StoredPrompt)) (*StoredPrompt, error) {
+	var prompt StoredPrompt
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		prompt = StoredPrompt{}
+		return dbs.prompts(ctx).Transaction(func(tx *gorm.DB) error {
+			result := tx.Where("name = ?", name).Limit(1).Find(&prompt)
+			if result.Error != nil {
+				return fmt.Errorf("failed to retrieve prompt: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+			}
+			before := prompt
+
+			changes(&prompt)
+			if err := validateStoredPrompt(&prompt); err != nil {
+				return err
+			}
+			if err := tx.Save(&prompt).Error; err != nil {
+				return fmt.Errorf("failed to update prompt: %w", err)
+			}
+			return recordAudit(tx, auditPrompt, prompt.ID, auditUpdate, &before, &prompt)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+// addStoredPrompts registers the prompts stored in the database. A prompt whose template
+// no longer parses is skipped with a warning rather than failing startup.
+func (app *App) addStoredPrompts(ctx context.Context) {
+	prompts, err := app.dbService.StoredPrompts(ctx)
+	if err != nil {
+		slog.Warn("Failed to load stored prompts", "error", err)
+		return
+	}
+	for i := range prompts {
+		if err := app.registerPrompt(&prompts[i]); err != nil {
+			slog.Warn("Skipping stored prompt", "name", prompts[i].Name, "error", err)
+		}
+	}
+}
+
+// registerPrompt adds or replaces the stored prompt on the server, which notifies clients
+// that the list of prompts changed
+func (app *App) registerPrompt(p *StoredPrompt) error {
+	def, err := p.definition()
+	if err != nil {
+		return err
+	}
+	app.addPrompt(app.server, def)
+	return nil
+}
+
+// requestPromptArguments decodes the arguments argument of create_prompt and update_prompt
+func requestPromptArguments(raw any) ([]StoredPromptArgument, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, invalidField("arguments", err.Error())
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	arguments := []StoredPromptArgument{}
+	if err := decoder.Decode(&arguments); err != nil {
+		return nil, invalidField("arguments", fmt.Sprintf("must be a list of objects with name, description and required: %v", err))
+	}
+	return arguments, nil
+}
+
+// requestPromptResources decodes the resources argument of create_prompt and update_prompt
+func requestPromptResources(raw any) ([]string, error) {
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, invalidField("resources", "must be a list of resource URIs")
+	}
+	resources := make([]string, len(items))
+	for i, item := range items {
+		if resources[i], ok = item.(string); !ok {
+			return nil, invalidField(fmt.Sprintf("resources[%d]", i), "must be a string")
+		}
+	}
+	return resources, nil
+}
+
+// promptResult renders a stored prompt as the result of a tool
+func promptResult(prompt *StoredPrompt) (*mcp.CallToolResult, error) {
+	jsonData, err := json.MarshalIndent(prompt, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal prompt to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// createPromptHandler handles the create_prompt tool request
+func (app *App) createPromptHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := request.RequireString("name")
+	if err != nil {
+		return argumentError("name", err), nil
+	}
+	description, err := request.RequireString("description")
+	if err != nil {
+		return argumentError("description", err), nil
+	}
+	text, err := request.RequireString("template")
+	if err != nil {
+		return argumentError("template", err), nil
+	}
+
+	prompt := &StoredPrompt{
+		Name:        strings.TrimSpace(name),
+		Description: strings.TrimSpace(description),
+		Template:    text,
+		Arguments:   []StoredPromptArgument{},
+		Resources:   []string{},
+	}
+	args := request.GetArguments()
+	if raw, ok := args["arguments"]; ok {
+		if prompt.Arguments, err = requestPromptArguments(raw); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+	if raw, ok := args["resources"]; ok {
+		if prompt.Resources, err = requestPromptResources(raw); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+
+	if err := app.dbService.CreatePrompt(ctx, prompt); err != nil {
+		return toolErrorResult(err)
+	}
+	if err := app.registerPrompt(prompt); err != nil {
+		return nil, fmt.Errorf("failed to register prompt: %w", err)
+	}
+	return promptResult(prompt)
+}
+
+// updatePromptHandler handles the update_prompt tool request
+func (app *App) updatePromptHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := request.RequireString("name")
+	if err != nil {
+		return argumentError("name", err), nil
+	}
+	name = strings.TrimSpace(name)
+	if isBuiltinPrompt(name) {
+		return toolErrorResult(fmt.Errorf("%w: %s is a built-in prompt and cannot be changed", ErrFailedPrecondition, name))
+	}
+
+	args := request.GetArguments()
+	var changes []func(p *StoredPrompt)
+	if _, ok := args["description"]; ok {
+		description := strings.TrimSpace(request.GetString("description", ""))
+		changes = append(changes, func(p *StoredPrompt) { p.Description = description })
+	}
+	if _, ok := args["template"]; ok {
+		text := request.GetString("template", "")
+		changes = append(changes, func(p *StoredPrompt) { p.Template = text })
+	}
+	if raw, ok := args["arguments"]; ok {
+		arguments, err := requestPromptArguments(raw)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		changes = append(changes, func(p *StoredPrompt) { p.Arguments = arguments })
+	}
+	if raw, ok := args["resources"]; ok {
+		resources, err := requestPromptResources(raw)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		changes = append(changes, func(p *StoredPrompt) { p.Resources = resources })
+	}
+	if len(changes) == 0 {
+		return newToolError(CodeInvalidArgument, "nothing to update: provide description, template, arguments and/or resources"), nil
+	}
+
+	prompt, err := app.dbService.UpdatePrompt(ctx, name, func(p *StoredPrompt) {
+		for _, change := range changes {
+			change(p)
+		}
+	})
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	if err := app.registerPrompt(prompt); err != nil {
+		return nil, fmt.Errorf("failed to register prompt: %w", err)
+	}
+	return promptResult(prompt)
+}
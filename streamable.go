@@ -0,0 +1,333 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// streamablePath is the endpoint of the Streamable HTTP transport
+const streamablePath = "/mcp"
+
+// maxReplayEvents is how many events of a session's listening stream are kept for resumption
+const maxReplayEvents = 100
+
+// streamSweepInterval is how often idle Streamable HTTP sessions are expired
+const streamSweepInterval = time.Minute
+
+// bufferedEvent is an event sent on a listening stream, kept so that it can be replayed
+type bufferedEvent struct {
+	ID    int64
+	Event []byte
+}
+
+// streamSession is a session of the Streamable HTTP transport
+type streamSession struct {
+	LastSeen    time.Time
+	NextEventID int64
+	Events      []bufferedEvent
+}
+
+// streamSessionManager issues and tracks the session ids of the Streamable HTTP transport.
+// A session lasts from initialize until the client deletes it or it is idle for longer than
+// the session TTL, independently of the client's listening streams; session state is only
+// released when it ends. It implements server.SessionIdManager.
+type streamSessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]*streamSession
+	idleTTL  time.Duration
+	// onClose are run when a session ends, in place of the unregister hooks
+	onClose []server.OnUnregisterSessionHookFunc
+}
+
+// newStreamSessionManager creates a session manager expiring sessions idle for longer than idleTTL
+func newStreamSessionManager(idleTTL time.Duration) *streamSessionManager {
+	return &streamSessionManager{sessions: make(map[string]*streamSession), idleTTL: idleTTL}
+}
+
+// Generate starts a new session and returns its id
+func (m *streamSessionManager) Generate() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(fmt.Sprintf("failed to generate session id: %v", err))
+	}
+	id := "mcp-session-" + hex.EncodeToString(buf)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[id] = &streamSession{LastSeen: time.Now()}
+	return id
+}
+
+// Validate reports unknown, deleted and expired sessions as terminated, so that the
+// client starts a new one, and otherwise records activity on the session
+func (m *streamSessionManager) Validate(sessionID string) (isTerminated bool, err error) {
+	if sessionID == "" {
+		return false, errors.New("missing session id")
+	}
+
+	m.mu.Lock()
+	session, ok := m.sessions[sessionID]
+	if ok && m.expired(session, time.Now()) {
+		delete(m.sessions, sessionID)
+		m.mu.Unlock()
+		m.close(context.Background(), sessionID)
+		return true, nil
+	}
+	defer m.mu.Unlock()
+	if !ok {
+		return true, nil
+	}
+	session.LastSeen = time.Now()
+	return false, nil
+}
+
+// Terminate ends a session at the client's request
+func (m *streamSessionManager) Terminate(sessionID string) (isNotAllowed bool, err error) {
+	m.mu.Lock()
+	_, ok := m.sessions[sessionID]
+	delete(m.sessions, sessionID)
+	m.mu.Unlock()
+
+	if ok {
+		m.close(context.Background(), sessionID)
+	}
+	return false, nil
+}
+
+// expired reports whether session has been idle for longer than the TTL
+func (m *streamSessionManager) expired(session *streamSession, now time.Time) bool {
+	return m.idleTTL > 0 && now.Sub(session.LastSeen) > m.idleTTL
+}
+
+// isOpen reports whether id is a session that has not ended
+func (m *streamSessionManager) isOpen(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.sessions[id]
+	return ok
+}
+
+// close runs the end-of-session hooks for id
+func (m *streamSessionManager) close(ctx context.Context, id string) {
+	slog.Debug("Streamable HTTP session ended", "session", id)
+	for _, hook := range m.onClose {
+		hook(ctx, endedSession(id))
+	}
+}
+
+// unlessOpen wraps an unregister hook so that it is skipped while the session is still
+// open, as a Streamable HTTP client may close its listening stream and open another
+func (m *streamSessionManager) unlessOpen(hook server.OnUnregisterSessionHookFunc) server.OnUnregisterSessionHookFunc {
+	return func(ctx context.Context, session server.ClientSession) {
+		if m.isOpen(session.SessionID()) {
+			return
+		}
+		hook(ctx, session)
+	}
+}
+
+// Start expires idle sessions every streamSweepInterval until ctx is cancelled
+func (m *streamSessionManager) Start(ctx context.Context) {
+	if m.idleTTL <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(streamSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, id := range m.sweep(now) {
+					m.close(ctx, id)
+				}
+			}
+		}
+	}()
+}
+
+// sweep removes the sessions that have expired by now and returns their ids
+func (m *streamSessionManager) sweep(now time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []string
+	for id, session := range m.sessions {
+		if m.expired(session, now) {
+			delete(m.sessions, id)
+			expired = append(expired, id)
+		}
+	}
+	return expired
+}
+
+// record assigns the next event id of a session to event and keeps it for replay;
+// ok is false if the session has ended
+func (m *streamSessionManager) record(sessionID string, event []byte) (id int64, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[sessionID]
+	if !ok {
+		return 0, false
+	}
+	session.NextEventID++
+	session.Events = append(session.Events, bufferedEvent{ID: session.NextEventID, Event: event})
+	if len(session.Events) > maxReplayEvents {
+		session.Events = session.Events[len(session.Events)-maxReplayEvents:]
+	}
+	return session.NextEventID, true
+}
+
+// replay returns the kept events of a session sent after the event with id after
+func (m *streamSessionManager) replay(sessionID string, after int64) []bufferedEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	var events []bufferedEvent
+	for _, event := range session.Events {
+		if event.ID > after {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// endedSession stands in for the client session passed to hooks when a Streamable HTTP session ends
+type endedSession string
+
+func (s endedSession) Initialize()                                         {}
+func (s endedSession) Initialized() bool                                   { return false }
+func (s endedSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return nil }
+func (s endedSession) SessionID() string                                   { return string(s) }
+
+// streamWriter wraps the response writer of the Streamable HTTP transport. It rewrites
+// recorded infrastructure errors in JSON and event-stream responses and, on a session's
+// listening stream, numbers the events and replays those after Last-Event-ID.
+type streamWriter struct {
+	http.ResponseWriter
+	mapper *rpcErrorMapper
+	// sessions and sessionID are set on listening streams only
+	sessions  *streamSessionManager
+	sessionID string
+	// replayAfter is the Last-Event-ID of a resumed stream
+	replayAfter *int64
+	// requestSession is the Mcp-Session-Id of the request
+	requestSession string
+}
+
+// session returns the id of the session the response is written to, which the library
+// generates and returns in the response headers for initialize requests
+func (sw *streamWriter) session() string {
+	if sw.requestSession != "" {
+		return sw.requestSession
+	}
+	return sw.Header().Get(server.HeaderKeySessionID)
+}
+
+// WriteHeader implements http.ResponseWriter; a resumed stream replays the missed events
+// right after its headers
+func (sw *streamWriter) WriteHeader(code int) {
+	sw.ResponseWriter.WriteHeader(code)
+	if code != http.StatusOK || sw.replayAfter == nil {
+		return
+	}
+	after := *sw.replayAfter
+	sw.replayAfter = nil
+	for _, event := range sw.sessions.replay(sw.sessionID, after) {
+		fmt.Fprintf(sw.ResponseWriter, "id: %d\n%s", event.ID, event.Event)
+	}
+}
+
+// Write implements http.ResponseWriter; each call carries a complete JSON response or event
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	data, isEvent := bytes.CutPrefix(p, []byte(sseMessagePrefix))
+	if !isEvent {
+		rewritten, ok := sw.mapper.rewrite(sw.session(), bytes.TrimRight(p, "\n"))
+		if !ok {
+			return sw.ResponseWriter.Write(p)
+		}
+		if _, err := sw.ResponseWriter.Write(append(rewritten, '\n')); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	message := bytes.TrimRight(data, "\n")
+	if rewritten, ok := sw.mapper.rewrite(sw.session(), message); ok {
+		message = rewritten
+	}
+	event := append(append([]byte(sseMessagePrefix), message...), "\n\n"...)
+	if sw.sessions != nil {
+		if id, ok := sw.sessions.record(sw.sessionID, event); ok {
+			event = append([]byte("id: "+strconv.FormatInt(id, 10)+"\n"), event...)
+		}
+	}
+	if _, err := sw.ResponseWriter.Write(event); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush implements http.Flusher, which event streams require
+func (sw *streamWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// streamableHandler serves remote clients over the Streamable HTTP transport: requests are
+// posted to /mcp with the Mcp-Session-Id returned by initialize, GET /mcp opens a listening
+// stream that can be resumed with Last-Event-ID, and DELETE /mcp ends the session.
+// Listening streams of unknown sessions are rejected and responses go through a streamWriter.
+func (app *App) streamableHandler(s *server.MCPServer) http.Handler {
+	streamable := server.NewStreamableHTTPServer(s,
+		server.WithEndpointPath(streamablePath),
+		server.WithSessionIdManager(app.streamSessions),
+		server.WithHeartbeatInterval(30*time.Second),
+	)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.clientRequests.deliverHTTP(w, r) {
+			return
+		}
+		sessionID := r.Header.Get(server.HeaderKeySessionID)
+		sw := &streamWriter{ResponseWriter: w, mapper: app.rpcErrors, requestSession: sessionID}
+		if r.Method == http.MethodGet && sessionID != "" {
+			if terminated, err := app.streamSessions.Validate(sessionID); err != nil || terminated {
+				http.Error(w, "Session terminated", http.StatusNotFound)
+				return
+			}
+			sw.sessions = app.streamSessions
+			sw.sessionID = sessionID
+			if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+				after, err := strconv.ParseInt(lastEventID, 10, 64)
+				if err != nil {
+					http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+					return
+				}
+				sw.replayAfter = &after
+			}
+		}
+		streamable.ServeHTTP(sw, r)
+	})
+}
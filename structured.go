@@ -0,0 +1,197 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"gopkg.in/yaml.v3"
+)
+
+// structuredMIMETypes maps the output formats that re-encode JSON payloads to their MIME types
+var structuredMIMETypes = map[string]string{
+	formatYAML: "application/yaml",
+	formatTOML: "application/toml",
+}
+
+// tomlRootKey holds a payload that is not an object, as a TOML document must be a table
+const tomlRootKey = "items"
+
+// decodeGeneric decodes JSON into maps, slices and scalars, keeping integers as int64
+// so that they are not re-encoded as floats
+func decodeGeneric(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("trailing data after JSON value")
+	}
+	return normalizeNumbers(v), nil
+}
+
+// normalizeNumbers replaces the json.Number values in v by int64 or float64
+func normalizeNumbers(v any) any {
+	switch val := v.(type) {
+	case json.Number:
+		if n, err := strconv.ParseInt(string(val), 10, 64); err == nil {
+			return n
+		}
+		f, _ := val.Float64()
+		return f
+	case map[string]any:
+		for k, item := range val {
+			val[k] = normalizeNumbers(item)
+		}
+	case []any:
+		for i, item := range val {
+			val[i] = normalizeNumbers(item)
+		}
+	}
+	return v
+}
+
+// convertJSON re-encodes a JSON object or array as YAML or TOML. ok is false if data is
+// anything else, such as plain text or a bare number.
+func convertJSON(data []byte, format string) (converted []byte, ok bool, err error) {
+	v, err := decodeGeneric(data)
+	if err != nil {
+		return nil, false, nil
+	}
+	switch v.(type) {
+	case map[string]any, []any:
+	default:
+		return nil, false, nil
+	}
+
+	switch format {
+	case formatYAML:
+		converted, err = yaml.Marshal(v)
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to marshal result to YAML: %w", err)
+		}
+	case formatTOML:
+		if _, isObject := v.(map[string]any); !isObject {
+			v = map[string]any{tomlRootKey: v}
+		}
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+			return nil, true, fmt.Errorf("failed to marshal result to TOML: %w", err)
+		}
+		converted = buf.Bytes()
+	default:
+		return data, true, nil
+	}
+	return converted, true, nil
+}
+
+// structuredFormat returns the preferred format of the session if it re-encodes JSON payloads, else ""
+func (app *App) structuredFormat(ctx context.Context, requested string) (string, error) {
+	format := requested
+	if format == "" {
+		state, err := app.sessions.Load(ctx, sessionID(ctx))
+		if err != nil {
+			return "", err
+		}
+		format = state.Preferences[outputFormatPreference]
+	}
+	if _, ok := structuredMIMETypes[format]; !ok {
+		return "", nil
+	}
+	return format, nil
+}
+
+// formatMiddleware re-encodes the JSON text of successful tool results as YAML or TOML
+// when the output_format argument or the session preference asks for it. Error results
+// stay JSON so that clients can always read their codes.
+func (app *App) formatMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		requested := request.GetString(outputFormatArg, "")
+		if requested != "" && !validOutputFormat(requested) {
+			return toolErrorResult(invalidOutputFormat())
+		}
+
+		result, err := next(ctx, request)
+		if err != nil || result == nil || result.IsError {
+			return result, err
+		}
+		format, err := app.structuredFormat(ctx, requested)
+		if err != nil || format == "" {
+			return result, err
+		}
+
+		for i, content := range result.Content {
+			text, ok := content.(mcp.TextContent)
+			if !ok {
+				continue
+			}
+			converted, ok, err := convertJSON([]byte(text.Text), format)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				text.Text = string(converted)
+				result.Content[i] = text
+			}
+		}
+		return result, nil
+	}
+}
+
+// resourceReader is the signature shared by resource and resource template handlers
+type resourceReader = func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error)
+
+// formatResource wraps the handler of a JSON resource so that its contents are re-encoded
+// as YAML or TOML when the output_format query parameter or the session preference asks for it.
+// The handler reads the database of the tenant selected by the tenant query parameter or
+// the session preference.
+func (app *App) formatResource(next resourceReader) resourceReader {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		var requested, tenant string
+		if uri, err := url.Parse(request.Params.URI); err == nil {
+			requested = uri.Query().Get(outputFormatArg)
+			tenant = uri.Query().Get(tenantArg)
+		}
+		if requested != "" && !validOutputFormat(requested) {
+			return nil, resourceError(invalidOutputFormat())
+		}
+		ctx, err := app.tenantContext(ctx, tenant)
+		if err != nil {
+			return nil, resourceError(err)
+		}
+
+		contents, err := next(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		format, err := app.structuredFormat(ctx, requested)
+		if err != nil || format == "" {
+			return contents, err
+		}
+
+		for i, content := range contents {
+			text, ok := content.(mcp.TextResourceContents)
+			if !ok || text.MIMEType != "application/json" {
+				continue
+			}
+			converted, ok, err := convertJSON([]byte(text.Text), format)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				text.Text = string(converted)
+				text.MIMEType = structuredMIMETypes[format]
+				contents[i] = text
+			}
+		}
+		return contents, nil
+	}
+}
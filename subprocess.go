@@ -0,0 +1,128 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Limits applied to command tools that do not set their own
+const (
+	defaultCommandTimeout        = 30 * time.Second
+	defaultCommandMaxOutputBytes = 1 << 20
+)
+
+// errOutputLimit is returned by limitedBuffer once the output limit is exceeded
+var errOutputLimit = errors.New("output limit exceeded")
+
+// limitedBuffer collects output up to a limit and fails writes beyond it, which
+// makes the command fail rather than buffering unbounded output
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if lb.buf.Len()+len(p) > lb.limit {
+		lb.exceeded = true
+		return 0, errOutputLimit
+	}
+	return lb.buf.Write(p)
+}
+
+// truncatedBuffer keeps the first limit bytes of output and silently drops the rest, so that
+// diagnostics do not fail a command
+type truncatedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (tb *truncatedBuffer) Write(p []byte) (int, error) {
+	if room := tb.limit - tb.buf.Len(); room > 0 {
+		tb.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// runCommand runs a command tool with the JSON-encoded arguments on stdin and returns its stdout
+func runCommand(ctx context.Context, def DeclarativeTool, params map[string]any) ([]byte, error) {
+	timeout := def.Timeout
+	if timeout == 0 {
+		timeout = defaultCommandTimeout
+	}
+	limit := def.MaxOutputBytes
+	if limit == 0 {
+		limit = defaultCommandMaxOutputBytes
+	}
+
+	input, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode arguments: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, def.Command[0], def.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	stdout := &limitedBuffer{limit: limit}
+	stderr := &truncatedBuffer{limit: 4096}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Do not wait for orphaned children holding the output pipes open
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, fmt.Errorf("command %s timed out after %s", def.Name, timeout)
+	case stdout.exceeded:
+		return nil, fmt.Errorf("command %s wrote more than %d bytes", def.Name, limit)
+	case err != nil:
+		message := strings.TrimSpace(stderr.buf.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, fmt.Errorf("command %s failed: %s", def.Name, message)
+	}
+	return stdout.buf.Bytes(), nil
+}
+
+// commandToolHandler returns the handler running the command of a declarative tool.
+// The command receives the arguments as a JSON object on stdin and must write a JSON
+// value to stdout; a non-zero exit status is reported as a tool error with its stderr.
+func (app *App) commandToolHandler(def DeclarativeTool) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		params, err := declarativeArgs(def, request)
+		if err != nil {
+			return toolErrorResult(err)
+		}
+
+		output, err := runCommand(ctx, def, params)
+		if err != nil {
+			if ctx.Err() != nil {
+				return toolErrorResult(ctx.Err())
+			}
+			return toolErrorResult(err)
+		}
+
+		var result any
+		if err := json.Unmarshal(output, &result); err != nil {
+			return toolErrorResult(fmt.Errorf("command %s did not write valid JSON: %w", def.Name, err))
+		}
+		jsonData, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s result to JSON: %w", def.Name, err)
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	}
+}
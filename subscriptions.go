@@ -0,0 +1,196 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Methods of resource subscriptions, which the MCP library does not handle itself
+const (
+	methodResourcesSubscribe   = "resources/subscribe"
+	methodResourcesUnsubscribe = "resources/unsubscribe"
+)
+
+// productListURI is the URI of the product list resource
+const productListURI = "products://list"
+
+// ResourceSubscriptions holds the resources each session subscribed to and notifies the
+// subscribers when products change. Subscriptions are matched ignoring the query of their
+// URI, so that a subscriber to products://list?category=widgets is notified of any change
+// to the product list, with the URI it subscribed to.
+type ResourceSubscriptions struct {
+	app *App
+
+	mu sync.Mutex
+	// sessions maps session ids to the URIs they subscribed to
+	sessions map[string]map[string]bool
+}
+
+// NewResourceSubscriptions creates an empty set of subscriptions
+func NewResourceSubscriptions(app *App) *ResourceSubscriptions {
+	return &ResourceSubscriptions{app: app, sessions: make(map[string]map[string]bool)}
+}
+
+// onRequest handles resources/subscribe and resources/unsubscribe requests; it is registered
+// as an OnRequestInitialization hook. The library answers these methods as not found, so the
+// response is acknowledged through the error mapper once the subscription is recorded.
+func (rs *ResourceSubscriptions) onRequest(ctx context.Context, id any, message any) error {
+	raw, ok := message.(json.RawMessage)
+	if !ok || id == nil {
+		return nil
+	}
+	var request struct {
+		Method string              `json:"method"`
+		Params mcp.SubscribeParams `json:"params"`
+	}
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return nil
+	}
+	if request.Method != methodResourcesSubscribe && request.Method != methodResourcesUnsubscribe {
+		return nil
+	}
+
+	session := sessionID(ctx)
+	if session == "" {
+		return fmt.Errorf("%s requires a session", request.Method)
+	}
+	if request.Params.URI == "" {
+		return fmt.Errorf("%s requires a uri", request.Method)
+	}
+	if _, err := url.Parse(request.Params.URI); err != nil {
+		return fmt.Errorf("invalid uri %q: %v", request.Params.URI, err)
+	}
+
+	rs.mu.Lock()
+	if request.Method == methodResourcesSubscribe {
+		if rs.sessions[session] == nil {
+			rs.sessions[session] = make(map[string]bool)
+		}
+		rs.sessions[session][request.Params.URI] = true
+	} else {
+		delete(rs.sessions[session], request.Params.URI)
+		if len(rs.sessions[session]) == 0 {
+			delete(rs.sessions, session)
+		}
+	}
+	rs.mu.Unlock()
+
+	slog.Debug("Resource subscription changed", "session", session, "method", request.Method, "uri", request.Params.URI)
+	rs.app.rpcErrors.acknowledge(ctx, id)
+	return nil
+}
+
+// onUnregister drops the subscriptions of a session that ended
+func (rs *ResourceSubscriptions) onUnregister(ctx context.Context, session server.ClientSession) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	delete(rs.sessions, session.SessionID())
+}
+
+// notify sends a resource update notification for each subscription matching one of uris
+func (rs *ResourceSubscriptions) notify(uris ...string) {
+	if rs.app.server == nil {
+		return
+	}
+
+	type delivery struct{ session, uri string }
+	var deliveries []delivery
+	rs.mu.Lock()
+	for session, subscribed := range rs.sessions {
+		for uri := range subscribed {
+			if slices.Contains(uris, subscriptionBase(uri)) {
+				deliveries = append(deliveries, delivery{session, uri})
+			}
+		}
+	}
+	rs.mu.Unlock()
+
+	for _, d := range deliveries {
+		err := rs.app.server.SendNotificationToSpecificClient(d.session, mcp.MethodNotificationResourceUpdated, map[string]any{
+			"uri": d.uri,
+		})
+		if err != nil {
+			slog.Debug("Failed to send resource update", "session", d.session, "uri", d.uri, "error", err)
+		}
+	}
+}
+
+// subscriptionBase returns uri without its query, to match it against changed resources
+func subscriptionBase(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	u.RawQuery = ""
+	u.ForceQuery = false
+	return u.String()
+}
+
+// productChangesKey is the context key of the products changed by a tool call
+type productChangesKey struct{}
+
+// productChanges collects the ids of the products changed by a tool call
+type productChanges struct {
+	mu  sync.Mutex
+	ids []uint
+}
+
+// noteProductChange records in the collector of ctx, if any, that the product id changed
+func noteProductChange(ctx context.Context, id uint) {
+	changes, ok := ctx.Value(productChangesKey{}).(*productChanges)
+	if !ok {
+		return
+	}
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+	if !slices.Contains(changes.ids, id) {
+		changes.ids = append(changes.ids, id)
+	}
+}
+
+// forgetProductChanges discards the changes collected in ctx, for writes that are rolled back
+// on purpose such as dry runs
+func forgetProductChanges(ctx context.Context) {
+	changes, ok := ctx.Value(productChangesKey{}).(*productChanges)
+	if !ok {
+		return
+	}
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+	changes.ids = nil
+}
+
+// middleware notifies the subscribers of the product list and of the changed products once a
+// tool call that changed products succeeds. Changes are collected from the audit log entries
+// written by the call, so every write tool is covered.
+func (rs *ResourceSubscriptions) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		changes := &productChanges{}
+		result, err := next(context.WithValue(ctx, productChangesKey{}, changes), request)
+		if err != nil || result == nil || result.IsError {
+			return result, err
+		}
+
+		changes.mu.Lock()
+		ids := changes.ids
+		changes.mu.Unlock()
+		if len(ids) == 0 {
+			return result, err
+		}
+		uris := []string{productListURI}
+		for _, id := range ids {
+			uris = append(uris, "products://"+strconv.FormatUint(uint64(id), 10))
+		}
+		rs.notify(uris...)
+		return result, err
+	}
+}
@@ -0,0 +1,496 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// suppliersListURI is the URI of the resource listing the contact details of every supplier
+const suppliersListURI = "suppliers://list"
+
+// ErrSupplierNotFound is returned when no supplier has the requested id
+var ErrSupplierNotFound = fmt.Errorf("supplier %w", ErrNotFound)
+
+// Supplier supplies products; products reference their supplier by id. Its contact details
+// are exposed as the suppliers:// resources.
+type Supplier struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex" json:"name"`
+	ContactName string    `json:"contact_name,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	Phone       string    `json:"phone,omitempty"`
+	Address     string    `json:"address,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SupplierSummary is a supplier with the number of products it supplies
+type SupplierSummary struct {
+	Supplier
+	Products int64 `json:"products"`
+}
+
+// SupplierProducts is the result of the products_by_supplier tool
+type SupplierProducts struct {
+	Supplier Supplier `json:"supplier"`
+	Products any      `json:"products"`
+}
+
+// supplierValue returns the supplier id of p, or nil if it has no supplier
+func (p *Product) supplierValue() any {
+	if p.SupplierID == nil {
+		return nil
+	}
+	return *p.SupplierID
+}
+
+// checkSupplier checks that the supplier of a product exists
+func (p *Product) checkSupplier(tx *gorm.DB) error {
+	if p.SupplierID == nil {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&Supplier{}).Where("id = ?", *p.SupplierID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to look up supplier: %w", err)
+	}
+	if count == 0 {
+		return invalidField("supplier_id", fmt.Sprintf("unknown supplier %d; create it with create_supplier first", *p.SupplierID))
+	}
+	return nil
+}
+
+// supplierRef returns the supplier reference of a product for id; zero leaves the product
+// without a supplier
+func supplierRef(id int) *uint {
+	if id == 0 {
+		return nil
+	}
+	ref := uint(id)
+	return &ref
+}
+
+// validateSupplier checks the fields of a supplier to be saved
+func validateSupplier(s *Supplier) error {
+	var fields []FieldError
+	if s.Name == "" {
+		fields = append(fields, FieldError{Field: "name", Message: "must not be empty"})
+	}
+	if s.Email != "" && !validEmail(s.Email) {
+		fields = append(fields, FieldError{Field: "email", Message: emailFormatMessage})
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// checkSupplierName checks in tx that no other supplier than s has its name
+func checkSupplierName(tx *gorm.DB, s *Supplier) error {
+	var others []Supplier
+	if err := tx.Where("name = ? AND id <> ?", s.Name, s.ID).Limit(1).Find(&others).Error; err != nil {
+		return err
+	}
+	if len(others) > 0 {
+		return fmt.Errorf("%w: supplier %q already exists with id %d", ErrConflict, s.Name, others[0].ID)
+	}
+	return nil
+}
+
+// CreateSupplier inserts a new supplier; names must be unique
+func (dbs *DBService) CreateSupplier(ctx context.Context, supplier *Supplier) error {
+	if err := validateSupplier(supplier); err != nil {
+		return err
+	}
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := checkSupplierName(tx, supplier); err != nil {
+				return err
+			}
+			if err := tx.Create(supplier).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, auditSupplier, supplier.ID, auditCreate, nil, supplier)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create supplier: %w", err)
+	}
+	return nil
+}
+
+// UpdateSupplier applies changes to the supplier with the given id and returns it
+func (dbs *DBService) UpdateSupplier(ctx context.Context, id uint, changes func(s *Supplier)) (*Supplier, error) {
+	var supplier Supplier
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		supplier = Supplier{}
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			result := tx.Limit(1).Find(&supplier, id)
+			if result.Error != nil {
+				return fmt.Errorf("failed to retrieve supplier: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: id %d", ErrSupplierNotFound, id)
+			}
+			before := supplier
+
+			changes(&supplier)
+			if err := validateSupplier(&supplier); err != nil {
+				return err
+			}
+			if err := checkSupplierName(tx, &supplier); err != nil {
+				return err
+			}
+			if err := tx.Save(&supplier).Error; err != nil {
+				return fmt.Errorf("failed to update supplier: %w", err)
+			}
+			return recordAudit(tx, auditSupplier, supplier.ID, auditUpdate, &before, &supplier)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &supplier, nil
+}
+
+// DeleteSupplier deletes the supplier with the given id and returns it as it was. A supplier
+// still supplying products cannot be deleted; soft-deleted products lose their supplier.
+func (dbs *DBService) DeleteSupplier(ctx context.Context, id uint) (*Supplier, error) {
+	var supplier Supplier
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		supplier = Supplier{}
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			result := tx.Limit(1).Find(&supplier, id)
+			if result.Error != nil {
+				return fmt.Errorf("failed to retrieve supplier: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: id %d", ErrSupplierNotFound, id)
+			}
+
+			var count int64
+			if err := tx.Model(&Product{}).Where("supplier_id = ?", id).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to count products: %w", err)
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: supplier %d still supplies %d products; give them another supplier with update_product first", ErrFailedPrecondition, id, count)
+			}
+			err := tx.Unscoped().Model(&Product{}).Where("supplier_id = ?", id).UpdateColumn("supplier_id", nil).Error
+			if err != nil {
+				return fmt.Errorf("failed to unlink deleted products: %w", err)
+			}
+
+			if err := tx.Delete(&supplier).Error; err != nil {
+				return fmt.Errorf("failed to delete supplier: %w", err)
+			}
+			return recordAudit(tx, auditSupplier, supplier.ID, auditDelete, &supplier, nil)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &supplier, nil
+}
+
+// GetSupplier returns the supplier with the given id
+func (dbs *DBService) GetSupplier(ctx context.Context, id uint) (*Supplier, error) {
+	var suppliers []Supplier
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		suppliers = nil
+		return dbs.conn(ctx).Limit(1).Find(&suppliers, id).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve supplier: %w", err)
+	}
+	if len(suppliers) == 0 {
+		return nil, fmt.Errorf("%w: id %d", ErrSupplierNotFound, id)
+	}
+	return &suppliers[0], nil
+}
+
+// ListSuppliers returns every supplier with the number of products it supplies, ordered by name
+func (dbs *DBService) ListSuppliers(ctx context.Context) ([]SupplierSummary, error) {
+	suppliers := []SupplierSummary{}
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.conn(ctx).Model(&Supplier{}).
+			Select("suppliers.*, COUNT(products.id) AS products").
+			Joins("LEFT JOIN products ON products.supplier_id = suppliers.id AND products.deleted_at IS NULL").
+			Group("suppliers.id").
+			Order("suppliers.name").
+			Scan(&suppliers).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve suppliers: %w", err)
+	}
+	return suppliers, nil
+}
+
+// ProductsBySupplier returns a page of the products of the supplier with the given id ordered
+// by id, and the number of such products
+func (dbs *DBService) ProductsBySupplier(ctx context.Context, id uint, limit, offset int) ([]Product, int, error) {
+	var products []Product
+	var total int64
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		db := dbs.conn(ctx).Model(&Product{}).Where("supplier_id = ?", id)
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return err
+		}
+		db = db.Order("id").Offset(offset)
+		if limit > 0 {
+			db = db.Limit(limit)
+		}
+		return db.Find(&products).Error
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve products: %w", err)
+	}
+	return products, int(total), nil
+}
+
+// requestSupplier returns the supplier described by the arguments of a create_supplier request
+func requestSupplier(request mcp.CallToolRequest) *Supplier {
+	return &Supplier{
+		Name:        strings.TrimSpace(request.GetString("name", "")),
+		ContactName: strings.TrimSpace(request.GetString("contact_name", "")),
+		Email:       strings.ToLower(strings.TrimSpace(request.GetString("email", ""))),
+		Phone:       strings.TrimSpace(request.GetString("phone", "")),
+		Address:     strings.TrimSpace(request.GetString("address", "")),
+	}
+}
+
+// supplierResult renders a supplier as the result of a tool
+func supplierResult(supplier *Supplier) (*mcp.CallToolResult, error) {
+	jsonData, err := json.MarshalIndent(supplier, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal supplier to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// createSupplierHandler handles the create_supplier tool request
+func (app *App) createSupplierHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if _, err := request.RequireString("name"); err != nil {
+		return argumentError("name", err), nil
+	}
+
+	supplier := requestSupplier(request)
+	if err := app.dbService.CreateSupplier(ctx, supplier); err != nil {
+		return toolErrorResult(err)
+	}
+	return supplierResult(supplier)
+}
+
+// updateSupplierHandler handles the update_supplier tool request; only the given fields change
+func (app *App) updateSupplierHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+
+	args := request.GetArguments()
+	given := requestSupplier(request)
+	updates := map[string]func(s *Supplier){
+		"name":         func(s *Supplier) { s.Name = given.Name },
+		"contact_name": func(s *Supplier) { s.ContactName = given.ContactName },
+		"email":        func(s *Supplier) { s.Email = given.Email },
+		"phone":        func(s *Supplier) { s.Phone = given.Phone },
+		"address":      func(s *Supplier) { s.Address = given.Address },
+	}
+	var changes []func(s *Supplier)
+	for name, update := range updates {
+		if _, ok := args[name]; ok {
+			changes = append(changes, update)
+		}
+	}
+	if len(changes) == 0 {
+		return newToolError(CodeInvalidArgument, "nothing to update: provide name, contact_name, email, phone and/or address"), nil
+	}
+
+	supplier, err := app.dbService.UpdateSupplier(ctx, uint(id), func(s *Supplier) {
+		for _, change := range changes {
+			change(s)
+		}
+	})
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	return supplierResult(supplier)
+}
+
+// DeleteSupplierResult reports the supplier previewed or deleted by delete_supplier
+type DeleteSupplierResult struct {
+	Supplier          *Supplier  `json:"supplier"`
+	Confirmed         bool       `json:"confirmed"`
+	ConfirmationToken string     `json:"confirmation_token,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	Message           string     `json:"message"`
+}
+
+// deleteSupplierHandler handles the delete_supplier tool request. Without a confirmation
+// token it only previews the supplier and issues a one-time token; deleting requires a
+// second call with the same id and that token.
+func (app *App) deleteSupplierHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return argumentError("id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("id", "must be a positive integer"))
+	}
+
+	var result DeleteSupplierResult
+	if token := request.GetString(confirmationTokenArg, ""); token == "" {
+		supplier, err := app.dbService.GetSupplier(ctx, uint(id))
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		token, expiresAt, err := app.confirmations.issue(ctx, request.Params.Name, request.GetArguments(), []uint{supplier.ID})
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		result = DeleteSupplierResult{
+			Supplier:          supplier,
+			ConfirmationToken: token,
+			ExpiresAt:         &expiresAt,
+			Message:           "Preview only; call again with the same id and this confirmation_token to delete this supplier",
+		}
+	} else {
+		if _, err := app.confirmations.redeem(ctx, request.Params.Name, request.GetArguments(), token); err != nil {
+			return toolErrorResult(err)
+		}
+		supplier, err := app.dbService.DeleteSupplier(ctx, uint(id))
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		result = DeleteSupplierResult{
+			Supplier:  supplier,
+			Confirmed: true,
+			Message:   fmt.Sprintf("Deleted supplier %d", id),
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal delete result to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// listSuppliersHandler handles the list_suppliers tool request
+func (app *App) listSuppliersHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	suppliers, err := app.dbService.ListSuppliers(ctx)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(suppliers, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal suppliers to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// productsBySupplierHandler handles the products_by_supplier tool request
+func (app *App) productsBySupplierHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("supplier_id")
+	if err != nil {
+		return argumentError("supplier_id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("supplier_id", "must be a positive integer"))
+	}
+	fields, err := requestFields(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	requested := request.GetInt("limit", 0)
+	if requested < 0 || requested > maxProductListLimit {
+		return toolErrorResult(invalidField("limit", fmt.Sprintf("must be between 1 and %d", maxProductListLimit)))
+	}
+	var offset int
+	if cursor := request.GetString("cursor", ""); cursor != "" {
+		if offset, err = decodeCursor("cursor", cursor); err != nil {
+			return toolErrorResult(err)
+		}
+	}
+
+	supplier, err := app.dbService.GetSupplier(ctx, uint(id))
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	limits := app.config.Results
+	products, total, err := app.dbService.ProductsBySupplier(ctx, uint(id), limits.fetchLimit(requested), offset)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	data, n, truncation, err := limitResult(limits, products, offset, total, requested, true, renderJSON(func(page []Product) any {
+		projected := make([]any, len(page))
+		for i := range page {
+			projected[i] = projectProduct(&page[i], fields)
+		}
+		return SupplierProducts{Supplier: *supplier, Products: projected}
+	}))
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	if err := app.quotas.AddRows(ctx, n); err != nil {
+		return toolErrorResult(err)
+	}
+	return truncatedToolResult(string(data), truncation)
+}
+
+// suppliersResourceHandler serves suppliers://list, the contact details of every supplier,
+// and suppliers://{id}, those of one supplier
+func (app *App) suppliersResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri, err := url.Parse(request.Params.URI)
+	if err != nil {
+		return nil, resourceError(invalidField("uri", err.Error()))
+	}
+	for name := range uri.Query() {
+		if name != outputFormatArg && name != tenantArg {
+			return nil, resourceError(invalidField(name, "unknown parameter"))
+		}
+	}
+
+	var content any
+	if uri.Host == "list" && uri.Path == "" {
+		suppliers, err := app.dbService.ListSuppliers(ctx)
+		if err != nil {
+			return nil, resourceError(err)
+		}
+		content = suppliers
+	} else {
+		id, err := strconv.ParseUint(uri.Host, 10, 0)
+		if err != nil || id == 0 || uri.Path != "" {
+			return nil, resourceError(invalidField("uri", "expected suppliers://list or suppliers://{id} with a positive supplier id"))
+		}
+		supplier, err := app.dbService.GetSupplier(ctx, uint(id))
+		if err != nil {
+			return nil, resourceError(err)
+		}
+		content = supplier
+	}
+
+	jsonData, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal suppliers to JSON: %w", err)
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
@@ -0,0 +1,245 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxTagLength bounds the length of tag names
+const maxTagLength = 50
+
+// Tag labels products; a product has any number of tags and a tag any number of products.
+// Tags are created when first applied to a product.
+type Tag struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"uniqueIndex"`
+	CreatedAt time.Time
+}
+
+// ProductTag joins a product to one of its tags
+type ProductTag struct {
+	ProductID uint `gorm:"primaryKey;autoIncrement:false"`
+	TagID     uint `gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt time.Time
+}
+
+// TagSummary is a tag with the number of products carrying it
+type TagSummary struct {
+	Tag
+	Products int64
+}
+
+// ProductTags is the result of the tag_product and untag_product tools
+type ProductTags struct {
+	ProductID uint     `json:"product_id"`
+	Tags      []string `json:"tags"`
+}
+
+// taggedProducts returns a subquery selecting the ids of the products tagged tag
+func taggedProducts(db *gorm.DB, tag string) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Table("product_tags").
+		Select("product_tags.product_id").
+		Joins("JOIN tags ON tags.id = product_tags.tag_id").
+		Where("tags.name = ?", tag)
+}
+
+// normalizeTags returns the tag names in the argument field of a tool request, trimmed,
+// lower-cased, sorted and without duplicates
+func normalizeTags(field string, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, invalidField(field, "must contain at least one tag")
+	}
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		tag := strings.ToLower(strings.TrimSpace(name))
+		if tag == "" || len(tag) > maxTagLength || strings.Contains(tag, ",") {
+			return nil, invalidField(field, fmt.Sprintf("tags must be 1 to %d characters long and must not contain commas", maxTagLength))
+		}
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags), nil
+}
+
+// productTagNames returns the tags of the product with the given id in tx, sorted by name
+func productTagNames(tx *gorm.DB, id uint) ([]string, error) {
+	tags := []string{}
+	err := tx.Table("tags").
+		Joins("JOIN product_tags ON product_tags.tag_id = tags.id").
+		Where("product_tags.product_id = ?", id).
+		Order("tags.name").
+		Pluck("tags.name", &tags).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve product tags: %w", err)
+	}
+	return tags, nil
+}
+
+// changeProductTags applies change to the tags of the product with the given id in a
+// transaction, records the change in the audit log and returns the resulting tags
+func (dbs *DBService) changeProductTags(ctx context.Context, id uint, change func(tx *gorm.DB) error) ([]string, error) {
+	var tags []string
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := findProduct(tx, id); err != nil {
+				return err
+			}
+			before, err := productTagNames(tx, id)
+			if err != nil {
+				return err
+			}
+			if err := change(tx); err != nil {
+				return err
+			}
+			if tags, err = productTagNames(tx, id); err != nil {
+				return err
+			}
+			if slices.Equal(before, tags) {
+				return nil
+			}
+			return recordAudit(tx, auditProductTags, id, auditUpdate, map[string]any{"tags": before}, map[string]any{"tags": tags})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// TagProduct adds tags to the product with the given id, creating the tags that do not exist,
+// and returns all of its tags. Tags the product already has are left as they are.
+func (dbs *DBService) TagProduct(ctx context.Context, id uint, names []string) ([]string, error) {
+	return dbs.changeProductTags(ctx, id, func(tx *gorm.DB) error {
+		tags := make([]Tag, len(names))
+		for i, name := range names {
+			tags[i] = Tag{Name: name}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+			return fmt.Errorf("failed to create tags: %w", err)
+		}
+
+		var ids []uint
+		if err := tx.Model(&Tag{}).Where("name IN ?", names).Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("failed to retrieve tags: %w", err)
+		}
+		links := make([]ProductTag, len(ids))
+		for i, tagID := range ids {
+			links[i] = ProductTag{ProductID: id, TagID: tagID}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+			return fmt.Errorf("failed to tag product: %w", err)
+		}
+		return nil
+	})
+}
+
+// UntagProduct removes tags from the product with the given id and returns its remaining
+// tags. Tags the product does not have are ignored.
+func (dbs *DBService) UntagProduct(ctx context.Context, id uint, names []string) ([]string, error) {
+	return dbs.changeProductTags(ctx, id, func(tx *gorm.DB) error {
+		err := tx.Where("product_id = ? AND tag_id IN (?)", id, tx.Model(&Tag{}).Select("id").Where("name IN ?", names)).
+			Delete(&ProductTag{}).Error
+		if err != nil {
+			return fmt.Errorf("failed to untag product: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListTags returns every tag with the number of products carrying it, ordered by name
+func (dbs *DBService) ListTags(ctx context.Context) ([]TagSummary, error) {
+	var tags []TagSummary
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.conn(ctx).Model(&Tag{}).
+			Select("tags.*, COUNT(products.id) AS products").
+			Joins("LEFT JOIN product_tags ON product_tags.tag_id = tags.id").
+			Joins("LEFT JOIN products ON products.id = product_tags.product_id AND products.deleted_at IS NULL").
+			Group("tags.id").
+			Order("tags.name").
+			Scan(&tags).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve tags: %w", err)
+	}
+	return tags, nil
+}
+
+// productTagsArgs extracts the product id and the normalized tags of a tag_product or
+// untag_product request
+func productTagsArgs(request mcp.CallToolRequest) (uint, []string, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return 0, nil, invalidField("id", err.Error())
+	}
+	if id <= 0 {
+		return 0, nil, invalidField("id", "must be a positive integer")
+	}
+
+	names, err := request.RequireStringSlice("tags")
+	if err != nil {
+		return 0, nil, invalidField("tags", err.Error())
+	}
+	if names, err = normalizeTags("tags", names); err != nil {
+		return 0, nil, err
+	}
+	return uint(id), names, nil
+}
+
+// productTagsResult renders the tags of a product as the JSON text result of a tool
+func productTagsResult(id uint, tags []string) (*mcp.CallToolResult, error) {
+	jsonData, err := json.MarshalIndent(ProductTags{ProductID: id, Tags: tags}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal product tags to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// tagProductHandler handles the tag_product tool request
+func (app *App) tagProductHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, names, err := productTagsArgs(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	tags, err := app.dbService.TagProduct(ctx, id, names)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	return productTagsResult(id, tags)
+}
+
+// untagProductHandler handles the untag_product tool request
+func (app *App) untagProductHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, names, err := productTagsArgs(request)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	tags, err := app.dbService.UntagProduct(ctx, id, names)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	return productTagsResult(id, tags)
+}
+
+// listTagsHandler handles the list_tags tool request
+func (app *App) listTagsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	tags, err := app.dbService.ListTags(ctx)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tags to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,173 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"gorm.io/gorm"
+)
+
+// tenantArg names the tool argument and resource query parameter selecting a tenant
+const tenantArg = "tenant"
+
+// tenantPreference is the session preference holding the tenant of the session
+const tenantPreference = "tenant"
+
+// defaultTenant names the configured database itself; it cannot be redefined by TENANTS
+const defaultTenant = "default"
+
+// tenantKey is the context key of the tenant selected for a request
+type tenantKey struct{}
+
+// withTenant returns a context whose database operations go to the database of tenant
+func withTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// tenantFromContext returns the tenant selected for ctx, or an empty string for the
+// default database
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// conn returns a session on the database of the tenant in ctx, falling back to the default
+// database. Tenants are validated before they are put into a context.
+func (dbs *DBService) conn(ctx context.Context) *gorm.DB {
+	if db, ok := dbs.tenants[tenantFromContext(ctx)]; ok {
+		return db.WithContext(ctx)
+	}
+	return dbs.db.WithContext(ctx)
+}
+
+// UseTenants routes the operations of requests for a tenant to its database, keyed by
+// tenant name. The databases are closed with the service.
+func (dbs *DBService) UseTenants(tenants map[string]*gorm.DB) {
+	dbs.tenants = tenants
+}
+
+// InitializeTenants opens the database of every tenant configured in cfg, migrating and
+// seeding each as InitializeDatabase and SeedDatabase would the default database
+func InitializeTenants(cfg *Config) (map[string]*gorm.DB, error) {
+	tenants := make(map[string]*gorm.DB, len(cfg.Tenants))
+	for _, name := range slices.Sorted(maps.Keys(cfg.Tenants)) {
+		tenantCfg := cfg.tenantConfig(name)
+		if tenantCfg.Ephemeral() {
+			closeTenants(tenants)
+			return nil, fmt.Errorf("tenant %s: tenant databases cannot be held in memory", name)
+		}
+		db, err := InitializeDatabase(tenantCfg)
+		if err != nil {
+			closeTenants(tenants)
+			return nil, fmt.Errorf("tenant %s: %w", name, err)
+		}
+		tenants[name] = db
+
+		if cfg.SeedDatabase {
+			if err := SeedDatabase(db); err != nil {
+				slog.Warn("Tenant database seeding failed", "tenant", name, "error", err)
+			}
+		}
+	}
+	return tenants, nil
+}
+
+// closeTenants closes the databases of tenants
+func closeTenants(tenants map[string]*gorm.DB) {
+	for name, db := range tenants {
+		if err := NewDBService(db).Close(); err != nil {
+			slog.Warn("Failed to close tenant database", "tenant", name, "error", err)
+		}
+	}
+}
+
+// tenantConfig returns the configuration of the database of tenant, which has no replicas
+func (cfg *Config) tenantConfig(tenant string) *Config {
+	tenantCfg := *cfg
+	tenantCfg.DBPath = cfg.Tenants[tenant]
+	tenantCfg.ReplicaDBPaths = nil
+	return &tenantCfg
+}
+
+// validTenant reports whether tenant names a configured tenant or the default database
+func (app *App) validTenant(tenant string) bool {
+	_, ok := app.config.Tenants[tenant]
+	return ok || tenant == defaultTenant
+}
+
+// tenantNames returns the default database followed by the configured tenants, sorted
+func (app *App) tenantNames() []string {
+	return append([]string{defaultTenant}, slices.Sorted(maps.Keys(app.config.Tenants))...)
+}
+
+// unknownTenant returns the validation error for a tenant that is not configured
+func (app *App) unknownTenant(tenant string) error {
+	return invalidField(tenantArg, fmt.Sprintf("unknown tenant %q (expected one of %s)", tenant, strings.Join(app.tenantNames(), ", ")))
+}
+
+// tenantContext selects the tenant of a request: the requested tenant if given, otherwise
+// the tenant preference of the session, otherwise the default database
+func (app *App) tenantContext(ctx context.Context, requested string) (context.Context, error) {
+	if len(app.config.Tenants) == 0 && requested == "" {
+		return ctx, nil
+	}
+
+	tenant := requested
+	if tenant == "" {
+		state, err := app.sessions.Load(ctx, sessionID(ctx))
+		if err != nil {
+			return nil, err
+		}
+		tenant = state.Preferences[tenantPreference]
+	}
+	if tenant == "" || tenant == defaultTenant {
+		return ctx, nil
+	}
+	if !app.validTenant(tenant) {
+		return nil, app.unknownTenant(tenant)
+	}
+	return withTenant(ctx, tenant), nil
+}
+
+// tenantMiddleware routes the database operations of a tool call to the tenant named by
+// the tenant argument or the session preference
+func (app *App) tenantMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx, err := app.tenantContext(ctx, request.GetString(tenantArg, ""))
+		if err != nil {
+			return toolErrorResult(err)
+		}
+		return next(ctx, request)
+	}
+}
+
+// listTenantsHandler handles the tenants resource request
+func (app *App) listTenantsHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	current := tenantFromContext(ctx)
+	if current == "" {
+		current = defaultTenant
+	}
+
+	jsonData, err := json.MarshalIndent(map[string]any{
+		"tenants": append([]string{defaultTenant}, slices.Sorted(maps.Keys(app.config.Tenants))...),
+		"current": current,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tenants to JSON: %w", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      "tenants://list",
+			MIMEType: "application/json",
+			Text:     string(jsonData),
+		},
+	}, nil
+}
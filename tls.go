@@ -0,0 +1,104 @@
+package mcpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certExpiryWarning is how long before expiry a configured certificate is reported at startup
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// loadTLSConfig returns the TLS configuration of the HTTP transports, or nil if TLS is
+// not configured. A certificate from files is loaded and checked up front so that a bad
+// certificate fails at startup rather than on the first handshake. With a client CA,
+// connections without a valid client certificate fail the handshake, before any HTTP
+// request is read.
+func loadTLSConfig(cfg *Config) (*tls.Config, error) {
+	tlsConfig, err := serverTLSConfig(cfg)
+	if err != nil || tlsConfig == nil || cfg.TLSClientCAFile == "" {
+		return tlsConfig, err
+	}
+	if err := requireClientCerts(tlsConfig, cfg.TLSClientCAFile); err != nil {
+		return nil, err
+	}
+	return tlsConfig, nil
+}
+
+// serverTLSConfig returns the TLS configuration holding the server certificate, or nil
+// if TLS is not configured
+func serverTLSConfig(cfg *Config) (*tls.Config, error) {
+	switch {
+	case cfg.TLSCertFile != "":
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate %s with key %s: %w", cfg.TLSCertFile, cfg.TLSKeyFile, err)
+		}
+		leaf := cert.Leaf
+		if leaf == nil {
+			if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return nil, fmt.Errorf("failed to parse TLS certificate %s: %w", cfg.TLSCertFile, err)
+			}
+		}
+		now := time.Now()
+		if now.Before(leaf.NotBefore) {
+			return nil, fmt.Errorf("TLS certificate %s is not valid before %s", cfg.TLSCertFile, leaf.NotBefore.Format(time.RFC3339))
+		}
+		if now.After(leaf.NotAfter) {
+			return nil, fmt.Errorf("TLS certificate %s expired on %s", cfg.TLSCertFile, leaf.NotAfter.Format(time.RFC3339))
+		}
+		if leaf.NotAfter.Sub(now) < certExpiryWarning {
+			slog.Warn("TLS certificate expires soon", "file", cfg.TLSCertFile, "not_after", leaf.NotAfter)
+		}
+		return &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		}, nil
+
+	case len(cfg.TLSAutocertDomains) > 0:
+		// Certificates are obtained with the TLS-ALPN-01 challenge, which needs HTTP_ADDR on port 443
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLSAutocertDomains...),
+			Cache:      autocert.DirCache(cfg.TLSAutocertCache),
+		}
+		tlsConfig := manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return tlsConfig, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// requireClientCerts makes tlsConfig reject clients that do not present a certificate
+// signed by one of the CAs in caFile. ACME TLS-ALPN-01 challenges are exempt, as the
+// certificate authority validating an autocert domain has no client certificate.
+func requireClientCerts(tlsConfig *tls.Config, caFile string) error {
+	pemData, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read TLS client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemData) {
+		return fmt.Errorf("TLS client CA file %s contains no PEM certificates", caFile)
+	}
+
+	challengeConfig := tlsConfig.Clone()
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if slices.Contains(hello.SupportedProtos, acme.ALPNProto) {
+			return challengeConfig, nil
+		}
+		return nil, nil
+	}
+	return nil
+}
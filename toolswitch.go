@@ -0,0 +1,301 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// toolGroupWrite names, among the tools to enable or disable, every tool not annotated
+// read-only except the tool administration tool itself
+const toolGroupWrite = "write"
+
+// toolAdminName is the name of the tool enabling and disabling tools
+const toolAdminName = "set_tools_enabled"
+
+// ToolSwitch enables and disables tools while the server runs. Disabled tools are hidden
+// from tool lists and their calls fail; clients are notified whenever the list changes.
+type ToolSwitch struct {
+	mu     sync.RWMutex
+	server *server.MCPServer
+	// tools holds the registered tools by name
+	tools    map[string]mcp.Tool
+	disabled map[string]bool
+}
+
+// ToolSwitchResult is the result of the set_tools_enabled tool
+type ToolSwitchResult struct {
+	Enabled bool `json:"enabled"`
+	// Tools are the tools enabled or disabled, with groups expanded
+	Tools []string `json:"tools"`
+	// Disabled lists every tool disabled after the change
+	Disabled []string `json:"disabled"`
+}
+
+// NewToolSwitch creates a tool switch with every tool enabled
+func NewToolSwitch() *ToolSwitch {
+	return &ToolSwitch{tools: make(map[string]mcp.Tool), disabled: make(map[string]bool)}
+}
+
+// load records the tools registered on s; it must be called before any is disabled
+func (ts *ToolSwitch) load(s *server.MCPServer) error {
+	tools, err := registeredTools(s)
+	if err != nil {
+		return err
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.server = s
+	for _, tool := range tools {
+		ts.tools[tool.Name] = tool
+	}
+	return nil
+}
+
+// registeredTools returns the tools registered on s and not disabled. The library does not
+// expose them, so they are listed as a client would, page by page.
+func registeredTools(s *server.MCPServer) ([]mcp.Tool, error) {
+	var tools []mcp.Tool
+	cursor := mcp.Cursor("")
+	for {
+		request, err := json.Marshal(map[string]any{
+			"jsonrpc": mcp.JSONRPC_VERSION,
+			"id":      0,
+			"method":  mcp.MethodToolsList,
+			"params":  map[string]any{"cursor": cursor},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the registered tools: %w", err)
+		}
+		response, ok := s.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+		if !ok {
+			return nil, fmt.Errorf("failed to list the registered tools")
+		}
+		result, ok := response.Result.(mcp.ListToolsResult)
+		if !ok {
+			return nil, fmt.Errorf("failed to list the registered tools: unexpected result %T", response.Result)
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// resolve expands the tool names and groups in names into sorted tool names; it must be
+// called with the lock held
+func (ts *ToolSwitch) resolve(names []string) ([]string, error) {
+	resolved := make(map[string]bool)
+	for _, name := range names {
+		if name == toolGroupWrite {
+			for _, tool := range ts.tools {
+				if writesData(tool) {
+					resolved[tool.Name] = true
+				}
+			}
+			continue
+		}
+		if _, ok := ts.tools[name]; !ok {
+			return nil, invalidField("tools", fmt.Sprintf("unknown tool %q (expected a tool name or %s)", name, toolGroupWrite))
+		}
+		resolved[name] = true
+	}
+	return slices.Sorted(maps.Keys(resolved)), nil
+}
+
+// SetEnabled enables or disables the named tools and groups and returns the expanded tool
+// names. The tool administration tool cannot disable itself.
+func (ts *ToolSwitch) SetEnabled(names []string, enabled bool) ([]string, error) {
+	ts.mu.Lock()
+	resolved, err := ts.resolve(names)
+	if err != nil {
+		ts.mu.Unlock()
+		return nil, err
+	}
+	if !enabled && slices.Contains(resolved, toolAdminName) {
+		ts.mu.Unlock()
+		return nil, invalidField("tools", fmt.Sprintf("%s cannot disable itself", toolAdminName))
+	}
+
+	changed := false
+	for _, name := range resolved {
+		if ts.disabled[name] != !enabled {
+			changed = true
+		}
+		if enabled {
+			delete(ts.disabled, name)
+		} else {
+			ts.disabled[name] = true
+		}
+	}
+	ts.mu.Unlock()
+
+	if changed {
+		ts.notify()
+	}
+	return resolved, nil
+}
+
+// Reset disables exactly the named tools and groups, enabling every other tool
+func (ts *ToolSwitch) Reset(names []string) error {
+	ts.mu.Lock()
+	resolved, err := ts.resolve(names)
+	if err != nil {
+		ts.mu.Unlock()
+		return err
+	}
+	disabled := make(map[string]bool, len(resolved))
+	for _, name := range resolved {
+		disabled[name] = true
+	}
+	changed := !maps.Equal(disabled, ts.disabled)
+	ts.disabled = disabled
+	ts.mu.Unlock()
+
+	if changed {
+		ts.notify()
+	}
+	return nil
+}
+
+// Disabled returns the names of the disabled tools, sorted
+func (ts *ToolSwitch) Disabled() []string {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return slices.Sorted(maps.Keys(ts.disabled))
+}
+
+// writesData reports whether tool may change data: it is not annotated read-only and is not
+// the tool administration tool, which only changes the tools offered
+func writesData(tool mcp.Tool) bool {
+	readOnly := tool.Annotations.ReadOnlyHint != nil && *tool.Annotations.ReadOnlyHint
+	return !readOnly && tool.Name != toolAdminName
+}
+
+// writeTools returns the sorted names of the registered tools that may change data
+func (ts *ToolSwitch) writeTools() []string {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	names := []string{}
+	for _, tool := range ts.tools {
+		if writesData(tool) {
+			names = append(names, tool.Name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// registered reports whether the named tool is registered
+func (ts *ToolSwitch) registered(name string) bool {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	_, ok := ts.tools[name]
+	return ok
+}
+
+// isDisabled reports whether the named tool is disabled
+func (ts *ToolSwitch) isDisabled(name string) bool {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.disabled[name]
+}
+
+// notify tells every client that the list of tools changed
+func (ts *ToolSwitch) notify() {
+	ts.mu.RLock()
+	s := ts.server
+	ts.mu.RUnlock()
+	if s != nil {
+		s.SendNotificationToAllClients(mcp.MethodNotificationToolsListChanged, nil)
+	}
+}
+
+// filter hides the disabled tools from tool lists; it is registered as a tool filter
+func (ts *ToolSwitch) filter(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	return slices.DeleteFunc(tools, func(tool mcp.Tool) bool {
+		return ts.isDisabled(tool.Name)
+	})
+}
+
+// middleware fails the calls to disabled tools, which clients may still know of
+func (ts *ToolSwitch) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if ts.isDisabled(request.Params.Name) {
+			return toolErrorResult(fmt.Errorf("tool %s is %w: it was disabled by an operator", request.Params.Name, ErrUnsupported))
+		}
+		return next(ctx, request)
+	}
+}
+
+// setToolsEnabledHandler handles the set_tools_enabled tool request
+func (app *App) setToolsEnabledHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	names, err := request.RequireStringSlice("tools")
+	if err != nil {
+		return argumentError("tools", err), nil
+	}
+	if len(names) == 0 {
+		return toolErrorResult(invalidField("tools", "must name at least one tool"))
+	}
+	enabled, err := request.RequireBool("enabled")
+	if err != nil {
+		return argumentError("enabled", err), nil
+	}
+
+	tools, err := app.toolSwitch.SetEnabled(names, enabled)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	slog.Info("Tools switched", "enabled", enabled, "tools", tools, "session", sessionID(ctx))
+
+	jsonData, err := json.MarshalIndent(ToolSwitchResult{Enabled: enabled, Tools: tools, Disabled: app.toolSwitch.Disabled()}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tool switch result to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// reloadOnHangup reloads the configuration whenever SIGHUP arrives, until ctx is done
+func (app *App) reloadOnHangup(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				app.reloadConfig()
+			}
+		}
+	}()
+}
+
+// reloadConfig loads the configuration again and applies the settings that can change while
+// the server runs: the disabled tools, replacing those switched by set_tools_enabled. Only
+// settings read from files through NAME_FILE variables can differ from startup.
+func (app *App) reloadConfig() {
+	cfg, err := LoadConfig()
+	if err != nil {
+		slog.Error("Failed to reload configuration", "error", err)
+		return
+	}
+	if err := app.toolSwitch.Reset(cfg.DisabledTools); err != nil {
+		slog.Error("Failed to apply DISABLED_TOOLS", "error", err)
+		return
+	}
+	slog.Info("Reloaded configuration", "disabled_tools", app.toolSwitch.Disabled())
+}
@@ -0,0 +1,160 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Transports accepted by ParseTransports and Run
+const (
+	TransportStdio      = "stdio"
+	TransportHTTP       = "http"
+	TransportStreamable = "streamable"
+	TransportWebSocket  = "websocket"
+)
+
+// transports lists the supported transports; all but stdio are served on HTTP_ADDR
+var transports = []string{TransportStdio, TransportHTTP, TransportStreamable, TransportWebSocket}
+
+// ParseTransports parses the comma-separated list of transports given to -transport
+func ParseTransports(list string) ([]string, error) {
+	var selected []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(transports, name) {
+			return nil, fmt.Errorf("unknown transport %q (expected %s)", name, strings.Join(transports, ", "))
+		}
+		if !slices.Contains(selected, name) {
+			selected = append(selected, name)
+		}
+	}
+	return selected, nil
+}
+
+// serve runs the MCP server over the selected transports until ctx is done or one of
+// them stops; the others are then shut down as well. The network transports share
+// one HTTP server on HTTP_ADDR.
+func (app *App) serve(ctx context.Context, s *server.MCPServer, selected []string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	mux := http.NewServeMux()
+	network := false
+	for _, transport := range selected {
+		switch transport {
+		case TransportHTTP:
+			sse := app.sseHandler(s)
+			mux.Handle(sseEndpoint, sse)
+			mux.Handle(sseMessageEndpoint, sse)
+		case TransportStreamable:
+			mux.Handle(streamablePath, app.streamableHandler(s))
+			app.streamSessions.Start(ctx)
+		case TransportWebSocket:
+			mux.Handle(websocketPath, app.websocketHandler(ctx, s))
+		default:
+			continue
+		}
+		network = true
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	run := func(name string, fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The first transport to stop takes the others down with it
+			defer cancel()
+			if err := fn(); err != nil {
+				errOnce.Do(func() { firstErr = fmt.Errorf("%s transport: %w", name, err) })
+			}
+		}()
+	}
+	if slices.Contains(selected, TransportStdio) {
+		run(TransportStdio, func() error { return app.serveStdio(ctx, s) })
+	}
+	if network {
+		run("HTTP", func() error { return app.serveHTTP(ctx, mux) })
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// serveStdio serves a single client over standard input and output
+func (app *App) serveStdio(ctx context.Context, s *server.MCPServer) error {
+	stdio := server.NewStdioServer(s)
+	if err := stdio.Listen(ctx, app.clientRequests.Reader(os.Stdin), app.rpcErrors.Writer(os.Stdout)); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// serveHTTP serves the network transports until ctx is done. Requests inherit ctx so
+// that long-lived streams end on shutdown.
+func (app *App) serveHTTP(ctx context.Context, handler http.Handler) error {
+	httpServer := &http.Server{
+		Addr:              app.config.HTTPAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+		TLSConfig:         app.tlsConfig,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("Failed to shut down HTTP server", "error", err)
+		}
+	}()
+
+	listen := httpServer.ListenAndServe
+	scheme := "http"
+	if app.tlsConfig != nil {
+		// The certificates are in TLSConfig
+		listen = func() error { return httpServer.ListenAndServeTLS("", "") }
+		scheme = "https"
+	}
+	slog.Info("Serving MCP over HTTP", "addr", app.config.HTTPAddr, "scheme", scheme, "client_certs", app.config.TLSClientCAFile != "")
+	if err := listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve HTTP: %w", err)
+	}
+	return nil
+}
+
+// Endpoints of the SSE transport
+const (
+	sseEndpoint        = "/sse"
+	sseMessageEndpoint = "/message"
+)
+
+// sseHandler serves remote clients over HTTP with Server-Sent Events: each client opens
+// GET /sse to receive messages and posts its requests to the endpoint announced there
+func (app *App) sseHandler(s *server.MCPServer) http.Handler {
+	sse := server.NewSSEServer(s,
+		server.WithSSEEndpoint(sseEndpoint),
+		server.WithMessageEndpoint(sseMessageEndpoint),
+		server.WithKeepAlive(true),
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.clientRequests.deliverHTTP(w, r) {
+			return
+		}
+		sse.ServeHTTP(app.rpcErrors.EventWriter(w), r)
+	})
+}
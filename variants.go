@@ -0,0 +1,238 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// ErrVariantNotFound is returned when a product has no variant with the requested id
+var ErrVariantNotFound = fmt.Errorf("product variant %w", ErrNotFound)
+
+// ProductVariant is a variant of a product, such as a size or a color, with its own SKU and
+// stock. A product with variants holds its stock in them; the price of a variant defaults to
+// that of its product.
+type ProductVariant struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	ProductID uint   `gorm:"index;uniqueIndex:idx_product_variants_options" json:"product_id"`
+	SKU       string `gorm:"uniqueIndex" json:"sku"`
+	Size      string `gorm:"uniqueIndex:idx_product_variants_options" json:"size,omitempty"`
+	Color     string `gorm:"uniqueIndex:idx_product_variants_options" json:"color,omitempty"`
+	// Price, if set, overrides the price of the product
+	Price     *float64  `json:"price,omitempty"`
+	Stock     int       `json:"stock"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// VariantStock is the stock of a variant in a stock level
+type VariantStock struct {
+	VariantID uint   `json:"variant_id"`
+	SKU       string `json:"sku"`
+	Size      string `json:"size,omitempty"`
+	Color     string `json:"color,omitempty"`
+	Stock     int    `json:"stock"`
+}
+
+// ProductVariants is the result of the list_variants tool
+type ProductVariants struct {
+	ProductID uint             `json:"product_id"`
+	Code      string           `json:"code"`
+	Variants  []ProductVariant `json:"variants"`
+	// Stock is the total stock of the variants
+	Stock int `json:"stock"`
+}
+
+// validateVariant checks the fields of a variant to be created
+func validateVariant(v *ProductVariant) error {
+	var fields []FieldError
+	if v.SKU == "" {
+		fields = append(fields, FieldError{Field: "sku", Message: "must not be empty"})
+	}
+	if v.Size == "" && v.Color == "" {
+		fields = append(fields, FieldError{Field: "size", Message: "size or color must be given"})
+	}
+	if v.Price != nil && *v.Price < 0 {
+		fields = append(fields, FieldError{Field: "price", Message: "must not be negative"})
+	}
+	if v.Stock < 0 {
+		fields = append(fields, FieldError{Field: "stock", Message: "must not be negative"})
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// CreateVariant adds a variant to its product. SKUs are unique across variants, and a product
+// has at most one variant of each size and color.
+func (dbs *DBService) CreateVariant(ctx context.Context, variant *ProductVariant) error {
+	if err := validateVariant(variant); err != nil {
+		return err
+	}
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := findProduct(tx, variant.ProductID); err != nil {
+				return err
+			}
+
+			var existing []ProductVariant
+			err := tx.Where("sku = ? OR (product_id = ? AND size = ? AND color = ?)", variant.SKU, variant.ProductID, variant.Size, variant.Color).
+				Limit(1).Find(&existing).Error
+			if err != nil {
+				return err
+			}
+			if len(existing) > 0 {
+				if existing[0].SKU == variant.SKU {
+					return fmt.Errorf("%w: SKU %s already exists on variant %d of product %d", ErrConflict, variant.SKU, existing[0].ID, existing[0].ProductID)
+				}
+				return fmt.Errorf("%w: product %d already has a variant of size %q and color %q, with SKU %s", ErrConflict, variant.ProductID, variant.Size, variant.Color, existing[0].SKU)
+			}
+
+			if err := tx.Create(variant).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, auditProductVariant, variant.ID, auditCreate, nil, variant)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create variant: %w", err)
+	}
+	return nil
+}
+
+// ListVariants returns the variants of the product with the given id ordered by id
+func (dbs *DBService) ListVariants(ctx context.Context, id uint) ([]ProductVariant, error) {
+	variants := []ProductVariant{}
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.conn(ctx).Where("product_id = ?", id).Order("id").Find(&variants).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve variants: %w", err)
+	}
+	return variants, nil
+}
+
+// AdjustVariantStock adds delta, which may be negative, to the stock of a variant of the
+// product with the given id and returns the variant with the stock it had before. A delta
+// that would take the stock below zero fails without changing it; concurrent
+// adjustments are applied one after the other.
+func (dbs *DBService) AdjustVariantStock(ctx context.Context, id, variantID uint, delta int) (*ProductVariant, int, error) {
+	var variant ProductVariant
+	var previous int
+	err := dbs.withRetry(ctx, func(ctx context.Context) error {
+		return dbs.primary(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := findProduct(tx, id); err != nil {
+				return err
+			}
+			// The variant stays locked until its stock is saved
+			var variants []ProductVariant
+			if err := forUpdate(tx).Where("id = ? AND product_id = ?", variantID, id).Limit(1).Find(&variants).Error; err != nil {
+				return fmt.Errorf("failed to retrieve variant: %w", err)
+			}
+			if len(variants) == 0 {
+				return fmt.Errorf("%w: product %d has no variant %d", ErrVariantNotFound, id, variantID)
+			}
+			variant = variants[0]
+			before := variant
+
+			previous = variant.Stock
+			if variant.Stock+delta < 0 {
+				return fmt.Errorf("%w: variant %d has %d in stock, cannot remove %d", ErrFailedPrecondition, variantID, variant.Stock, -delta)
+			}
+			variant.Stock += delta
+			if err := tx.Save(&variant).Error; err != nil {
+				return fmt.Errorf("failed to update variant: %w", err)
+			}
+			return recordAudit(tx, auditProductVariant, variant.ID, auditUpdate, &before, &variant)
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &variant, previous, nil
+}
+
+// variantStocks returns the stock of each variant
+func variantStocks(variants []ProductVariant) []VariantStock {
+	stocks := make([]VariantStock, len(variants))
+	for i, v := range variants {
+		stocks[i] = VariantStock{VariantID: v.ID, SKU: v.SKU, Size: v.Size, Color: v.Color, Stock: v.Stock}
+	}
+	return stocks
+}
+
+// createVariantHandler handles the create_variant tool request
+func (app *App) createVariantHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("product_id")
+	if err != nil {
+		return argumentError("product_id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("product_id", "must be a positive integer"))
+	}
+	sku, err := request.RequireString("sku")
+	if err != nil {
+		return argumentError("sku", err), nil
+	}
+
+	variant := &ProductVariant{
+		ProductID: uint(id),
+		SKU:       strings.TrimSpace(sku),
+		Size:      strings.TrimSpace(request.GetString("size", "")),
+		Color:     strings.TrimSpace(request.GetString("color", "")),
+		Stock:     request.GetInt("stock", 0),
+	}
+	if _, ok := request.GetArguments()["price"]; ok {
+		price, err := request.RequireFloat("price")
+		if err != nil {
+			return argumentError("price", err), nil
+		}
+		variant.Price = &price
+	}
+
+	if err := app.dbService.CreateVariant(ctx, variant); err != nil {
+		return toolErrorResult(err)
+	}
+
+	jsonData, err := json.MarshalIndent(variant, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal variant to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// listVariantsHandler handles the list_variants tool request
+func (app *App) listVariantsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("product_id")
+	if err != nil {
+		return argumentError("product_id", err), nil
+	}
+	if id <= 0 {
+		return toolErrorResult(invalidField("product_id", "must be a positive integer"))
+	}
+
+	product, err := app.dbService.GetProduct(ctx, uint(id), "")
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	variants, err := app.dbService.ListVariants(ctx, product.ID)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+
+	result := ProductVariants{ProductID: product.ID, Code: product.Code, Variants: variants}
+	for _, v := range variants {
+		result.Stock += v.Stock
+	}
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal variants to JSON: %w", err)
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,65 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// toolVersionArg is the argument selecting the version of a versioned tool
+const toolVersionArg = "version"
+
+// toolVersion is one schema version of a logical tool
+type toolVersion struct {
+	Version     string
+	Description string
+	Options     []mcp.ToolOption
+	Handler     server.ToolHandlerFunc
+}
+
+// addVersionedTool registers the advertised versions of a logical tool side by side.
+// Each version is available as name_<version>; name itself takes an optional version
+// argument and otherwise routes to the default version. Versions missing from
+// Config.ToolVersions are not registered at all.
+func (app *App) addVersionedTool(s *server.MCPServer, name string, versions []toolVersion) {
+	advertised := make(map[string]toolVersion)
+	var names []string
+	for _, v := range versions {
+		if !slices.Contains(app.config.ToolVersions, v.Version) {
+			continue
+		}
+		advertised[v.Version] = v
+		names = append(names, v.Version)
+
+		options := append([]mcp.ToolOption{mcp.WithDescription(v.Description)}, v.Options...)
+		s.AddTool(mcp.NewTool(name+"_"+v.Version, options...), v.Handler)
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	// Fall back to the newest advertised version if the tool lacks the configured default
+	defaultVersion, ok := advertised[app.config.DefaultToolVersion]
+	if !ok {
+		defaultVersion = advertised[names[len(names)-1]]
+	}
+
+	options := append([]mcp.ToolOption{
+		mcp.WithDescription(fmt.Sprintf("%s (version %s unless %s is given)", defaultVersion.Description, defaultVersion.Version, toolVersionArg)),
+		mcp.WithString(toolVersionArg,
+			mcp.Description("Tool version to use"),
+			mcp.Enum(names...),
+		),
+	}, defaultVersion.Options...)
+	s.AddTool(mcp.NewTool(name, options...), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		version := request.GetString(toolVersionArg, defaultVersion.Version)
+		v, ok := advertised[version]
+		if !ok {
+			return toolErrorResult(invalidField(toolVersionArg, fmt.Sprintf("unknown version %q of %s", version, name)))
+		}
+		return v.Handler(ctx, request)
+	})
+}
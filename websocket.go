@@ -0,0 +1,208 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// websocketPath is the endpoint of the WebSocket transport
+const websocketPath = "/ws"
+
+// Keepalive and size limits of WebSocket connections
+const (
+	wsPingInterval    = 30 * time.Second
+	wsPongWait        = 2 * wsPingInterval
+	wsWriteWait       = 10 * time.Second
+	wsMaxMessageBytes = 4 << 20
+)
+
+// wsSession is the client session of a WebSocket connection
+type wsSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+	loggingLevel  atomic.Value
+}
+
+func (s *wsSession) SessionID() string { return s.id }
+
+func (s *wsSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+
+func (s *wsSession) Initialize() { s.initialized.Store(true) }
+
+func (s *wsSession) Initialized() bool { return s.initialized.Load() }
+
+func (s *wsSession) SetLogLevel(level mcp.LoggingLevel) { s.loggingLevel.Store(level) }
+
+func (s *wsSession) GetLogLevel() mcp.LoggingLevel {
+	if level, ok := s.loggingLevel.Load().(mcp.LoggingLevel); ok {
+		return level
+	}
+	return mcp.LoggingLevelError
+}
+
+// newWSSession creates the session of a new WebSocket connection
+func newWSSession() (*wsSession, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %w", err)
+	}
+	return &wsSession{id: "ws-" + hex.EncodeToString(buf), notifications: make(chan mcp.JSONRPCNotification, 64)}, nil
+}
+
+// wsUpgrader returns the upgrader accepting connections from the configured origins;
+// without any, only same-origin browser connections and non-browser clients are accepted
+func (app *App) wsUpgrader() *websocket.Upgrader {
+	upgrader := &websocket.Upgrader{}
+	origins := app.config.WSAllowedOrigins
+	if len(origins) > 0 {
+		upgrader.CheckOrigin = func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || slices.Contains(origins, "*") || slices.Contains(origins, origin)
+		}
+	}
+	return upgrader
+}
+
+// websocketHandler serves remote clients exchanging MCP JSON-RPC messages as WebSocket
+// text messages on /ws until ctx is done; connections beyond WS_MAX_CONNECTIONS are refused
+func (app *App) websocketHandler(ctx context.Context, s *server.MCPServer) http.Handler {
+	slots := make(chan struct{}, app.config.WSMaxConnections)
+	upgrader := app.wsUpgrader()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		default:
+			http.Error(w, "Too many connections", http.StatusServiceUnavailable)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already responded to the client
+			slog.Debug("WebSocket upgrade failed", "error", err)
+			return
+		}
+		// Hijacked connections are not tracked by the HTTP server; they close on ctx themselves
+		if err := app.serveWSConn(ctx, s, conn); err != nil {
+			slog.Warn("WebSocket connection failed", "remote", r.RemoteAddr, "error", err)
+		}
+	})
+}
+
+// serveWSConn runs the MCP session of one WebSocket connection until the client goes
+// away, stops answering pings or ctx is done. Requests are handled concurrently; a single
+// writer sends their responses, the session's notifications and the keepalive pings.
+func (app *App) serveWSConn(ctx context.Context, s *server.MCPServer, conn *websocket.Conn) error {
+	defer conn.Close()
+
+	session, err := newWSSession()
+	if err != nil {
+		return err
+	}
+	if err := s.RegisterSession(ctx, session); err != nil {
+		return fmt.Errorf("failed to register session: %w", err)
+	}
+	defer s.UnregisterSession(ctx, session.id)
+
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sessionCtx := s.WithContext(connCtx, session)
+	out := make(chan []byte, 64)
+
+	go func() {
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+		// Unblock the reader whenever the writer stops
+		defer conn.Close()
+
+		write := func(message []byte) error {
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			return conn.WriteMessage(websocket.TextMessage, message)
+		}
+		for {
+			var err error
+			select {
+			case message := <-out:
+				err = write(message)
+			case notification := <-session.notifications:
+				var message []byte
+				if message, err = json.Marshal(notification); err == nil {
+					if rewritten, ok := app.rpcErrors.rewrite(session.id, message); ok {
+						message = rewritten
+					}
+					err = write(message)
+				}
+			case <-ticker.C:
+				err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			case <-connCtx.Done():
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+					time.Now().Add(wsWriteWait))
+				return
+			}
+			if err != nil {
+				slog.Debug("WebSocket write failed", "session", session.id, "error", err)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	conn.SetReadLimit(wsMaxMessageBytes)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			// Clients commonly go away without a close handshake (abnormal closure)
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) && connCtx.Err() == nil {
+				return err
+			}
+			return nil
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		// Any message shows the client is alive
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		if app.clientRequests.deliver(session.id, data) {
+			continue
+		}
+
+		go func() {
+			response := s.HandleMessage(sessionCtx, data)
+			if response == nil {
+				return
+			}
+			message, err := json.Marshal(response)
+			if err != nil {
+				slog.Warn("Failed to encode WebSocket response", "session", session.id, "error", err)
+				return
+			}
+			if rewritten, ok := app.rpcErrors.rewrite(session.id, message); ok {
+				message = rewritten
+			}
+			select {
+			case out <- message:
+			case <-connCtx.Done():
+			}
+		}()
+	}
+}